@@ -0,0 +1,224 @@
+package gemu
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrBadCondition is returned by SetAchievements for an achievement whose
+// conditions don't parse.
+var ErrBadCondition = errors.New("invalid achievement condition")
+
+// Achievement is a goal met when every condition in MemAddr holds on the
+// same frame. MemAddr uses the core of the rcheevos syntax: conditions
+// joined by "_", each comparing two operands with =, !=, <, <=, > or >=.
+// An operand is a number, decimal or with an "h" prefix for hex, or a
+// memory read such as 0xH00A2: 0xH reads a byte, 0x or "0x " 16 bits
+// and 0xX 32 bits, little endian; 0xL and 0xU the low and high nibble and
+// 0xM to 0xT a single bit. A "d" in front of a read gives the value it had
+// the frame before. Flags, hit counts and alternate groups aren't
+// supported.
+type Achievement struct {
+	ID      uint32
+	Title   string
+	MemAddr string
+}
+
+// achievement is an Achievement being checked.
+type achievement struct {
+	Achievement
+	conds []condition
+	// its conditions have been false once, so it can unlock; like
+	// rcheevos, one already met when it's loaded waits for the next time
+	armed    bool
+	unlocked bool
+}
+
+type condition struct {
+	left, right operand
+	cmp         string
+}
+
+// operand is a constant or a memory read; a read keeps the value it had
+// last frame for delta.
+type operand struct {
+	mem   bool
+	delta bool
+	size  byte
+	addr  uint32
+	value uint32
+	prev  uint32
+}
+
+// SetAchievements replaces the achievements checked for the loaded game
+// at the end of each frame. An unlocked achievement is announced on screen
+// and listed by Unlocked. Loading another ROM drops them.
+func (e *Emulator) SetAchievements(list []Achievement) error {
+	var parsed []*achievement
+	for _, a := range list {
+		conds, err := parseConditions(a.MemAddr)
+		if err != nil {
+			return fmt.Errorf("achievement %d: %w", a.ID, err)
+		}
+		parsed = append(parsed, &achievement{Achievement: a, conds: conds})
+	}
+	e.achievements = parsed
+	return nil
+}
+
+// Unlocked returns the achievements unlocked since they were set, in the
+// order they were given.
+func (e *Emulator) Unlocked() []Achievement {
+	var list []Achievement
+	for _, a := range e.achievements {
+		if a.unlocked {
+			list = append(list, a.Achievement)
+		}
+	}
+	return list
+}
+
+// checkAchievements evaluates every achievement still locked against
+// memory as the frame left it.
+func (e *Emulator) checkAchievements() {
+	for _, a := range e.achievements {
+		if a.unlocked {
+			continue
+		}
+		met := true
+		// every operand is read, so deltas stay a frame behind
+		for i := range a.conds {
+			if !a.conds[i].eval(e) {
+				met = false
+			}
+		}
+		switch {
+		case !met:
+			a.armed = true
+		case a.armed:
+			a.unlocked = true
+			e.logger.Info("achievement unlocked", "id", a.ID, "title", a.Title)
+			e.ShowMessage("Unlocked: %s", a.Title)
+		}
+	}
+}
+
+func (c *condition) eval(e *Emulator) bool {
+	l, r := c.left.eval(e), c.right.eval(e)
+	switch c.cmp {
+	case "=":
+		return l == r
+	case "!=":
+		return l != r
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	}
+	return l >= r
+}
+
+func (o *operand) eval(e *Emulator) uint32 {
+	if !o.mem {
+		return o.value
+	}
+	var v uint32
+	switch o.size {
+	case 'H':
+		v = e.Peek(o.addr, 1)
+	case ' ':
+		v = e.Peek(o.addr, 2)
+	case 'X':
+		v = e.Peek(o.addr, 4)
+	case 'L':
+		v = e.Peek(o.addr, 1) & 0x0F
+	case 'U':
+		v = e.Peek(o.addr, 1) >> 4
+	default:
+		v = e.Peek(o.addr, 1) >> (o.size - 'M') & 1
+	}
+	prev := o.prev
+	o.prev = v
+	if o.delta {
+		return prev
+	}
+	return v
+}
+
+func parseConditions(s string) ([]condition, error) {
+	var conds []condition
+	for _, c := range strings.Split(s, "_") {
+		cond, err := parseCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	return conds, nil
+}
+
+func parseCondition(s string) (condition, error) {
+	// two-character comparisons first, so "<=" isn't taken for "<"
+	for _, cmp := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		i := strings.Index(s, cmp)
+		if i < 0 {
+			continue
+		}
+		left, err := parseOperand(s[:i])
+		if err != nil {
+			return condition{}, err
+		}
+		right, err := parseOperand(s[i+len(cmp):])
+		if err != nil {
+			return condition{}, err
+		}
+		return condition{left: left, right: right, cmp: cmp}, nil
+	}
+	return condition{}, fmt.Errorf("%w %q: no comparison", ErrBadCondition, s)
+}
+
+func parseOperand(s string) (operand, error) {
+	bad := fmt.Errorf("%w %q", ErrBadCondition, s)
+	t := strings.ToLower(s)
+	var o operand
+	if strings.HasPrefix(t, "d0x") {
+		o.delta = true
+		t = t[1:]
+		s = s[1:]
+	}
+	if !strings.HasPrefix(t, "0x") {
+		base := 10
+		if strings.HasPrefix(t, "h") {
+			base, t = 16, t[1:]
+		}
+		v, err := strconv.ParseUint(t, base, 32)
+		if err != nil {
+			return o, bad
+		}
+		o.value = uint32(v)
+		return o, nil
+	}
+
+	o.mem = true
+	t, s = t[2:], s[2:]
+	o.size = ' '
+	if len(s) > 0 {
+		switch c := strings.ToUpper(s[:1])[0]; {
+		case c == ' ':
+			t = t[1:]
+		case c == 'H' || c == 'X' || c == 'L' || c == 'U' || c >= 'M' && c <= 'T':
+			o.size = c
+			t = t[1:]
+		}
+	}
+	addr, err := strconv.ParseUint(t, 16, 32)
+	if err != nil || addr > 0xFFFF {
+		return o, bad
+	}
+	o.addr = uint32(addr)
+	return o, nil
+}
@@ -0,0 +1,99 @@
+package gemu
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAchievementUnlocksOnce(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	ram := e.cpu.GetMemory()
+	err := e.SetAchievements([]Achievement{
+		{ID: 1, Title: "Level 3", MemAddr: "0xH0010=3_d0xH0010=2"},
+		{ID: 2, Title: "Rich", MemAddr: "0x 0020>=h1000_0xM0022=1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := func(level, lo, hi, flags uint8) []Achievement {
+		ram[0x10], ram[0x20], ram[0x21], ram[0x22] = level, lo, hi, flags
+		e.checkAchievements()
+		return e.Unlocked()
+	}
+	if got := frame(2, 0, 0, 0); len(got) != 0 {
+		t.Fatalf("unlocked %v before any condition held", got)
+	}
+	if got := frame(3, 0, 0x10, 0); len(got) != 1 || got[0].ID != 1 {
+		t.Fatalf("after level 2 -> 3: unlocked %v, want achievement 1", got)
+	}
+	// a level 3 held for a second frame is no longer a change from 2
+	if got := frame(3, 0, 0x10, 1); len(got) != 2 || got[1].ID != 2 {
+		t.Fatalf("unlocked %v, want both", got)
+	}
+	if m := e.Messages(); len(m) != 2 || m[0] != "Unlocked: Level 3" {
+		t.Errorf("messages %q", m)
+	}
+}
+
+func TestAchievementMetWhenLoadedWaits(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	ram := e.cpu.GetMemory()
+	ram[0x30] = 1
+	if err := e.SetAchievements([]Achievement{{ID: 7, MemAddr: "0xH0030=1"}}); err != nil {
+		t.Fatal(err)
+	}
+	e.checkAchievements()
+	if len(e.Unlocked()) != 0 {
+		t.Fatal("unlocked on the frame it was loaded")
+	}
+	ram[0x30] = 0
+	e.checkAchievements()
+	ram[0x30] = 1
+	e.checkAchievements()
+	if len(e.Unlocked()) != 1 {
+		t.Fatal("not unlocked once its condition held again")
+	}
+}
+
+func TestAchievementPeekHasNoSideEffects(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetAchievements([]Achievement{{ID: 1, MemAddr: "0xH2002=0_0xH2007=0_0xH4016=0"}}); err != nil {
+		t.Fatal(err)
+	}
+	p := e.ppu
+	setAddr := func(a uint16) {
+		p.WriteRegister(0x2006, uint8(a>>8))
+		p.WriteRegister(0x2006, uint8(a))
+	}
+	setAddr(0x2100)
+	p.WriteRegister(0x2007, 0x55)
+	p.WriteRegister(0x2007, 0x66)
+	setAddr(0x2100)
+	for i := 0; i < 3; i++ {
+		e.checkAchievements()
+	}
+	p.ReadRegister(0x2007)
+	if got := p.ReadRegister(0x2007); got != 0x55 {
+		t.Errorf("PPUDATA read $%02X after checking, want $55: the peeks moved the VRAM address", got)
+	}
+}
+
+func TestBadAchievementConditions(t *testing.T) {
+	e := newTestEmulator()
+	for _, memaddr := range []string{"", "0xH0010", "0xHZZ=1", "0xH0010=x", "0xH10000=1", "0xH0010=1_"} {
+		err := e.SetAchievements([]Achievement{{ID: 9, MemAddr: memaddr}})
+		if !errors.Is(err, ErrBadCondition) {
+			t.Errorf("%q: got %v, want ErrBadCondition", memaddr, err)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Package asm is a small 6502 assembler for patching memory and writing
+// snippets, the reverse of package disasm.
+//
+// A source is a list of statements separated by newlines or "/":
+//
+//	$C123: LDA #$01 / loop: DEX / BNE loop / RTS
+//
+// A $hex address followed by a colon at the very start, or an .org
+// statement before any code, sets the origin. Other "name:" prefixes
+// define labels. Numbers are $hex, %binary or decimal, and ";" starts a
+// comment.
+package asm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+var (
+	ErrUnknownMnemonic = errors.New("unknown mnemonic")
+	ErrBadOperand      = errors.New("invalid operand")
+	ErrBranchRange     = errors.New("branch target out of range")
+	ErrUndefinedLabel  = errors.New("undefined label")
+	ErrMisplacedOrg    = errors.New(".org after code")
+)
+
+// Error is an assembly error at a statement.
+type Error struct {
+	Statement int // 1-based
+	Text      string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("statement %d %q: %v", e.Statement, e.Text, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// opcodes maps a mnemonic and address mode back to its opcode.
+var opcodes = map[string]map[uint8]uint8{}
+
+func init() {
+	for op := 0; op < 256; op++ {
+		ins, ok := cpu.Lookup(uint8(op))
+		if !ok {
+			continue
+		}
+		modes := opcodes[ins.Label]
+		if modes == nil {
+			modes = map[uint8]uint8{}
+			opcodes[ins.Label] = modes
+		}
+		if _, dup := modes[ins.AddressMode]; !dup {
+			modes[ins.AddressMode] = uint8(op)
+		}
+	}
+}
+
+type statement struct {
+	n       int
+	text    string
+	label   string
+	mnem    string
+	operand string
+
+	addr uint16
+	mode uint8
+	size int
+}
+
+// Assemble assembles src at origin, unless src starts with its own origin,
+// and returns the address of the first byte along with the code.
+func Assemble(src string, origin uint16) (uint16, []byte, error) {
+	src = strings.TrimSpace(src)
+	if rest, ok := strings.CutPrefix(src, "$"); ok {
+		if i := strings.IndexByte(rest, ':'); i > 0 {
+			if v, err := strconv.ParseUint(rest[:i], 16, 16); err == nil {
+				origin = uint16(v)
+				src = rest[i+1:]
+			}
+		}
+	}
+
+	stmts := split(src)
+	if len(stmts) > 0 && stmts[0].mnem == ".ORG" && stmts[0].label == "" {
+		v, err := value(stmts[0].operand, nil)
+		if err != nil {
+			return origin, nil, &Error{Statement: 1, Text: stmts[0].text, Err: err}
+		}
+		origin = v
+		stmts = stmts[1:]
+	}
+	labels := map[string]uint16{}
+
+	// first pass: pick each statement's mode and size and place the labels.
+	// Forward references are assumed to need two bytes.
+	pc := origin
+	for _, s := range stmts {
+		s.addr = pc
+		if s.label != "" {
+			labels[s.label] = pc
+		}
+		if s.mnem == "" {
+			continue
+		}
+		if err := s.size1(labels); err != nil {
+			return origin, nil, &Error{Statement: s.n, Text: s.text, Err: err}
+		}
+		pc += uint16(s.size)
+	}
+
+	// second pass: encode with every label known
+	code := make([]byte, 0, int(pc-origin))
+	for _, s := range stmts {
+		if s.mnem == "" {
+			continue
+		}
+		b, err := s.encode(labels)
+		if err != nil {
+			return origin, nil, &Error{Statement: s.n, Text: s.text, Err: err}
+		}
+		code = append(code, b...)
+	}
+	return origin, code, nil
+}
+
+func split(src string) []*statement {
+	var stmts []*statement
+	for _, line := range strings.Split(src, "\n") {
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		for _, text := range strings.Split(line, "/") {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				continue
+			}
+			s := &statement{n: len(stmts) + 1, text: text}
+			if i := strings.IndexByte(text, ':'); i > 0 && isIdent(text[:i]) {
+				s.label = text[:i]
+				text = strings.TrimSpace(text[i+1:])
+			}
+			mnem, operand, _ := strings.Cut(text, " ")
+			s.mnem = strings.ToUpper(mnem)
+			s.operand = strings.ReplaceAll(operand, " ", "")
+			stmts = append(stmts, s)
+		}
+	}
+	return stmts
+}
+
+func isIdent(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// size1 picks the address mode and size in the first pass.
+func (s *statement) size1(labels map[string]uint16) error {
+	if s.mnem == ".ORG" {
+		return ErrMisplacedOrg
+	}
+	if s.mnem == ".BYTE" {
+		s.size = len(strings.Split(s.operand, ","))
+		return nil
+	}
+	modes, ok := opcodes[s.mnem]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownMnemonic, s.mnem)
+	}
+
+	op := strings.ToUpper(s.operand)
+	var expr string
+	switch {
+	case op == "":
+		s.mode = cpu.Implicit
+		if _, ok := modes[cpu.Implicit]; !ok {
+			s.mode = cpu.Accumulator
+		}
+	case op == "A":
+		s.mode = cpu.Accumulator
+	case op[0] == '#':
+		s.mode = cpu.Immediate
+	case strings.HasPrefix(op, "(") && strings.HasSuffix(op, ",X)"):
+		s.mode = cpu.IndirectX
+	case strings.HasPrefix(op, "(") && strings.HasSuffix(op, "),Y"):
+		s.mode = cpu.IndirectY
+	case strings.HasPrefix(op, "(") && strings.HasSuffix(op, ")"):
+		s.mode = cpu.Indirect
+	case strings.HasSuffix(op, ",X"):
+		expr = s.operand[:len(op)-2]
+		s.mode = pickWidth(modes, expr, labels, cpu.ZeroPageX, cpu.AbsoluteX)
+	case strings.HasSuffix(op, ",Y"):
+		expr = s.operand[:len(op)-2]
+		s.mode = pickWidth(modes, expr, labels, cpu.ZeroPageY, cpu.AbsoluteY)
+	default:
+		if _, ok := modes[cpu.Relative]; ok {
+			s.mode = cpu.Relative
+		} else {
+			s.mode = pickWidth(modes, s.operand, labels, cpu.ZeroPage, cpu.Absolute)
+		}
+	}
+
+	if _, ok := modes[s.mode]; !ok {
+		return fmt.Errorf("%w: %s has no such addressing mode", ErrBadOperand, s.mnem)
+	}
+	s.size = modeSize(s.mode)
+	return nil
+}
+
+// pickWidth uses the zero page form when the value is known and fits.
+func pickWidth(modes map[uint8]uint8, expr string, labels map[string]uint16, zp, abs uint8) uint8 {
+	if _, ok := modes[zp]; !ok {
+		return abs
+	}
+	if v, err := value(expr, labels); err == nil && v < 0x100 {
+		return zp
+	}
+	return abs
+}
+
+func modeSize(mode uint8) int {
+	switch mode {
+	case cpu.Implicit, cpu.Accumulator:
+		return 1
+	case cpu.Absolute, cpu.AbsoluteX, cpu.AbsoluteY, cpu.Indirect:
+		return 3
+	}
+	return 2
+}
+
+func (s *statement) encode(labels map[string]uint16) ([]byte, error) {
+	if s.mnem == ".BYTE" {
+		b := make([]byte, 0, s.size)
+		for _, e := range strings.Split(s.operand, ",") {
+			v, err := value(e, labels)
+			if err != nil {
+				return nil, err
+			}
+			if v > 0xFF {
+				return nil, fmt.Errorf("%w: %s does not fit in a byte", ErrBadOperand, e)
+			}
+			b = append(b, uint8(v))
+		}
+		return b, nil
+	}
+
+	b := []byte{opcodes[s.mnem][s.mode]}
+	if s.size == 1 {
+		return b, nil
+	}
+
+	expr := s.operand
+	switch s.mode {
+	case cpu.Immediate:
+		expr = expr[1:]
+	case cpu.IndirectX, cpu.IndirectY:
+		expr = expr[1 : len(expr)-3]
+	case cpu.Indirect:
+		expr = expr[1 : len(expr)-1]
+	case cpu.ZeroPageX, cpu.ZeroPageY, cpu.AbsoluteX, cpu.AbsoluteY:
+		expr = expr[:len(expr)-2]
+	}
+	v, err := value(expr, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.mode == cpu.Relative {
+		off := int(v) - int(s.addr+2)
+		if off < -128 || off > 127 {
+			return nil, fmt.Errorf("%w: $%04X", ErrBranchRange, v)
+		}
+		return append(b, uint8(int8(off))), nil
+	}
+	if s.size == 2 {
+		if v > 0xFF {
+			return nil, fmt.Errorf("%w: $%X does not fit in a byte", ErrBadOperand, v)
+		}
+		return append(b, uint8(v)), nil
+	}
+	return append(b, cpu.LowByte(v), cpu.HighByte(v)), nil
+}
+
+// value evaluates a number or label.
+func value(expr string, labels map[string]uint16) (uint16, error) {
+	var v uint64
+	var err error
+	switch {
+	case expr == "":
+		return 0, ErrBadOperand
+	case expr[0] == '$':
+		v, err = strconv.ParseUint(expr[1:], 16, 16)
+	case expr[0] == '%':
+		v, err = strconv.ParseUint(expr[1:], 2, 16)
+	case expr[0] >= '0' && expr[0] <= '9':
+		v, err = strconv.ParseUint(expr, 10, 16)
+	default:
+		addr, ok := labels[expr]
+		if !ok {
+			return 0, fmt.Errorf("%w %s", ErrUndefinedLabel, expr)
+		}
+		return addr, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrBadOperand, expr)
+	}
+	return uint16(v), nil
+}
@@ -0,0 +1,130 @@
+package asm
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		src    string
+		origin uint16
+		want   []byte
+	}{
+		{"implied", "RTS", 0x8000, []byte{0x60}},
+		{"accumulator", "ASL A", 0x8000, []byte{0x0A}},
+		{"accumulator implied", "LSR", 0x8000, []byte{0x4A}},
+		{"immediate", "LDA #$01", 0x8000, []byte{0xA9, 0x01}},
+		{"immediate binary", "AND #%1010", 0x8000, []byte{0x29, 0x0A}},
+		{"immediate decimal", "LDX #200", 0x8000, []byte{0xA2, 0xC8}},
+		{"zero page", "STA $10", 0x8000, []byte{0x85, 0x10}},
+		{"zero page,X", "LDY $10,X", 0x8000, []byte{0xB4, 0x10}},
+		{"zero page,Y", "LDX $10,Y", 0x8000, []byte{0xB6, 0x10}},
+		{"absolute", "JSR $C123", 0x8000, []byte{0x20, 0x23, 0xC1}},
+		{"absolute,X", "LDA $0200,X", 0x8000, []byte{0xBD, 0x00, 0x02}},
+		{"absolute,Y", "STA $0200, Y", 0x8000, []byte{0x99, 0x00, 0x02}},
+		// STA has no zero page,Y form
+		{"absolute,Y for zero page", "STA $10,Y", 0x8000, []byte{0x99, 0x10, 0x00}},
+		{"(zero page,X)", "LDA ($20,X)", 0x8000, []byte{0xA1, 0x20}},
+		{"(zero page),Y", "STA ($20),Y", 0x8000, []byte{0x91, 0x20}},
+		{"indirect", "JMP ($FFFC)", 0x8000, []byte{0x6C, 0xFC, 0xFF}},
+		{"lower case", "lda #$01 / rts", 0x8000, []byte{0xA9, 0x01, 0x60}},
+		{"bytes", ".byte $01, 2, %11", 0x8000, []byte{1, 2, 3}},
+		{"comment", "NOP ; does nothing\nNOP", 0x8000, []byte{0xEA, 0xEA}},
+
+		{"backward label", "loop: DEX / BNE loop", 0x8000, []byte{0xCA, 0xD0, 0xFD}},
+		{"forward label", "BEQ done / NOP / done: RTS", 0x8000, []byte{0xF0, 0x01, 0xEA, 0x60}},
+		// a forward reference takes the absolute form
+		{"forward absolute", "LDA data / RTS / data: .byte 0", 0x8000, []byte{0xAD, 0x04, 0x80, 0x60, 0x00}},
+		{"label address", "JMP start / start: RTS", 0xC000, []byte{0x4C, 0x03, 0xC0, 0x60}},
+		// labels that are also hex numbers are labels
+		{"hex-like label", "add: LDA #$01\nJMP add", 0x8000, []byte{0xA9, 0x01, 0x4C, 0x00, 0x80}},
+		{"hex-like label cafe", "BNE cafe / cafe: RTS", 0x8000, []byte{0xD0, 0x00, 0x60}},
+	} {
+		org, code, err := Assemble(tc.src, tc.origin)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if org != tc.origin || !bytes.Equal(code, tc.want) {
+			t.Errorf("%s: assembled % X at $%04X, want % X at $%04X", tc.name, code, org, tc.want, tc.origin)
+		}
+	}
+}
+
+func TestAssembleOrigin(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		want uint16
+		code []byte
+	}{
+		{"default", "NOP", 0x8000, []byte{0xEA}},
+		{"$ prefix", "$C123: LDA #$01 / RTS", 0xC123, []byte{0xA9, 0x01, 0x60}},
+		{".org", ".org $0300\nloop: JMP loop", 0x0300, []byte{0x4C, 0x00, 0x03}},
+		// without the $, C123 is a label
+		{"label", "C123: JMP C123", 0x8000, []byte{0x4C, 0x00, 0x80}},
+	} {
+		org, code, err := Assemble(tc.src, 0x8000)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if org != tc.want || !bytes.Equal(code, tc.code) {
+			t.Errorf("%s: assembled % X at $%04X, want % X at $%04X", tc.name, code, org, tc.code, tc.want)
+		}
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	far := "BNE far / .byte " + strings.Repeat("0,", 127) + "0 / far: RTS"
+	for _, tc := range []struct {
+		name string
+		src  string
+		want error
+		// the statement the error is reported at
+		stmt int
+	}{
+		{"unknown mnemonic", "NOP / FOO $10", ErrUnknownMnemonic, 2},
+		{"no such mode", "JMP #$01", ErrBadOperand, 1},
+		{"bad number", "LDA #$1G", ErrBadOperand, 1},
+		{"immediate too wide", "LDA #$100", ErrBadOperand, 1},
+		{"byte too wide", ".byte $100", ErrBadOperand, 1},
+		{"undefined label", "JMP nowhere", ErrUndefinedLabel, 1},
+		{"branch forward out of range", far, ErrBranchRange, 1},
+		{"branch back out of range", "back: .byte " + strings.Repeat("0,", 126) + "0 / BNE back", ErrBranchRange, 2},
+		{"org after code", "NOP / .org $8000", ErrMisplacedOrg, 2},
+	} {
+		_, _, err := Assemble(tc.src, 0x8000)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.want)
+			continue
+		}
+		var e *Error
+		if errors.As(err, &e) && e.Statement != tc.stmt {
+			t.Errorf("%s: error at statement %d, want %d", tc.name, e.Statement, tc.stmt)
+		}
+	}
+}
+
+func TestBranchAtRangeLimits(t *testing.T) {
+	// 127 bytes forward and 128 back are the furthest a branch reaches
+	fwd := "BNE far / .byte " + strings.Repeat("0,", 126) + "0 / far: RTS"
+	_, code, err := Assemble(fwd, 0x8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code[1] != 0x7F {
+		t.Errorf("branch 127 forward assembled as % X", code[:2])
+	}
+	back := "back: .byte " + strings.Repeat("0,", 125) + "0 / BNE back"
+	if _, code, err = Assemble(back, 0x8000); err != nil {
+		t.Fatal(err)
+	}
+	if code[len(code)-1] != 0x80 {
+		t.Errorf("branch 128 back assembled as % X", code[len(code)-2:])
+	}
+}
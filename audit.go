@@ -0,0 +1,103 @@
+package gemu
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/goldmane/gemu/cpu"
+	"github.com/goldmane/gemu/refcpu"
+)
+
+// CycleAudit checks the cycles each executed instruction returns against
+// the official timing, with the page-cross and branch rules, from package
+// refcpu. Unofficial opcodes aren't checked.
+type CycleAudit struct {
+	mismatches []*CycleMismatch
+	byKey      map[cycleKey]*CycleMismatch
+	checked    uint64
+}
+
+// CycleMismatch is an opcode returning the wrong cycle count. Only the
+// first instruction to do so is kept for each opcode and pair of counts.
+type CycleMismatch struct {
+	PC        uint16
+	Bytes     []byte
+	Mnemonic  string
+	Got, Want int
+	// Count is how many times it happened
+	Count uint64
+}
+
+func (m *CycleMismatch) String() string {
+	return fmt.Sprintf("%04X  %-8s  %s  got %d cycles, want %d (%d times)",
+		m.PC, fmt.Sprintf("% X", m.Bytes), m.Mnemonic, m.Got, m.Want, m.Count)
+}
+
+type cycleKey struct {
+	opcode    uint8
+	got, want int
+}
+
+func NewCycleAudit() *CycleAudit {
+	return &CycleAudit{byKey: map[cycleKey]*CycleMismatch{}}
+}
+
+// expect returns the cycles the instruction at the cpu's pc should take.
+// It must be called before the instruction executes.
+func (a *CycleAudit) expect(c *cpu.CPU) (int, bool) {
+	s := refcpu.State{
+		PC: c.GetPC(),
+		A:  c.A.GetValue(), X: c.X.GetValue(), Y: c.Y.GetValue(),
+		P: c.Flags.Value(), SP: c.SP,
+	}
+	return refcpu.Cycles(s, c.Pages().Read)
+}
+
+// check records the instruction at pc if it took got cycles instead of want.
+func (a *CycleAudit) check(pc uint16, bytes []byte, got, want int) {
+	a.checked++
+	if got == want {
+		return
+	}
+	k := cycleKey{bytes[0], got, want}
+	if m, ok := a.byKey[k]; ok {
+		m.Count++
+		return
+	}
+	m := &CycleMismatch{
+		PC:       pc,
+		Bytes:    slices.Clone(bytes),
+		Mnemonic: refcpu.Ops[bytes[0]].Mnemonic,
+		Got:      got,
+		Want:     want,
+		Count:    1,
+	}
+	a.byKey[k] = m
+	a.mismatches = append(a.mismatches, m)
+}
+
+// Checked returns how many instructions have been checked.
+func (a *CycleAudit) Checked() uint64 {
+	return a.checked
+}
+
+// Mismatches returns the mismatches found, in the order they first
+// happened.
+func (a *CycleAudit) Mismatches() []*CycleMismatch {
+	return a.mismatches
+}
+
+// WriteReport writes a summary line and then a line per mismatch.
+func (a *CycleAudit) WriteReport(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "cycle audit: %d instructions checked, %d mismatching opcodes\n",
+		a.checked, len(a.mismatches)); err != nil {
+		return err
+	}
+	for _, m := range a.mismatches {
+		if _, err := fmt.Fprintf(w, "  %s\n", m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
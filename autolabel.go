@@ -0,0 +1,110 @@
+package gemu
+
+import (
+	"fmt"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+// handlerVectors are the interrupt vectors AutoLabels names the targets of.
+var handlerVectors = []struct {
+	addr uint16
+	name string
+}{
+	{0xFFFA, "nmi_handler"},
+	{0xFFFC, "reset"},
+	{0xFFFE, "irq_handler"},
+}
+
+// AutoLabels names code as a run reaches it, for reading traces of ROMs
+// without a symbol file: the targets of the JSRs executed become sub_XXXX,
+// and the handlers the interrupt vectors point at nmi_handler, reset and
+// irq_handler.
+type AutoLabels struct {
+	labels map[uint16]string
+}
+
+func NewAutoLabels() *AutoLabels {
+	return &AutoLabels{labels: map[uint16]string{}}
+}
+
+// Lookup returns the label found for addr.
+func (l *AutoLabels) Lookup(addr uint16) (string, bool) {
+	name, ok := l.labels[addr]
+	return name, ok
+}
+
+// Labels returns the labels found so far. The map is the labeler's own and
+// must not be modified.
+func (l *AutoLabels) Labels() map[uint16]string {
+	return l.labels
+}
+
+// observe labels what the instruction at pc, about to execute, tells. The
+// vectors are read each time since a mapper may switch them.
+func (l *AutoLabels) observe(c *cpu.CPU, pc uint16) {
+	pages := c.Pages()
+	for _, v := range handlerVectors {
+		if pc == uint16(pages.Read(v.addr))|uint16(pages.Read(v.addr+1))<<8 {
+			l.labels[pc] = v.name
+		}
+	}
+	if pages.Read(pc) == 0x20 {
+		// JSR
+		target := uint16(pages.Read(pc+1)) | uint16(pages.Read(pc+2))<<8
+		if _, ok := l.labels[target]; !ok {
+			l.labels[target] = fmt.Sprintf("sub_%04X", target)
+		}
+	}
+}
+
+// Trace line columns: the disassembly, and the registers after it.
+const (
+	traceTextColumn  = 16
+	traceStateColumn = 48
+)
+
+// appendLabeled appends a trace line to b with the labels substituted for
+// the addresses in its disassembly, keeping the register columns aligned
+// where the labels fit.
+func (l *AutoLabels) appendLabeled(b, line []byte) []byte {
+	if len(line) < traceStateColumn {
+		return append(b, line...)
+	}
+	b = append(b, line[:traceTextColumn]...)
+	start := len(b)
+	text := line[traceTextColumn : traceStateColumn-1]
+	for i := 0; i < len(text); i++ {
+		if text[i] == '$' && i+5 <= len(text) {
+			if addr, ok := parseHex4(text[i+1 : i+5]); ok {
+				if name, ok := l.labels[addr]; ok {
+					b = append(b, name...)
+					i += 4
+					continue
+				}
+			}
+		}
+		b = append(b, text[i])
+	}
+	// trim the padding the labels took up, keeping a space
+	for len(b)-start > traceStateColumn-1-traceTextColumn && b[len(b)-1] == ' ' && b[len(b)-2] == ' ' {
+		b = b[:len(b)-1]
+	}
+	b = append(b, ' ')
+	return append(b, line[traceStateColumn:]...)
+}
+
+func parseHex4(s []byte) (uint16, bool) {
+	var v uint16
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			v = v<<4 | uint16(c-'0')
+		case c >= 'A' && c <= 'F':
+			v = v<<4 | uint16(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,122 @@
+"""Thin ctypes wrapper around libgemu, the emulator built as a C shared
+library:
+
+    go build -buildmode=c-shared -o libgemu.so ./cmd/libgemu
+
+    from gemu import Emulator
+    emu = Emulator("./libgemu.so")
+    emu.load_rom("nestest.nes")
+    emu.step()
+    print(hex(emu.pc), emu.read(0x0000, 16))
+"""
+
+import ctypes
+
+
+class GemuError(Exception):
+    pass
+
+
+class Emulator:
+    SCREEN_WIDTH = 256
+    SCREEN_HEIGHT = 240
+
+    def __init__(self, library="./libgemu.so"):
+        lib = ctypes.CDLL(library)
+        h = ctypes.c_size_t
+        u8p = ctypes.POINTER(ctypes.c_uint8)
+        for name, args, res in [
+            ("gemu_new", [], h),
+            ("gemu_free", [h], None),
+            ("gemu_error", [h], ctypes.c_char_p),
+            ("gemu_load_rom", [h, ctypes.c_char_p], ctypes.c_int),
+            ("gemu_soft_reset", [h], None),
+            ("gemu_power_cycle", [h], ctypes.c_int),
+            ("gemu_step", [h], ctypes.c_int),
+            ("gemu_run_frame", [h], ctypes.c_int),
+            ("gemu_read", [h, ctypes.c_uint16, u8p, ctypes.c_int], None),
+            ("gemu_write", [h, ctypes.c_uint16, u8p, ctypes.c_int], None),
+            ("gemu_pc", [h], ctypes.c_uint16),
+            ("gemu_instructions", [h], ctypes.c_uint64),
+            ("gemu_frame", [h], ctypes.c_uint64),
+            ("gemu_set_input", [h, ctypes.c_int, ctypes.c_uint32], None),
+            ("gemu_set_position", [h, ctypes.c_int, ctypes.c_double], None),
+            ("gemu_framebuffer", [h, u8p], None),
+            ("gemu_framebuffer_size", [], ctypes.c_int),
+        ]:
+            fn = getattr(lib, name)
+            fn.argtypes = args
+            fn.restype = res
+        self._lib = lib
+        self._h = lib.gemu_new()
+
+    def close(self):
+        if self._h:
+            self._lib.gemu_free(self._h)
+            self._h = None
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.close()
+
+    def __del__(self):
+        self.close()
+
+    def _check(self, rc):
+        if rc != 0:
+            raise GemuError(self._lib.gemu_error(self._h).decode())
+
+    def load_rom(self, path):
+        self._check(self._lib.gemu_load_rom(self._h, path.encode()))
+
+    def soft_reset(self):
+        """Press reset: jump through the reset vector, keeping memory."""
+        self._lib.gemu_soft_reset(self._h)
+
+    def power_cycle(self):
+        """Switch off and on, reloading the ROM and clearing RAM."""
+        self._check(self._lib.gemu_power_cycle(self._h))
+
+    def step(self):
+        """Run one instruction."""
+        self._check(self._lib.gemu_step(self._h))
+
+    def run_frame(self):
+        self._check(self._lib.gemu_run_frame(self._h))
+
+    def read(self, addr, n):
+        buf = (ctypes.c_uint8 * n)()
+        self._lib.gemu_read(self._h, addr, buf, n)
+        return bytes(buf)
+
+    def write(self, addr, data):
+        buf = (ctypes.c_uint8 * len(data)).from_buffer_copy(data)
+        self._lib.gemu_write(self._h, addr, buf, len(data))
+
+    def set_input(self, port, buttons):
+        self._lib.gemu_set_input(self._h, port, buttons)
+
+    def set_position(self, port, x):
+        """Set a paddle's knob, from 0 (left) to 1 (right)."""
+        self._lib.gemu_set_position(self._h, port, x)
+
+    @property
+    def pc(self):
+        return self._lib.gemu_pc(self._h)
+
+    @property
+    def instructions(self):
+        return self._lib.gemu_instructions(self._h)
+
+    @property
+    def frame(self):
+        return self._lib.gemu_frame(self._h)
+
+    def framebuffer(self):
+        """The current frame as 256x240 RGBA bytes."""
+        size = self._lib.gemu_framebuffer_size()
+        buf = (ctypes.c_uint8 * size)()
+        self._lib.gemu_framebuffer(self._h, buf)
+        return bytes(buf)
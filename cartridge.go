@@ -0,0 +1,91 @@
+package gemu
+
+import (
+	"bytes"
+
+	"github.com/goldmane/gemu/gemu"
+)
+
+// prgWindow is the size of the windows PRG is switched in.
+const prgWindow = 0x2000
+
+// mapperPage passes the cpu's accesses to the cartridge on to its mapper.
+// Reads of $6000-$FFFF don't get here: they come straight from the work
+// RAM and the PRG the mapper presents, which is mapped again after each
+// write. The expansion area below is all the mapper's to answer.
+type mapperPage struct {
+	e *Emulator
+}
+
+func (p *mapperPage) Read(addr uint16) uint8 {
+	return p.e.cart.Mapper.CPURead(addr)
+}
+
+func (p *mapperPage) Write(addr uint16, v uint8) {
+	p.e.cart.Mapper.CPUWrite(addr, v)
+	p.e.mapPRG()
+}
+
+// mapCartridge puts the inserted cartridge into the cpu's address space:
+// its mapper at $4020-$5FFF, behind the PRG ROM at $8000-$FFFF, which
+// becomes read-only, and its work RAM at $6000-$7FFF. The part of the
+// expansion area in page $40 is passed on by the I/O page.
+func (e *Emulator) mapCartridge() {
+	e.cartPage = &mapperPage{e: e}
+	e.prg = [4][]byte{}
+	e.cpu.Pages().MapHandler(0x41, 0x1F, e.cartPage)
+	e.mapPRG()
+	if m, ok := e.cart.Mapper.(gemu.RAMMapper); ok {
+		if ram := m.WorkRAM(); len(ram) > 0 {
+			e.cpu.Pages().MapMemory(0x60, 0x20, ram, true)
+		} else {
+			e.cpu.Pages().MapHandler(0x60, 0x20, nil)
+		}
+	}
+}
+
+// mapPRG maps each 8kb window of $8000-$FFFF onto the PRG the mapper
+// presents there, after a write that may have switched banks. Only the
+// windows that changed are mapped again, and only their decoded
+// instructions dropped. A mapper that can't say where its windows are has
+// them copied into memory instead.
+func (e *Emulator) mapPRG() {
+	m, ok := e.cart.Mapper.(gemu.BankMapper)
+	for w := range e.prg {
+		start := 0x8000 + w*prgWindow
+		if !ok {
+			e.copyPRG(start)
+			continue
+		}
+		win := m.PRGWindow(uint16(start))
+		if len(win) == 0 || len(win)%0x100 != 0 {
+			// pages can't mirror it, so it goes in memory
+			e.copyPRG(start)
+			continue
+		}
+		if old := e.prg[w]; len(old) == len(win) && &old[0] == &win[0] {
+			continue
+		}
+		e.prg[w] = win
+		e.cpu.Pages().MapROM(uint8(start>>8), prgWindow/0x100, win, e.cartPage)
+		e.cpu.InvalidateDecodeCache(uint16(start), uint16(start+prgWindow-1))
+	}
+}
+
+// copyPRG copies the window at start as the mapper now presents it into
+// memory, and maps that.
+func (e *Emulator) copyPRG(start int) {
+	mem := e.cpu.GetMemory()[start : start+prgWindow]
+	var win [prgWindow]byte
+	for i := range win {
+		win[i] = e.cart.Mapper.CPURead(uint16(start + i))
+	}
+	w := (start - 0x8000) / prgWindow
+	if e.prg[w] != nil && &e.prg[w][0] == &mem[0] && bytes.Equal(mem, win[:]) {
+		return
+	}
+	copy(mem, win[:])
+	e.prg[w] = mem
+	e.cpu.Pages().MapROM(uint8(start>>8), prgWindow/0x100, mem, e.cartPage)
+	e.cpu.InvalidateDecodeCache(uint16(start), uint16(start+prgWindow-1))
+}
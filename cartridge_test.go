@@ -0,0 +1,54 @@
+package gemu
+
+import (
+	"testing"
+
+	"github.com/goldmane/gemu/gemu"
+)
+
+// action53 returns an Action 53 cartridge with four 16kb PRG banks, each
+// filled with its own number.
+func action53(t *testing.T) gemu.Cartridge {
+	t.Helper()
+	img := append([]byte{'N', 'E', 'S', 0x1A, 4, 0, 0xC0, 0x10}, make([]byte, 8)...)
+	for bank := 0; bank < 4; bank++ {
+		for i := 0; i < 0x4000; i++ {
+			img = append(img, byte(bank))
+		}
+	}
+	var c gemu.Cartridge
+	if err := c.InsertBytes(img); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestBankSwitchMapsPRG(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		wrap func(gemu.Mapper) gemu.Mapper
+	}{
+		{"windows", func(m gemu.Mapper) gemu.Mapper { return m }},
+		// without PRGWindow the banks are copied into memory
+		{"copied", func(m gemu.Mapper) gemu.Mapper { return struct{ gemu.Mapper }{m} }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTestEmulator()
+			rom := action53(t)
+			rom.Mapper = tc.wrap(rom.Mapper)
+			e.insert(rom, "")
+
+			pages := e.cpu.Pages()
+			// powers on with the last 32kb
+			if got := [2]uint8{pages.Read(0x8000), pages.Read(0xC000)}; got != [2]uint8{2, 3} {
+				t.Fatalf("banks at $8000, $C000 = %v, want [2 3]", got)
+			}
+			// select the outer bank register and switch to the first 32kb
+			e.cpu.Store(0x5000, 0x81)
+			e.cpu.Store(0x8000, 0x00)
+			if got := [2]uint8{pages.Read(0x9FFF), pages.Read(0xFFFF)}; got != [2]uint8{0, 1} {
+				t.Errorf("banks at $9FFF, $FFFF = %v, want [0 1]", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Package cc65 reads the debug info files written by the cc65 toolchain
+// (ld65 --dbgfile), to map cpu addresses back to assembly source lines.
+package cc65
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrNotDebugInfo = errors.New("not a cc65 debug info file")
+
+// DebugInfo maps cpu addresses to the source lines that produced them.
+type DebugInfo struct {
+	Files []string
+	// ranges sorted by start address
+	ranges []sourceRange
+}
+
+type sourceRange struct {
+	start, end uint16 // [start, end)
+	file       int
+	line       int
+	kind       int // line type: 0 source, 1 C, 2 macro expansion
+}
+
+// Load reads the debug info file at path.
+func Load(path string) (*DebugInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+type segment struct {
+	start uint16
+}
+
+type span struct {
+	seg, start, size int
+}
+
+type line struct {
+	file, line, kind int
+	spans            []int
+}
+
+// Parse reads debug info from r. Only the records needed to map addresses
+// to lines are kept: file, seg, span and line.
+func Parse(r io.Reader) (*DebugInfo, error) {
+	files := map[int]string{}
+	segs := map[int]segment{}
+	spans := map[int]span{}
+	var lines []line
+	sawVersion := false
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		kind, rest, _ := strings.Cut(sc.Text(), "\t")
+		if kind == "" {
+			continue
+		}
+		attrs, err := parseAttrs(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		switch kind {
+		case "version":
+			sawVersion = true
+		case "file":
+			files[attrs.int("id")] = attrs.str("name")
+		case "seg":
+			segs[attrs.int("id")] = segment{start: uint16(attrs.int("start"))}
+		case "span":
+			spans[attrs.int("id")] = span{seg: attrs.int("seg"), start: attrs.int("start"), size: attrs.int("size")}
+		case "line":
+			l := line{file: attrs.int("file"), line: attrs.int("line"), kind: attrs.int("type")}
+			if s, ok := attrs["span"]; ok {
+				for _, id := range strings.Split(s, "+") {
+					v, err := strconv.Atoi(id)
+					if err != nil {
+						return nil, fmt.Errorf("line %d: bad span list %q", n, s)
+					}
+					l.spans = append(l.spans, v)
+				}
+			}
+			lines = append(lines, l)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if !sawVersion {
+		return nil, ErrNotDebugInfo
+	}
+
+	d := &DebugInfo{}
+	ids := make(map[int]int, len(files))
+	for _, id := range slices.Sorted(maps.Keys(files)) {
+		ids[id] = len(d.Files)
+		d.Files = append(d.Files, files[id])
+	}
+	for _, l := range lines {
+		// a line in a file that wasn't listed can't be shown
+		file, ok := ids[l.file]
+		if !ok {
+			continue
+		}
+		for _, id := range l.spans {
+			sp, ok := spans[id]
+			seg, ok2 := segs[sp.seg]
+			if !ok || !ok2 || sp.size == 0 {
+				continue
+			}
+			start := int(seg.start) + sp.start
+			d.ranges = append(d.ranges, sourceRange{
+				start: uint16(start),
+				end:   uint16(start + sp.size),
+				file:  file,
+				line:  l.line,
+				kind:  l.kind,
+			})
+		}
+	}
+	// Lookup searches backwards, so among ranges starting together put the
+	// narrowest, plain source line last: an instruction's own line wins over
+	// a macro invocation around it
+	sort.SliceStable(d.ranges, func(i, j int) bool {
+		a, b := d.ranges[i], d.ranges[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		if a.end-a.start != b.end-b.start {
+			return a.end-a.start > b.end-b.start
+		}
+		return a.kind > b.kind
+	})
+	return d, nil
+}
+
+// Lookup returns the source file and line that produced the byte at addr,
+// or false if no line in a known file covers it. Banked code that shares
+// an address with another bank resolves to whichever range was found
+// first.
+func (d *DebugInfo) Lookup(addr uint16) (string, int, bool) {
+	// ranges starting at or before addr; take the closest one covering it
+	i := sort.Search(len(d.ranges), func(i int) bool { return d.ranges[i].start > addr })
+	for j := i - 1; j >= 0; j-- {
+		r := d.ranges[j]
+		if addr < r.end || r.end < r.start {
+			return d.Files[r.file], r.line, true
+		}
+		// spans are short, so give up once well past any that could cover
+		if addr-r.start > 256 {
+			break
+		}
+	}
+	return "", 0, false
+}
+
+// attrs are the key=value pairs of a record.
+type attrs map[string]string
+
+func parseAttrs(s string) (attrs, error) {
+	a := attrs{}
+	for s != "" {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, fmt.Errorf("missing = in %q", s)
+		}
+		var val string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in %q", s)
+			}
+			val, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			val, rest, _ = strings.Cut(rest, ",")
+			rest = "," + rest
+		}
+		a[key] = val
+		s = strings.TrimPrefix(rest, ",")
+	}
+	return a, nil
+}
+
+func (a attrs) str(key string) string {
+	return a[key]
+}
+
+// int parses a decimal or 0x hex attribute, or 0 if it is missing.
+func (a attrs) int(key string) int {
+	s, ok := a[key]
+	if !ok {
+		return 0
+	}
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return 0
+	}
+	return int(v)
+}
@@ -0,0 +1,71 @@
+package cc65
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	d, err := Load("testdata/hello.dbg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"main.s", "macros.inc"}; strings.Join(d.Files, ",") != strings.Join(want, ",") {
+		t.Errorf("files %q, want %q", d.Files, want)
+	}
+	for _, tc := range []struct {
+		addr uint16
+		file string
+		line int
+		ok   bool
+	}{
+		{0xC000, "main.s", 10, true},
+		{0xC001, "main.s", 10, true},
+		{0xC002, "main.s", 11, true},
+		{0xC004, "main.s", 11, true},
+		// the macro's own line wins over the line invoking it
+		{0xC005, "macros.inc", 4, true},
+		{0xC006, "macros.inc", 4, true},
+		{0xC007, "main.s", 12, true},
+		// a line with two spans covers both
+		{0xC00B, "main.s", 12, true},
+		{0xC00C, "main.s", 12, true},
+		// in a file the debug info doesn't list
+		{0xC00D, "", 0, false},
+		{0xC00E, "", 0, false},
+		{0xBFFF, "", 0, false},
+		{0x0000, "", 0, false},
+	} {
+		file, line, ok := d.Lookup(tc.addr)
+		if file != tc.file || line != tc.line || ok != tc.ok {
+			t.Errorf("$%04X: %s:%d %v, want %s:%d %v", tc.addr, file, line, ok, tc.file, tc.line, tc.ok)
+		}
+	}
+}
+
+func TestParseWithoutFiles(t *testing.T) {
+	src := "version\tmajor=2,minor=0\n" +
+		"line\tid=0,file=0,line=1,span=0\n" +
+		"seg\tid=0,name=\"CODE\",start=0x8000,size=1\n" +
+		"span\tid=0,seg=0,start=0,size=1\n"
+	d, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file, line, ok := d.Lookup(0x8000); ok {
+		t.Errorf("$8000 resolved to %s:%d with no files", file, line)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse(strings.NewReader("file\tid=0,name=\"a.s\"\n")); !errors.Is(err, ErrNotDebugInfo) {
+		t.Errorf("no version record: %v, want %v", err, ErrNotDebugInfo)
+	}
+	if _, err := Parse(strings.NewReader("version\tmajor=2\nfile\tid=0,name=\"a.s\n")); err == nil {
+		t.Error("unterminated string parsed")
+	}
+	if _, err := Parse(strings.NewReader("version\tmajor=2\nline\tid=0,file=0,span=1+x\n")); err == nil {
+		t.Error("bad span list parsed")
+	}
+}
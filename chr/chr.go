@@ -0,0 +1,105 @@
+// Package chr converts 2bpp NES pattern table data to and from images laid
+// out as tile sheets, 16 tiles to a row as in YY-CHR and most other editors.
+package chr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TileSize is the bytes per 8x8 tile: two 8 byte bit planes.
+	TileSize = 16
+	// SheetColumns is the number of tiles in a sheet row.
+	SheetColumns = 16
+)
+
+var ErrSheetSize = errors.New("sheet is not a whole number of 8x8 tile rows 128 pixels wide")
+
+// Gray is the default palette, from black for colour 0 up to white.
+var Gray = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xFF},
+	color.RGBA{0x55, 0x55, 0x55, 0xFF},
+	color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+	color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+}
+
+// ParsePalette parses "gray" or four comma separated RRGGBB colours.
+func ParsePalette(s string) (color.Palette, error) {
+	if s == "" || strings.EqualFold(s, "gray") || strings.EqualFold(s, "grey") {
+		return Gray, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("palette %q needs four colours", s)
+	}
+	p := make(color.Palette, 4)
+	for i, part := range parts {
+		v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(part), "#"), 16, 24)
+		if err != nil {
+			return nil, fmt.Errorf("invalid colour %q", part)
+		}
+		p[i] = color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xFF}
+	}
+	return p, nil
+}
+
+// Sheet renders data as a tile sheet coloured with palette. A partial
+// trailing tile is ignored.
+func Sheet(data []byte, palette color.Palette) *image.Paletted {
+	tiles := len(data) / TileSize
+	rows := (tiles + SheetColumns - 1) / SheetColumns
+	img := image.NewPaletted(image.Rect(0, 0, SheetColumns*8, rows*8), palette)
+
+	for t := 0; t < tiles; t++ {
+		tile := data[t*TileSize : (t+1)*TileSize]
+		x0, y0 := t%SheetColumns*8, t/SheetColumns*8
+		for y := 0; y < 8; y++ {
+			lo, hi := tile[y], tile[y+8]
+			for x := 0; x < 8; x++ {
+				bit := 7 - x
+				c := (lo>>bit)&1 | (hi>>bit&1)<<1
+				img.SetColorIndex(x0+x, y0+y, c)
+			}
+		}
+	}
+	return img
+}
+
+// Tiles converts a tile sheet back to 2bpp data. Each pixel becomes the
+// index of the closest colour in palette, so sheets re-saved as true colour
+// by an editor still import. A paletted image with at most four colours is
+// taken by index as it is.
+func Tiles(img image.Image, palette color.Palette) ([]byte, error) {
+	b := img.Bounds()
+	if b.Dx() != SheetColumns*8 || b.Dy()%8 != 0 {
+		return nil, fmt.Errorf("%w: got %dx%d", ErrSheetSize, b.Dx(), b.Dy())
+	}
+
+	index := func(x, y int) uint8 {
+		return uint8(palette.Index(img.At(x, y)))
+	}
+	if p, ok := img.(*image.Paletted); ok && len(p.Palette) <= 4 {
+		index = p.ColorIndexAt
+	}
+
+	tiles := b.Dy() / 8 * SheetColumns
+	data := make([]byte, tiles*TileSize)
+	for t := 0; t < tiles; t++ {
+		tile := data[t*TileSize : (t+1)*TileSize]
+		x0, y0 := b.Min.X+t%SheetColumns*8, b.Min.Y+t/SheetColumns*8
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				c := index(x0+x, y0+y)
+				bit := uint8(7 - x)
+				tile[y] |= (c & 1) << bit
+				tile[y+8] |= (c >> 1 & 1) << bit
+			}
+		}
+	}
+	return data, nil
+}
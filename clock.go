@@ -0,0 +1,186 @@
+package gemu
+
+import "container/heap"
+
+// Clock is the master clock. Devices attached to it are ticked at a fixed
+// divider of the master rate, and callbacks can be scheduled to fire at a
+// given master cycle (an NMI at a PPU dot, a mapper IRQ at a scanline, the
+// end of a DMA stall).
+//
+// Lazy devices are not ticked as the clock advances. The eager devices (the
+// cpu) run in a batch up to the next event, and the lazy ones catch up to
+// the current cycle only when something needs their state: before an event
+// fires, or when Sync is called on a register access.
+type Clock struct {
+	cycle   uint64
+	devices []*clockDevice
+	events  eventQueue
+	seq     uint64
+}
+
+// Device is a handle to a device attached to the clock.
+type Device = clockDevice
+
+type clockDevice struct {
+	divider uint64
+	next    uint64
+	// tick returns how many of its own cycles the device consumed, so a cpu
+	// can run a whole instruction in one call
+	tick func() uint64
+	lazy bool
+}
+
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Attach adds a device ticked every divider master cycles. Devices due on
+// the same master cycle are ticked in the order they were attached.
+func (c *Clock) Attach(divider uint64, tick func() uint64) *Device {
+	d := &clockDevice{divider: divider, next: c.cycle, tick: tick}
+	c.devices = append(c.devices, d)
+	return d
+}
+
+// AttachLazy adds a device ticked every divider master cycles, but only
+// when it is caught up by Sync or a pending event.
+func (c *Clock) AttachLazy(divider uint64, tick func() uint64) *Device {
+	d := &clockDevice{divider: divider, next: c.cycle, tick: tick, lazy: true}
+	c.devices = append(c.devices, d)
+	return d
+}
+
+// Hold stops every device except keep for n master cycles from now, giving
+// keep that much extra time, e.g. extra cpu-only scanlines to overclock.
+func (c *Clock) Hold(n uint64, keep *Device) {
+	c.Sync()
+	for _, d := range c.devices {
+		if d != keep {
+			d.next += n
+		}
+	}
+}
+
+// Sync catches every lazy device up to the current cycle. Components call
+// it before their state is observed, e.g. when the cpu reads a register.
+// Ticks due on the current cycle itself are left: they come after the
+// events and the eager devices due then, so a device that reads a lazy
+// one's state sees it as it was going into the cycle.
+func (c *Clock) Sync() {
+	for _, d := range c.devices {
+		if !d.lazy {
+			continue
+		}
+		for d.next < c.cycle {
+			d.advance()
+		}
+	}
+}
+
+// Delay puts off the device's next tick by n master cycles, e.g. while the
+// cpu runs its reset sequence.
+func (d *Device) Delay(n uint64) {
+	d.next += n
+}
+
+// Cycle returns the current master cycle.
+func (c *Clock) Cycle() uint64 {
+	return c.cycle
+}
+
+// Schedule fires fn once the clock reaches the master cycle at. Events due
+// on the same cycle fire in the order they were scheduled, before any device
+// is ticked on that cycle.
+func (c *Clock) Schedule(at uint64, fn func()) {
+	c.seq++
+	heap.Push(&c.events, &event{at: at, seq: c.seq, fire: fn})
+}
+
+// After fires fn delay master cycles from now.
+func (c *Clock) After(delay uint64, fn func()) {
+	c.Schedule(c.cycle+delay, fn)
+}
+
+// Step advances the clock to the next cycle something is due, fires the
+// pending events and ticks the due devices.
+func (c *Clock) Step() {
+	next, ok := c.nextDue()
+	if !ok {
+		return
+	}
+	c.cycle = next
+
+	if len(c.events) > 0 && c.events[0].at <= c.cycle {
+		c.Sync()
+		for len(c.events) > 0 && c.events[0].at <= c.cycle {
+			e := heap.Pop(&c.events).(*event)
+			e.fire()
+		}
+	}
+
+	for _, d := range c.devices {
+		if d.lazy || d.next > c.cycle {
+			continue
+		}
+		d.advance()
+	}
+}
+
+func (d *clockDevice) advance() {
+	n := d.tick()
+	if n == 0 {
+		n = 1
+	}
+	d.next += n * d.divider
+}
+
+func (c *Clock) nextDue() (uint64, bool) {
+	var next uint64
+	ok := false
+	for _, d := range c.devices {
+		if d.lazy {
+			continue
+		}
+		if !ok || d.next < next {
+			next = d.next
+			ok = true
+		}
+	}
+	if len(c.events) > 0 && (!ok || c.events[0].at < next) {
+		next = c.events[0].at
+		ok = true
+	}
+	return next, ok
+}
+
+type event struct {
+	at   uint64
+	seq  uint64
+	fire func()
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x any) {
+	*q = append(*q, x.(*event))
+}
+
+func (q *eventQueue) Pop() any {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return e
+}
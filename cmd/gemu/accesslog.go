@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/goldmane/gemu/cpu"
+	"github.com/goldmane/gemu/debug"
+)
+
+// runAccessLog implements "gemu accesslog", which lists the accesses in a
+// log recorded with "gemu -access-log" that pass the filters. To find what
+// last wrote $0300:
+//
+//	gemu accesslog -addr '$0300' -kind w -last -n 1 run.acc
+func runAccessLog(args []string) error {
+	fs := flag.NewFlagSet("gemu accesslog", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu accesslog [flags] log")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", "", "address or inclusive range accessed, e.g. $0300 or $0200-$02FF")
+	kinds := fs.String("kind", "rwf", "kinds of access: any of r (read), w (write) and f (opcode and operand fetch)")
+	pc := fs.String("pc", "", "only accesses by the instruction at this address")
+	from := fs.Uint64("from", 0, "first cpu cycle")
+	to := fs.Uint64("to", 0, "last cpu cycle (default: the end of the log)")
+	limit := fs.Int("n", 0, "stop after this many matches (default: all)")
+	last := fs.Bool("last", false, "with -n, show the last matches instead of the first")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	filter := debug.AllAccesses
+	if *addr != "" {
+		lo, hi, ranged := strings.Cut(*addr, "-")
+		var err error
+		if filter.Lo, err = parseAddress(lo); err != nil {
+			return err
+		}
+		filter.Hi = filter.Lo
+		if ranged {
+			if filter.Hi, err = parseAddress(hi); err != nil {
+				return err
+			}
+		}
+	}
+	filter.Kinds = 0
+	for _, k := range *kinds {
+		switch k {
+		case 'r':
+			filter.Kinds |= 1 << cpu.AccessRead
+		case 'w':
+			filter.Kinds |= 1 << cpu.AccessWrite
+		case 'f':
+			filter.Kinds |= 1 << cpu.AccessFetch
+		default:
+			return fmt.Errorf("invalid access kind %q", k)
+		}
+	}
+	if *pc != "" {
+		v, err := parseAddress(*pc)
+		if err != nil {
+			return err
+		}
+		filter.PC, filter.HasPC = v, true
+	}
+	filter.FromCycle = *from
+	if *to != 0 {
+		filter.ToCycle = *to
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	log, err := debug.NewAccessLogReader(f)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	// with -last, keep a ring of the latest matches
+	var ring []cpu.Access
+	matched := 0
+	for {
+		a, err := log.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !filter.Match(a) {
+			continue
+		}
+		matched++
+		if *last && *limit > 0 {
+			if len(ring) < *limit {
+				ring = append(ring, a)
+			} else {
+				ring[(matched-1)%*limit] = a
+			}
+			continue
+		}
+		fmt.Fprintln(out, debug.FormatAccess(a))
+		if matched == *limit {
+			break
+		}
+	}
+	// the oldest match in a full ring is the one after the newest
+	start := 0
+	if len(ring) == *limit && *limit > 0 {
+		start = matched % *limit
+	}
+	for i := range ring {
+		fmt.Fprintln(out, debug.FormatAccess(ring[(start+i)%len(ring)]))
+	}
+	return nil
+}
+
+// writeAccessLog opens path for an access log and returns the writer and a
+// function to flush and close it.
+func writeAccessLog(path string) (*debug.AccessLogWriter, func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	l := debug.NewAccessLogWriter(f)
+	return l, func() error {
+		err := l.Flush()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
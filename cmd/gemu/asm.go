@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/goldmane/gemu/asm"
+	"github.com/goldmane/gemu/disasm"
+)
+
+// runAsm implements "gemu asm", which assembles a snippet to binary.
+func runAsm(args []string) error {
+	fs := flag.NewFlagSet("gemu asm", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `usage: gemu asm [flags] ["LDA #$01 / RTS" ...]`)
+		fmt.Fprintln(fs.Output(), "With no arguments the source is read from stdin.")
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "write the binary to this file instead of stdout")
+	origin := fs.String("origin", "$8000", "address of the first byte, unless the source gives one")
+	listing := fs.Bool("l", false, "print a listing of the result instead of the binary")
+	fs.Parse(args)
+
+	org, err := parseAddress(*origin)
+	if err != nil {
+		return err
+	}
+
+	var src string
+	if fs.NArg() > 0 {
+		src = strings.Join(fs.Args(), "\n")
+	} else {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		src = string(b)
+	}
+
+	org, code, err := asm.Assemble(src, org)
+	if err != nil {
+		return err
+	}
+
+	if *listing {
+		lines := disasm.Disassemble(code, disasm.Options{Origin: org})
+		return disasm.Write(os.Stdout, lines, disasm.Format{Bytes: true})
+	}
+	if *output != "" {
+		return os.WriteFile(*output, code, 0o644)
+	}
+	_, err = os.Stdout.Write(code)
+	return err
+}
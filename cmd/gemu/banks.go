@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	core "github.com/goldmane/gemu/gemu"
+)
+
+// runBanks implements "gemu banks", which splits a ROM into one file per
+// PRG and CHR bank and joins them back together.
+func runBanks(args []string) error {
+	usage := "usage: gemu banks split [flags] rom.nes\n       gemu banks join [flags] -o rom.nes"
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	fs := flag.NewFlagSet("gemu banks "+args[0], flag.ExitOnError)
+	dir := fs.String("dir", "banks", "directory holding the bank files")
+	prgSize := fs.Int("prg-size", 16, "PRG bank size in KB when splitting")
+	chrSize := fs.Int("chr-size", 8, "CHR bank size in KB when splitting")
+	output := fs.String("o", "", "ROM to write when joining")
+	fs.Parse(args[1:])
+
+	switch args[0] {
+	case "split":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("%s", usage)
+		}
+		return splitBanks(fs.Arg(0), *dir, *prgSize*1024, *chrSize*1024)
+	case "join":
+		if *output == "" {
+			return fmt.Errorf("%s", usage)
+		}
+		return joinBanks(*dir, *output)
+	}
+	return fmt.Errorf("unknown banks command %q\n%s", args[0], usage)
+}
+
+// splitBanks writes header.bin, trainer.bin if there is one, and
+// prg-NN.bin and chr-NN.bin for each bank to dir.
+func splitBanks(path, dir string, prgSize, chrSize int) error {
+	if prgSize <= 0 || chrSize <= 0 {
+		return fmt.Errorf("bank sizes must be positive")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cart core.Cartridge
+	if err := cart.ParseImage(data); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	write := func(name string, b []byte) error {
+		return os.WriteFile(filepath.Join(dir, name), b, 0o644)
+	}
+	if err := write("header.bin", cart.Header[:]); err != nil {
+		return err
+	}
+	if cart.Trainer != nil {
+		if err := write("trainer.bin", cart.Trainer); err != nil {
+			return err
+		}
+	}
+	for i, b := range chunks(cart.PRG, prgSize) {
+		if err := write(fmt.Sprintf("prg-%02d.bin", i), b); err != nil {
+			return err
+		}
+	}
+	for i, b := range chunks(cart.CHR, chrSize) {
+		if err := write(fmt.Sprintf("chr-%02d.bin", i), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func chunks(b []byte, size int) [][]byte {
+	var out [][]byte
+	for len(b) > 0 {
+		n := min(size, len(b))
+		out = append(out, b[:n])
+		b = b[n:]
+	}
+	return out
+}
+
+// joinBanks reassembles the files written by splitBanks, taking the PRG and
+// CHR sizes in the header from the bank files, so banks can be added or
+// dropped.
+func joinBanks(dir, output string) error {
+	header, err := os.ReadFile(filepath.Join(dir, "header.bin"))
+	if err != nil {
+		return err
+	}
+	var cart core.Cartridge
+	if copy(cart.Header[:], header) != len(cart.Header) {
+		return fmt.Errorf("header.bin is too short")
+	}
+
+	trainer, err := os.ReadFile(filepath.Join(dir, "trainer.bin"))
+	switch {
+	case err == nil:
+		cart.Trainer = trainer
+		cart.Header[6] |= 0x04
+	case os.IsNotExist(err):
+		cart.Header[6] &^= 0x04
+	default:
+		return err
+	}
+
+	if cart.PRG, err = readBanks(dir, "prg"); err != nil {
+		return err
+	}
+	if cart.CHR, err = readBanks(dir, "chr"); err != nil {
+		return err
+	}
+	if len(cart.PRG)%16384 != 0 || len(cart.CHR)%8192 != 0 {
+		return fmt.Errorf("PRG must total a multiple of 16KB and CHR of 8KB")
+	}
+	cart.SyncSizes()
+
+	image := cart.Bytes()
+	// make sure the result parses before writing it
+	if err := new(core.Cartridge).ParseImage(image); err != nil {
+		return err
+	}
+	return os.WriteFile(output, image, 0o644)
+}
+
+// readBanks joins the bank files named kind-N.bin in dir in the order of
+// their numbers, which needn't be padded to sort.
+func readBanks(dir, kind string) ([]byte, error) {
+	names, err := filepath.Glob(filepath.Join(dir, kind+"-*.bin"))
+	if err != nil {
+		return nil, err
+	}
+	numbers := make(map[string]int, len(names))
+	for _, name := range names {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), kind+"-"), ".bin"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("bank file %s isn't numbered", name)
+		}
+		numbers[name] = n
+	}
+	slices.SortFunc(names, func(a, b string) int { return numbers[a] - numbers[b] })
+
+	var out []byte
+	for _, name := range names {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJoinBanksInNumberOrder(t *testing.T) {
+	dir := t.TempDir()
+	header := []byte{'N', 'E', 'S', 0x1A, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if err := os.WriteFile(filepath.Join(dir, "header.bin"), header, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// unpadded, so bank 10 sorts before bank 2 by name
+	const banks = 12
+	for i := 0; i < banks; i++ {
+		b := make([]byte, 16384)
+		for j := range b {
+			b[j] = byte(i)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("prg-%d.bin", i)), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "rom.nes")
+	if err := joinBanks(dir, out); err != nil {
+		t.Fatal(err)
+	}
+	rom, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rom[4] != banks {
+		t.Errorf("header has %d PRG banks, want %d", rom[4], banks)
+	}
+	for i := 0; i < banks; i++ {
+		if got := rom[16+i*16384]; got != byte(i) {
+			t.Errorf("bank %d holds prg-%d.bin", i, got)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "prg-extra.bin"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := joinBanks(dir, out); err == nil {
+		t.Error("joined a bank file without a number")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/goldmane/gemu/disasm"
+	core "github.com/goldmane/gemu/gemu"
+)
+
+// runCFG implements "gemu cfg", which lists the subroutines found by
+// following control flow from the interrupt vectors.
+func runCFG(args []string) error {
+	fs := flag.NewFlagSet("gemu cfg", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu cfg [flags] rom.nes")
+		fs.PrintDefaults()
+	}
+	dot := fs.String("dot", "", "also write the control flow graph to this file in Graphviz DOT format")
+	unreached := fs.Bool("unreached", false, "list the ranges never reached as code (data or dead code)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("cfg takes exactly one ROM")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var cart core.Cartridge
+	if err := cart.ParseImage(data); err != nil {
+		return err
+	}
+
+	// without running the mapper only the bank fixed at the top of memory
+	// is known to hold the vectors
+	code := cart.PRG
+	origin := uint16(0x8000)
+	if len(code) != 0x8000 {
+		code = code[len(code)-min(len(code), 0x4000):]
+		origin = 0xC000
+	}
+	g := disasm.Analyze(code, origin)
+
+	out := bufio.NewWriter(os.Stdout)
+	if err := g.WriteSubroutines(out); err != nil {
+		return err
+	}
+	if *unreached {
+		fmt.Fprintln(out, "\nunreached:")
+		for _, r := range g.Unreached() {
+			fmt.Fprintf(out, "%04X-%04X  %d bytes\n", r[0], r[1]-1, int(r[1])-int(r[0]))
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	if *dot != "" {
+		f, err := os.Create(*dot)
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(f)
+		if err := g.WriteDOT(w); err != nil {
+			f.Close()
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"image/png"
+	"os"
+
+	"github.com/goldmane/gemu/chr"
+	core "github.com/goldmane/gemu/gemu"
+)
+
+// runCHR implements "gemu chr", which exports a ROM's CHR as a PNG tile
+// sheet and imports an edited sheet back into a copy of the ROM.
+func runCHR(args []string) error {
+	usage := "usage: gemu chr export [flags] -o sheet.png rom.nes\n       gemu chr import [flags] -o patched.nes rom.nes sheet.png"
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	fs := flag.NewFlagSet("gemu chr "+args[0], flag.ExitOnError)
+	output := fs.String("o", "", "file to write: the PNG on export, the ROM on import")
+	palette := fs.String("palette", "gray", `"gray" or four comma separated RRGGBB colours for colours 0-3`)
+	bank := fs.Int("bank", -1, "only export or replace this 8KB CHR bank (default all)")
+	raw := fs.Bool("raw", false, "the input is raw CHR data, e.g. from gemu banks split, not an iNES ROM")
+	fs.Parse(args[1:])
+
+	switch args[0] {
+	case "export":
+		if fs.NArg() != 1 || *output == "" {
+			return fmt.Errorf("%s", usage)
+		}
+		pal, err := chr.ParsePalette(*palette)
+		if err != nil {
+			return err
+		}
+		data, err := readCHR(fs.Arg(0), *raw)
+		if err != nil {
+			return err
+		}
+		if *bank >= 0 {
+			const bankSize = 8192
+			if (*bank+1)*bankSize > len(data) {
+				return fmt.Errorf("bank %d out of range, CHR has %d banks of 8KB", *bank, len(data)/bankSize)
+			}
+			data = data[*bank*bankSize : (*bank+1)*bankSize]
+		}
+
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, chr.Sheet(data, pal)); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+
+	case "import":
+		if fs.NArg() != 2 || *output == "" {
+			return fmt.Errorf("%s", usage)
+		}
+		pal, err := chr.ParsePalette(*palette)
+		if err != nil {
+			return err
+		}
+		return importCHR(fs.Arg(0), fs.Arg(1), *output, pal, *bank)
+	}
+	return fmt.Errorf("unknown chr command %q\n%s", args[0], usage)
+}
+
+// importCHR writes a copy of the ROM at romPath to output with its CHR, or
+// one bank of it, replaced by the tiles in the sheet at sheetPath. The sheet
+// can't grow the CHR; extra tile rows past the end are an error.
+func importCHR(romPath, sheetPath, output string, pal color.Palette, bank int) error {
+	data, err := os.ReadFile(romPath)
+	if err != nil {
+		return err
+	}
+	var cart core.Cartridge
+	if err := cart.ParseImage(data); err != nil {
+		return err
+	}
+	if len(cart.CHR) == 0 {
+		return fmt.Errorf("%s has no CHR ROM to replace", romPath)
+	}
+
+	f, err := os.Open(sheetPath)
+	if err != nil {
+		return err
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", sheetPath, err)
+	}
+	tiles, err := chr.Tiles(img, pal)
+	if err != nil {
+		return err
+	}
+
+	dst := cart.CHR
+	if bank >= 0 {
+		const bankSize = 8192
+		if (bank+1)*bankSize > len(cart.CHR) {
+			return fmt.Errorf("bank %d out of range, CHR has %d banks of 8KB", bank, len(cart.CHR)/bankSize)
+		}
+		dst = cart.CHR[bank*bankSize : (bank+1)*bankSize]
+	}
+	if len(tiles) > len(dst) {
+		return fmt.Errorf("sheet has %d tiles but the CHR only holds %d", len(tiles)/chr.TileSize, len(dst)/chr.TileSize)
+	}
+	copy(dst, tiles)
+
+	return os.WriteFile(output, cart.Bytes(), 0o644)
+}
+
+// readCHR returns the CHR ROM of the ROM at path, or the whole file if raw.
+func readCHR(path string, raw bool) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || raw {
+		return data, err
+	}
+	var cart core.Cartridge
+	if err := cart.ParseImage(data); err != nil {
+		return nil, err
+	}
+	if len(cart.CHR) == 0 {
+		return nil, fmt.Errorf("%s has no CHR ROM; its CHR RAM is only filled while the game runs", path)
+	}
+	return cart.CHR, nil
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goldmane/gemu"
+	"github.com/goldmane/gemu/cc65"
+	"github.com/goldmane/gemu/debug"
+	"github.com/goldmane/gemu/disasm"
+)
+
+// runDebug implements "gemu debug", a terminal debugger with panes for the
+// disassembly, registers, stack, breakpoints and memory, redrawn after
+// every command.
+func runDebug(args []string) error {
+	fs := flag.NewFlagSet("gemu debug", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu debug [flags] [rom.nes]")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("http", "", "serve the browser debugger on this address instead of the terminal one")
+	breakOn := fs.String("break-on", "", "stop continuing on interrupt events: a comma separated list of nmi, irq, brk and rti")
+	var watches watchFlag
+	fs.Var(&watches, "watch", "watch expression as name=expr, e.g. lives=$075A or ptr=$10.w (repeatable)")
+	autoLabels := fs.Bool("auto-labels", false, "name subroutines and interrupt handlers in the disassembly as the program reaches them")
+	dbgFile := fs.String("dbg", "", "cc65 debug info file (ld65 --dbgfile) to show source lines from")
+	fs.Parse(args)
+
+	rom := "nestest.nes"
+	if fs.NArg() > 0 {
+		rom = fs.Arg(0)
+	}
+	emu := gemu.NewEmulator()
+	if *autoLabels {
+		emu.SetAutoLabels(gemu.NewAutoLabels())
+	}
+	if err := emu.LoadROM(rom); err != nil {
+		return fmt.Errorf("inserting ROM: %w", err)
+	}
+	if fs.NArg() > 0 {
+		rememberROM(emu, rom)
+	}
+
+	d := debug.New(emu)
+	events, err := debug.ParseEvents(*breakOn)
+	if err != nil {
+		return err
+	}
+	d.SetBreakOn(events)
+	if *dbgFile != "" {
+		info, err := cc65.Load(*dbgFile)
+		if err != nil {
+			return fmt.Errorf("loading debug info: %w", err)
+		}
+		d.SetSourceMap(info)
+	}
+	for _, w := range watches {
+		d.AddWatch(w.Name, w.Expr)
+	}
+	if *addr != "" {
+		fmt.Fprintf(os.Stderr, "debugger at http://%s/\n", *addr)
+		return http.ListenAndServe(*addr, debug.NewWebServer(d))
+	}
+	t := &tui{d: d, out: bufio.NewWriter(os.Stdout)}
+	return t.run(os.Stdin)
+}
+
+const (
+	paneWidth   = 44
+	disasmLines = 12
+	memoryRows  = 8
+	stackLines  = 6
+)
+
+type tui struct {
+	d      *debug.Debugger
+	out    *bufio.Writer
+	memory uint16
+	status string
+	last   string
+}
+
+const debugHelp = "s [n] step  c continue  b/d addr break/delete  bi nmi|irq|brk|rti toggle  m addr memory  a addr: code assemble  w name expr/uw name watch  dump range file  reset  power  q quit"
+
+func (t *tui) run(in io.Reader) error {
+	t.status = debugHelp
+	sc := bufio.NewScanner(in)
+	for {
+		t.draw()
+		if !sc.Scan() {
+			return sc.Err()
+		}
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			line = t.last
+		}
+		t.last = line
+		if quit := t.command(line); quit {
+			return nil
+		}
+	}
+}
+
+// command runs one command line and reports whether to quit.
+func (t *tui) command(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	arg := func() (uint16, bool) {
+		if len(fields) < 2 {
+			t.status = fields[0] + " needs an address"
+			return 0, false
+		}
+		addr, err := parseAddress(fields[1])
+		if err != nil {
+			t.status = err.Error()
+			return 0, false
+		}
+		return addr, true
+	}
+
+	t.status = ""
+	switch fields[0] {
+	case "s", "step":
+		n := 1
+		if len(fields) > 1 {
+			if v, err := strconv.Atoi(fields[1]); err == nil && v > 0 {
+				n = v
+			}
+		}
+		for i := 0; i < n; i++ {
+			if err := t.d.Step(); err != nil {
+				t.status = err.Error()
+				break
+			}
+		}
+	case "c", "continue":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		reason, err := t.d.Continue(ctx)
+		stop()
+		t.status = "stopped: " + reason.String()
+		if err != nil {
+			t.status += ": " + err.Error()
+		}
+	case "b", "break":
+		if addr, ok := arg(); ok {
+			t.d.AddBreakpoint(addr)
+		}
+	case "d", "delete":
+		if addr, ok := arg(); ok {
+			t.d.RemoveBreakpoint(addr)
+		}
+	case "bi":
+		if len(fields) < 2 {
+			t.status = "bi needs nmi, irq, brk or rti"
+		} else if e, err := debug.ParseEvents(fields[1]); err != nil {
+			t.status = err.Error()
+		} else {
+			t.d.SetBreakOn(t.d.BreakOn() ^ e)
+		}
+	case "m", "memory":
+		if addr, ok := arg(); ok {
+			t.memory = addr &^ 0x0F
+		}
+	case "a", "asm":
+		if len(fields) < 3 {
+			t.status = "asm needs an address and code, e.g. asm C123: LDA #$01 / RTS"
+		} else if err := t.assemble(line); err != nil {
+			t.status = err.Error()
+		}
+	case "w", "watch":
+		if len(fields) < 3 {
+			t.status = "w needs a name and an expression"
+		} else if err := t.d.AddWatch(fields[1], strings.Join(fields[2:], "")); err != nil {
+			t.status = err.Error()
+		}
+	case "uw", "unwatch":
+		if len(fields) < 2 {
+			t.status = "uw needs a watch name"
+		} else {
+			t.d.RemoveWatch(fields[1])
+		}
+	case "dump":
+		if len(fields) < 3 {
+			t.status = "dump needs a range and a file"
+		} else if err := t.dump(fields[1], fields[2]); err != nil {
+			t.status = err.Error()
+		} else {
+			t.status = "dumped to " + fields[2]
+		}
+	case "reset":
+		t.d.SoftReset()
+	case "power":
+		if err := t.d.PowerCycle(); err != nil {
+			t.status = err.Error()
+		}
+	case "q", "quit":
+		return true
+	default:
+		t.status = debugHelp
+	}
+	return false
+}
+
+// assemble runs an asm command line, "asm addr: code", patching the code
+// into memory at addr.
+func (t *tui) assemble(line string) error {
+	_, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	at, src, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	addr, err := parseAddress(strings.TrimSuffix(at, ":"))
+	if err != nil {
+		return err
+	}
+	code, err := t.d.Emulator().Patch(addr, src)
+	if err != nil {
+		return err
+	}
+	t.status = fmt.Sprintf("assembled %d bytes at $%04X", len(code), addr)
+	return nil
+}
+
+// dump writes the memory range spec to path, as hex if it is a .hex or .txt
+// file and as binary otherwise.
+func (t *tui) dump(spec, path string) error {
+	r, err := debug.ParseRange(spec)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := t.d.Dump(f, r, debug.DumpFormatFor(path)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// draw redraws every pane.
+func (t *tui) draw() {
+	regs := t.d.Registers()
+
+	var left []string
+	left = append(left, "DISASSEMBLY")
+	for _, pc := range t.d.History()[max(0, len(t.d.History())-3):] {
+		left = append(left, t.disasmLine(pc, "  "))
+	}
+	for i, l := range t.d.Disassemble(regs.PC, disasmLines) {
+		marker := "  "
+		if i == 0 {
+			marker = "> "
+		}
+		left = append(left, t.formatLine(l, marker))
+	}
+
+	right := []string{
+		"REGISTERS",
+		fmt.Sprintf("PC:%04X  A:%02X X:%02X Y:%02X", regs.PC, regs.A, regs.X, regs.Y),
+		fmt.Sprintf("SP:%02X    P:%02X %s", regs.SP, regs.P, regs.FlagString()),
+		fmt.Sprintf("CYC:%d", regs.Cycles),
+	}
+	if src := t.d.Source(regs.PC); src != "" {
+		right = append(right, "SRC:"+src)
+	}
+	right = append(right, "", "STACK")
+	entries := t.d.StackEntries()
+	for _, e := range entries[:min(stackLines, len(entries))] {
+		right = append(right, fmt.Sprintf("%04X  %-5s  %s", e.Addr, fmt.Sprintf("% X", e.Bytes), e))
+	}
+	if len(entries) > stackLines {
+		right = append(right, fmt.Sprintf("(%d more)", len(entries)-stackLines))
+	}
+	right = append(right, "", "BREAKPOINTS")
+	bps := t.d.Breakpoints()
+	if len(bps) == 0 && t.d.BreakOn() == 0 {
+		right = append(right, "(none)")
+	}
+	if on := t.d.BreakOn(); on != 0 {
+		right = append(right, "on "+on.String())
+	}
+	for chunk := range slices.Chunk(bps, 6) {
+		var s []string
+		for _, b := range chunk {
+			s = append(s, fmt.Sprintf("%04X", b))
+		}
+		right = append(right, strings.Join(s, " "))
+	}
+	if lines := t.d.WatchLines(); len(lines) > 0 {
+		right = append(right, "", "WATCHES")
+		right = append(right, lines...)
+	}
+
+	// clear the screen and home the cursor
+	t.out.WriteString("\x1b[H\x1b[2J")
+	for i := 0; i < max(len(left), len(right)); i++ {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		fmt.Fprintf(t.out, "%-*s%s\n", paneWidth, l, r)
+	}
+
+	fmt.Fprintf(t.out, "\nMEMORY\n")
+	for row := 0; row < memoryRows; row++ {
+		addr := t.memory + uint16(row*16)
+		fmt.Fprintf(t.out, "%04X  % X\n", addr, t.d.Memory(addr, 16))
+	}
+	fmt.Fprintf(t.out, "\n%s\n> ", t.status)
+	t.out.Flush()
+}
+
+func (t *tui) disasmLine(pc uint16, marker string) string {
+	return t.formatLine(t.d.Disassemble(pc, 1)[0], marker)
+}
+
+func (t *tui) formatLine(l disasm.Line, marker string) string {
+	if slices.Contains(t.d.Breakpoints(), l.Addr) {
+		marker = "*" + marker[1:]
+	}
+	raw := fmt.Sprintf("% X", l.Bytes)
+	return fmt.Sprintf("%s%04X  %-9s %s %s", marker, l.Addr, raw, l.Mnemonic, l.Operand)
+}
+
+// watchFlag collects repeated -watch name=expr flags.
+type watchFlag []debug.Watch
+
+func (f *watchFlag) String() string {
+	var s []string
+	for _, w := range *f {
+		s = append(s, w.Name+"="+w.Expr)
+	}
+	return strings.Join(s, " ")
+}
+
+func (f *watchFlag) Set(v string) error {
+	name, expr, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("watch %q must be name=expr", v)
+	}
+	w, err := debug.ParseWatch(name, expr)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, w)
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goldmane/gemu/cpu"
+	"github.com/goldmane/gemu/refcpu"
+)
+
+var errDiffFailed = errors.New("difftest found mismatches")
+
+// runDiffTest implements "gemu difftest", which runs random instruction
+// sequences through the cpu core and the reference interpreter in package
+// refcpu side by side, comparing registers, flags, cycles and the bytes
+// written after every instruction. Each sequence starts from random memory
+// and registers; whenever the next opcode is one the core doesn't
+// implement it is replaced with one it does, so runs go through branches,
+// jumps and returns wherever they lead.
+func runDiffTest(args []string) error {
+	fs := flag.NewFlagSet("gemu difftest", flag.ExitOnError)
+	n := fs.Int("n", 1000, "sequences to run")
+	length := fs.Int("len", 64, "instructions per sequence")
+	seed := fs.Uint64("seed", 0, "random seed, to repeat a run (default: from the clock)")
+	show := fs.Int("max", 10, "mismatches to print in full")
+	fs.Parse(args)
+	if *seed == 0 {
+		*seed = uint64(time.Now().UnixNano())
+	}
+
+	var ops []uint8
+	for _, op := range slices.Sorted(maps.Keys(refcpu.Ops)) {
+		if _, ok := cpu.Lookup(op); ok {
+			ops = append(ops, op)
+		}
+	}
+
+	var steps, mismatches int
+	failed := map[string]int{}
+	for i := 0; i < *n; i++ {
+		d := newDiffRun(rand.New(rand.NewPCG(*seed, uint64(i))), ops)
+		steps += d.run(*length)
+		if d.mismatch == "" {
+			continue
+		}
+		failed[d.mnemonic]++
+		if mismatches++; mismatches <= *show {
+			fmt.Printf("sequence %d: %s\n", i, d.mismatch)
+		}
+	}
+
+	fmt.Printf("difftest: %d sequences, %d instructions, %d opcodes, seed %d\n", *n, steps, len(ops), *seed)
+	if len(failed) == 0 {
+		fmt.Println("ok: no mismatches")
+		return nil
+	}
+	fmt.Printf("%d sequences mismatched\n", mismatches)
+	var by []string
+	for _, m := range slices.Sorted(maps.Keys(failed)) {
+		by = append(by, fmt.Sprintf("%s %d", m, failed[m]))
+	}
+	fmt.Printf("mismatching sequences by instruction: %s\n", strings.Join(by, ", "))
+	return errDiffFailed
+}
+
+// diffRun is one random sequence run through both cores.
+type diffRun struct {
+	rng  *rand.Rand
+	ops  []uint8
+	ref  *refcpu.CPU
+	core *cpu.CPU
+	bus  *cpu.TestBus
+
+	// mismatch describes the first difference, and mnemonic is the
+	// instruction that caused it
+	mismatch string
+	mnemonic string
+}
+
+func newDiffRun(rng *rand.Rand, ops []uint8) *diffRun {
+	ref := &refcpu.CPU{}
+	for i := range ref.Mem {
+		ref.Mem[i] = uint8(rng.Uint32())
+	}
+	ref.PC = uint16(rng.Uint32())
+	ref.A, ref.X, ref.Y = uint8(rng.Uint32()), uint8(rng.Uint32()), uint8(rng.Uint32())
+	ref.SP = uint8(rng.Uint32())
+	ref.P = uint8(rng.Uint32())&^refcpu.B | refcpu.U
+
+	c := &cpu.CPU{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	c.Reset()
+	bus := &cpu.TestBus{}
+	copy(bus[:], ref.Mem[:])
+	c.SetBus(bus)
+	c.SetPC(ref.PC)
+	c.A.SetRegister(ref.A)
+	c.X.SetRegister(ref.X)
+	c.Y.SetRegister(ref.Y)
+	c.SP = ref.SP
+	for bit := uint8(1); bit != 0; bit <<= 1 {
+		c.Flags.SetFlag(bit, ref.P&bit != 0)
+	}
+	return &diffRun{rng: rng, ops: ops, ref: ref, core: c, bus: bus}
+}
+
+// run steps both cores up to n instructions, stopping at the first
+// mismatch, and returns how many it ran.
+func (d *diffRun) run(n int) int {
+	mem := d.bus[:]
+	for i := 0; i < n; i++ {
+		pc := d.ref.PC
+		if !slices.Contains(d.ops, d.ref.Mem[pc]) {
+			op := d.ops[d.rng.IntN(len(d.ops))]
+			d.ref.Mem[pc], mem[pc] = op, op
+		}
+		before := d.ref.State
+		op := refcpu.Ops[d.ref.Mem[pc]]
+		var code []byte
+		for j := 0; j < op.Mode.Length(); j++ {
+			code = append(code, d.ref.Mem[pc+uint16(j)])
+		}
+
+		want, _ := d.ref.Step()
+		got, err := d.step()
+		if diffs := d.compare(got, want, err); len(diffs) > 0 {
+			d.mnemonic = op.Mnemonic
+			d.mismatch = fmt.Sprintf("step %d: %04X  %-8s  %s  from A:%02X X:%02X Y:%02X P:%02X SP:%02X: %s",
+				i, pc, fmt.Sprintf("% X", code), op.Mnemonic,
+				before.A, before.X, before.Y, before.P, before.SP, strings.Join(diffs, ", "))
+			return i + 1
+		}
+	}
+	// catch stray writes the per step checks can't see
+	for a := range mem {
+		if mem[a] != d.ref.Mem[a] {
+			d.mnemonic = "memory"
+			d.mismatch = fmt.Sprintf("after %d steps: memory at %04X got %02X want %02X", n, a, mem[a], d.ref.Mem[a])
+			break
+		}
+	}
+	return n
+}
+
+// step runs one instruction on the core, turning a panic into an error.
+func (d *diffRun) step() (cycles int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	r, err := d.core.Step()
+	return int(r.Cycles), err
+}
+
+func (d *diffRun) compare(got, want int, err error) []string {
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var diffs []string
+	check := func(field string, g, w any) {
+		if g != w {
+			diffs = append(diffs, fmt.Sprintf("%s got %v want %v", field, g, w))
+		}
+	}
+	hex := func(v uint8) string { return fmt.Sprintf("%02X", v) }
+	check("PC", fmt.Sprintf("%04X", d.core.GetPC()), fmt.Sprintf("%04X", d.ref.PC))
+	check("A", hex(d.core.A.GetValue()), hex(d.ref.A))
+	check("X", hex(d.core.X.GetValue()), hex(d.ref.X))
+	check("Y", hex(d.core.Y.GetValue()), hex(d.ref.Y))
+	check("P", hex(d.core.Flags.Value()), hex(d.ref.P))
+	check("SP", hex(d.core.SP), hex(d.ref.SP))
+	check("cycles", got, want)
+	mem := d.bus[:]
+	for _, a := range d.ref.Writes {
+		check(fmt.Sprintf("$%04X", a), hex(mem[a]), hex(d.ref.Mem[a]))
+	}
+	return diffs
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goldmane/gemu/disasm"
+	core "github.com/goldmane/gemu/gemu"
+)
+
+// runDisasm implements "gemu disasm", which lists a ROM's PRG banks.
+func runDisasm(args []string) error {
+	fs := flag.NewFlagSet("gemu disasm", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu disasm [flags] rom.nes")
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "write the listing to this file instead of stdout")
+	bank := fs.Int("bank", -1, "only disassemble this PRG bank (default all)")
+	bankSize := fs.Int("bank-size", 16, "PRG bank size in KB (8, 16 or 32)")
+	origin := fs.String("origin", "", "cpu address of the start of each bank (default $C000 for the last 16KB bank, $8000 otherwise)")
+	start := fs.String("start", "", "skip to this cpu address within each bank")
+	showBytes := fs.Bool("bytes", true, "show the raw instruction bytes")
+	showASCII := fs.Bool("ascii", false, "show the instruction bytes as ASCII")
+	mlbPath := fs.String("mlb", "", "also write the jump targets found as a Mesen label file")
+	cdlPath := fs.String("cdl", "", "FCEUX/Mesen code/data log; bytes logged only as data are listed as .byte")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("disasm takes exactly one ROM")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var cart core.Cartridge
+	if err := cart.ParseImage(data); err != nil {
+		return err
+	}
+	if len(cart.PRG) == 0 {
+		return fmt.Errorf("ROM has no PRG")
+	}
+
+	size := *bankSize * 1024
+	switch *bankSize {
+	case 8, 16, 32:
+	default:
+		return fmt.Errorf("invalid bank size %dKB", *bankSize)
+	}
+	if len(cart.PRG) < size {
+		size = len(cart.PRG)
+	}
+	banks := len(cart.PRG) / size
+
+	var cdl []byte
+	if *cdlPath != "" {
+		if cdl, err = os.ReadFile(*cdlPath); err != nil {
+			return fmt.Errorf("reading CDL: %w", err)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	out := bufio.NewWriter(w)
+
+	first, last := 0, banks-1
+	if *bank >= 0 {
+		if *bank >= banks {
+			return fmt.Errorf("bank %d out of range, ROM has %d banks of %dKB", *bank, banks, size/1024)
+		}
+		first, last = *bank, *bank
+	}
+
+	var mlb *bufio.Writer
+	if *mlbPath != "" {
+		f, err := os.Create(*mlbPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		mlb = bufio.NewWriter(f)
+	}
+
+	format := disasm.Format{Bytes: *showBytes, ASCII: *showASCII}
+	for b := first; b <= last; b++ {
+		offset := b * size
+		code := cart.PRG[offset : offset+size]
+		var opts disasm.Options
+
+		opts.Origin = defaultOrigin(b, banks, size)
+		if *origin != "" {
+			addr, err := parseAddress(*origin)
+			if err != nil {
+				return err
+			}
+			opts.Origin = addr
+		}
+		if offset < len(cdl) {
+			opts.CDL = cdl[offset:min(offset+size, len(cdl))]
+		}
+
+		if *start != "" {
+			addr, err := parseAddress(*start)
+			if err != nil {
+				return err
+			}
+			skip := int(addr) - int(opts.Origin)
+			if skip < 0 || skip >= len(code) {
+				return fmt.Errorf("start $%04X is outside bank %d ($%04X-$%04X)", addr, b, opts.Origin, int(opts.Origin)+len(code)-1)
+			}
+			code = code[skip:]
+			offset += skip
+			if len(opts.CDL) > skip {
+				opts.CDL = opts.CDL[skip:]
+			} else {
+				opts.CDL = nil
+			}
+			opts.Origin = addr
+		}
+
+		if last > first {
+			fmt.Fprintf(out, "; bank %d\n", b)
+		}
+		if err := disasm.Write(out, disasm.Disassemble(code, opts), format); err != nil {
+			return err
+		}
+		if mlb != nil {
+			if err := disasm.WriteMLB(mlb, disasm.Labels(code, opts), opts.Origin, offset); err != nil {
+				return err
+			}
+		}
+	}
+	if mlb != nil {
+		if err := mlb.Flush(); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// defaultOrigin maps bank b of n to where it usually sits: the last 16KB
+// (the reset vector's bank) at $C000, everything else at $8000.
+func defaultOrigin(b, n, size int) uint16 {
+	if size == 16*1024 && b == n-1 {
+		return 0xC000
+	}
+	if size == 8*1024 {
+		return 0x8000 + uint16(b%4)*0x2000
+	}
+	return 0x8000
+}
+
+// parseAddress accepts $C000, 0xC000 or C000.
+func parseAddress(s string) (uint16, error) {
+	t := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(s, "$"), "0x"), "0X")
+	v, err := strconv.ParseUint(t, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+	return uint16(v), nil
+}
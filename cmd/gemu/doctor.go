@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	core "github.com/goldmane/gemu/gemu"
+)
+
+var errDoctorFailed = errors.New("gemu can't run this ROM")
+
+// runDoctor implements "gemu doctor", which inspects a ROM without running
+// it and reports whether gemu can: the header, the mapper, CHR ROM or RAM,
+// region, trainer and, with -gamedb, whether the dump is known and good.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("gemu doctor", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu doctor [flags] rom.nes")
+		fs.PrintDefaults()
+	}
+	gameDB := fs.String("gamedb", "", "database of known dumps to check the ROM against")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var db *core.GameDB
+	if *gameDB != "" {
+		var err error
+		if db, err = core.LoadGameDB(*gameDB); err != nil {
+			return fmt.Errorf("loading game database: %w", err)
+		}
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	report := func(status, format string, args ...any) {
+		if status == "FAIL" {
+			failed = true
+		}
+		fmt.Printf("%-5s %s\n", status, fmt.Sprintf(format, args...))
+	}
+
+	var cart core.Cartridge
+	if err := cart.ParseImage(data); err != nil {
+		report("FAIL", "header: %v", err)
+		return errDoctorFailed
+	}
+	checkHeader(&cart, len(data), report)
+
+	id := cart.MapperID()
+	if factory, ok := core.LookupMapper(id); !ok {
+		report("FAIL", "mapper %d is not supported (supported: %v)", id, core.Mappers())
+	} else if _, err := factory(&cart); err != nil {
+		report("FAIL", "mapper %d: %v", id, err)
+	} else {
+		report("ok", "mapper %d is supported", id)
+	}
+
+	report("ok", "PRG ROM: %d KB", len(cart.PRG)/1024)
+	if len(cart.CHR) > 0 {
+		report("ok", "CHR ROM: %d KB", len(cart.CHR)/1024)
+	} else {
+		report("ok", "CHR RAM, no CHR ROM")
+	}
+	if cart.Trainer != nil {
+		report("warn", "trainer present; it is loaded at $7000 only if the board has PRG RAM there")
+	}
+
+	region := cart.Region()
+	game, known := db.Lookup(&cart)
+	switch {
+	case db == nil:
+		report("ok", "region: %s (from the header)", region.Name)
+	case !known:
+		report("warn", "dump %08X is not in the database; region %s is from the header", cart.CRC32(), region.Name)
+	default:
+		report("ok", "known dump: %s", game.Name)
+		if game.Region.Name != region.Name {
+			report("warn", "region: the header says %s, the database %s, which gemu uses", region.Name, game.Region.Name)
+		} else {
+			report("ok", "region: %s", region.Name)
+		}
+		if game.BadDump() {
+			report("FAIL", "the database marks this as a bad dump; find a good one")
+		}
+	}
+
+	if failed {
+		return errDoctorFailed
+	}
+	fmt.Println("gemu should be able to run this ROM")
+	return nil
+}
+
+// checkHeader reports problems with a parsed header that gemu works
+// around, for an image of size bytes.
+func checkHeader(c *core.Cartridge, size int, report func(status, format string, args ...any)) {
+	switch {
+	case c.IsNES2():
+		report("ok", "header: NES 2.0")
+	case c.Header[7]&0x0C != 0:
+		report("warn", "header: archaic iNES with garbage in bytes 7-15 (\"%s\"); the mapper number may be wrong; correct it with gemu header -nes2 -mapper", printable(c.Header[7:]))
+	default:
+		garbage := false
+		for _, b := range c.Header[12:] {
+			garbage = garbage || b != 0
+		}
+		if garbage {
+			report("warn", "header: iNES with garbage in bytes 12-15; fix it with gemu header -nes2")
+		} else {
+			report("ok", "header: iNES")
+		}
+	}
+
+	want := 16 + len(c.Trainer) + len(c.PRG) + len(c.CHR)
+	if size > want {
+		report("warn", "%d bytes of data after the CHR ROM are ignored", size-want)
+	}
+}
+
+// printable returns b with bytes outside printable ASCII shown as dots.
+func printable(b []byte) string {
+	s := make([]byte, len(b))
+	for i, c := range b {
+		s[i] = '.'
+		if c >= 0x20 && c < 0x7F {
+			s[i] = c
+		}
+	}
+	return string(s)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/goldmane/gemu"
+	"github.com/goldmane/gemu/debug"
+)
+
+// runDumpMem implements "gemu dump-mem", which runs a ROM from reset for a
+// number of frames and writes ranges of cpu memory to a file, for offline
+// analysis:
+//
+//	gemu dump-mem -frames 600 -o ram.bin game.nes ram
+//	gemu dump-mem game.nes '$0200-$02FF' prg-ram
+func runDumpMem(args []string) error {
+	fs := flag.NewFlagSet("gemu dump-mem", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu dump-mem [flags] rom.nes range...")
+		fmt.Fprintln(fs.Output(), "a range is ram, prg-ram, prg, cpu, the PPU's vram, palette or oam, a cpu address or an inclusive range such as $0200-$02FF")
+		fs.PrintDefaults()
+	}
+	frames := fs.Int("frames", 0, "frames to run from reset before dumping")
+	out := fs.String("o", "", "file to write (default: stdout)")
+	format := fs.String("format", "", "bin or hex (default: hex for .hex and .txt files and stdout, else bin)")
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var ranges []debug.MemoryRange
+	for _, s := range fs.Args()[1:] {
+		r, err := debug.ParseRange(s)
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, r)
+	}
+	f := debug.DumpHex
+	if *out != "" {
+		f = debug.DumpFormatFor(*out)
+	}
+	if *format != "" {
+		var err error
+		if f, err = debug.ParseDumpFormat(*format); err != nil {
+			return err
+		}
+	}
+
+	emu := gemu.NewEmulator()
+	if err := emu.LoadROM(fs.Arg(0)); err != nil {
+		return fmt.Errorf("inserting ROM: %w", err)
+	}
+	for i := 0; i < *frames; i++ {
+		if err := emu.RunFrame(); err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	bw := bufio.NewWriter(w)
+	d := debug.New(emu)
+	for _, r := range ranges {
+		if err := d.Dump(bw, r, f); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goldmane/gemu"
+)
+
+var errFramesDiffer = errors.New("frames differ from the golden images")
+
+// runFrames implements "gemu frames", which runs a ROM from reset and
+// compares the frame at each checkpoint against a golden PNG, to catch
+// rendering changes. Golden images are named after the ROM and frame, e.g.
+// golden/game_0060.png, and -update writes them.
+func runFrames(args []string) error {
+	fs := flag.NewFlagSet("gemu frames", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu frames [flags] rom.nes")
+		fs.PrintDefaults()
+	}
+	at := fs.String("at", "60", "comma separated frame numbers to check")
+	dir := fs.String("dir", "golden", "directory of golden images")
+	tolerance := fs.Int("tolerance", 0, "largest difference in any colour channel still counted as matching")
+	maxPixels := fs.Int("max-pixels", 0, "pixels allowed to differ by more than the tolerance")
+	update := fs.Bool("update", false, "write the frames as the new golden images instead of comparing")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var checks []uint64
+	for _, s := range strings.Split(*at, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid frame number %q", s)
+		}
+		checks = append(checks, n)
+	}
+	slices.Sort(checks)
+
+	rom := fs.Arg(0)
+	emu := gemu.NewEmulator()
+	if err := emu.LoadROM(rom); err != nil {
+		return fmt.Errorf("inserting ROM: %w", err)
+	}
+	if *update {
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	base := strings.TrimSuffix(filepath.Base(rom), filepath.Ext(rom))
+	failed := false
+	for frame := uint64(0); len(checks) > 0; frame++ {
+		if frame == checks[0] {
+			checks = checks[1:]
+			path := filepath.Join(*dir, fmt.Sprintf("%s_%04d.png", base, frame))
+			if *update {
+				if err := writePNG(path, emu.Framebuffer()); err != nil {
+					return err
+				}
+				fmt.Printf("frame %d: wrote %s\n", frame, path)
+				continue
+			}
+			ok, err := checkFrame(path, emu.Framebuffer(), *tolerance, *maxPixels)
+			if err != nil {
+				return err
+			}
+			failed = failed || !ok
+		}
+		if len(checks) > 0 {
+			if err := emu.RunFrame(); err != nil {
+				return fmt.Errorf("frame %d: %w", frame, err)
+			}
+		}
+	}
+	if failed {
+		return errFramesDiffer
+	}
+	return nil
+}
+
+// checkFrame compares frame against the golden image at path and reports
+// whether it matches. A mismatch writes a .diff.png beside the golden
+// image with the differing pixels in red over a dimmed copy of the frame.
+func checkFrame(path string, frame *image.RGBA, tolerance, maxPixels int) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	want, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	if want.Bounds() != frame.Bounds() {
+		fmt.Printf("%s: size %v, want %v\n", path, frame.Bounds().Size(), want.Bounds().Size())
+		return false, nil
+	}
+
+	diff := image.NewRGBA(frame.Bounds())
+	differ, worst := 0, 0
+	for y := frame.Rect.Min.Y; y < frame.Rect.Max.Y; y++ {
+		for x := frame.Rect.Min.X; x < frame.Rect.Max.X; x++ {
+			got := frame.RGBAAt(x, y)
+			w := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+			d := max(delta(got.R, w.R), delta(got.G, w.G), delta(got.B, w.B))
+			worst = max(worst, d)
+			if d > tolerance {
+				differ++
+				diff.SetRGBA(x, y, color.RGBA{0xFF, 0, 0, 0xFF})
+			} else {
+				diff.SetRGBA(x, y, color.RGBA{got.R / 4, got.G / 4, got.B / 4, 0xFF})
+			}
+		}
+	}
+	if differ <= maxPixels {
+		fmt.Printf("%s: ok\n", path)
+		return true, nil
+	}
+	diffPath := strings.TrimSuffix(path, ".png") + ".diff.png"
+	if err := writePNG(diffPath, diff); err != nil {
+		return false, err
+	}
+	fmt.Printf("%s: %d pixels differ, by up to %d; see %s\n", path, differ, worst, diffPath)
+	return false, nil
+}
+
+func delta(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
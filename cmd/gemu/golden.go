@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/goldmane/gemu"
+)
+
+// runGolden implements "gemu golden", which writes a ROM's trace in a
+// reference log format, to compare later versions or other emulators with.
+func runGolden(args []string) error {
+	fs := flag.NewFlagSet("gemu golden", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu golden [flags] [rom.nes]")
+		fs.PrintDefaults()
+	}
+	formatName := fs.String("format", "nestest", "log format ("+strings.Join(gemu.TraceFormats(), ", ")+")")
+	out := fs.String("o", "", "output file (default: stdout)")
+	limit := fs.Uint64("n", 0, "instructions to log (default: until the cpu stops or ^C)")
+	start := fs.String("start", "", startUsage)
+	fs.Parse(args)
+
+	format, err := referenceFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	rom := "nestest.nes"
+	if fs.NArg() > 0 {
+		rom = fs.Arg(0)
+	}
+	emu := gemu.NewEmulator()
+	if err := emu.LoadROM(rom); err != nil {
+		return fmt.Errorf("inserting ROM: %w", err)
+	}
+	if err := startAt(emu, *start); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	emu.SetTrace(bw)
+	emu.SetTraceFormat(format)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *limit == 0 {
+		err = emu.Run(ctx)
+	} else {
+		err = runFor(ctx, emu, *limit)
+	}
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "stopped at instruction %d: %v\n", emu.Counter(), err)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	core "github.com/goldmane/gemu/gemu"
+)
+
+// runHeader implements "gemu header", which prints a ROM's header and
+// optionally writes a copy with fields rewritten.
+func runHeader(args []string) error {
+	fs := flag.NewFlagSet("gemu header", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu header [flags] rom.nes")
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "write the ROM with the edited header to this file")
+	mapper := fs.Int("mapper", -1, "set the mapper number")
+	mirroring := fs.String("mirroring", "", "set the mirroring (horizontal, vertical or four-screen)")
+	battery := fs.String("battery", "", "set whether the cartridge has a battery (true or false)")
+	nes2 := fs.Bool("nes2", false, "upgrade the header to NES 2.0")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("header takes exactly one ROM")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var cart core.Cartridge
+	if err := cart.ParseImage(data); err != nil {
+		return err
+	}
+
+	edited := false
+	if *mirroring != "" {
+		m, ok := core.MirroringByName(*mirroring)
+		if !ok {
+			return fmt.Errorf("unknown mirroring %q", *mirroring)
+		}
+		cart.SetMirroring(m)
+		edited = true
+	}
+	if *battery != "" {
+		on, err := strconv.ParseBool(*battery)
+		if err != nil {
+			return fmt.Errorf("invalid battery setting %q", *battery)
+		}
+		cart.SetBattery(on)
+		edited = true
+	}
+
+	// upgrade after the battery edit, which decides the RAM kind, and
+	// before the mapper edit, which may need NES 2.0
+	if *nes2 {
+		cart.UpgradeNES2()
+		edited = true
+	}
+	if *mapper >= 0 {
+		if *mapper > 0xFFF {
+			return fmt.Errorf("invalid mapper %d", *mapper)
+		}
+		if err := cart.SetMapperID(uint16(*mapper)); err != nil {
+			return err
+		}
+		edited = true
+	}
+	printHeader(os.Stdout, &cart)
+
+	if !edited {
+		return nil
+	}
+	if *output == "" {
+		return fmt.Errorf("header edits need -o to write the corrected copy")
+	}
+	return os.WriteFile(*output, cart.Bytes(), 0o644)
+}
+
+func printHeader(w io.Writer, c *core.Cartridge) {
+	format := "iNES"
+	if c.IsNES2() {
+		format = "NES 2.0"
+	}
+	fmt.Fprintf(w, "Format:     %s\n", format)
+	fmt.Fprintf(w, "Mapper:     %d\n", c.MapperID())
+	if c.IsNES2() {
+		fmt.Fprintf(w, "Submapper:  %d\n", c.Submapper())
+	}
+	fmt.Fprintf(w, "PRG ROM:    %d KB\n", len(c.PRG)/1024)
+	if len(c.CHR) > 0 {
+		fmt.Fprintf(w, "CHR ROM:    %d KB\n", len(c.CHR)/1024)
+	} else {
+		fmt.Fprintf(w, "CHR ROM:    none (CHR RAM)\n")
+	}
+	if c.IsNES2() {
+		ram, nvram := c.PRGRAMSize()
+		fmt.Fprintf(w, "PRG RAM:    %d bytes, %d battery backed\n", ram, nvram)
+		ram, nvram = c.CHRRAMSize()
+		fmt.Fprintf(w, "CHR RAM:    %d bytes, %d battery backed\n", ram, nvram)
+	} else {
+		fmt.Fprintf(w, "PRG RAM:    %d KB\n", c.WorkRAMSize()/1024)
+	}
+	fmt.Fprintf(w, "Mirroring:  %s\n", c.Mirroring())
+	fmt.Fprintf(w, "Battery:    %t\n", c.Battery())
+	fmt.Fprintf(w, "Trainer:    %t\n", c.Trainer != nil)
+	fmt.Fprintf(w, "Region:     %s\n", c.Region().Name)
+	fmt.Fprintf(w, "Raw:        % X\n", c.Header)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+
+	"github.com/goldmane/gemu/cpu"
+	"github.com/goldmane/gemu/debug"
+)
+
+// writeHeatmap saves the access counts as a PNG and/or CSV, skipping
+// either whose path is empty.
+func writeHeatmap(h *cpu.Heatmap, pngPath, csvPath string) error {
+	if pngPath != "" {
+		if err := writePNG(pngPath, debug.HeatmapImage(h)); err != nil {
+			return err
+		}
+	}
+	if csvPath != "" {
+		f, err := os.Create(csvPath)
+		if err != nil {
+			return err
+		}
+		err = debug.WriteHeatmapCSV(f, h)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/goldmane/gemu"
+	"github.com/goldmane/gemu/cc65"
+	"github.com/goldmane/gemu/cpu"
+	core "github.com/goldmane/gemu/gemu"
+)
+
+func main() {
+	if err := dispatch(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// dispatch runs the subcommand named by the first argument, or the nestest
+// trace run when there is none.
+func dispatch(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "disasm":
+			return runDisasm(args[1:])
+		case "asm":
+			return runAsm(args[1:])
+		case "header":
+			return runHeader(args[1:])
+		case "banks":
+			return runBanks(args[1:])
+		case "chr":
+			return runCHR(args[1:])
+		case "cfg":
+			return runCFG(args[1:])
+		case "serve":
+			return runServe(args[1:])
+		case "debug":
+			return runDebug(args[1:])
+		case "profile":
+			return runProfile(args[1:])
+		case "verify":
+			return runVerify(args[1:])
+		case "golden":
+			return runGolden(args[1:])
+		case "testsuite":
+			return runTestSuite(args[1:])
+		case "frames":
+			return runFrames(args[1:])
+		case "difftest":
+			return runDiffTest(args[1:])
+		case "accesslog":
+			return runAccessLog(args[1:])
+		case "recent":
+			return runRecent(args[1:])
+		case "dump-mem":
+			return runDumpMem(args[1:])
+		case "doctor":
+			return runDoctor(args[1:])
+		case "scenario":
+			return runScenario(args[1:])
+		}
+	}
+	return run(args)
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("gemu", flag.ExitOnError)
+	regionName := fs.String("region", "", "force the timing region (ntsc, pal or dendy) instead of reading it from the ROM header")
+	overclock := fs.Int("overclock", 0, "extra cpu-only scanlines to run after vblank each frame")
+	patch := fs.String("patch", "", "IPS or BPS patch to apply to the ROM (default: a .ips or .bps next to it)")
+	autoLabels := fs.Bool("auto-labels", false, "name subroutines and interrupt handlers in the trace as the run reaches them, e.g. sub_C72A and nmi_handler")
+	dbgFile := fs.String("dbg", "", "cc65 debug info file (ld65 --dbgfile) to annotate the trace with source lines")
+	pluginPaths := fs.String("plugins", "", "comma separated Go plugins to load, e.g. extra mappers")
+	refSource := fs.String("ref", "./reference.txt", `reference trace to compare against: a file, "-" for stdin, or tcp://host:port or unix:///path to read another emulator's live trace`)
+	refFormat := fs.String("ref-format", "", "parse the reference as this emulator's trace format and compare fields ("+strings.Join(gemu.TraceFormats(), ", ")+"; default: compare lines exactly)")
+	gameDB := fs.String("gamedb", "", "database of known dumps (crc32 region name per line) to pick the region from when the header is wrong")
+	heatmapPNG := fs.String("heatmap", "", "write a PNG heatmap of cpu memory accesses to this file when the run ends")
+	heatmapCSV := fs.String("heatmap-csv", "", "write per-address read, write and execute counts as CSV to this file when the run ends")
+	accessLog := fs.String("access-log", "", "record every cpu bus access to this binary log, for querying with gemu accesslog")
+	timeline := fs.String("timeline", "", "write a Chrome trace event timeline of the run to this file, for chrome://tracing or Perfetto")
+	timelineCalls := fs.Bool("timeline-calls-only", false, "leave the instructions out of the timeline, keeping the calls, interrupts and frames")
+	diagnostics := fs.String("diagnostics", "", "write a diagnostics bundle (report, last instructions, screenshot, RAM) to this directory if the run crashes or freezes")
+	freezeFrames := fs.Int("freeze-frames", 0, "with -diagnostics, stop when the cpu sits in one loop with RAM and the picture unchanged for this many frames (0: never)")
+	auditCycles := fs.Bool("audit-cycles", false, "check every instruction's cycle count against the official timing and report mismatches on stderr when the run ends")
+	logLevel := fs.String("log-level", "info", "log level (debug, info, warn or error)")
+	start := fs.String("start", "C000", startUsage)
+	fs.Parse(args)
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q", *logLevel)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	stopAfter := -1
+	if fs.NArg() > 0 {
+		val, err := strconv.Atoi(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid instruction count %q", fs.Arg(0))
+		}
+		stopAfter = val
+	}
+
+	if *pluginPaths != "" {
+		for _, p := range strings.Split(*pluginPaths, ",") {
+			if err := core.LoadPlugin(p); err != nil {
+				return err
+			}
+			logger.Info("plugin loaded", "path", p)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	emu := gemu.NewEmulator()
+	emu.SetLogger(logger)
+	if *regionName != "" {
+		region, ok := core.RegionByName(*regionName)
+		if !ok {
+			return fmt.Errorf("unknown region %q", *regionName)
+		}
+		emu.SetRegion(region)
+	}
+	emu.SetOverclock(*overclock)
+	emu.SetPatch(*patch)
+	if *gameDB != "" {
+		db, err := core.LoadGameDB(*gameDB)
+		if err != nil {
+			return fmt.Errorf("loading game database: %w", err)
+		}
+		emu.SetGameDB(db)
+	}
+	if *dbgFile != "" {
+		info, err := cc65.Load(*dbgFile)
+		if err != nil {
+			return fmt.Errorf("loading debug info: %w", err)
+		}
+		emu.SetSourceMap(info)
+	}
+
+	if *autoLabels {
+		emu.SetAutoLabels(gemu.NewAutoLabels())
+	}
+
+	err := emu.LoadROM("nestest.nes")
+	if err != nil {
+		return fmt.Errorf("inserting ROM: %w", err)
+	}
+	if err := startAt(emu, *start); err != nil {
+		return err
+	}
+
+	format, err := referenceFormat(*refFormat)
+	if err != nil {
+		return err
+	}
+	ref, err := openReference(*refSource)
+	if err != nil {
+		return fmt.Errorf("opening reference: %w", err)
+	}
+	defer ref.Close()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	emu.SetTrace(out)
+	emu.SetReference(ref)
+	emu.SetReferenceFormat(format)
+
+	var heat *cpu.Heatmap
+	if *heatmapPNG != "" || *heatmapCSV != "" {
+		heat = new(cpu.Heatmap)
+		emu.CPU().SetHeatmap(heat)
+	}
+	if *accessLog != "" {
+		l, closeLog, err := writeAccessLog(*accessLog)
+		if err != nil {
+			return err
+		}
+		emu.CPU().SetAccessHook(l.Record)
+		defer func() {
+			if cerr := closeLog(); cerr != nil {
+				logger.Error("writing access log", "err", cerr)
+			}
+		}()
+	}
+	if *timeline != "" {
+		f, err := os.Create(*timeline)
+		if err != nil {
+			return err
+		}
+		t := gemu.NewTimeline(f)
+		t.Instructions = !*timelineCalls
+		emu.SetTimeline(t)
+		defer func() {
+			err := t.Close()
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				logger.Error("writing timeline", "err", err)
+			}
+		}()
+	}
+	if *diagnostics != "" {
+		emu.SetDiagnostics(&gemu.Diagnostics{Dir: *diagnostics, FreezeFrames: *freezeFrames})
+	}
+	var audit *gemu.CycleAudit
+	if *auditCycles {
+		audit = gemu.NewCycleAudit()
+		emu.SetCycleAudit(audit)
+	}
+
+	if stopAfter < 0 {
+		err = emu.Run(ctx)
+	} else {
+		err = runFor(ctx, emu, uint64(stopAfter))
+	}
+	if heat != nil {
+		if herr := writeHeatmap(heat, *heatmapPNG, *heatmapCSV); herr != nil {
+			return fmt.Errorf("writing heatmap: %w", herr)
+		}
+	}
+	if audit != nil {
+		audit.WriteReport(os.Stderr)
+	}
+
+	var mismatch *gemu.MismatchError
+	switch {
+	case errors.As(err, &mismatch):
+		fmt.Fprintln(out, "No match")
+		fmt.Fprintln(out, mismatch.Got)
+		fmt.Fprintln(out, "VV REF VV")
+		fmt.Fprintln(out, mismatch.Want)
+		fmt.Fprintf(out, "first difference at line %d: %s\n", mismatch.Line, mismatch.Divergence())
+		return nil
+	case errors.Is(err, gemu.ErrReferenceExhausted), errors.Is(err, context.Canceled):
+		fmt.Fprintln(out, err)
+		return nil
+	}
+	return err
+}
+
+// referenceFormat looks up a reference trace format by name; none for ""
+// means comparing lines as text.
+func referenceFormat(name string) (gemu.TraceFormat, error) {
+	if name == "" {
+		return nil, nil
+	}
+	f, ok := gemu.TraceFormatByName(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown trace format %q (want %s)", name, strings.Join(gemu.TraceFormats(), ", "))
+	}
+	return f, nil
+}
+
+// openReference opens a reference trace from a file, stdin, or a socket
+// another emulator writes its trace to as it runs, for lockstep comparison.
+func openReference(src string) (io.ReadCloser, error) {
+	switch {
+	case src == "-":
+		return io.NopCloser(os.Stdin), nil
+	case strings.HasPrefix(src, "tcp://"):
+		return net.Dial("tcp", strings.TrimPrefix(src, "tcp://"))
+	case strings.HasPrefix(src, "unix://"):
+		return net.Dial("unix", strings.TrimPrefix(src, "unix://"))
+	}
+	return os.Open(src)
+}
+
+// startUsage is the usage of the -start flags.
+const startUsage = "start the cpu at this address instead of the ROM's reset vector, e.g. C000 for nestest's automated mode (empty: the reset vector)"
+
+// startAt points the cpu at the address in s once the ROM is loaded, or
+// leaves it at the reset vector if s is empty.
+func startAt(emu *gemu.Emulator, s string) error {
+	if s == "" {
+		return nil
+	}
+	addr, err := parseAddress(s)
+	if err != nil {
+		return err
+	}
+	emu.CPU().SetPC(addr)
+	return nil
+}
+
+// runFor steps the emulator until it has executed n instructions.
+func runFor(ctx context.Context, emu *gemu.Emulator, n uint64) error {
+	for emu.Counter() < n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := emu.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
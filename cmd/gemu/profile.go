@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/goldmane/gemu"
+	"github.com/goldmane/gemu/debug"
+)
+
+// runProfile implements "gemu profile", which runs a ROM attributing cpu
+// cycles to subroutines and writes them as folded stacks for a flame graph:
+//
+//	gemu profile -n 100000 game.nes | flamegraph.pl > game.svg
+func runProfile(args []string) error {
+	fs := flag.NewFlagSet("gemu profile", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu profile [flags] [rom.nes]")
+		fs.PrintDefaults()
+	}
+	out := fs.String("o", "", "output file (default: stdout)")
+	limit := fs.Uint64("n", 0, "instructions to run (default: until the cpu stops or ^C)")
+	fs.Parse(args)
+
+	rom := "nestest.nes"
+	if fs.NArg() > 0 {
+		rom = fs.Arg(0)
+	}
+	emu := gemu.NewEmulator()
+	if err := emu.LoadROM(rom); err != nil {
+		return fmt.Errorf("inserting ROM: %w", err)
+	}
+
+	d := debug.New(emu)
+	p := debug.NewProfile()
+	d.SetProfile(p)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var stopped error
+	for n := uint64(0); *limit == 0 || n < *limit; n++ {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := d.Step(); err != nil {
+			stopped = err
+			break
+		}
+	}
+	if stopped != nil {
+		fmt.Fprintf(os.Stderr, "stopped after %d cycles: %v\n", p.Total(), stopped)
+	}
+
+	if *out == "" {
+		return writeFolded(os.Stdout, p)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	err = writeFolded(f, p)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeFolded(w io.Writer, p *debug.Profile) error {
+	bw := bufio.NewWriter(w)
+	if err := p.WriteFolded(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
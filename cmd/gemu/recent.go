@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/goldmane/gemu"
+	core "github.com/goldmane/gemu/gemu"
+)
+
+// runRecent implements "gemu recent", which lists the recently played
+// ROMs, or launches one by its number with another subcommand:
+//
+//	gemu recent             list them
+//	gemu recent 2           serve the second
+//	gemu recent 2 debug     debug it instead
+func runRecent(args []string) error {
+	fs := flag.NewFlagSet("gemu recent", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu recent [flags] [n [command [flags]]]")
+		fs.PrintDefaults()
+	}
+	gameDB := fs.String("gamedb", "", "database of known dumps to take the titles from")
+	clear := fs.Bool("clear", false, "forget the recent ROMs")
+	fs.Parse(args)
+
+	recent, err := loadRecent()
+	if err != nil {
+		return err
+	}
+	if *clear {
+		recent.ROMs = nil
+		return recent.Save()
+	}
+
+	if fs.NArg() > 0 {
+		n, err := strconv.Atoi(fs.Arg(0))
+		if err != nil || n < 1 || n > len(recent.ROMs) {
+			return fmt.Errorf("no recent ROM %q; gemu recent lists them", fs.Arg(0))
+		}
+		cmd := []string{"serve"}
+		if fs.NArg() > 1 {
+			cmd = fs.Args()[1:]
+		}
+		return dispatch(append(cmd, recent.ROMs[n-1].Path))
+	}
+
+	var db *core.GameDB
+	if *gameDB != "" {
+		if db, err = core.LoadGameDB(*gameDB); err != nil {
+			return err
+		}
+	}
+	if len(recent.ROMs) == 0 {
+		fmt.Println("no recent ROMs")
+		return nil
+	}
+	for i, rom := range recent.ROMs {
+		title := rom.Title
+		if db != nil {
+			var cart core.Cartridge
+			cart.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+			if cart.Insert(rom.Path) == nil {
+				if game, ok := db.Lookup(&cart); ok {
+					title = game.Name
+				}
+			}
+		}
+		fmt.Printf("%2d  %-32s  %-19s  %s\n", i+1, title, rom.Played.Format(time.DateTime), rom.Path)
+	}
+	return nil
+}
+
+func loadRecent() (*gemu.RecentROMs, error) {
+	path, err := gemu.DefaultRecentPath()
+	if err != nil {
+		return nil, err
+	}
+	return gemu.LoadRecentROMs(path)
+}
+
+// rememberROM adds the ROM just loaded into emu to the recent list. The
+// list is a convenience, so failing to update it is only logged.
+func rememberROM(emu *gemu.Emulator, path string) {
+	recent, err := loadRecent()
+	if err == nil {
+		game, _ := emu.Game()
+		recent.Add(path, game.Name)
+		err = recent.Save()
+	}
+	if err != nil {
+		slog.Debug("updating recent ROMs", "err", err)
+	}
+}
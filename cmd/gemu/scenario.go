@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/goldmane/gemu/scenario"
+)
+
+var errScenarioFailed = errors.New("scenarios failed")
+
+// runScenario implements "gemu scenario", which plays scenario scripts
+// (see package scenario) and reports the assertions that failed.
+func runScenario(args []string) error {
+	fs := flag.NewFlagSet("gemu scenario", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu scenario script...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		s, err := scenario.ParseFile(path)
+		if err != nil {
+			return err
+		}
+		res, err := s.Play()
+		switch {
+		case err != nil:
+			fmt.Printf("FAIL  %s: %v\n", path, err)
+		case !res.Passed():
+			fmt.Printf("FAIL  %s (%d frames)\n", path, res.Frames)
+		default:
+			fmt.Printf("ok    %s (%d frames)\n", path, res.Frames)
+		}
+		if res != nil {
+			for _, f := range res.Failures {
+				fmt.Printf("      %s\n", f)
+			}
+		}
+		if err != nil || !res.Passed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", errScenarioFailed, failed, fs.NArg())
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/goldmane/gemu"
+	"github.com/goldmane/gemu/debug"
+	core "github.com/goldmane/gemu/gemu"
+	"github.com/goldmane/gemu/input"
+	"github.com/goldmane/gemu/remote"
+)
+
+// runServe implements "gemu serve", which runs the emulator under the HTTP
+// control API.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("gemu serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu serve [flags] [rom.nes]")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", "localhost:6502", "address to listen on")
+	overlay := fs.Bool("overlay", false, "draw the debug overlay (frame rate, speed, counters) over the frame")
+	var watches watchFlag
+	fs.Var(&watches, "watch", "watch expression as name=expr shown on the overlay every frame (repeatable)")
+	port1 := fs.String("port1", "joypad", "device in controller port 1 ("+strings.Join(input.Names(), ", ")+")")
+	port2 := fs.String("port2", "joypad", "device in controller port 2")
+	expansion := fs.String("expansion", "none", "device in the Famicom expansion port ("+strings.Join(input.ExpansionNames(), ", ")+"), addressed as input port 2")
+	watchROM := fs.Bool("watch-rom", false, "power cycle into the ROM again whenever its file changes, for homebrew builds")
+	gameDB := fs.String("gamedb", "", "database of known dumps, for the region and the names of the per-game folders")
+	settingsPath, _ := gemu.DefaultGameSettingsPath()
+	fs.StringVar(&settingsPath, "settings", settingsPath, "file the per-game settings, such as the overclock, are kept in")
+	dirs, _ := gemu.DefaultGameDirs()
+	fs.StringVar(&dirs.Saves, "saves-dir", dirs.Saves, "base directory for battery saves, with a folder per game")
+	fs.StringVar(&dirs.States, "states-dir", dirs.States, "base directory for save states, with a folder per game")
+	fs.StringVar(&dirs.Screenshots, "screenshots-dir", dirs.Screenshots, "base directory for screenshots, with a folder per game")
+	fs.StringVar(&dirs.Crashes, "crashes-dir", dirs.Crashes, "directory for the diagnostics bundles written when a game crashes or freezes")
+	freezeFrames := fs.Int("freeze-frames", 0, "stop and write a diagnostics bundle when the cpu sits in one loop with RAM and the picture unchanged for this many frames (0: never)")
+	fs.Parse(args)
+
+	emu := gemu.NewEmulator()
+	for i, name := range []string{*port1, *port2} {
+		d, ok := input.New(name)
+		if !ok {
+			return fmt.Errorf("unknown controller port device %q", name)
+		}
+		emu.SetPort(i, d)
+	}
+	exp, ok := input.NewExpansion(*expansion)
+	if !ok {
+		return fmt.Errorf("unknown expansion port device %q", *expansion)
+	}
+	emu.SetExpansion(exp)
+	if *gameDB != "" {
+		db, err := core.LoadGameDB(*gameDB)
+		if err != nil {
+			return fmt.Errorf("loading game database: %w", err)
+		}
+		emu.SetGameDB(db)
+	}
+	if settingsPath != "" {
+		settings, err := gemu.LoadGameSettings(settingsPath)
+		if err != nil {
+			return fmt.Errorf("loading game settings: %w", err)
+		}
+		emu.SetGameSettings(settings)
+	}
+	emu.SetOverlay(*overlay)
+	emu.SetDiagnostics(&gemu.Diagnostics{Dir: dirs.Crashes, FreezeFrames: *freezeFrames})
+	if len(watches) > 0 {
+		ws := debug.Watches(watches)
+		emu.SetOverlaySource(func() []string { return ws.Lines(emu.CPU(), emu.PeekMemory) })
+	}
+	loaded := false
+	if fs.NArg() > 0 {
+		if err := emu.LoadROM(fs.Arg(0)); err != nil {
+			return fmt.Errorf("inserting ROM: %w", err)
+		}
+		loaded = true
+		rememberROM(emu, fs.Arg(0))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	srv := remote.NewServer(emu, loaded)
+	srv.SetGameDirs(dirs)
+	if recent, err := loadRecent(); err == nil {
+		srv.SetRecent(recent)
+	} else {
+		slog.Warn("recent ROMs unavailable", "err", err)
+	}
+	httpSrv := &http.Server{Addr: *addr, Handler: srv}
+	go func() {
+		<-ctx.Done()
+		httpSrv.Shutdown(context.Background())
+	}()
+	go srv.Run(ctx)
+	if *watchROM {
+		go srv.WatchROM(ctx, 250*time.Millisecond)
+	}
+
+	slog.Info("serving control API", "addr", *addr)
+	if err := httpSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
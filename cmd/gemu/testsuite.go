@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goldmane/gemu"
+	"github.com/goldmane/gemu/cpu"
+)
+
+var errSuiteFailed = errors.New("test suite failed")
+
+// romResult is one ROM's entry in the test suite report.
+type romResult struct {
+	ROM string `json:"rom"`
+	// pass, fail, timeout or error
+	Result string `json:"result"`
+	// how the result was decided: blargg, framehash or timeout
+	Method  string `json:"method,omitempty"`
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Frames  uint64 `json:"frames"`
+	// crc32 of the last frame, to record as the .hash of a good run
+	FrameHash string `json:"frameHash,omitempty"`
+}
+
+type suiteReport struct {
+	Passed  int         `json:"passed"`
+	Failed  int         `json:"failed"`
+	Results []romResult `json:"results"`
+}
+
+// runTestSuite implements "gemu testsuite", which runs every .nes file
+// under a directory headlessly and reports pass or fail for each as JSON. A ROM
+// is done when:
+//
+//   - it reports through blargg's protocol: $DE $B0 $61 at $6001 and a
+//     status below $80 at $6000, 0 for a pass, with a message from $6004
+//   - it has a .hash file beside it holding the crc32 of the expected frame,
+//     and the frame matches
+//   - or the frame limit runs out, which fails it
+func runTestSuite(args []string) error {
+	fs := flag.NewFlagSet("gemu testsuite", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gemu testsuite [flags] dir")
+		fs.PrintDefaults()
+	}
+	frames := fs.Uint64("frames", 60*60, "frames to run each ROM for before timing out")
+	out := fs.String("o", "", "write the JSON report to this file (default: stdout)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	roms, err := findROMs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var report suiteReport
+	for _, rom := range roms {
+		r := runTestROM(rom, *frames)
+		if r.Result == "pass" {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, r)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return errSuiteFailed
+	}
+	return nil
+}
+
+// findROMs returns the .nes files under dir, in lexical order.
+func findROMs(dir string) ([]string, error) {
+	var roms []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".nes") {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+	return roms, err
+}
+
+// blargg status codes at $6000 that aren't results
+const (
+	blarggRunning    = 0x80
+	blarggNeedsReset = 0x81
+)
+
+// blarggResetDelay is how many frames to wait before pressing reset when a
+// ROM asks for it; the protocol wants at least 100ms.
+const blarggResetDelay = 10
+
+func runTestROM(rom string, limit uint64) romResult {
+	r := romResult{ROM: rom}
+	emu := gemu.NewEmulator()
+	emu.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := emu.LoadROM(rom); err != nil {
+		r.Result, r.Message = "error", err.Error()
+		return r
+	}
+
+	want := ""
+	if b, err := os.ReadFile(strings.TrimSuffix(rom, filepath.Ext(rom)) + ".hash"); err == nil {
+		want = strings.ToLower(strings.TrimSpace(string(b)))
+	}
+
+	pages := emu.CPU().Pages()
+	resetAt := uint64(0)
+	for r.Frames = 0; r.Frames < limit; r.Frames++ {
+		if err := emu.RunFrame(); err != nil {
+			r.Result, r.Message = "error", err.Error()
+			break
+		}
+		r.FrameHash = fmt.Sprintf("%08x", crc32.ChecksumIEEE(emu.Framebuffer().Pix))
+
+		if pages.Read(0x6001) == 0xDE && pages.Read(0x6002) == 0xB0 && pages.Read(0x6003) == 0x61 {
+			status := pages.Read(0x6000)
+			switch {
+			case status == blarggNeedsReset:
+				if resetAt == 0 {
+					resetAt = r.Frames + blarggResetDelay
+				} else if r.Frames >= resetAt {
+					emu.SoftReset()
+					resetAt = 0
+				}
+				continue
+			case status < blarggRunning:
+				r.Method, r.Code = "blargg", int(status)
+				r.Message = blarggMessage(pages)
+				r.Result = "fail"
+				if status == 0 {
+					r.Result = "pass"
+				}
+				return r
+			}
+		}
+		if want != "" && r.FrameHash == want {
+			r.Method, r.Result = "framehash", "pass"
+			return r
+		}
+	}
+	if r.Result == "" {
+		r.Method, r.Result = "timeout", "timeout"
+	}
+	return r
+}
+
+// blarggMessage reads the zero terminated text a blargg test writes from
+// $6004.
+func blarggMessage(pages *cpu.PageTable) string {
+	var b []byte
+	for a := uint16(0x6004); a < 0x7000; a++ {
+		c := pages.Read(a)
+		if c == 0 {
+			break
+		}
+		b = append(b, c)
+	}
+	return strings.TrimSpace(string(b))
+}
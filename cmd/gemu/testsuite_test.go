@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindROMsInSubdirectories(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.nes", "a/2.nes", "a/1.NES", "a/readme.txt", "c/d/e.nes"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	roms, err := findROMs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range roms {
+		roms[i], _ = filepath.Rel(dir, r)
+		roms[i] = filepath.ToSlash(roms[i])
+	}
+	want := []string{"a/1.NES", "a/2.nes", "b.nes", "c/d/e.nes"}
+	if !slices.Equal(roms, want) {
+		t.Errorf("found %q, want %q", roms, want)
+	}
+}
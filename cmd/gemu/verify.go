@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/goldmane/gemu"
+)
+
+var errVerifyFailed = errors.New("verify failed")
+
+// runVerify implements "gemu verify", which runs a ROM against a reference
+// trace to the end of the log and summarises how far the two agree:
+//
+//	gemu verify -rom nestest.nes -log reference.txt
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("gemu verify", flag.ExitOnError)
+	rom := fs.String("rom", "nestest.nes", "ROM to run")
+	log := fs.String("log", "reference.txt", `reference trace: a file, "-" for stdin, or a tcp:// or unix:// socket`)
+	formatName := fs.String("format", "", "parse the log as this emulator's trace format and compare fields ("+strings.Join(gemu.TraceFormats(), ", ")+"; default: compare lines exactly)")
+	lines := fs.Int("context", 5, "matching lines to show before a difference")
+	start := fs.String("start", "C000", startUsage)
+	fs.Parse(args)
+
+	emu := gemu.NewEmulator()
+	if err := emu.LoadROM(*rom); err != nil {
+		return fmt.Errorf("inserting ROM: %w", err)
+	}
+	if err := startAt(emu, *start); err != nil {
+		return err
+	}
+	format, err := referenceFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	ref, err := openReference(*log)
+	if err != nil {
+		return fmt.Errorf("opening reference: %w", err)
+	}
+	defer ref.Close()
+
+	tail := &traceTail{keep: *lines + 1}
+	emu.SetTrace(tail)
+	emu.SetReference(ref)
+	emu.SetReferenceFormat(format)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = emu.Run(ctx)
+
+	fmt.Printf("verify: %s against %s\n", *rom, *log)
+	var mismatch *gemu.MismatchError
+	switch {
+	case errors.Is(err, gemu.ErrReferenceExhausted):
+		fmt.Printf("ok: all %d lines match\n", emu.Counter())
+		return nil
+	case errors.As(err, &mismatch):
+		fmt.Printf("matched %d lines\n", mismatch.Line-1)
+		fmt.Printf("first difference at line %d: %s\n\n", mismatch.Line, mismatch.Divergence())
+		// the last line kept is the mismatching one
+		for _, l := range tail.lines[:len(tail.lines)-1] {
+			fmt.Printf("  %s\n", l)
+		}
+		fmt.Printf("- %4d  %s\n", mismatch.Line, mismatch.Want)
+		fmt.Printf("+ %4d  %s\n", mismatch.Line, mismatch.Got)
+		fmt.Printf("        %s\n", highlight(mismatch))
+	default:
+		// the line that failed never made it to the trace
+		fmt.Printf("matched %d lines\n", emu.Counter()-1)
+		fmt.Printf("stopped at line %d before the reference ended: %v\n", emu.Counter(), err)
+	}
+	return errVerifyFailed
+}
+
+// highlight returns a line of carets under the columns of the emulator's
+// line that differ from the reference.
+func highlight(m *gemu.MismatchError) string {
+	marks := []byte(strings.Repeat(" ", len(m.Got)))
+	for _, d := range m.Diffs() {
+		start := gemu.TraceColumn(m.Got, d.Field)
+		if start < 0 {
+			continue
+		}
+		for i := start; i < start+len(d.Got) && i < len(marks); i++ {
+			marks[i] = '^'
+		}
+	}
+	return strings.TrimRight(string(marks), " ")
+}
+
+// traceTail keeps the last lines of a trace. The emulator writes one line
+// per call.
+type traceTail struct {
+	keep  int
+	lines []string
+}
+
+func (t *traceTail) Write(p []byte) (int, error) {
+	if len(t.lines) == t.keep {
+		t.lines = append(t.lines[:0], t.lines[1:]...)
+	}
+	t.lines = append(t.lines, strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
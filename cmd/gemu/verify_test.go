@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/goldmane/gemu"
+)
+
+func TestHighlight(t *testing.T) {
+	emu := gemu.NewEmulator()
+	if err := emu.LoadROM("../../nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	emu.CPU().SetPC(0xC000)
+	emu.SetTrace(io.Discard)
+	// nestest starts with JMP $C5F5 (4C F5 C5) and A:00 P:24
+	emu.SetReference(io.NopCloser(strings.NewReader(
+		"C000  $20 $F5 $C5     JSR $C5F5                       A:01 X:00 Y:00 P:24 SP:FD CYC: 21 SL:0   CPU Cycle:7\n")))
+	format, _ := gemu.TraceFormatByName("mesen")
+	emu.SetReferenceFormat(format)
+
+	var m *gemu.MismatchError
+	if err := emu.Run(context.Background()); !errors.As(err, &m) {
+		t.Fatalf("ran to %v, want a mismatch", err)
+	}
+	got := highlight(m)
+	want := "      ^^^^^^^^  ^^^" + strings.Repeat(" ", 31) + "^^"
+	if got != want {
+		t.Errorf("highlighted\n%s\n%s\nwant\n%s", m.Got, got, want)
+	}
+}
@@ -0,0 +1,161 @@
+// Command libgemu builds the emulator as a C shared library, for driving it
+// from other languages:
+//
+//	go build -buildmode=c-shared -o libgemu.so ./cmd/libgemu
+//
+// Emulators are referred to by an opaque handle from gemu_new. Functions
+// that can fail return 0 on success and -1 on failure, with the message
+// available from gemu_error until the next failing call. bindings/python
+// wraps the library with ctypes.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"unsafe"
+
+	"github.com/goldmane/gemu"
+)
+
+type instance struct {
+	emu *gemu.Emulator
+	err *C.char
+}
+
+func get(h C.uintptr_t) *instance {
+	return cgo.Handle(h).Value().(*instance)
+}
+
+// fail records err for gemu_error and returns -1.
+func (in *instance) fail(err error) C.int {
+	if in.err != nil {
+		C.free(unsafe.Pointer(in.err))
+	}
+	in.err = C.CString(err.Error())
+	return -1
+}
+
+//export gemu_new
+func gemu_new() C.uintptr_t {
+	return C.uintptr_t(cgo.NewHandle(&instance{emu: gemu.NewEmulator()}))
+}
+
+//export gemu_free
+func gemu_free(h C.uintptr_t) {
+	in := get(h)
+	if in.err != nil {
+		C.free(unsafe.Pointer(in.err))
+	}
+	cgo.Handle(h).Delete()
+}
+
+//export gemu_error
+func gemu_error(h C.uintptr_t) *C.char {
+	return get(h).err
+}
+
+//export gemu_load_rom
+func gemu_load_rom(h C.uintptr_t, path *C.char) C.int {
+	in := get(h)
+	if err := in.emu.LoadROM(C.GoString(path)); err != nil {
+		return in.fail(err)
+	}
+	return 0
+}
+
+//export gemu_soft_reset
+func gemu_soft_reset(h C.uintptr_t) {
+	get(h).emu.SoftReset()
+}
+
+//export gemu_power_cycle
+func gemu_power_cycle(h C.uintptr_t) C.int {
+	in := get(h)
+	if err := in.emu.PowerCycle(); err != nil {
+		return in.fail(err)
+	}
+	return 0
+}
+
+//export gemu_step
+func gemu_step(h C.uintptr_t) C.int {
+	in := get(h)
+	if err := in.emu.Step(); err != nil {
+		return in.fail(err)
+	}
+	return 0
+}
+
+//export gemu_run_frame
+func gemu_run_frame(h C.uintptr_t) C.int {
+	in := get(h)
+	if err := in.emu.RunFrame(); err != nil {
+		return in.fail(err)
+	}
+	return 0
+}
+
+//export gemu_read
+func gemu_read(h C.uintptr_t, addr C.uint16_t, buf *C.uint8_t, n C.int) {
+	emu := get(h).emu
+	out := unsafe.Slice((*byte)(buf), int(n))
+	for i := range out {
+		out[i] = emu.PeekMemory(uint16(addr) + uint16(i))
+	}
+}
+
+//export gemu_write
+func gemu_write(h C.uintptr_t, addr C.uint16_t, buf *C.uint8_t, n C.int) {
+	cpu := get(h).emu.CPU()
+	for i, b := range unsafe.Slice((*byte)(buf), int(n)) {
+		cpu.Store(uint16(addr)+uint16(i), b)
+	}
+}
+
+//export gemu_pc
+func gemu_pc(h C.uintptr_t) C.uint16_t {
+	return C.uint16_t(get(h).emu.CPU().GetPC())
+}
+
+//export gemu_instructions
+func gemu_instructions(h C.uintptr_t) C.uint64_t {
+	return C.uint64_t(get(h).emu.Counter())
+}
+
+//export gemu_frame
+func gemu_frame(h C.uintptr_t) C.uint64_t {
+	return C.uint64_t(get(h).emu.Frame())
+}
+
+//export gemu_set_input
+func gemu_set_input(h C.uintptr_t, port C.int, buttons C.uint32_t) {
+	get(h).emu.SetInput(int(port), uint32(buttons))
+}
+
+// gemu_set_position sets the knob of a paddle or similar device, from 0
+// (left) to 1 (right).
+//
+//export gemu_set_position
+func gemu_set_position(h C.uintptr_t, port C.int, x C.double) {
+	get(h).emu.SetPosition(int(port), float64(x))
+}
+
+// gemu_framebuffer copies the current frame, 256x240 RGBA, into buf, which
+// must hold gemu_framebuffer_size() bytes.
+//
+//export gemu_framebuffer
+func gemu_framebuffer(h C.uintptr_t, buf *C.uint8_t) {
+	copy(unsafe.Slice((*byte)(buf), gemu.ScreenWidth*gemu.ScreenHeight*4), get(h).emu.Framebuffer().Pix)
+}
+
+//export gemu_framebuffer_size
+func gemu_framebuffer_size() C.int {
+	return gemu.ScreenWidth * gemu.ScreenHeight * 4
+}
+
+func main() {}
@@ -0,0 +1,47 @@
+package cpu
+
+// AccessKind is the kind of a bus access.
+type AccessKind uint8
+
+const (
+	// AccessFetch is a read of an opcode or operand byte at the pc
+	AccessFetch AccessKind = iota
+	AccessRead
+	AccessWrite
+)
+
+func (k AccessKind) String() string {
+	switch k {
+	case AccessFetch:
+		return "F"
+	case AccessRead:
+		return "R"
+	case AccessWrite:
+		return "W"
+	}
+	return "?"
+}
+
+// Access is one cpu bus access. Instructions run whole, so Cycle is the
+// cycle the instruction started on rather than that of the access itself.
+type Access struct {
+	Cycle uint64
+	// PC is the address of the instruction making the access
+	PC    uint16
+	Addr  uint16
+	Value uint8
+	Kind  AccessKind
+}
+
+// AccessHook is called for every bus access the cpu makes, including stack
+// pushes and pulls. Debugger reads through Pages() are not seen.
+type AccessHook func(Access)
+
+// SetAccessHook starts reporting accesses to h, or stops if h is nil.
+func (cpu *CPU) SetAccessHook(h AccessHook) {
+	cpu.onAccess = h
+}
+
+func (cpu *CPU) access(addr uint16, v uint8, kind AccessKind) {
+	cpu.onAccess(Access{Cycle: cpu.TotalCycles, PC: cpu.opPC, Addr: addr, Value: v, Kind: kind})
+}
@@ -0,0 +1,152 @@
+package cpu
+
+// operandLength is the number of operand bytes after the opcode in each
+// addressing mode.
+var operandLength = [...]int{
+	Absolute:    2,
+	AbsoluteX:   2,
+	AbsoluteY:   2,
+	Immediate:   1,
+	ZeroPage:    1,
+	ZeroPageX:   1,
+	ZeroPageY:   1,
+	Implicit:    0,
+	Relative:    1,
+	Accumulator: 0,
+	IndirectX:   1,
+	IndirectY:   1,
+	Indirect:    2,
+}
+
+// Operand is an instruction's operand as its addressing mode resolves it.
+// It is worked out once, before the operation runs, so operations only
+// deal with a value or an address.
+type Operand struct {
+	Mode uint8
+	// Base is what the instruction itself holds: the immediate byte, the
+	// zero page or absolute address before indexing, the address of the
+	// pointer for the indirect modes, or the branch offset.
+	Base uint16
+	// Pointer is where an indirect mode's pointer was: for IndirectX the
+	// zero page address it is read from once X is added, and for
+	// IndirectY the address it holds before Y is added.
+	Pointer uint16
+	// Address is the effective address, which the operand is read from or
+	// written to, or the jump or branch target.
+	Address uint16
+	// Crossed is whether indexing, or a branch, moved Address onto another
+	// page than the one it started from.
+	Crossed bool
+	// Value is the immediate byte, A for Accumulator, or the byte at
+	// Address before the instruction ran, if it reads or writes there.
+	Value uint8
+}
+
+// resolve fetches the operand bytes of an instruction in mode, with the pc
+// just past the opcode, and works out the effective address into o. It
+// reads the pointers of the indirect modes but not the operand itself,
+// which loads, stores and jumps each treat differently.
+func (cpu *CPU) resolve(o *Operand, mode uint8) {
+	*o = Operand{Mode: mode}
+	switch mode {
+	case Immediate:
+		o.Address = cpu.pc
+		o.Value = cpu.Fetch()
+		o.Base = uint16(o.Value)
+	case Accumulator:
+		o.Value = cpu.A.GetValue()
+	case ZeroPage:
+		o.Base = uint16(cpu.Fetch())
+		o.Address = o.Base
+	case ZeroPageX, ZeroPageY:
+		zp := cpu.Fetch()
+		o.Base = uint16(zp)
+		// indexing wraps within the zero page
+		if mode == ZeroPageX {
+			zp += cpu.X.GetValue()
+		} else {
+			zp += cpu.Y.GetValue()
+		}
+		o.Address = uint16(zp)
+	case Absolute:
+		o.Base = cpu.Fetch16()
+		o.Address = o.Base
+	case AbsoluteX, AbsoluteY:
+		o.Base = cpu.Fetch16()
+		if mode == AbsoluteX {
+			o.Address = o.Base + uint16(cpu.X.GetValue())
+		} else {
+			o.Address = o.Base + uint16(cpu.Y.GetValue())
+		}
+		o.Crossed = PageCrossed(o.Base, o.Address)
+	case IndirectX:
+		zp := cpu.Fetch()
+		o.Base = uint16(zp)
+		zp += cpu.X.GetValue()
+		o.Pointer = uint16(zp)
+		o.Address = cpu.zeroPagePointer(zp)
+	case IndirectY:
+		zp := cpu.Fetch()
+		o.Base = uint16(zp)
+		o.Pointer = cpu.zeroPagePointer(zp)
+		o.Address = o.Pointer + uint16(cpu.Y.GetValue())
+		o.Crossed = PageCrossed(o.Pointer, o.Address)
+	case Indirect:
+		o.Base = cpu.Fetch16()
+		// the 6502 doesn't carry into the high byte of the pointer's
+		// address, so JMP ($10FF) reads its target from $10FF and $1000
+		hi := o.Base&0xFF00 | uint16(uint8(o.Base)+1)
+		o.Address = ToAddress(cpu.FetchAddress(hi), cpu.FetchAddress(o.Base))
+	case Relative:
+		offset := cpu.Fetch()
+		o.Base = uint16(offset)
+		o.Address = cpu.pc + uint16(int8(offset))
+		o.Crossed = PageCrossed(cpu.pc, o.Address)
+	}
+}
+
+// zeroPagePointer reads the little endian pointer at zp, wrapping within
+// the zero page.
+func (cpu *CPU) zeroPagePointer(zp uint8) uint16 {
+	lo := cpu.FetchAddress(uint16(zp))
+	hi := cpu.FetchAddress(uint16(zp + 1))
+	return ToAddress(hi, lo)
+}
+
+// appendDetails appends the operand column of the trace, in nestest's
+// format. memory says whether the instruction reads or writes at the
+// address, which is then followed by the byte there.
+func (o *Operand) appendDetails(b []byte, memory bool) []byte {
+	switch o.Mode {
+	case Immediate:
+		return appendHexf(b, "#$%02X", o.Base)
+	case Accumulator:
+		return append(b, 'A')
+	case Relative:
+		return appendHexf(b, "$%04X", o.Address)
+	case Indirect:
+		return appendHexf(b, "($%04X) = %04X", o.Base, o.Address)
+	case ZeroPage:
+		b = appendHexf(b, "$%02X", o.Base)
+	case ZeroPageX:
+		b = appendHexf(b, "$%02X,X @ %02X", o.Base, o.Address)
+	case ZeroPageY:
+		b = appendHexf(b, "$%02X,Y @ %02X", o.Base, o.Address)
+	case Absolute:
+		b = appendHexf(b, "$%04X", o.Base)
+	case AbsoluteX:
+		b = appendHexf(b, "$%04X,X @ %04X", o.Base, o.Address)
+	case AbsoluteY:
+		b = appendHexf(b, "$%04X,Y @ %04X", o.Base, o.Address)
+	case IndirectX:
+		b = appendHexf(b, "($%02X,X) @ %02X = %04X", o.Base, o.Pointer, o.Address)
+	case IndirectY:
+		b = appendHexf(b, "($%02X),Y = %04X @ %04X", o.Base, o.Pointer, o.Address)
+	default:
+		return b
+	}
+	if !memory {
+		return b
+	}
+	return appendHexf(b, " = %02X", uint16(o.Value))
+}
@@ -0,0 +1,84 @@
+package cpu
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		mode    uint8
+		org     uint16
+		operand []byte
+		a, x, y uint8
+		want    Operand
+		// where the pc should be after the operand bytes
+		pc uint16
+	}{
+		{"immediate", Immediate, 0x8000, []byte{0x42}, 0, 0, 0,
+			Operand{Base: 0x42, Address: 0x8001, Value: 0x42}, 0x8002},
+		{"accumulator", Accumulator, 0x8000, nil, 0x37, 0, 0,
+			Operand{Value: 0x37}, 0x8001},
+		{"zero page", ZeroPage, 0x8000, []byte{0x80}, 0, 0, 0,
+			Operand{Base: 0x80, Address: 0x0080}, 0x8002},
+		{"zero page,X", ZeroPageX, 0x8000, []byte{0x80}, 0, 0x05, 0,
+			Operand{Base: 0x80, Address: 0x0085}, 0x8002},
+		// indexing stays in the zero page
+		{"zero page,X wraps", ZeroPageX, 0x8000, []byte{0xF0}, 0, 0x20, 0,
+			Operand{Base: 0xF0, Address: 0x0010}, 0x8002},
+		{"zero page,Y wraps", ZeroPageY, 0x8000, []byte{0xFF}, 0, 0, 0x02,
+			Operand{Base: 0xFF, Address: 0x0001}, 0x8002},
+		{"absolute", Absolute, 0x8000, []byte{0x34, 0x12}, 0, 0, 0,
+			Operand{Base: 0x1234, Address: 0x1234}, 0x8003},
+		{"absolute,X", AbsoluteX, 0x8000, []byte{0xF0, 0x12}, 0, 0x0F, 0,
+			Operand{Base: 0x12F0, Address: 0x12FF}, 0x8003},
+		{"absolute,X crossing", AbsoluteX, 0x8000, []byte{0xF0, 0x12}, 0, 0x10, 0,
+			Operand{Base: 0x12F0, Address: 0x1300, Crossed: true}, 0x8003},
+		// indexing past $FFFF wraps to the zero page
+		{"absolute,Y wraps", AbsoluteY, 0x8000, []byte{0xFF, 0xFF}, 0, 0, 0x02,
+			Operand{Base: 0xFFFF, Address: 0x0001, Crossed: true}, 0x8003},
+		// ($20,X) reads the pointer at $24
+		{"(zero page,X)", IndirectX, 0x8000, []byte{0x20}, 0, 0x04, 0,
+			Operand{Base: 0x20, Pointer: 0x24, Address: 0x0400}, 0x8002},
+		// ($FE,X) with X=1 reads its pointer from $FF and $00
+		{"(zero page,X) wraps", IndirectX, 0x8000, []byte{0xFE}, 0, 0x01, 0,
+			Operand{Base: 0xFE, Pointer: 0xFF, Address: 0x0502}, 0x8002},
+		{"(zero page),Y", IndirectY, 0x8000, []byte{0x20}, 0, 0, 0x0F,
+			Operand{Base: 0x20, Pointer: 0x10F0, Address: 0x10FF}, 0x8002},
+		{"(zero page),Y crossing", IndirectY, 0x8000, []byte{0x20}, 0, 0, 0x10,
+			Operand{Base: 0x20, Pointer: 0x10F0, Address: 0x1100, Crossed: true}, 0x8002},
+		// ($FF),Y reads its pointer from $FF and $00
+		{"(zero page),Y wraps", IndirectY, 0x8000, []byte{0xFF}, 0, 0, 0x01,
+			Operand{Base: 0xFF, Pointer: 0x0502, Address: 0x0503}, 0x8002},
+		{"indirect", Indirect, 0x8000, []byte{0x20, 0x00}, 0, 0, 0,
+			Operand{Base: 0x0020, Address: 0x10F0}, 0x8003},
+		{"indirect page end", Indirect, 0x8000, []byte{0xFF, 0x02}, 0, 0, 0,
+			Operand{Base: 0x02FF, Address: 0x3412}, 0x8003},
+		// offsets count from the next instruction
+		{"relative forward", Relative, 0x8000, []byte{0x10}, 0, 0, 0,
+			Operand{Base: 0x10, Address: 0x8012}, 0x8002},
+		{"relative back crossing", Relative, 0x8000, []byte{0xFC}, 0, 0, 0,
+			Operand{Base: 0xFC, Address: 0x7FFE, Crossed: true}, 0x8002},
+		{"relative forward crossing", Relative, 0x80F0, []byte{0x10}, 0, 0, 0,
+			Operand{Base: 0x10, Address: 0x8102, Crossed: true}, 0x80F2},
+	} {
+		// the opcode byte itself isn't looked at
+		c, bus := newBus(tc.org, append([]byte{0xEA}, tc.operand...)...)
+		bus[0x20], bus[0x21] = 0xF0, 0x10
+		bus[0x24], bus[0x25] = 0x00, 0x04
+		bus[0xFF], bus[0x00] = 0x02, 0x05
+		bus[0x02FF], bus[0x0200], bus[0x0300] = 0x12, 0x34, 0x99
+		c.A.SetRegister(tc.a)
+		c.X.SetRegister(tc.x)
+		c.Y.SetRegister(tc.y)
+		c.SetPC(tc.org + 1)
+
+		var o Operand
+		c.resolve(&o, tc.mode)
+		tc.want.Mode = tc.mode
+		if o != tc.want {
+			t.Errorf("%s: resolved %+v, want %+v", tc.name, o, tc.want)
+		}
+		if pc := c.GetPC(); pc != tc.pc {
+			t.Errorf("%s: pc $%04X after the operand, want $%04X", tc.name, pc, tc.pc)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cpu
+
+// Bus is what the cpu reads and writes memory through: fetches, operands,
+// the stack and the interrupt vectors. A bus may also have a
+// Peek(addr uint16) uint8 method reading without side effects, which the
+// trace uses to show what a store overwrote; without one, it shows $FF.
+type Bus interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, v uint8)
+}
+
+// TestBus is a flat 64kb of RAM with nothing mapped over it, for driving
+// the cpu outside the emulator, e.g. against a reference core or a test
+// program.
+type TestBus [0x10000]uint8
+
+func (b *TestBus) Read(addr uint16) uint8 {
+	return b[addr]
+}
+
+func (b *TestBus) Write(addr uint16, v uint8) {
+	b[addr] = v
+}
+
+func (b *TestBus) Peek(addr uint16) uint8 {
+	return b[addr]
+}
+
+// SetBus makes the cpu access memory through b instead of its page table.
+// A nil b goes back to the page table. The bus is kept across Reset.
+func (cpu *CPU) SetBus(b Bus) {
+	if cpu.cache != nil {
+		cpu.cache = &decodeCache{}
+	}
+	cpu.pre = nil
+	cpu.bus = b
+}
+
+// Bus returns the bus the cpu accesses memory through: the one given to
+// SetBus, or else its page table.
+func (cpu *CPU) Bus() Bus {
+	if cpu.bus == nil {
+		return cpu.pages
+	}
+	return cpu.bus
+}
+
+// read and write go to the bus if one is set, and straight to the page
+// table otherwise, which saves the emulator an interface call per access.
+func (cpu *CPU) read(addr uint16) uint8 {
+	if cpu.bus == nil {
+		return cpu.pages.Read(addr)
+	}
+	return cpu.bus.Read(addr)
+}
+
+func (cpu *CPU) write(addr uint16, v uint8) {
+	if cpu.bus == nil {
+		cpu.pages.Write(addr, v)
+		return
+	}
+	cpu.bus.Write(addr, v)
+}
+
+// peek reads addr without side effects, see Bus.
+func (cpu *CPU) peek(addr uint16) uint8 {
+	if cpu.bus == nil {
+		return cpu.pages.peek(addr)
+	}
+	if p, ok := cpu.bus.(interface{ Peek(addr uint16) uint8 }); ok {
+		return p.Peek(addr)
+	}
+	return 0xFF
+}
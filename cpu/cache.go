@@ -0,0 +1,138 @@
+package cpu
+
+const (
+	cacheBase     = 0x8000
+	cacheBankSize = 0x2000
+	cacheBanks    = (0x10000 - cacheBase) / cacheBankSize
+)
+
+// decoded is an instruction as it was decoded at an address: its entry in
+// the opcode table, and its opcode and operand bytes, Length of them.
+type decoded struct {
+	ins   *Instruction
+	bytes [3]byte
+}
+
+// decodeCache holds the instruction decoded at each PRG address
+// ($8000-$FFFF) in 8kb banks, with its operand bytes, so executing code
+// that has run before reads neither opcode nor operand from the bus. Code
+// running from RAM, or from pages mapped to a handler, is not cached.
+type decodeCache struct {
+	banks [cacheBanks]*[cacheBankSize]decoded
+}
+
+func (c *decodeCache) get(addr uint16) *decoded {
+	if addr < cacheBase {
+		return nil
+	}
+	bank := c.banks[(addr-cacheBase)/cacheBankSize]
+	if bank == nil {
+		return nil
+	}
+	d := &bank[(addr-cacheBase)%cacheBankSize]
+	if d.ins == nil {
+		return nil
+	}
+	return d
+}
+
+func (c *decodeCache) put(addr uint16, d decoded) {
+	if addr < cacheBase {
+		return
+	}
+	i := (addr - cacheBase) / cacheBankSize
+	if c.banks[i] == nil {
+		c.banks[i] = new([cacheBankSize]decoded)
+	}
+	c.banks[i][(addr-cacheBase)%cacheBankSize] = d
+}
+
+// invalidate drops the instructions a write to addr changes: any that
+// starts there, or up to two bytes before with an operand there.
+func (c *decodeCache) invalidate(addr uint16) {
+	if addr < cacheBase {
+		return
+	}
+	for a := int(addr) - 2; a <= int(addr); a++ {
+		if a < cacheBase {
+			continue
+		}
+		if bank := c.banks[(a-cacheBase)/cacheBankSize]; bank != nil {
+			bank[(a-cacheBase)%cacheBankSize].ins = nil
+		}
+	}
+}
+
+// invalidateRange drops every bank overlapping start-end inclusive, or
+// holding an instruction whose operand is in it.
+func (c *decodeCache) invalidateRange(start, end uint16) {
+	if end < cacheBase {
+		return
+	}
+	if start < cacheBase+2 {
+		start = cacheBase
+	} else {
+		start -= 2
+	}
+	for i := (start - cacheBase) / cacheBankSize; i <= (end-cacheBase)/cacheBankSize; i++ {
+		c.banks[i] = nil
+	}
+}
+
+// EnableDecodeCache turns on caching of decoded PRG instructions. Writes
+// made through Store invalidate the cached entries they change; a mapper
+// switching banks must call InvalidateDecodeCache for the window it changed.
+func (cpu *CPU) EnableDecodeCache() {
+	if cpu.cache == nil {
+		cpu.cache = &decodeCache{}
+	}
+}
+
+// InvalidateDecodeCache drops cached instructions between start and end
+// inclusive, e.g. after a PRG bank switch.
+func (cpu *CPU) InvalidateDecodeCache(start, end uint16) {
+	if cpu.cache != nil {
+		cpu.cache.invalidateRange(start, end)
+	}
+}
+
+// Decode returns the instruction at the current pc without advancing it,
+// and false if the opcode there is not implemented. Once it is cached, the
+// Fetch calls that follow take its bytes from the cache.
+func (cpu *CPU) Decode() (*Instruction, bool) {
+	cpu.pre = nil
+	if cpu.cache != nil {
+		if d := cpu.cache.get(cpu.pc); d != nil {
+			cpu.pre = d
+			return d.ins, true
+		}
+	}
+
+	ins := table[cpu.read(cpu.pc)]
+	if ins == nil {
+		return nil, false
+	}
+	if cpu.cache != nil {
+		if d, ok := cpu.predecode(ins); ok {
+			cpu.cache.put(cpu.pc, d)
+		}
+	}
+	return ins, true
+}
+
+// predecode reads the bytes of ins at pc for the cache, if they are all in
+// pages backed by memory, which can be read ahead without side effects.
+func (cpu *CPU) predecode(ins *Instruction) (decoded, bool) {
+	d := decoded{ins: ins}
+	if cpu.bus != nil {
+		return d, false
+	}
+	for i := 0; i < ins.Length; i++ {
+		a := cpu.pc + uint16(i)
+		if a < cpu.pc || cpu.pages.mem[a>>8] == nil {
+			return d, false
+		}
+		d.bytes[i] = cpu.pages.Read(a)
+	}
+	return d, true
+}
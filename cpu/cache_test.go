@@ -0,0 +1,79 @@
+package cpu
+
+import "testing"
+
+// loop is a short PRG loop of loads, arithmetic and a store, for timing
+// Step: LDA $0200,X; ADC #$01; STA $10; LDA ($10),Y; INX; BNE; JMP $8000.
+var loop = []byte{
+	0xBD, 0x00, 0x02,
+	0x69, 0x01,
+	0x85, 0x10,
+	0xB1, 0x10,
+	0xE8,
+	0xD0, 0xF4,
+	0x4C, 0x00, 0x80,
+}
+
+// newPRG returns a cpu on its page table with code at $8000, where the
+// decode cache works, and the pc there.
+func newPRG(code []byte, cache bool) *CPU {
+	c := &CPU{}
+	c.Reset()
+	if cache {
+		c.EnableDecodeCache()
+	}
+	copy(c.GetMemory()[0x8000:], code)
+	c.SetPC(0x8000)
+	return c
+}
+
+func BenchmarkStep(b *testing.B) {
+	for _, bc := range []struct {
+		name  string
+		cache bool
+	}{{"uncached", false}, {"cached", true}} {
+		b.Run(bc.name, func(b *testing.B) {
+			c := newPRG(loop, bc.cache)
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Step(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeCacheRunsWhatMemoryHolds(t *testing.T) {
+	for _, cache := range []bool{false, true} {
+		c := newPRG(loop, cache)
+		for i := 0; i < 100; i++ {
+			if _, err := c.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// rewrite the immediate operand of the ADC, and then its opcode,
+		// of code the cache has seen
+		c.Store(0x8004, 0x10)
+		c.SetPC(0x8003)
+		r, err := c.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Operand.Value != 0x10 {
+			t.Errorf("cache %v: ADC operand after store = $%02X, want $10", cache, r.Operand.Value)
+		}
+		c.Store(0x8003, 0x29)
+		c.SetPC(0x8003)
+		if r, _ := c.Step(); r.Mnemonic != "AND" {
+			t.Errorf("cache %v: instruction after store = %s, want AND", cache, r.Mnemonic)
+		}
+
+		// a bank switch replaces memory without a store
+		copy(c.GetMemory()[0x8003:], []byte{0x09, 0x20})
+		c.InvalidateDecodeCache(0x8000, 0x9FFF)
+		c.SetPC(0x8003)
+		if r, _ := c.Step(); r.Mnemonic != "ORA" || r.Operand.Value != 0x20 {
+			t.Errorf("cache %v: after invalidating, got %s #$%02X, want ORA #$20", cache, r.Mnemonic, r.Operand.Value)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package cpu
 
 import (
 	"fmt"
+	"log/slog"
+	"strconv"
 
 	"github.com/goldmane/gemu/gemu"
 )
@@ -46,19 +48,58 @@ type CPU struct {
 
 	Flags gemu.CpuFlag
 
-	TempValue        uint8
-	TempValue16      uint16
-	TempAddress      uint16
-	TempAddress_2    uint16
-	TempAddressValue uint8
-	PrevPC           uint16
+	// PPUPosition returns the scanline and dot of the PPU for the trace's
+	// PPU column. If it is nil the column is worked out from TotalCycles
+	// with Region's cpu:ppu ratio, as if the PPU started with the cpu.
+	PPUPosition func() (scanline, dot int)
+	Region      gemu.Region
+	// PollNMI, if set, reports whether NMI will be asserted within the
+	// given number of cycles from the start of the current instruction, so
+	// that one arriving part way through BRK or an IRQ can hijack it. An
+	// NMI it reports is taken then, and its edge must not be passed on
+	// again with TriggerNMI.
+	PollNMI func(cycles uint64) bool
+
+	PrevPC uint16
 
 	DetailsOverride string
 
-	CyclesRemaining uint8
-	TotalCycles     uint64
+	TotalCycles uint64
+
+	// Logger receives the output of the debugging helpers. If nil,
+	// slog.Default() is used.
+	Logger *slog.Logger
+
+	// raw bytes of the current instruction, for the trace
+	fetched  [3]byte
+	nfetched int
+	// operand of the current instruction, for the trace
+	operand Operand
 
 	memory []byte
+	pages  *PageTable
+	bus    Bus
+	cache  *decodeCache
+	// the cached instruction being executed, which Fetch takes its bytes
+	// from, or nil
+	pre  *decoded
+	heat *Heatmap
+
+	// pending NMI and the level of the IRQ line
+	nmi bool
+	irq bool
+
+	onInterrupt InterruptHook
+	onAccess    AccessHook
+	// pc of the instruction being executed, for onAccess
+	opPC uint16
+}
+
+func (cpu *CPU) logger() *slog.Logger {
+	if cpu.Logger == nil {
+		return slog.Default()
+	}
+	return cpu.Logger
 }
 
 func (cpu *CPU) Reset() {
@@ -69,17 +110,28 @@ func (cpu *CPU) Reset() {
 	cpu.Y = Register{value: 0x00, previous: 0x00}
 
 	cpu.TotalCycles = 7 // starting value
+	cpu.nmi, cpu.irq = false, false
 
-	// init the memory
+	// init the memory, flat until something maps pages over it
 	cpu.memory = make([]byte, 64*1024)
+	cpu.pages = &PageTable{}
+	cpu.pages.MapMemory(0x00, 256, cpu.memory, true)
+	if cpu.cache != nil {
+		cpu.cache = &decodeCache{}
+	}
+	cpu.pre = nil
 
 	// init the flags
 	cpu.Flags.Reset()
 }
 
+// LoadCartridge copies the cartridge space ($6000-$FFFF) as its mapper
+// presents it into memory.
 func (cpu *CPU) LoadCartridge(c gemu.Cartridge) {
-	copy(cpu.memory[0x8000:], c.PRG)
-	copy(cpu.memory[0xC000:], c.PRG)
+	for addr := 0x6000; addr <= 0xFFFF; addr++ {
+		cpu.memory[addr] = c.Mapper.CPURead(uint16(addr))
+	}
+	cpu.InvalidateDecodeCache(0x6000, 0xFFFF)
 }
 
 func (cpu *CPU) SetPC(v uint16) {
@@ -91,41 +143,95 @@ func (cpu *CPU) GetPC() uint16 {
 	return cpu.pc
 }
 
-func (cpu *CPU) Fetch() (uint8, string) {
-	cpu.TempAddress = uint16(0x0)<<8 | uint16(cpu.memory[cpu.pc])
-	p := fmt.Sprintf("%02X ", cpu.TempAddress)
+// Fetch reads the byte at pc and advances it. The bytes fetched since the
+// last ClearFetched are kept for the trace.
+func (cpu *CPU) Fetch() uint8 {
+	if cpu.heat != nil && cpu.nfetched == 0 {
+		cpu.heat.Execs[cpu.pc]++
+	}
+	if cpu.nfetched == 0 {
+		cpu.opPC = cpu.pc
+	}
+	var v uint8
+	if cpu.pre != nil && cpu.nfetched < len(cpu.pre.bytes) {
+		v = cpu.pre.bytes[cpu.nfetched]
+	} else {
+		v = cpu.read(cpu.pc)
+	}
+	if cpu.onAccess != nil {
+		cpu.access(cpu.pc, v, AccessFetch)
+	}
+	if cpu.nfetched < len(cpu.fetched) {
+		cpu.fetched[cpu.nfetched] = v
+		cpu.nfetched++
+	}
 	cpu.PrevPC = cpu.pc
 	cpu.pc++
-	return uint8(cpu.TempAddress & 0xFF), p
+	return v
 }
 
-func (cpu *CPU) Fetch16() (uint16, string) {
-	low, ls := cpu.Fetch()
-	high, hs := cpu.Fetch()
-	cpu.TempAddress = uint16(high)<<8 | uint16(low)
-	return cpu.TempAddress, (ls + hs + " ")
+func (cpu *CPU) Fetch16() uint16 {
+	low := cpu.Fetch()
+	high := cpu.Fetch()
+	return ToAddress(high, low)
+}
+
+// Fetched returns the bytes fetched for the current instruction.
+func (cpu *CPU) Fetched() []byte {
+	return cpu.fetched[:cpu.nfetched]
+}
+
+// ClearFetched starts recording the bytes of a new instruction.
+func (cpu *CPU) ClearFetched() {
+	cpu.nfetched = 0
 }
 
 func (cpu *CPU) FetchAddress(addr uint16) uint8 {
-	return cpu.memory[addr]
+	if cpu.heat != nil {
+		cpu.heat.Reads[addr]++
+	}
+	v := cpu.read(addr)
+	if cpu.onAccess != nil {
+		cpu.access(addr, v, AccessRead)
+	}
+	return v
 }
 
 func (cpu *CPU) Store(addr uint16, v uint8) {
-	cpu.memory[addr] = v
+	if cpu.heat != nil {
+		cpu.heat.Writes[addr]++
+	}
+	cpu.write(addr, v)
+	if cpu.onAccess != nil {
+		cpu.access(addr, v, AccessWrite)
+	}
+	if cpu.cache != nil {
+		cpu.cache.invalidate(addr)
+	}
 }
 
 func (cpu *CPU) StackPush(v uint8) {
 	a := uint16(0x0100) | uint16(cpu.SP)
-	// cpu.memory[cpu.SP] = v
-	cpu.memory[a] = v
+	if cpu.heat != nil {
+		cpu.heat.Writes[a]++
+	}
+	cpu.write(a, v)
+	if cpu.onAccess != nil {
+		cpu.access(a, v, AccessWrite)
+	}
 	cpu.SP--
 }
 
 func (cpu *CPU) StackPop() uint8 {
 	cpu.SP++
 	a := uint16(0x0100) | uint16(cpu.SP)
-	// r := cpu.memory[cpu.SP]
-	r := cpu.memory[a]
+	if cpu.heat != nil {
+		cpu.heat.Reads[a]++
+	}
+	r := cpu.read(a)
+	if cpu.onAccess != nil {
+		cpu.access(a, r, AccessRead)
+	}
 	return r
 }
 
@@ -147,49 +253,82 @@ const (
 )
 
 func (cpu CPU) PrintDetails(addressMode uint8, counter uint64) string {
+	return string(cpu.AppendState(nil))
+}
 
-	r1 := (func(addressMode uint8) string {
-		var a, x, y uint8
-
-		a = cpu.A.GetValue()
-		x = cpu.X.GetValue()
-		y = cpu.Y.GetValue()
-
-		return fmt.Sprintf("A:%02X X:%02X Y:%02X", a, x, y)
-	})(addressMode)
-
-	// figure the ppu values
-	t3 := cpu.TotalCycles * 3
-	ppu1 := t3 / 341
-	ppu2 := t3 % 341
+// AppendState appends the register and cycle columns of the trace:
+// A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7
+func (cpu *CPU) AppendState(b []byte) []byte {
+	var ppu1, ppu2 uint64
+	if cpu.PPUPosition != nil {
+		scanline, dot := cpu.PPUPosition()
+		ppu1, ppu2 = uint64(scanline), uint64(dot)
+	} else {
+		region := cpu.Region
+		if region.PPUDivider == 0 {
+			region = gemu.NTSC
+		}
+		dots := cpu.TotalCycles * region.CPUDivider / region.PPUDivider
+		ppu1 = dots / uint64(region.DotsPerScanline)
+		ppu2 = dots % uint64(region.DotsPerScanline)
+	}
 
-	// print registers
-	b := fmt.Sprintf("P:%02X SP:%02X PPU:%3d,%3d", cpu.Flags.Value(), cpu.SP, ppu1, ppu2)
-	c := fmt.Sprintf("CYC:%d", cpu.TotalCycles)
+	b = appendHexf(b, "A:%02X X:%02X Y:%02X P:%02X SP:%02X PPU:",
+		uint16(cpu.A.GetValue()), uint16(cpu.X.GetValue()), uint16(cpu.Y.GetValue()),
+		uint16(cpu.Flags.Value()), uint16(cpu.SP))
+	b = appendPadded(b, ppu1, 3)
+	b = append(b, ',')
+	b = appendPadded(b, ppu2, 3)
+	b = append(b, " CYC:"...)
+	return strconv.AppendUint(b, cpu.TotalCycles, 10)
+}
 
-	// return fmt.Sprintf("%-28s%s %s %s", d, r1, b, c)
-	return fmt.Sprintf("%s %s %s", r1, b, c)
+// Pages returns the cpu's memory map, so regions of it can be remapped to
+// other memory or to register handlers. The cpu doesn't use it while
+// SetBus has given it another bus.
+func (cpu *CPU) Pages() *PageTable {
+	return cpu.pages
 }
 
+// GetMemory returns the flat 64kb memory behind the page table. Pages that
+// have been remapped no longer read or write it, and nothing does while
+// SetBus has given the cpu another bus.
 func (cpu CPU) GetMemory() []byte {
 	return cpu.memory
 }
 
+// FindInMemory logs every address holding v at debug level.
+//
+// Deprecated: dump memory with gemu dump-mem or debug.Debugger.Dump and
+// search the dump offline.
 func (cpu CPU) FindInMemory(v uint8) {
-	fmt.Printf("\nLooking for %02X:\n", v)
+	log := cpu.logger()
 	for i := 0; i < len(cpu.memory); i++ {
 		if cpu.memory[i] == v {
-			fmt.Printf("%04X\n", i)
+			log.Debug("found in memory", "value", fmt.Sprintf("%02X", v), "addr", fmt.Sprintf("%04X", i))
 		}
 	}
 }
 
-func (cpu CPU) PrintStack() {
-	start := uint16(0x01FD)
-	end := (uint16(0x0100) | uint16(cpu.SP)) - 1
-	fmt.Printf("\nStack from 0x01FD to 0x%04X:\n", end)
-	for i := start; i >= end; i -= 0x01 {
-		fmt.Printf("0x%04X: 0x%02X\n", i, cpu.memory[i])
+// Stack returns the bytes on the stack, from the top (SP+1) to $01FF.
+// It reads without side effects.
+func (cpu *CPU) Stack() []byte {
+	var b []byte
+	for a := 0x0100 + int(cpu.SP) + 1; a <= 0x01FF; a++ {
+		b = append(b, cpu.peek(uint16(a)))
+	}
+	return b
+}
+
+// PrintStack logs the bytes on the stack at debug level.
+//
+// Deprecated: debug.Debugger.StackEntries tells return addresses, pushed
+// status and data apart.
+func (cpu *CPU) PrintStack() {
+	log := cpu.logger()
+	top := 0x0100 + uint16(cpu.SP) + 1
+	log.Debug("stack", "from", fmt.Sprintf("%04X", top), "to", "01FF")
+	for i, v := range cpu.Stack() {
+		log.Debug("stack entry", "addr", fmt.Sprintf("%04X", top+uint16(i)), "value", fmt.Sprintf("%02X", v))
 	}
-	fmt.Println()
 }
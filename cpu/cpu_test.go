@@ -0,0 +1,188 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/goldmane/gemu/gemu"
+)
+
+// newBus returns a cpu on a TestBus with code at org and the pc there.
+func newBus(org uint16, code ...byte) (*CPU, *TestBus) {
+	bus := new(TestBus)
+	copy(bus[org:], code)
+	c := &CPU{}
+	c.Reset()
+	c.SetBus(bus)
+	c.SetPC(org)
+	return c, bus
+}
+
+func TestJMPIndirectWrapsInPage(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		pointer uint16
+		mem     map[uint16]uint8
+		want    uint16
+	}{
+		{"in page", 0x10FE, map[uint16]uint8{0x10FE: 0x12, 0x10FF: 0x34}, 0x3412},
+		// the high byte comes from $1000, not $1100
+		{"page end", 0x10FF, map[uint16]uint8{0x10FF: 0x12, 0x1000: 0x56, 0x1100: 0x99}, 0x5612},
+	} {
+		c, bus := newBus(0x8000, 0x6C, uint8(tc.pointer), uint8(tc.pointer>>8))
+		for a, v := range tc.mem {
+			bus[a] = v
+		}
+		if _, err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+		if got := c.GetPC(); got != tc.want {
+			t.Errorf("%s: JMP ($%04X) went to $%04X, want $%04X", tc.name, tc.pointer, got, tc.want)
+		}
+	}
+}
+
+func TestPageCrossCycles(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		org  uint16
+		code []byte
+		x, y uint8
+		want uint8
+	}{
+		{"LDA abs,X", 0x8000, []byte{0xBD, 0xF0, 0x10}, 0x0F, 0, 4},
+		{"LDA abs,X crossing", 0x8000, []byte{0xBD, 0xF0, 0x10}, 0x10, 0, 5},
+		{"LDA abs,Y crossing", 0x8000, []byte{0xB9, 0xFF, 0x10}, 0, 0x01, 5},
+		{"LDA (zp),Y", 0x8000, []byte{0xB1, 0x20}, 0, 0x0F, 5},
+		{"LDA (zp),Y crossing", 0x8000, []byte{0xB1, 0x20}, 0, 0x10, 6},
+		// stores and read-modify-writes always take the extra cycle
+		{"STA abs,X", 0x8000, []byte{0x9D, 0xF0, 0x10}, 0x01, 0, 5},
+		{"STA abs,X crossing", 0x8000, []byte{0x9D, 0xF0, 0x10}, 0x10, 0, 5},
+		{"STA (zp),Y", 0x8000, []byte{0x91, 0x20}, 0, 0x01, 6},
+		{"INC abs,X", 0x8000, []byte{0xFE, 0xF0, 0x10}, 0x01, 0, 7},
+		{"INC abs,X crossing", 0x8000, []byte{0xFE, 0xF0, 0x10}, 0x10, 0, 7},
+		// Z is clear after Reset, so BNE is taken and BEQ isn't
+		{"branch not taken", 0x8000, []byte{0xF0, 0x10}, 0, 0, 2},
+		{"branch taken", 0x8000, []byte{0xD0, 0x10}, 0, 0, 3},
+		{"branch taken crossing", 0x80F0, []byte{0xD0, 0x10}, 0, 0, 4},
+		{"branch back crossing", 0x8000, []byte{0xD0, 0xF0}, 0, 0, 4},
+	} {
+		c, bus := newBus(tc.org, tc.code...)
+		// ($20) points at $10F0
+		bus[0x20], bus[0x21] = 0xF0, 0x10
+		c.X.SetRegister(tc.x)
+		c.Y.SetRegister(tc.y)
+		r, err := c.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Cycles != tc.want {
+			t.Errorf("%s: %d cycles, want %d", tc.name, r.Cycles, tc.want)
+		}
+	}
+}
+
+func TestDecimalModeIsIgnored(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		code  []byte
+		a     uint8
+		carry bool
+		want  uint8
+		wantC bool
+	}{
+		// BCD would give $10, and $00 with carry
+		{"ADC", []byte{0x69, 0x01}, 0x09, false, 0x0A, false},
+		{"ADC carry out", []byte{0x69, 0x01}, 0x99, false, 0x9A, false},
+		// BCD would give $09
+		{"SBC", []byte{0xE9, 0x01}, 0x10, true, 0x0F, true},
+	} {
+		c, _ := newBus(0x8000, append([]byte{0xF8}, tc.code...)...)
+		c.A.SetRegister(tc.a)
+		c.Flags.SetFlag(gemu.Carry, tc.carry)
+		for i := 0; i < 2; i++ {
+			if _, err := c.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := c.A.GetValue(); got != tc.want || c.Flags.GetFlag(gemu.Carry) != tc.wantC {
+			t.Errorf("%s: A=$%02X C=%v, want $%02X C=%v", tc.name, got, c.Flags.GetFlag(gemu.Carry), tc.want, tc.wantC)
+		}
+		if !c.Flags.GetFlag(gemu.Decimal) {
+			t.Errorf("%s: SED didn't set D", tc.name)
+		}
+	}
+}
+
+// nmiBus raises NMI on the cpu, if armed, as an interrupt sequence
+// starting with SP at $FD pushes the status, before it reads the vector.
+type nmiBus struct {
+	TestBus
+	cpu *CPU
+	arm bool
+}
+
+func (b *nmiBus) Write(addr uint16, v uint8) {
+	b.TestBus[addr] = v
+	if b.arm && addr == 0x01FB {
+		b.cpu.TriggerNMI()
+	}
+}
+
+func TestInterruptHijacking(t *testing.T) {
+	const (
+		nmiHandler = 0x9000
+		irqHandler = 0xA000
+	)
+	for _, tc := range []struct {
+		name string
+		// BRK if set, else an IRQ taken before the NOP
+		brk bool
+		// NMI arrives during the sequence
+		nmi  bool
+		want uint16
+		// what Step reports entering, for an IRQ
+		kind Interrupt
+		b    bool
+	}{
+		{"BRK", true, false, irqHandler, 0, true},
+		{"BRK hijacked", true, true, nmiHandler, 0, true},
+		{"IRQ", false, false, irqHandler, IRQ, false},
+		{"IRQ hijacked", false, true, nmiHandler, NMI, false},
+	} {
+		bus := &nmiBus{arm: tc.nmi}
+		bus.TestBus[0x8000] = 0xEA
+		if tc.brk {
+			bus.TestBus[0x8000] = 0x00
+		}
+		bus.TestBus[0xFFFA], bus.TestBus[0xFFFB] = 0x00, 0x90
+		bus.TestBus[0xFFFE], bus.TestBus[0xFFFF] = 0x00, 0xA0
+		c := &CPU{}
+		c.Reset()
+		bus.cpu = c
+		c.SetBus(bus)
+		c.SetPC(0x8000)
+		c.Flags.SetFlag(gemu.InterruptDisable, false)
+		if !tc.brk {
+			c.TriggerIRQ()
+		}
+
+		r, err := c.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.GetPC(); got != tc.want {
+			t.Errorf("%s: entered $%04X, want $%04X", tc.name, got, tc.want)
+		}
+		if !tc.brk && r.Interrupt != tc.kind {
+			t.Errorf("%s: Step reports %v, want %v", tc.name, r.Interrupt, tc.kind)
+		}
+		if status := bus.TestBus[0x01FB]; status&0x10 != 0 != tc.b {
+			t.Errorf("%s: pushed status $%02X, want B %v", tc.name, status, tc.b)
+		}
+		// a hijacking NMI is taken, not left pending
+		c.ReleaseIRQ()
+		if r, _ := c.Step(); r.Interrupted {
+			t.Errorf("%s: took %v after the handler was entered", tc.name, r.Interrupt)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package cpu
+
+// Heatmap counts the cpu's accesses to each address. Reads include stack
+// pulls, writes include stack pushes, and an execute is counted for each
+// opcode fetch (operand bytes are not counted).
+type Heatmap struct {
+	Reads  [0x10000]uint32
+	Writes [0x10000]uint32
+	Execs  [0x10000]uint32
+}
+
+// SetHeatmap starts counting accesses into h, or stops if h is nil.
+// Debugger reads through Pages() are not counted.
+func (cpu *CPU) SetHeatmap(h *Heatmap) {
+	cpu.heat = h
+}
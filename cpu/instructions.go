@@ -0,0 +1,451 @@
+package cpu
+
+import (
+	"github.com/goldmane/gemu/gemu"
+)
+
+func HighByte(a uint16) uint8 {
+	h := uint8(a >> 8)
+	return h
+}
+
+func LowByte(a uint16) uint8 {
+	b := uint8(0xFF & a)
+	return b
+}
+
+func PageCrossed(a uint16, b uint16) bool {
+	pa := a >> 8
+	pb := b >> 8
+	return pa != pb
+}
+
+func Fetch(c CPU, a uint16) uint8 {
+	return c.FetchAddress(a)
+}
+
+type Instruction struct {
+	Opcode uint8
+	Label  string
+	Length int
+	// Cycles      uint8 // this is the return value of the Function
+	AddressMode uint8
+	// Function executes the instruction and returns the cycles it took
+	Function func(cpu *CPU) uint8
+	// AppendDetails appends the operand column of the trace, after the
+	// instruction has executed
+	AppendDetails func(cpu *CPU, b []byte) []byte
+}
+
+// Lookup returns the instruction decoded from opcode, and false if the
+// opcode is not implemented.
+func Lookup(opcode uint8) (Instruction, bool) {
+	ins := table[opcode]
+	if ins == nil {
+		return Instruction{}, false
+	}
+	return *ins, true
+}
+
+// table indexes the instructions by opcode
+var table [256]*Instruction
+
+func init() {
+	for i := range instructions {
+		table[instructions[i].Opcode] = &instructions[i]
+	}
+}
+
+// access is what an instruction does with the memory its operand
+// addresses, which decides whether the operand is read before the
+// operation runs.
+type access uint8
+
+const (
+	// no operand, or one whose address is all that's used: jumps and
+	// branches
+	noAccess access = iota
+	readAccess
+	writeAccess
+	// read, then write the result back
+	modifyAccess
+)
+
+// define makes an instruction that resolves its operand in mode, reads it
+// if acc says to, and then runs op, which returns the cycles taken.
+func define(opcode uint8, label string, mode uint8, acc access, op func(cpu *CPU, o *Operand) uint8) Instruction {
+	memory := acc != noAccess && mode != Immediate && mode != Accumulator
+	return Instruction{
+		Opcode:      opcode,
+		Label:       label,
+		Length:      1 + operandLength[mode],
+		AddressMode: mode,
+		Function: func(cpu *CPU) uint8 {
+			o := &cpu.operand
+			cpu.resolve(o, mode)
+			switch {
+			case !memory:
+			case acc == writeAccess:
+				// only for the trace, so without the side effects of
+				// reading a register
+				o.Value = cpu.peek(o.Address)
+			default:
+				o.Value = cpu.FetchAddress(o.Address)
+			}
+			return op(cpu, o)
+		},
+		AppendDetails: func(cpu *CPU, b []byte) []byte {
+			return cpu.operand.appendDetails(b, memory)
+		},
+	}
+}
+
+// load makes an instruction that reads its operand and passes it to op.
+// Indexing across a page takes a cycle more.
+func load(opcode uint8, label string, mode uint8, cycles uint8, op func(cpu *CPU, v uint8)) Instruction {
+	return define(opcode, label, mode, readAccess, func(cpu *CPU, o *Operand) uint8 {
+		op(cpu, o.Value)
+		if o.Crossed {
+			return cycles + 1
+		}
+		return cycles
+	})
+}
+
+// store makes an instruction that writes the value op returns to its
+// operand's address.
+func store(opcode uint8, label string, mode uint8, cycles uint8, op func(cpu *CPU) uint8) Instruction {
+	return define(opcode, label, mode, writeAccess, func(cpu *CPU, o *Operand) uint8 {
+		cpu.Store(o.Address, op(cpu))
+		return cycles
+	})
+}
+
+// modify makes a read-modify-write instruction, which replaces its operand
+// with what op makes of it: A in Accumulator mode, or else memory.
+func modify(opcode uint8, label string, mode uint8, cycles uint8, op func(cpu *CPU, v uint8) uint8) Instruction {
+	return define(opcode, label, mode, modifyAccess, func(cpu *CPU, o *Operand) uint8 {
+		r := op(cpu, o.Value)
+		if mode == Accumulator {
+			cpu.A.SetRegister(r)
+		} else {
+			cpu.Store(o.Address, r)
+		}
+		return cycles
+	})
+}
+
+// implied makes an instruction with no operand.
+func implied(opcode uint8, label string, cycles uint8, op func(cpu *CPU)) Instruction {
+	return define(opcode, label, Implicit, noAccess, func(cpu *CPU, o *Operand) uint8 {
+		op(cpu)
+		return cycles
+	})
+}
+
+// branch makes a branch taken when flag is set, or clear if set is false.
+// It takes a cycle more when taken, and another when the target is on
+// another page.
+func branch(opcode uint8, label string, flag uint8, set bool) Instruction {
+	return define(opcode, label, Relative, noAccess, func(cpu *CPU, o *Operand) uint8 {
+		if cpu.Flags.GetFlag(flag) != set {
+			return 2
+		}
+		cpu.SetPC(o.Address)
+		if o.Crossed {
+			return 4
+		}
+		return 3
+	})
+}
+
+// group makes the instructions of a mnemonic available in the eight modes
+// of the accumulator arithmetic and logic group, in this order.
+func group(label string, opcodes [8]uint8, op func(cpu *CPU, v uint8)) []Instruction {
+	modes := [8]uint8{Immediate, ZeroPage, ZeroPageX, Absolute, AbsoluteX, AbsoluteY, IndirectX, IndirectY}
+	cycles := [8]uint8{2, 3, 4, 4, 4, 4, 6, 5}
+	var ins []Instruction
+	for i, opcode := range opcodes {
+		ins = append(ins, load(opcode, label, modes[i], cycles[i], op))
+	}
+	return ins
+}
+
+// shift makes the five forms of a shift or rotate: Accumulator, ZeroPage,
+// ZeroPageX, Absolute and AbsoluteX.
+func shift(label string, opcodes [5]uint8, op func(cpu *CPU, v uint8) uint8) []Instruction {
+	modes := [5]uint8{Accumulator, ZeroPage, ZeroPageX, Absolute, AbsoluteX}
+	cycles := [5]uint8{2, 5, 6, 6, 7}
+	var ins []Instruction
+	for i, opcode := range opcodes {
+		ins = append(ins, modify(opcode, label, modes[i], cycles[i], op))
+	}
+	return ins
+}
+
+var instructions = concat(
+	group("ORA", [8]uint8{0x09, 0x05, 0x15, 0x0D, 0x1D, 0x19, 0x01, 0x11}, ora),
+	group("AND", [8]uint8{0x29, 0x25, 0x35, 0x2D, 0x3D, 0x39, 0x21, 0x31}, and),
+	group("EOR", [8]uint8{0x49, 0x45, 0x55, 0x4D, 0x5D, 0x59, 0x41, 0x51}, eor),
+	group("ADC", [8]uint8{0x69, 0x65, 0x75, 0x6D, 0x7D, 0x79, 0x61, 0x71}, adc),
+	group("LDA", [8]uint8{0xA9, 0xA5, 0xB5, 0xAD, 0xBD, 0xB9, 0xA1, 0xB1}, lda),
+	group("CMP", [8]uint8{0xC9, 0xC5, 0xD5, 0xCD, 0xDD, 0xD9, 0xC1, 0xD1}, cmp),
+	group("SBC", [8]uint8{0xE9, 0xE5, 0xF5, 0xED, 0xFD, 0xF9, 0xE1, 0xF1}, sbc),
+
+	shift("ASL", [5]uint8{0x0A, 0x06, 0x16, 0x0E, 0x1E}, asl),
+	shift("ROL", [5]uint8{0x2A, 0x26, 0x36, 0x2E, 0x3E}, rol),
+	shift("LSR", [5]uint8{0x4A, 0x46, 0x56, 0x4E, 0x5E}, lsr),
+	shift("ROR", [5]uint8{0x6A, 0x66, 0x76, 0x6E, 0x7E}, ror),
+
+	[]Instruction{
+		load(0xA2, "LDX", Immediate, 2, ldx),
+		load(0xA6, "LDX", ZeroPage, 3, ldx),
+		load(0xB6, "LDX", ZeroPageY, 4, ldx),
+		load(0xAE, "LDX", Absolute, 4, ldx),
+		load(0xBE, "LDX", AbsoluteY, 4, ldx),
+		load(0xA0, "LDY", Immediate, 2, ldy),
+		load(0xA4, "LDY", ZeroPage, 3, ldy),
+		load(0xB4, "LDY", ZeroPageX, 4, ldy),
+		load(0xAC, "LDY", Absolute, 4, ldy),
+		load(0xBC, "LDY", AbsoluteX, 4, ldy),
+		load(0xE0, "CPX", Immediate, 2, cpx),
+		load(0xE4, "CPX", ZeroPage, 3, cpx),
+		load(0xEC, "CPX", Absolute, 4, cpx),
+		load(0xC0, "CPY", Immediate, 2, cpy),
+		load(0xC4, "CPY", ZeroPage, 3, cpy),
+		load(0xCC, "CPY", Absolute, 4, cpy),
+		load(0x24, "BIT", ZeroPage, 3, bit),
+		load(0x2C, "BIT", Absolute, 4, bit),
+
+		store(0x85, "STA", ZeroPage, 3, sta),
+		store(0x95, "STA", ZeroPageX, 4, sta),
+		store(0x8D, "STA", Absolute, 4, sta),
+		store(0x9D, "STA", AbsoluteX, 5, sta),
+		store(0x99, "STA", AbsoluteY, 5, sta),
+		store(0x81, "STA", IndirectX, 6, sta),
+		store(0x91, "STA", IndirectY, 6, sta),
+		store(0x86, "STX", ZeroPage, 3, stx),
+		store(0x96, "STX", ZeroPageY, 4, stx),
+		store(0x8E, "STX", Absolute, 4, stx),
+		store(0x84, "STY", ZeroPage, 3, sty),
+		store(0x94, "STY", ZeroPageX, 4, sty),
+		store(0x8C, "STY", Absolute, 4, sty),
+
+		modify(0xE6, "INC", ZeroPage, 5, inc),
+		modify(0xF6, "INC", ZeroPageX, 6, inc),
+		modify(0xEE, "INC", Absolute, 6, inc),
+		modify(0xFE, "INC", AbsoluteX, 7, inc),
+		modify(0xC6, "DEC", ZeroPage, 5, dec),
+		modify(0xD6, "DEC", ZeroPageX, 6, dec),
+		modify(0xCE, "DEC", Absolute, 6, dec),
+		modify(0xDE, "DEC", AbsoluteX, 7, dec),
+
+		branch(0x10, "BPL", gemu.Negative, false),
+		branch(0x30, "BMI", gemu.Negative, true),
+		branch(0x50, "BVC", gemu.Overflow, false),
+		branch(0x70, "BVS", gemu.Overflow, true),
+		branch(0x90, "BCC", gemu.Carry, false),
+		branch(0xB0, "BCS", gemu.Carry, true),
+		branch(0xD0, "BNE", gemu.Zero, false),
+		branch(0xF0, "BEQ", gemu.Zero, true),
+
+		define(0x4C, "JMP", Absolute, noAccess, func(cpu *CPU, o *Operand) uint8 {
+			cpu.SetPC(o.Address)
+			return 3
+		}),
+		define(0x6C, "JMP", Indirect, noAccess, func(cpu *CPU, o *Operand) uint8 {
+			cpu.SetPC(o.Address)
+			return 5
+		}),
+		define(0x20, "JSR", Absolute, noAccess, func(cpu *CPU, o *Operand) uint8 {
+			// push the address of the last byte of the JSR, which RTS
+			// returns past
+			ret := cpu.GetPC() - 1
+			cpu.StackPush(HighByte(ret))
+			cpu.StackPush(LowByte(ret))
+			cpu.SetPC(o.Address)
+			return 6
+		}),
+		implied(0x60, "RTS", 6, func(cpu *CPU) {
+			lo := cpu.StackPop()
+			hi := cpu.StackPop()
+			cpu.SetPC(ToAddress(hi, lo) + 1)
+		}),
+		implied(0x00, "BRK", InterruptCycles, func(cpu *CPU) {
+			// RTI returns past the padding byte after BRK
+			cpu.enterInterrupt(BRK, cpu.GetPC()+1, irqVector)
+		}),
+		implied(0x40, "RTI", 6, func(cpu *CPU) {
+			pullFlags(cpu)
+			lo := cpu.StackPop()
+			hi := cpu.StackPop()
+			cpu.SetPC(ToAddress(hi, lo))
+		}),
+
+		implied(0x48, "PHA", 3, func(cpu *CPU) {
+			cpu.StackPush(cpu.A.GetValue())
+		}),
+		implied(0x08, "PHP", 3, func(cpu *CPU) {
+			// B and the unused bit are set in the pushed copy
+			cpu.StackPush(cpu.Flags.Value() | 0x30)
+		}),
+		implied(0x68, "PLA", 4, func(cpu *CPU) {
+			lda(cpu, cpu.StackPop())
+		}),
+		implied(0x28, "PLP", 4, pullFlags),
+
+		implied(0x18, "CLC", 2, func(cpu *CPU) { cpu.Flags.SetFlag(gemu.Carry, false) }),
+		implied(0x38, "SEC", 2, func(cpu *CPU) { cpu.Flags.SetFlag(gemu.Carry, true) }),
+		implied(0x58, "CLI", 2, func(cpu *CPU) { cpu.Flags.SetFlag(gemu.InterruptDisable, false) }),
+		implied(0x78, "SEI", 2, func(cpu *CPU) { cpu.Flags.SetFlag(gemu.InterruptDisable, true) }),
+		implied(0xB8, "CLV", 2, func(cpu *CPU) { cpu.Flags.SetFlag(gemu.Overflow, false) }),
+		implied(0xD8, "CLD", 2, func(cpu *CPU) { cpu.Flags.SetFlag(gemu.Decimal, false) }),
+		implied(0xF8, "SED", 2, func(cpu *CPU) { cpu.Flags.SetFlag(gemu.Decimal, true) }),
+
+		implied(0xAA, "TAX", 2, func(cpu *CPU) { ldx(cpu, cpu.A.GetValue()) }),
+		implied(0xA8, "TAY", 2, func(cpu *CPU) { ldy(cpu, cpu.A.GetValue()) }),
+		implied(0x8A, "TXA", 2, func(cpu *CPU) { lda(cpu, cpu.X.GetValue()) }),
+		implied(0x98, "TYA", 2, func(cpu *CPU) { lda(cpu, cpu.Y.GetValue()) }),
+		implied(0xBA, "TSX", 2, func(cpu *CPU) { ldx(cpu, cpu.SP) }),
+		// TXS is the one transfer that leaves the flags alone
+		implied(0x9A, "TXS", 2, func(cpu *CPU) { cpu.SP = cpu.X.GetValue() }),
+
+		implied(0xE8, "INX", 2, func(cpu *CPU) { ldx(cpu, cpu.X.GetValue()+1) }),
+		implied(0xC8, "INY", 2, func(cpu *CPU) { ldy(cpu, cpu.Y.GetValue()+1) }),
+		implied(0xCA, "DEX", 2, func(cpu *CPU) { ldx(cpu, cpu.X.GetValue()-1) }),
+		implied(0x88, "DEY", 2, func(cpu *CPU) { ldy(cpu, cpu.Y.GetValue()-1) }),
+
+		implied(0xEA, "NOP", 2, func(cpu *CPU) {}),
+	},
+)
+
+func concat(groups ...[]Instruction) []Instruction {
+	var all []Instruction
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
+
+func ToAddress(hi uint8, lo uint8) uint16 {
+	return (uint16(hi) << 8) | uint16(lo)
+}
+
+// setZN sets Z and N from v, as every load, transfer and arithmetic
+// result does.
+func setZN(cpu *CPU, v uint8) {
+	cpu.Flags.SetZeroByValue(v)
+	cpu.Flags.SetNegative(v)
+}
+
+func lda(cpu *CPU, v uint8) {
+	cpu.A.SetRegister(v)
+	setZN(cpu, v)
+}
+
+func ldx(cpu *CPU, v uint8) {
+	cpu.X.SetRegister(v)
+	setZN(cpu, v)
+}
+
+func ldy(cpu *CPU, v uint8) {
+	cpu.Y.SetRegister(v)
+	setZN(cpu, v)
+}
+
+func sta(cpu *CPU) uint8 { return cpu.A.GetValue() }
+func stx(cpu *CPU) uint8 { return cpu.X.GetValue() }
+func sty(cpu *CPU) uint8 { return cpu.Y.GetValue() }
+
+func ora(cpu *CPU, v uint8) { lda(cpu, cpu.A.GetValue()|v) }
+func and(cpu *CPU, v uint8) { lda(cpu, cpu.A.GetValue()&v) }
+func eor(cpu *CPU, v uint8) { lda(cpu, cpu.A.GetValue()^v) }
+
+// adc adds v and the carry to the accumulator, setting C, Z, V and N.
+func adc(cpu *CPU, v uint8) {
+	a := cpu.A.GetValue()
+	r := uint16(a) + uint16(v) + uint16(cpu.Flags.GetFlagUint8(gemu.Carry))
+	r8 := uint8(r)
+	cpu.Flags.SetFlag(gemu.Carry, r > 0xFF)
+	cpu.Flags.SetZeroByValue(r8)
+	cpu.Flags.SetFlag(gemu.Overflow, (r8^a)&(r8^v)&0x80 != 0)
+	cpu.Flags.SetNegative(r8)
+	cpu.A.SetRegister(r8)
+}
+
+// sbc subtracts v and the borrow, the inverted carry, from the
+// accumulator. On the 6502 that is adding the complement of v.
+func sbc(cpu *CPU, v uint8) {
+	adc(cpu, ^v)
+}
+
+// compare sets C, Z and N from reg - v, as CMP, CPX and CPY do.
+func compare(cpu *CPU, reg, v uint8) {
+	cpu.Flags.SetFlag(gemu.Carry, reg >= v)
+	setZN(cpu, reg-v)
+}
+
+func cmp(cpu *CPU, v uint8) { compare(cpu, cpu.A.GetValue(), v) }
+func cpx(cpu *CPU, v uint8) { compare(cpu, cpu.X.GetValue(), v) }
+func cpy(cpu *CPU, v uint8) { compare(cpu, cpu.Y.GetValue(), v) }
+
+// bit sets Z from A & v, and copies bits 7 and 6 of v into N and V.
+func bit(cpu *CPU, v uint8) {
+	cpu.Flags.SetZeroByValue(cpu.A.GetValue() & v)
+	cpu.Flags.SetOverflow(v)
+	cpu.Flags.SetNegative(v)
+}
+
+// asl shifts left: C <- [76543210] <- 0
+func asl(cpu *CPU, v uint8) uint8 {
+	cpu.Flags.SetFlag(gemu.Carry, v&0x80 != 0)
+	r := v << 1
+	setZN(cpu, r)
+	return r
+}
+
+// lsr shifts right: 0 -> [76543210] -> C
+func lsr(cpu *CPU, v uint8) uint8 {
+	cpu.Flags.SetFlag(gemu.Carry, v&0x01 != 0)
+	r := v >> 1
+	setZN(cpu, r)
+	return r
+}
+
+// rol rotates left through the carry: C <- [76543210] <- C
+func rol(cpu *CPU, v uint8) uint8 {
+	r := v<<1 | cpu.Flags.GetFlagUint8(gemu.Carry)
+	cpu.Flags.SetFlag(gemu.Carry, v&0x80 != 0)
+	setZN(cpu, r)
+	return r
+}
+
+// ror rotates right through the carry: C -> [76543210] -> C
+func ror(cpu *CPU, v uint8) uint8 {
+	r := v>>1 | cpu.Flags.GetFlagUint8(gemu.Carry)<<7
+	cpu.Flags.SetFlag(gemu.Carry, v&0x01 != 0)
+	setZN(cpu, r)
+	return r
+}
+
+func inc(cpu *CPU, v uint8) uint8 {
+	setZN(cpu, v+1)
+	return v + 1
+}
+
+func dec(cpu *CPU, v uint8) uint8 {
+	setZN(cpu, v-1)
+	return v - 1
+}
+
+// pullFlags pulls the status from the stack, as PLP and RTI do. B and the
+// unused bit only exist in pushed copies, so they are left as they are.
+func pullFlags(cpu *CPU) {
+	f := cpu.StackPop()
+	cpu.Flags.SetCarry(f)
+	cpu.Flags.SetZero(f)
+	cpu.Flags.SetInterruptDisable(f)
+	cpu.Flags.SetDecimal(f)
+	cpu.Flags.SetOverflow(f)
+	cpu.Flags.SetNegative(f)
+}
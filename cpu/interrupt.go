@@ -0,0 +1,118 @@
+package cpu
+
+import "github.com/goldmane/gemu/gemu"
+
+// Interrupt says what made the cpu enter an interrupt handler.
+type Interrupt int
+
+const (
+	NMI Interrupt = iota
+	IRQ
+	BRK
+)
+
+func (i Interrupt) String() string {
+	switch i {
+	case NMI:
+		return "nmi"
+	case IRQ:
+		return "irq"
+	case BRK:
+		return "brk"
+	}
+	return "unknown"
+}
+
+// InterruptHook is called when the cpu enters an interrupt handler, after
+// the return address and status are pushed and the pc is loaded from the
+// vector. from is the return address pushed, where RTI resumes.
+type InterruptHook func(kind Interrupt, from uint16)
+
+// SetInterruptHook sets the function called on entering an interrupt
+// handler, or removes it if f is nil.
+func (cpu *CPU) SetInterruptHook(f InterruptHook) {
+	cpu.onInterrupt = f
+}
+
+// InterruptCycles is how many cycles the cpu takes to enter an interrupt
+// handler, BRK included.
+const InterruptCycles = 7
+
+// hijackCycles is how far into BRK or an IRQ's sequence an NMI can arrive
+// and still take it over, before the vector is read.
+const hijackCycles = 4
+
+// vectors where the handler addresses are read from. BRK shares IRQ's.
+const (
+	nmiVector = 0xFFFA
+	irqVector = 0xFFFE
+)
+
+// TriggerNMI latches a non-maskable interrupt, which the cpu takes before
+// its next instruction whatever the I flag says. NMI is edge triggered, so
+// it is taken once however long the source holds its line.
+func (cpu *CPU) TriggerNMI() {
+	cpu.nmi = true
+}
+
+// TriggerIRQ asserts the IRQ line. The cpu takes the interrupt before its
+// next instruction once the I flag is clear, and again after every RTI for
+// as long as the line stays asserted, so the source calls ReleaseIRQ when
+// the handler acknowledges it.
+func (cpu *CPU) TriggerIRQ() {
+	cpu.irq = true
+}
+
+// ReleaseIRQ lets go of the IRQ line.
+func (cpu *CPU) ReleaseIRQ() {
+	cpu.irq = false
+}
+
+// ClearInterrupts drops a latched NMI and releases IRQ, as reset does. A
+// source still holding IRQ asserts it again before the next instruction.
+func (cpu *CPU) ClearInterrupts() {
+	cpu.nmi, cpu.irq = false, false
+}
+
+// ServiceInterrupt enters the handler of a pending NMI, or of an IRQ if
+// interrupts are enabled, taking InterruptCycles. It is called between
+// instructions and reports which interrupt it took, if any.
+func (cpu *CPU) ServiceInterrupt() (Interrupt, bool) {
+	switch {
+	case cpu.nmi:
+		cpu.nmi = false
+		return cpu.enterInterrupt(NMI, cpu.pc, nmiVector), true
+	case cpu.irq && !cpu.Flags.GetFlag(gemu.InterruptDisable):
+		return cpu.enterInterrupt(IRQ, cpu.pc, irqVector), true
+	}
+	return 0, false
+}
+
+// enterInterrupt pushes ret and the status and jumps through vector, and
+// returns the kind of interrupt whose handler it entered. The pushed
+// status has B set only for BRK, which is how a handler shared with IRQ
+// tells the two apart; the unused bit is always set. An NMI that arrives
+// during BRK or an IRQ, before the vector is read, hijacks it: the
+// sequence goes on to NMI's vector, with the status already pushed, and
+// the BRK or IRQ is lost.
+func (cpu *CPU) enterInterrupt(kind Interrupt, ret uint16, vector uint16) Interrupt {
+	cpu.StackPush(HighByte(ret))
+	cpu.StackPush(LowByte(ret))
+	status := cpu.Flags.Value() | 0x20
+	if kind == BRK {
+		status |= 0x10
+	}
+	cpu.StackPush(status)
+	cpu.Flags.SetFlag(gemu.InterruptDisable, true)
+	if kind != NMI && (cpu.nmi || cpu.PollNMI != nil && cpu.PollNMI(hijackCycles)) {
+		cpu.nmi = false
+		kind, vector = NMI, nmiVector
+	}
+	lo := cpu.FetchAddress(vector)
+	hi := cpu.FetchAddress(vector + 1)
+	cpu.SetPC(ToAddress(hi, lo))
+	if cpu.onInterrupt != nil {
+		cpu.onInterrupt(kind, ret)
+	}
+	return kind
+}
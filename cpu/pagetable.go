@@ -0,0 +1,102 @@
+package cpu
+
+const pageSize = 0x100
+
+// PageHandler handles accesses to pages that are not plain memory, such as
+// hardware registers.
+type PageHandler interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, v uint8)
+}
+
+// PageTable maps the 64kb address space as 256 pages of 256 bytes. A page
+// backed by memory is read and written with a slice index; only handler
+// pages pay for a call through the interface.
+type PageTable struct {
+	mem      [256][]byte
+	writable [256]bool
+	handlers [256]PageHandler
+}
+
+// MapMemory backs count pages starting at page with mem. If mem is smaller
+// than the range it is mirrored across it, so 2kb of RAM can be mapped over
+// $0000-$1FFF in one call. Writes to read-only pages are ignored.
+func (t *PageTable) MapMemory(page uint8, count int, mem []byte, writable bool) {
+	for i := 0; i < count; i++ {
+		off := (i * pageSize) % len(mem)
+		p := int(page) + i
+		t.mem[p] = mem[off : off+pageSize]
+		t.writable[p] = writable
+		t.handlers[p] = nil
+	}
+}
+
+// MapROM backs count pages starting at page with mem for reads, like a
+// read-only MapMemory, and dispatches writes to h. This is how a cartridge
+// with registers behind its ROM is mapped: fetches stay slice indexes and
+// only the register writes go through h.
+func (t *PageTable) MapROM(page uint8, count int, mem []byte, h PageHandler) {
+	t.MapMemory(page, count, mem, false)
+	for i := 0; i < count; i++ {
+		t.handlers[int(page)+i] = h
+	}
+}
+
+// MapHandler dispatches count pages starting at page to h.
+func (t *PageTable) MapHandler(page uint8, count int, h PageHandler) {
+	for i := 0; i < count; i++ {
+		p := int(page) + i
+		t.mem[p] = nil
+		t.writable[p] = false
+		t.handlers[p] = h
+	}
+}
+
+// Read returns the byte at addr. Unmapped pages read as 0.
+func (t *PageTable) Read(addr uint16) uint8 {
+	p := addr >> 8
+	if m := t.mem[p]; m != nil {
+		return m[addr&0xFF]
+	}
+	if h := t.handlers[p]; h != nil {
+		return h.Read(addr)
+	}
+	return 0
+}
+
+// Write stores v at addr.
+func (t *PageTable) Write(addr uint16, v uint8) {
+	p := addr >> 8
+	if m := t.mem[p]; m != nil {
+		if t.writable[p] {
+			m[addr&0xFF] = v
+			return
+		}
+	}
+	if h := t.handlers[p]; h != nil {
+		h.Write(addr, v)
+	}
+}
+
+// Poke stores v in the memory backing addr even if the page is read-only,
+// as a debugger patching ROM does, without calling a handler. Only a page
+// with no memory behind it has v written through its handler.
+func (t *PageTable) Poke(addr uint16, v uint8) {
+	p := addr >> 8
+	if m := t.mem[p]; m != nil {
+		m[addr&0xFF] = v
+		return
+	}
+	if h := t.handlers[p]; h != nil {
+		h.Write(addr, v)
+	}
+}
+
+// peek returns the byte at addr without calling a handler, so reading has
+// no side effects. Handler pages read as $FF, as an open bus often does.
+func (t *PageTable) peek(addr uint16) uint8 {
+	if m := t.mem[addr>>8]; m != nil {
+		return m[addr&0xFF]
+	}
+	return 0xFF
+}
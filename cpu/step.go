@@ -0,0 +1,97 @@
+package cpu
+
+import "fmt"
+
+// UnknownOpcodeError is returned when the cpu fetches an opcode it does not
+// implement.
+type UnknownOpcodeError struct {
+	Opcode uint8
+	PC     uint16
+}
+
+func (e *UnknownOpcodeError) Error() string {
+	return fmt.Sprintf("unknown opcode %02X at $%04X", e.Opcode, e.PC)
+}
+
+// StepResult describes what one Step did.
+type StepResult struct {
+	// PC is where the pc was before the step, and NextPC where it was left.
+	PC, NextPC uint16
+	// Interrupted is whether the step entered the handler of Interrupt
+	// instead of running an instruction, in which case only the pcs and
+	// Cycles are set.
+	Interrupted bool
+	Interrupt   Interrupt
+	Opcode      uint8
+	Mnemonic    string
+	// Bytes holds the opcode and then the operand bytes, Length of them in
+	// all.
+	Bytes  [3]byte
+	Length int
+	// Operand is the operand as its addressing mode resolved it, with the
+	// effective address.
+	Operand Operand
+	Cycles  uint8
+}
+
+// OperandBytes returns the bytes of the instruction after the opcode.
+func (r *StepResult) OperandBytes() []byte {
+	return r.Bytes[1:r.Length]
+}
+
+// Step runs the cpu for one instruction: it enters the handler of a pending
+// interrupt if there is one, and otherwise fetches, decodes and executes the
+// instruction at pc. The cycles it took are also added to TotalCycles.
+func (cpu *CPU) Step() (StepResult, error) {
+	r := StepResult{PC: cpu.pc}
+	if kind, ok := cpu.ServiceInterrupt(); ok {
+		cpu.TotalCycles += InterruptCycles
+		r.Interrupted, r.Interrupt = true, kind
+		r.Cycles, r.NextPC = InterruptCycles, cpu.pc
+		return r, nil
+	}
+	ins, ok := cpu.Decode()
+	if !ok {
+		return r, &UnknownOpcodeError{Opcode: cpu.read(cpu.pc), PC: cpu.pc}
+	}
+	cpu.ClearFetched()
+	cpu.Fetch()
+	r.Cycles = cpu.run(ins)
+	cpu.describe(&r, ins)
+	return r, nil
+}
+
+// Execute executes the instruction for opcode as if it had just been
+// fetched, with its operand bytes at pc, and the cycles it took are also
+// added to TotalCycles. It lets a program feed the cpu opcodes from
+// somewhere other than memory.
+func (cpu *CPU) Execute(opcode uint8) (StepResult, error) {
+	r := StepResult{PC: cpu.pc - 1}
+	ins := table[opcode]
+	if ins == nil {
+		return r, &UnknownOpcodeError{Opcode: opcode, PC: r.PC}
+	}
+	cpu.pre = nil
+	cpu.ClearFetched()
+	cpu.fetched[0] = opcode
+	cpu.nfetched = 1
+	cpu.opPC = r.PC
+	r.Cycles = cpu.run(ins)
+	cpu.describe(&r, ins)
+	return r, nil
+}
+
+func (cpu *CPU) run(ins *Instruction) uint8 {
+	c := ins.Function(cpu)
+	cpu.TotalCycles += uint64(c)
+	return c
+}
+
+// describe fills in r for the instruction ins that just ran.
+func (cpu *CPU) describe(r *StepResult, ins *Instruction) {
+	r.NextPC = cpu.pc
+	r.Opcode = ins.Opcode
+	r.Mnemonic = ins.Label
+	r.Length = copy(r.Bytes[:], cpu.Fetched())
+	r.Operand = cpu.operand
+}
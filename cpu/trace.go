@@ -0,0 +1,100 @@
+package cpu
+
+import "strconv"
+
+const hexDigits = "0123456789ABCDEF"
+
+// appendHexf appends format to b with each %0NX verb replaced by the next
+// value as zero padded upper case hex of at least N digits. It covers the
+// formats the trace uses without the allocations of fmt.
+func appendHexf(b []byte, format string, values ...uint16) []byte {
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+3 >= len(format) || len(values) == 0 {
+			b = append(b, c)
+			continue
+		}
+		// %0NX
+		width := int(format[i+2] - '0')
+		b = appendHex(b, values[0], width)
+		values = values[1:]
+		i += 3
+	}
+	return b
+}
+
+// appendHex appends v as upper case hex, zero padded to width digits.
+func appendHex(b []byte, v uint16, width int) []byte {
+	digits := 1
+	for n := v >> 4; n != 0; n >>= 4 {
+		digits++
+	}
+	for ; width > digits; width-- {
+		b = append(b, '0')
+	}
+	for shift := (digits - 1) * 4; shift >= 0; shift -= 4 {
+		b = append(b, hexDigits[(v>>shift)&0xF])
+	}
+	return b
+}
+
+// appendPadded appends v in decimal, right aligned in width columns.
+func appendPadded(b []byte, v uint64, width int) []byte {
+	digits := 1
+	for n := v / 10; n != 0; n /= 10 {
+		digits++
+	}
+	for ; width > digits; width-- {
+		b = append(b, ' ')
+	}
+	return strconv.AppendUint(b, v, 10)
+}
+
+// Tracer formats nestest style trace lines into a buffer it reuses, so
+// tracing every instruction doesn't allocate.
+type Tracer struct {
+	line  []byte
+	state []byte
+}
+
+// Begin records the pc and register state before an instruction executes.
+func (t *Tracer) Begin(cpu *CPU) {
+	t.line = appendHex(t.line[:0], cpu.GetPC(), 4)
+	t.line = append(t.line, ' ', ' ')
+	t.state = cpu.AppendState(t.state[:0])
+}
+
+// End completes the line once ins has executed and returns it. The slice is
+// only valid until the next call to Begin.
+func (t *Tracer) End(cpu *CPU, ins *Instruction) []byte {
+	// raw bytes, padded to a fixed column
+	start := len(t.line)
+	for _, v := range cpu.Fetched() {
+		t.line = appendHex(t.line, uint16(v), 2)
+		t.line = append(t.line, ' ')
+	}
+	for len(t.line)-start < 10 {
+		t.line = append(t.line, ' ')
+	}
+
+	t.line = append(t.line, ins.Label...)
+	t.line = append(t.line, ' ')
+
+	// operand details, padded to 27
+	start = len(t.line)
+	t.line = ins.AppendDetails(cpu, t.line)
+	for len(t.line)-start < 27 {
+		t.line = append(t.line, ' ')
+	}
+	t.line = append(t.line, ' ')
+
+	t.line = append(t.line, t.state...)
+	return t.line
+}
+
+// AppendCounter appends the instruction counter column that prefixes each
+// line of gemu's own trace (it is not part of the nestest log).
+func AppendCounter(b []byte, n uint64) []byte {
+	b = appendPadded(b, n, 4)
+	return append(b, ' ', ' ')
+}
@@ -0,0 +1,145 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+var ErrAccessLog = errors.New("not an access log")
+
+// An access log is the magic followed by fixed size little-endian records:
+//
+//	bytes 0-5   cycle, its low 48 bits
+//	bytes 6-7   pc
+//	bytes 8-9   addr
+//	byte  10    value
+//	byte  11    kind (cpu.AccessKind)
+//
+// A cycle of 2^48 or more, about five years of NTSC cpu time, wraps.
+const (
+	accessLogMagic   = "GEMUACC1"
+	accessRecordSize = 12
+)
+
+// AccessLogWriter records cpu bus accesses to a binary log, for answering
+// "who wrote this byte?" after the run. Pass its Record method to
+// cpu.SetAccessHook.
+type AccessLogWriter struct {
+	w   *bufio.Writer
+	rec [accessRecordSize]byte
+	n   uint64
+	err error
+}
+
+// NewAccessLogWriter starts a log on w. Call Flush when done.
+func NewAccessLogWriter(w io.Writer) *AccessLogWriter {
+	l := &AccessLogWriter{w: bufio.NewWriterSize(w, 64<<10)}
+	_, l.err = l.w.WriteString(accessLogMagic)
+	return l
+}
+
+// Record appends an access to the log. Errors are kept for Flush.
+func (l *AccessLogWriter) Record(a cpu.Access) {
+	if l.err != nil {
+		return
+	}
+	b := l.rec[:]
+	binary.LittleEndian.PutUint64(b[0:8], a.Cycle)
+	// the pc overwrites the top two bytes of the cycle, leaving 48 bits
+	binary.LittleEndian.PutUint16(b[6:], a.PC)
+	binary.LittleEndian.PutUint16(b[8:], a.Addr)
+	b[10] = a.Value
+	b[11] = byte(a.Kind)
+	_, l.err = l.w.Write(b)
+	l.n++
+}
+
+// Count returns how many accesses have been recorded.
+func (l *AccessLogWriter) Count() uint64 {
+	return l.n
+}
+
+// Flush writes out buffered records and returns the first error met.
+func (l *AccessLogWriter) Flush() error {
+	if l.err != nil {
+		return l.err
+	}
+	return l.w.Flush()
+}
+
+// AccessLogReader reads the records of an access log in order.
+type AccessLogReader struct {
+	r   *bufio.Reader
+	rec [accessRecordSize]byte
+}
+
+// NewAccessLogReader checks the log's magic and returns a reader for its
+// records.
+func NewAccessLogReader(r io.Reader) (*AccessLogReader, error) {
+	l := &AccessLogReader{r: bufio.NewReaderSize(r, 64<<10)}
+	magic := make([]byte, len(accessLogMagic))
+	if _, err := io.ReadFull(l.r, magic); err != nil || string(magic) != accessLogMagic {
+		return nil, ErrAccessLog
+	}
+	return l, nil
+}
+
+// Next returns the next access, or io.EOF at the end of the log.
+func (l *AccessLogReader) Next() (cpu.Access, error) {
+	b := l.rec[:]
+	if _, err := io.ReadFull(l.r, b); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w: truncated record", ErrAccessLog)
+		}
+		return cpu.Access{}, err
+	}
+	var cycle [8]byte
+	copy(cycle[:6], b)
+	return cpu.Access{
+		Cycle: binary.LittleEndian.Uint64(cycle[:]),
+		PC:    binary.LittleEndian.Uint16(b[6:]),
+		Addr:  binary.LittleEndian.Uint16(b[8:]),
+		Value: b[10],
+		Kind:  cpu.AccessKind(b[11]),
+	}, nil
+}
+
+// AccessFilter selects records from an access log. The zero value matches
+// nothing; start from AllAccesses.
+type AccessFilter struct {
+	// Lo and Hi bound the address accessed, inclusive
+	Lo, Hi uint16
+	// Kinds has bit 1<<kind set for each kind to match
+	Kinds uint8
+	// PC, if HasPC is set, matches only accesses by the instruction there
+	PC    uint16
+	HasPC bool
+	// FromCycle and ToCycle bound the cycle, inclusive
+	FromCycle, ToCycle uint64
+}
+
+// AllAccesses is a filter matching every access.
+var AllAccesses = AccessFilter{
+	Hi:      0xFFFF,
+	Kinds:   1<<cpu.AccessFetch | 1<<cpu.AccessRead | 1<<cpu.AccessWrite,
+	ToCycle: 1<<48 - 1,
+}
+
+// Match reports whether a passes the filter.
+func (f AccessFilter) Match(a cpu.Access) bool {
+	return a.Addr >= f.Lo && a.Addr <= f.Hi &&
+		f.Kinds&(1<<a.Kind) != 0 &&
+		(!f.HasPC || a.PC == f.PC) &&
+		a.Cycle >= f.FromCycle && a.Cycle <= f.ToCycle
+}
+
+// FormatAccess formats an access as a line of a log listing:
+// CYC:1234  C5F5  W $0300 = 1A
+func FormatAccess(a cpu.Access) string {
+	return fmt.Sprintf("CYC:%-10d %04X  %s $%04X = %02X", a.Cycle, a.PC, a.Kind, a.Addr, a.Value)
+}
@@ -0,0 +1,59 @@
+package debug
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+func TestAccessLogRoundTrip(t *testing.T) {
+	accesses := []cpu.Access{
+		{Cycle: 7, PC: 0xC000, Addr: 0xC000, Value: 0x4C, Kind: cpu.AccessFetch},
+		{Cycle: 1<<48 - 1, PC: 0xFFFF, Addr: 0x0300, Value: 0x1A, Kind: cpu.AccessWrite},
+		{Cycle: 0x123456789A, PC: 0x8001, Addr: 0x2002, Value: 0x80, Kind: cpu.AccessRead},
+	}
+	var buf bytes.Buffer
+	w := NewAccessLogWriter(&buf)
+	for _, a := range accesses {
+		w.Record(a)
+	}
+	// the cycle wraps at 48 bits rather than spilling into the pc
+	w.Record(cpu.Access{Cycle: 1<<48 + 5, PC: 0x1234, Kind: cpu.AccessRead})
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Count() != 4 || buf.Len() != len(accessLogMagic)+4*accessRecordSize {
+		t.Fatalf("%d records in %d bytes", w.Count(), buf.Len())
+	}
+
+	r, err := NewAccessLogReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(accesses, cpu.Access{Cycle: 5, PC: 0x1234, Kind: cpu.AccessRead})
+	for i, a := range want {
+		got, err := r.Next()
+		if err != nil || got != a {
+			t.Errorf("record %d: %+v, %v, want %+v", i, got, err, a)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("after the last record: %v, want io.EOF", err)
+	}
+}
+
+func TestAccessLogErrors(t *testing.T) {
+	if _, err := NewAccessLogReader(bytes.NewReader([]byte("GEMUACC"))); !errors.Is(err, ErrAccessLog) {
+		t.Errorf("short magic: %v", err)
+	}
+	r, err := NewAccessLogReader(bytes.NewReader([]byte(accessLogMagic + "12345")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); !errors.Is(err, ErrAccessLog) {
+		t.Errorf("truncated record: %v, want ErrAccessLog", err)
+	}
+}
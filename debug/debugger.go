@@ -0,0 +1,387 @@
+// Package debug drives an emulator under a debugger: breakpoints, stepping
+// and views of the registers, stack and memory. Frontends such as the
+// terminal debugger in cmd/gemu build on it.
+package debug
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/goldmane/gemu"
+	"github.com/goldmane/gemu/cpu"
+	"github.com/goldmane/gemu/disasm"
+)
+
+// historySize is the number of recently executed instructions kept.
+const historySize = 16
+
+// StopReason says why Continue returned.
+type StopReason int
+
+const (
+	StopBreakpoint StopReason = iota
+	StopError
+	StopCancelled
+	StopNMI
+	StopIRQ
+	StopBRK
+	StopRTI
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopBreakpoint:
+		return "breakpoint"
+	case StopError:
+		return "error"
+	case StopCancelled:
+		return "interrupted"
+	case StopNMI:
+		return "nmi"
+	case StopIRQ:
+		return "irq"
+	case StopBRK:
+		return "brk"
+	case StopRTI:
+		return "rti"
+	}
+	return "unknown"
+}
+
+// Debugger controls an emulator. The emulator must only be run through the
+// debugger while it is attached.
+type Debugger struct {
+	emu         *gemu.Emulator
+	breakpoints map[uint16]bool
+	// pcs of the last instructions executed, oldest first
+	history []uint16
+	watches Watches
+	// calls and interrupts on the stack, and what was pushed to each byte
+	frames []Frame
+	slots  [256]slot
+	// interrupts taken during the current step
+	taken   []cpu.Interrupt
+	breakOn Events
+
+	source gemu.SourceMap
+
+	profile *Profile
+	// folded call stack for the profile, empty when it needs rebuilding
+	stackKey string
+}
+
+// New attaches a debugger to emu.
+func New(emu *gemu.Emulator) *Debugger {
+	d := &Debugger{emu: emu, breakpoints: map[uint16]bool{}}
+	emu.CPU().SetInterruptHook(d.interrupted)
+	return d
+}
+
+// Events are the interrupt events Continue can stop on.
+type Events uint8
+
+const (
+	// stop at the first instruction of the handler
+	BreakNMI Events = 1 << iota
+	BreakIRQ
+	BreakBRK
+	// stop before an RTI executes
+	BreakRTI
+)
+
+type eventName struct {
+	name string
+	e    Events
+}
+
+var eventNames = []eventName{
+	{"nmi", BreakNMI}, {"irq", BreakIRQ}, {"brk", BreakBRK}, {"rti", BreakRTI},
+}
+
+// ParseEvents parses a comma separated list of nmi, irq, brk and rti.
+func ParseEvents(s string) (Events, error) {
+	var e Events
+	for _, name := range strings.Split(s, ",") {
+		i := slices.IndexFunc(eventNames, func(n eventName) bool { return n.name == name })
+		if i < 0 {
+			if name == "" {
+				continue
+			}
+			return 0, fmt.Errorf("unknown interrupt event %q (want nmi, irq, brk or rti)", name)
+		}
+		e |= eventNames[i].e
+	}
+	return e, nil
+}
+
+// String lists the events as e.g. "nmi,rti".
+func (e Events) String() string {
+	var names []string
+	for _, n := range eventNames {
+		if e&n.e != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// SetBreakOn sets the interrupt events Continue stops on.
+func (d *Debugger) SetBreakOn(e Events) {
+	d.breakOn = e
+}
+
+// BreakOn returns the interrupt events Continue stops on.
+func (d *Debugger) BreakOn() Events {
+	return d.breakOn
+}
+
+// Emulator returns the emulator being debugged.
+func (d *Debugger) Emulator() *gemu.Emulator {
+	return d.emu
+}
+
+// AddBreakpoint stops Continue before the instruction at addr executes.
+func (d *Debugger) AddBreakpoint(addr uint16) {
+	d.breakpoints[addr] = true
+}
+
+// RemoveBreakpoint removes the breakpoint at addr, if there is one.
+func (d *Debugger) RemoveBreakpoint(addr uint16) {
+	delete(d.breakpoints, addr)
+}
+
+// Breakpoints returns the breakpoint addresses in ascending order.
+func (d *Debugger) Breakpoints() []uint16 {
+	return slices.Sorted(maps.Keys(d.breakpoints))
+}
+
+// AddWatch adds a watch expression (see Watch), replacing any watch with
+// the same name.
+func (d *Debugger) AddWatch(name, expr string) error {
+	w, err := ParseWatch(name, expr)
+	if err != nil {
+		return err
+	}
+	d.watches.Add(w)
+	return nil
+}
+
+// RemoveWatch removes the watch called name, if there is one.
+func (d *Debugger) RemoveWatch(name string) {
+	d.watches.Remove(name)
+}
+
+// Watches returns the watches in the order they were added.
+func (d *Debugger) Watches() Watches {
+	return slices.Clone(d.watches)
+}
+
+// WatchLines evaluates every watch as name=value.
+func (d *Debugger) WatchLines() []string {
+	return d.watches.Lines(d.emu.CPU(), d.emu.PeekMemory)
+}
+
+// Step executes one instruction.
+func (d *Debugger) Step() error {
+	c := d.emu.CPU()
+	pc, sp := c.GetPC(), c.SP
+	op := c.Pages().Read(pc)
+	cycles := c.TotalCycles
+	d.taken = d.taken[:0]
+	if err := d.emu.Step(); err != nil {
+		return err
+	}
+	if d.profile != nil {
+		d.profile.cycles[d.foldedStack()] += c.TotalCycles - cycles
+	}
+	d.track(op, pc, sp)
+	if len(d.history) == historySize {
+		d.history = d.history[1:]
+	}
+	d.history = append(d.history, pc)
+	return nil
+}
+
+// SoftReset presses the emulator's reset button. The call stack is
+// forgotten, as the reset abandons it.
+func (d *Debugger) SoftReset() {
+	d.emu.SoftReset()
+	d.forgetStack()
+}
+
+// PowerCycle switches the emulator off and on, forgetting the call stack
+// and history.
+func (d *Debugger) PowerCycle() error {
+	if err := d.emu.PowerCycle(); err != nil {
+		return err
+	}
+	d.forgetStack()
+	d.history = nil
+	return nil
+}
+
+func (d *Debugger) forgetStack() {
+	d.frames = nil
+	d.slots = [256]slot{}
+	d.stackKey = ""
+}
+
+// Continue runs until the pc reaches a breakpoint, the emulator stops with
+// an error, or ctx is cancelled. A breakpoint at the current pc doesn't
+// stop it straight away.
+func (d *Debugger) Continue(ctx context.Context) (StopReason, error) {
+	for n := 0; ; n++ {
+		if n > 0 && d.breakpoints[d.emu.CPU().GetPC()] {
+			return StopBreakpoint, nil
+		}
+		if n > 0 && d.breakOn&BreakRTI != 0 && d.emu.CPU().Pages().Read(d.emu.CPU().GetPC()) == opRTI {
+			return StopRTI, nil
+		}
+		// checking ctx every instruction would dominate the loop
+		if n%1024 == 0 && ctx.Err() != nil {
+			return StopCancelled, ctx.Err()
+		}
+		if err := d.Step(); err != nil {
+			return StopError, err
+		}
+		for _, kind := range d.taken {
+			if reason, ok := d.stopOn(kind); ok {
+				return reason, nil
+			}
+		}
+	}
+}
+
+// stopOn reports whether Continue stops for an interrupt of kind, and why.
+func (d *Debugger) stopOn(kind cpu.Interrupt) (StopReason, bool) {
+	switch kind {
+	case cpu.NMI:
+		return StopNMI, d.breakOn&BreakNMI != 0
+	case cpu.IRQ:
+		return StopIRQ, d.breakOn&BreakIRQ != 0
+	case cpu.BRK:
+		return StopBRK, d.breakOn&BreakBRK != 0
+	}
+	return 0, false
+}
+
+// interrupted is the cpu's interrupt hook. The handler's frame goes on the
+// call stack, with the pushed status and return address marked.
+func (d *Debugger) interrupted(kind cpu.Interrupt, from uint16) {
+	c := d.emu.CPU()
+	d.taken = append(d.taken, kind)
+	top := c.SP + 1
+	d.slots[top], d.slots[top+1], d.slots[top+2] = slotStatus, slotReturnLo, slotReturnHi
+	d.frames = append(d.frames, Frame{
+		Kind:      FrameInterrupt,
+		Interrupt: kind,
+		Caller:    from,
+		Target:    c.GetPC(),
+		SP:        c.SP,
+	})
+	d.stackKey = ""
+}
+
+// SetSourceMap sets the source map Source looks lines up in, such as a
+// *cc65.DebugInfo, or removes it if m is nil.
+func (d *Debugger) SetSourceMap(m gemu.SourceMap) {
+	d.source = m
+}
+
+// Source returns the source line that assembled the code at addr as
+// "file:line", or "" if there is no source map or it doesn't cover addr.
+func (d *Debugger) Source(addr uint16) string {
+	if d.source == nil {
+		return ""
+	}
+	file, line, ok := d.source.Lookup(addr)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// History returns the pcs of the most recently executed instructions,
+// oldest first.
+func (d *Debugger) History() []uint16 {
+	return slices.Clone(d.history)
+}
+
+// Registers is a snapshot of the cpu registers.
+type Registers struct {
+	PC         uint16
+	A, X, Y, P uint8
+	SP         uint8
+	Cycles     uint64
+}
+
+// Registers returns the current cpu registers.
+func (d *Debugger) Registers() Registers {
+	c := d.emu.CPU()
+	return Registers{
+		PC:     c.GetPC(),
+		A:      c.A.GetValue(),
+		X:      c.X.GetValue(),
+		Y:      c.Y.GetValue(),
+		P:      c.Flags.Value(),
+		SP:     c.SP,
+		Cycles: c.TotalCycles,
+	}
+}
+
+// Flag reports whether a status flag (gemu/gemu.Carry etc.) is set.
+func (r Registers) Flag(f uint8) bool {
+	return r.P&f != 0
+}
+
+// FlagString shows the status flags as NVUBDIZC, with a dash for each
+// clear flag.
+func (r Registers) FlagString() string {
+	b := []byte("NVUBDIZC")
+	for i := range b {
+		if r.P&(0x80>>i) == 0 {
+			b[i] = '-'
+		}
+	}
+	return string(b)
+}
+
+// Memory reads n bytes of cpu memory starting at addr, without the side
+// effects of reading registers (see Emulator.PeekMemory).
+func (d *Debugger) Memory(addr uint16, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = d.emu.PeekMemory(addr + uint16(i))
+	}
+	return b
+}
+
+// PPUMemory reads n bytes of the PPU's address space starting at addr.
+func (d *Debugger) PPUMemory(addr uint16, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = d.emu.PPU().PeekMemory(addr + uint16(i))
+	}
+	return b
+}
+
+// Stack returns the bytes on the stack, from the top (SP+1) down to $01FF.
+func (d *Debugger) Stack() []byte {
+	return d.emu.CPU().Stack()
+}
+
+// Disassemble decodes n instructions from addr, with the emulator's
+// automatic labels if it has them.
+func (d *Debugger) Disassemble(addr uint16, n int) []disasm.Line {
+	// three bytes per instruction at most
+	code := d.Memory(addr, n*3)
+	opts := disasm.Options{Origin: addr}
+	if l := d.emu.AutoLabels(); l != nil {
+		opts.Labels = l.Labels()
+	}
+	lines := disasm.Disassemble(code, opts)
+	return lines[:min(n, len(lines))]
+}
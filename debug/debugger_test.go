@@ -0,0 +1,68 @@
+package debug
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/goldmane/gemu"
+)
+
+// newNestest returns a debugger on nestest, just reset.
+func newNestest(t *testing.T) *Debugger {
+	t.Helper()
+	emu := gemu.NewEmulator()
+	emu.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := emu.LoadROM("../nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	return New(emu)
+}
+
+func TestContinueToBreakpoint(t *testing.T) {
+	d := newNestest(t)
+	// start nestest's automated run
+	d.Emulator().CPU().SetPC(0xC000)
+	// C5FD JSR $C72D, the first call
+	d.AddBreakpoint(0xC72D)
+	reason, err := d.Continue(context.Background())
+	if err != nil || reason != StopBreakpoint {
+		t.Fatalf("Continue stopped for %v, %v", reason, err)
+	}
+	if pc := d.Registers().PC; pc != 0xC72D {
+		t.Fatalf("stopped at $%04X, want $C72D", pc)
+	}
+	want := []uint16{0xC000, 0xC5F5, 0xC5F7, 0xC5F9, 0xC5FB, 0xC5FD}
+	if h := d.History(); !slices.Equal(h, want) {
+		t.Errorf("history %04X, want %04X", h, want)
+	}
+
+	frames := d.CallStack()
+	if len(frames) != 1 || frames[0].Kind != FrameCall || frames[0].Caller != 0xC5FD || frames[0].Target != 0xC72D {
+		t.Fatalf("call stack %+v, want the JSR at $C5FD", frames)
+	}
+	entries := d.StackEntries()
+	if len(entries) == 0 || entries[0].Kind != EntryReturn {
+		t.Fatalf("stack %v, want a return address on top", entries)
+	}
+	if got := entries[0].String(); got != "return to C600 from sub_C72D" {
+		t.Errorf("top of stack %q", got)
+	}
+
+	// the breakpoint under the pc doesn't stop it again
+	d.AddBreakpoint(0xC72E)
+	if reason, err := d.Continue(context.Background()); err != nil || reason != StopBreakpoint || d.Registers().PC != 0xC72E {
+		t.Errorf("Continue stopped at $%04X for %v, %v, want $C72E", d.Registers().PC, reason, err)
+	}
+}
+
+func TestContinueCancelled(t *testing.T) {
+	d := newNestest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if reason, err := d.Continue(ctx); reason != StopCancelled || err == nil {
+		t.Errorf("Continue with a cancelled context stopped for %v, %v", reason, err)
+	}
+}
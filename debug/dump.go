@@ -0,0 +1,145 @@
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrBadRange = errors.New("bad memory range")
+
+// Space is the memory a MemoryRange is in.
+type Space int
+
+const (
+	// SpaceCPU is the cpu's address space
+	SpaceCPU Space = iota
+	// SpacePPU is the PPU's: pattern tables, nametables and palette
+	SpacePPU
+	// SpaceOAM is the 256 bytes of sprite memory
+	SpaceOAM
+)
+
+// MemoryRange is an inclusive range of addresses in Space.
+type MemoryRange struct {
+	Start, End uint16
+	Space      Space
+}
+
+// Len returns the number of bytes in the range.
+func (r MemoryRange) Len() int {
+	return int(r.End) - int(r.Start) + 1
+}
+
+// namedRanges are the regions ParseRange knows by name.
+var namedRanges = map[string]MemoryRange{
+	"ram":     {0x0000, 0x07FF, SpaceCPU},
+	"prg-ram": {0x6000, 0x7FFF, SpaceCPU},
+	"prg":     {0x8000, 0xFFFF, SpaceCPU},
+	"cpu":     {0x0000, 0xFFFF, SpaceCPU},
+	"vram":    {0x2000, 0x2FFF, SpacePPU},
+	"palette": {0x3F00, 0x3F1F, SpacePPU},
+	"oam":     {0x00, 0xFF, SpaceOAM},
+}
+
+// ParseRange parses a memory range: a region name (ram, prg-ram, prg or
+// cpu, or the PPU's vram, palette or oam), a single cpu address such as
+// $0300, or an inclusive range of them such as $0200-$02FF.
+func ParseRange(s string) (MemoryRange, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if r, ok := namedRanges[name]; ok {
+		return r, nil
+	}
+
+	lo, hi, ranged := strings.Cut(name, "-")
+	start, err := parseHex(lo)
+	if err != nil {
+		return MemoryRange{}, fmt.Errorf("%w %q", ErrBadRange, s)
+	}
+	r := MemoryRange{Start: start, End: start}
+	if ranged {
+		if r.End, err = parseHex(hi); err != nil || r.End < r.Start {
+			return MemoryRange{}, fmt.Errorf("%w %q", ErrBadRange, s)
+		}
+	}
+	return r, nil
+}
+
+func parseHex(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "$"), "0x")
+	v, err := strconv.ParseUint(s, 16, 16)
+	return uint16(v), err
+}
+
+// DumpFormat is how a memory dump is written.
+type DumpFormat int
+
+const (
+	// DumpBinary writes the bytes as they are
+	DumpBinary DumpFormat = iota
+	// DumpHex writes lines of an address, 16 bytes in hex and their ASCII
+	DumpHex
+)
+
+// ParseDumpFormat parses "bin" or "hex".
+func ParseDumpFormat(s string) (DumpFormat, error) {
+	switch strings.ToLower(s) {
+	case "bin", "binary":
+		return DumpBinary, nil
+	case "hex":
+		return DumpHex, nil
+	}
+	return 0, fmt.Errorf("unknown dump format %q", s)
+}
+
+// DumpFormatFor picks the format for a dump file by its extension: hex for
+// .hex and .txt, binary for anything else.
+func DumpFormatFor(path string) DumpFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hex", ".txt":
+		return DumpHex
+	}
+	return DumpBinary
+}
+
+// Dump writes the memory in r to w, read as Memory or PPUMemory read it,
+// so dumping has no side effects.
+func (d *Debugger) Dump(w io.Writer, r MemoryRange, f DumpFormat) error {
+	var b []byte
+	switch r.Space {
+	case SpacePPU:
+		b = d.PPUMemory(r.Start, r.Len())
+	case SpaceOAM:
+		b = d.emu.PPU().OAM()[r.Start : int(r.End)+1]
+	default:
+		b = d.Memory(r.Start, r.Len())
+	}
+	if f == DumpBinary {
+		_, err := w.Write(b)
+		return err
+	}
+	return WriteHexDump(w, r.Start, b)
+}
+
+// WriteHexDump writes b, which starts at addr, as hex dump lines:
+//
+//	0200  A9 00 8D 00 20 ...  |.... ...|
+func WriteHexDump(w io.Writer, addr uint16, b []byte) error {
+	for off := 0; off < len(b); off += 16 {
+		row := b[off:min(off+16, len(b))]
+		ascii := make([]byte, len(row))
+		for i, c := range row {
+			ascii[i] = '.'
+			if c >= 0x20 && c < 0x7F {
+				ascii[i] = c
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%04X  %-47s  |%s|\n", int(addr)+off, fmt.Sprintf("% X", row), ascii); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package debug
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+// HeatmapImage draws one pixel per address, 256 addresses to a row so each
+// row is a page: red for writes, green for executes and blue for reads.
+// Each channel is scaled logarithmically to its busiest address, so rarely
+// touched addresses still show.
+func HeatmapImage(h *cpu.Heatmap) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	rs, ws, xs := scale(&h.Reads), scale(&h.Writes), scale(&h.Execs)
+	for a := range 0x10000 {
+		img.SetRGBA(a&0xFF, a>>8, color.RGBA{
+			R: level(h.Writes[a], ws),
+			G: level(h.Execs[a], xs),
+			B: level(h.Reads[a], rs),
+			A: 0xFF,
+		})
+	}
+	return img
+}
+
+// scale returns the factor mapping log(1+count) to 0-255 for counts.
+func scale(counts *[0x10000]uint32) float64 {
+	var top uint32
+	for _, n := range counts {
+		top = max(top, n)
+	}
+	if top == 0 {
+		return 0
+	}
+	return 255 / math.Log1p(float64(top))
+}
+
+func level(n uint32, scale float64) uint8 {
+	return uint8(math.Log1p(float64(n)) * scale)
+}
+
+// WriteHeatmapCSV writes address,reads,writes,execs rows for every address
+// accessed at least once.
+func WriteHeatmapCSV(w io.Writer, h *cpu.Heatmap) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"address", "reads", "writes", "execs"})
+	for a := range 0x10000 {
+		r, wr, x := h.Reads[a], h.Writes[a], h.Execs[a]
+		if r|wr|x == 0 {
+			continue
+		}
+		cw.Write([]string{
+			fmt.Sprintf("$%04X", a),
+			strconv.FormatUint(uint64(r), 10),
+			strconv.FormatUint(uint64(wr), 10),
+			strconv.FormatUint(uint64(x), 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
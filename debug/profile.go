@@ -0,0 +1,69 @@
+package debug
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Profile attributes executed cpu cycles to the call stack they ran under.
+// An instruction counts towards the stack it started in, so a JSR is the
+// caller's and an RTS the callee's.
+type Profile struct {
+	cycles map[string]uint64
+}
+
+// NewProfile returns an empty profile.
+func NewProfile() *Profile {
+	return &Profile{cycles: map[string]uint64{}}
+}
+
+// SetProfile starts attributing the cycles of each step to p, or stops if
+// p is nil. Calls made before the debugger was attached aren't known, so
+// their cycles count towards the outermost frame seen.
+func (d *Debugger) SetProfile(p *Profile) {
+	d.profile = p
+	d.stackKey = ""
+}
+
+// Total returns the cycles recorded.
+func (p *Profile) Total() uint64 {
+	var n uint64
+	for _, c := range p.cycles {
+		n += c
+	}
+	return n
+}
+
+// WriteFolded writes the profile in the folded stack format read by
+// flamegraph.pl and most flame graph viewers: one line per call stack,
+// outermost first and separated by semicolons, followed by its cycles.
+func (p *Profile) WriteFolded(w io.Writer) error {
+	for _, stack := range slices.Sorted(maps.Keys(p.cycles)) {
+		if _, err := fmt.Fprintf(w, "%s %d\n", stack, p.cycles[stack]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// profileRoot names the code running outside any call seen.
+const profileRoot = "main"
+
+// foldedStack returns the current call stack as a folded stack line,
+// reusing the last one while the call stack hasn't changed.
+func (d *Debugger) foldedStack() string {
+	if d.stackKey != "" {
+		return d.stackKey
+	}
+	var b strings.Builder
+	b.WriteString(profileRoot)
+	for _, f := range d.frames {
+		b.WriteByte(';')
+		b.WriteString(frameLabel(f))
+	}
+	d.stackKey = b.String()
+	return d.stackKey
+}
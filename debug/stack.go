@@ -0,0 +1,177 @@
+package debug
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+// FrameKind says how a call stack frame was entered.
+type FrameKind int
+
+const (
+	FrameCall FrameKind = iota
+	FrameInterrupt
+)
+
+// Frame is a subroutine call or interrupt whose return address is still on
+// the stack.
+type Frame struct {
+	Kind FrameKind
+	// Interrupt is what entered an interrupt frame
+	Interrupt cpu.Interrupt
+	// Caller is the pc of the JSR, or where the interrupt returns to
+	Caller uint16
+	// Target is the subroutine or interrupt handler entered
+	Target uint16
+	// SP is the stack pointer after the push; the frame starts at SP+1
+	SP uint8
+}
+
+// EntryKind says what a stack entry holds.
+type EntryKind int
+
+const (
+	EntryData EntryKind = iota
+	EntryStatus
+	EntryReturn
+)
+
+func (k EntryKind) String() string {
+	switch k {
+	case EntryStatus:
+		return "status"
+	case EntryReturn:
+		return "return"
+	}
+	return "data"
+}
+
+// StackEntry is one item on the stack: a two byte return address, a status
+// byte pushed by PHP or an interrupt, or a byte of data.
+type StackEntry struct {
+	Addr  uint16
+	Bytes []byte
+	Kind  EntryKind
+	// for return addresses, where RTS or RTI resumes
+	Return uint16
+	// and, when the call was seen, the subroutine it entered and its label
+	Target uint16
+	Label  string
+}
+
+func (e StackEntry) String() string {
+	switch e.Kind {
+	case EntryStatus:
+		return fmt.Sprintf("status %02X", e.Bytes[0])
+	case EntryReturn:
+		if e.Label == "" {
+			return fmt.Sprintf("return to %04X", e.Return)
+		}
+		return fmt.Sprintf("return to %04X from %s", e.Return, e.Label)
+	}
+	return fmt.Sprintf("data %02X", e.Bytes[0])
+}
+
+// slot marks what the debugger saw pushed to each stack byte.
+type slot uint8
+
+const (
+	slotData slot = iota
+	slotStatus
+	slotReturnLo
+	slotReturnHi
+)
+
+const (
+	opJSR = 0x20
+	opPHP = 0x08
+	opRTI = 0x40
+)
+
+// track updates the call stack after an instruction with opcode op ran at
+// pc and moved the stack pointer from sp.
+func (d *Debugger) track(op uint8, pc uint16, sp uint8) {
+	c := d.emu.CPU()
+	// frames whose return address has been pulled are gone, whether by RTS,
+	// RTI or the code dropping it itself
+	for len(d.frames) > 0 && c.SP > d.frames[len(d.frames)-1].SP {
+		d.frames = d.frames[:len(d.frames)-1]
+		d.stackKey = ""
+	}
+	// an interrupt marks the bytes it pushed itself
+	if c.SP >= sp || len(d.taken) > 0 {
+		return
+	}
+
+	top := c.SP + 1
+	switch {
+	case op == opJSR && sp-c.SP == 2:
+		d.slots[top], d.slots[top+1] = slotReturnLo, slotReturnHi
+		d.frames = append(d.frames, Frame{Kind: FrameCall, Caller: pc, Target: c.GetPC(), SP: c.SP})
+		d.stackKey = ""
+	case op == opPHP && sp-c.SP == 1:
+		d.slots[top] = slotStatus
+	default:
+		for s := top; s != sp+1; s++ {
+			d.slots[s] = slotData
+		}
+	}
+}
+
+// CallStack returns the calls and interrupts still on the stack, outermost
+// first. Only those entered while the debugger was attached are known.
+func (d *Debugger) CallStack() []Frame {
+	return slices.Clone(d.frames)
+}
+
+// StackEntries walks the stack from the top (SP+1) to $01FF, telling
+// return addresses, pushed status bytes and data apart by what the
+// debugger saw push them. Bytes pushed before it was attached are data.
+func (d *Debugger) StackEntries() []StackEntry {
+	c := d.emu.CPU()
+	stack := c.Stack()
+	// frames by the stack byte their return address starts at
+	frames := map[uint8]Frame{}
+	for _, f := range d.frames {
+		if f.Kind == FrameInterrupt {
+			frames[f.SP+2] = f
+		} else {
+			frames[f.SP+1] = f
+		}
+	}
+
+	var entries []StackEntry
+	for i := 0; i < len(stack); {
+		s := c.SP + 1 + uint8(i)
+		e := StackEntry{Addr: 0x0100 | uint16(s), Bytes: stack[i : i+1], Kind: EntryData}
+		switch {
+		case d.slots[s] == slotReturnLo && i+1 < len(stack) && d.slots[s+1] == slotReturnHi:
+			e.Bytes = stack[i : i+2]
+			e.Kind = EntryReturn
+			e.Return = uint16(e.Bytes[0]) | uint16(e.Bytes[1])<<8
+			f, ok := frames[s]
+			if !ok || f.Kind == FrameCall {
+				// RTS resumes after the address pushed
+				e.Return++
+			}
+			if ok {
+				e.Target, e.Label = f.Target, frameLabel(f)
+			}
+		case d.slots[s] == slotStatus:
+			e.Kind = EntryStatus
+		}
+		entries = append(entries, e)
+		i += len(e.Bytes)
+	}
+	return entries
+}
+
+// frameLabel names the routine a frame entered, as disasm.Labels would.
+func frameLabel(f Frame) string {
+	if f.Kind == FrameInterrupt {
+		return f.Interrupt.String()
+	}
+	return fmt.Sprintf("sub_%04X", f.Target)
+}
@@ -0,0 +1,140 @@
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+var ErrBadWatch = errors.New("bad watch expression")
+
+// Watch is a named expression evaluated against the cpu. An expression is
+// a register (a, x, y, p, sp or pc) or a hex address, read as a byte:
+//
+//	$0300     the byte at $0300
+//	$0300,x   the byte at $0300+X (or ,y)
+//	$10.w     the little-endian word at $10 and $11
+//	$10,y.w   the word at $10+Y
+type Watch struct {
+	Name string
+	Expr string
+
+	reg   string
+	addr  uint16
+	index string
+	word  bool
+}
+
+// ParseWatch parses expr as a watch called name.
+func ParseWatch(name, expr string) (Watch, error) {
+	w := Watch{Name: name, Expr: expr}
+	s := strings.ToLower(strings.TrimSpace(expr))
+	switch s {
+	case "a", "x", "y", "p", "sp", "pc":
+		w.reg = s
+		return w, nil
+	}
+
+	s, w.word = strings.CutSuffix(s, ".w")
+	s, index, indexed := strings.Cut(s, ",")
+	if indexed {
+		if index != "x" && index != "y" {
+			return Watch{}, fmt.Errorf("%w %q: index must be x or y", ErrBadWatch, expr)
+		}
+		w.index = index
+	}
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), "0x")
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return Watch{}, fmt.Errorf("%w %q", ErrBadWatch, expr)
+	}
+	w.addr = uint16(v)
+	return w, nil
+}
+
+// Value evaluates the watch on c, reading memory through peek, which
+// should have no side effects, such as Emulator.PeekMemory.
+func (w Watch) Value(c *cpu.CPU, peek func(addr uint16) uint8) uint16 {
+	switch w.reg {
+	case "a":
+		return uint16(c.A.GetValue())
+	case "x":
+		return uint16(c.X.GetValue())
+	case "y":
+		return uint16(c.Y.GetValue())
+	case "p":
+		return uint16(c.Flags.Value())
+	case "sp":
+		return uint16(c.SP)
+	case "pc":
+		return c.GetPC()
+	}
+
+	addr := w.addr
+	switch w.index {
+	case "x":
+		addr += uint16(c.X.GetValue())
+	case "y":
+		addr += uint16(c.Y.GetValue())
+	}
+	v := uint16(peek(addr))
+	if w.word {
+		v |= uint16(peek(addr+1)) << 8
+	}
+	return v
+}
+
+// Wide reports whether the watch is 16 bits wide.
+func (w Watch) Wide() bool {
+	return w.word || w.reg == "pc"
+}
+
+// FormatValue evaluates the watch and shows it as $XX, or $XXXX when wide.
+func (w Watch) FormatValue(c *cpu.CPU, peek func(addr uint16) uint8) string {
+	if w.Wide() {
+		return fmt.Sprintf("$%04X", w.Value(c, peek))
+	}
+	return fmt.Sprintf("$%02X", w.Value(c, peek))
+}
+
+// Format shows the watch as name=value.
+func (w Watch) Format(c *cpu.CPU, peek func(addr uint16) uint8) string {
+	return w.Name + "=" + w.FormatValue(c, peek)
+}
+
+// Watches is an ordered list of watches, one per name.
+type Watches []Watch
+
+// Add adds w, replacing any watch with the same name.
+func (ws *Watches) Add(w Watch) {
+	for i := range *ws {
+		if (*ws)[i].Name == w.Name {
+			(*ws)[i] = w
+			return
+		}
+	}
+	*ws = append(*ws, w)
+}
+
+// Remove removes the watch called name and reports whether there was one.
+func (ws *Watches) Remove(name string) bool {
+	for i := range *ws {
+		if (*ws)[i].Name == name {
+			*ws = append((*ws)[:i], (*ws)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Lines formats every watch, for the emulator overlay or a debugger pane.
+func (ws Watches) Lines(c *cpu.CPU, peek func(addr uint16) uint8) []string {
+	lines := make([]string, len(ws))
+	for i, w := range ws {
+		lines[i] = w.Format(c, peek)
+	}
+	return lines
+}
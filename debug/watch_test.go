@@ -0,0 +1,68 @@
+package debug
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWatchValue(t *testing.T) {
+	d := newNestest(t)
+	emu := d.Emulator()
+	c := emu.CPU()
+	c.SetPC(0xC000)
+	c.X.SetRegister(0x02)
+	c.Y.SetRegister(0x04)
+	for i, b := range []uint8{0x11, 0x22, 0x33, 0x44, 0x55, 0x66} {
+		c.Store(0x0300+uint16(i), b)
+	}
+	for _, tc := range []struct {
+		expr string
+		want string
+	}{
+		{"x", "$02"},
+		{"pc", "$C000"},
+		{"$0300", "$11"},
+		{"0x300,x", "$33"},
+		{"$0300.w", "$2211"},
+		{"$0300,y.w", "$6655"},
+	} {
+		w, err := ParseWatch("w", tc.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		if got := w.FormatValue(c, emu.PeekMemory); got != tc.want {
+			t.Errorf("%s = %s, want %s", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestWatchKeepsVBlank(t *testing.T) {
+	d := newNestest(t)
+	emu := d.Emulator()
+	for emu.PeekMemory(0x2002)&0x80 == 0 {
+		if err := d.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.AddWatch("status", "$2002"); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if v := d.Watches()[0].Value(emu.CPU(), emu.PeekMemory); v&0x80 == 0 {
+			t.Fatalf("$2002 watch reads $%02X, want vblank set", v)
+		}
+	}
+	d.WatchLines()
+	if emu.PeekMemory(0x2002)&0x80 == 0 {
+		t.Error("evaluating a $2002 watch ended vblank")
+	}
+}
+
+func TestParseWatchErrors(t *testing.T) {
+	for _, expr := range []string{"", "q", "$10,z", "$10000", "$1G"} {
+		if _, err := ParseWatch("w", expr); !errors.Is(err, ErrBadWatch) {
+			t.Errorf("%q: error %v, want ErrBadWatch", expr, err)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package debug
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// WebServer serves a single page debugger for d over HTTP, so a headless
+// instance can be debugged from a browser. The page polls a small JSON API:
+//
+//	GET    /api/state               registers, disassembly, stack, breakpoints,
+//	                                watches
+//	GET    /api/memory?addr=        256 bytes of memory
+//	GET    /api/dump?range=&format= download a memory range (bin or hex)
+//	POST   /api/step?n=             step n instructions
+//	POST   /api/continue            run until a breakpoint or /api/pause
+//	POST   /api/pause
+//	POST   /api/breakpoints?addr=   add a breakpoint
+//	DELETE /api/breakpoints?addr=   remove one
+//	PUT    /api/breakon?events=     stop on interrupts, e.g. nmi,irq,brk,rti
+//	POST   /api/watches?name=&expr= add a watch expression
+//	DELETE /api/watches?name=       remove one
+//	POST   /api/reset               press reset
+//	POST   /api/power               switch off and on
+type WebServer struct {
+	mu      sync.Mutex
+	d       *Debugger
+	running context.CancelFunc
+	status  string
+
+	mux *http.ServeMux
+}
+
+// NewWebServer returns a web debugger for d.
+func NewWebServer(d *Debugger) *WebServer {
+	s := &WebServer{d: d, mux: http.NewServeMux()}
+	page, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	s.mux.Handle("GET /", http.FileServerFS(page))
+	s.mux.HandleFunc("GET /api/state", s.state)
+	s.mux.HandleFunc("GET /api/memory", s.memory)
+	s.mux.HandleFunc("GET /api/dump", s.dump)
+	s.mux.HandleFunc("POST /api/step", s.step)
+	s.mux.HandleFunc("POST /api/continue", s.cont)
+	s.mux.HandleFunc("POST /api/pause", s.pause)
+	s.mux.HandleFunc("POST /api/breakpoints", s.breakpoint(true))
+	s.mux.HandleFunc("DELETE /api/breakpoints", s.breakpoint(false))
+	s.mux.HandleFunc("PUT /api/breakon", s.setBreakOn)
+	s.mux.HandleFunc("POST /api/watches", s.addWatch)
+	s.mux.HandleFunc("DELETE /api/watches", s.removeWatch)
+	s.mux.HandleFunc("POST /api/reset", s.reset(false))
+	s.mux.HandleFunc("POST /api/power", s.reset(true))
+	return s
+}
+
+func (s *WebServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type webLine struct {
+	Addr   uint16 `json:"addr"`
+	Bytes  string `json:"bytes"`
+	Text   string `json:"text"`
+	Source string `json:"source,omitempty"`
+}
+
+type webState struct {
+	Running     bool       `json:"running"`
+	Status      string     `json:"status"`
+	Registers   Registers  `json:"registers"`
+	Flags       string     `json:"flags"`
+	Disassembly []webLine  `json:"disassembly"`
+	Stack       []webLine  `json:"stack"`
+	Breakpoints []uint16   `json:"breakpoints"`
+	BreakOn     string     `json:"breakOn"`
+	Watches     []webWatch `json:"watches"`
+}
+
+type webWatch struct {
+	Name  string `json:"name"`
+	Expr  string `json:"expr"`
+	Value string `json:"value"`
+}
+
+func (s *WebServer) state(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	regs := s.d.Registers()
+	st := webState{
+		Running:     s.running != nil,
+		Status:      s.status,
+		Registers:   regs,
+		Flags:       regs.FlagString(),
+		Breakpoints: s.d.Breakpoints(),
+		BreakOn:     s.d.BreakOn().String(),
+	}
+	for _, e := range s.d.StackEntries() {
+		st.Stack = append(st.Stack, webLine{Addr: e.Addr, Bytes: fmt.Sprintf("% X", e.Bytes), Text: e.String()})
+	}
+	emu := s.d.Emulator()
+	for _, wt := range s.d.Watches() {
+		value := wt.FormatValue(emu.CPU(), emu.PeekMemory)
+		st.Watches = append(st.Watches, webWatch{Name: wt.Name, Expr: wt.Expr, Value: value})
+	}
+	for _, l := range s.d.Disassemble(regs.PC, 24) {
+		st.Disassembly = append(st.Disassembly, webLine{
+			Addr:   l.Addr,
+			Bytes:  fmt.Sprintf("% X", l.Bytes),
+			Text:   l.Mnemonic + " " + l.Operand,
+			Source: s.d.Source(l.Addr),
+		})
+	}
+	s.mu.Unlock()
+	writeJSON(w, st)
+}
+
+func (s *WebServer) memory(w http.ResponseWriter, r *http.Request) {
+	addr, ok := addrParam(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	b := s.d.Memory(addr&^0x0F, 256)
+	s.mu.Unlock()
+	writeJSON(w, map[string]any{"addr": addr &^ 0x0F, "data": b})
+}
+
+// dump downloads a memory range, ?range=$0200-$02FF&format=hex, as binary
+// by default.
+func (s *WebServer) dump(w http.ResponseWriter, r *http.Request) {
+	rng, err := ParseRange(r.URL.Query().Get("range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f := DumpBinary
+	if v := r.URL.Query().Get("format"); v != "" {
+		if f, err = ParseDumpFormat(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var buf bytes.Buffer
+	s.mu.Lock()
+	s.d.Dump(&buf, rng, f)
+	s.mu.Unlock()
+	if f == DumpHex {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%04X-%04X.bin"`, rng.Start, rng.End))
+	}
+	w.Write(buf.Bytes())
+}
+
+func (s *WebServer) step(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || n < 1 {
+		n = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != nil {
+		http.Error(w, "running", http.StatusConflict)
+		return
+	}
+	s.status = ""
+	for i := 0; i < n; i++ {
+		if err := s.d.Step(); err != nil {
+			s.status = err.Error()
+			break
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// timeSlice is how long the emulator runs between letting other requests
+// at the debugger while continuing.
+const timeSlice = 20 * time.Millisecond
+
+func (s *WebServer) cont(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.running = cancel
+	s.status = "running"
+	go func() {
+		for {
+			slice, done := context.WithTimeout(ctx, timeSlice)
+			s.mu.Lock()
+			reason, err := s.d.Continue(slice)
+			done()
+			if reason != StopCancelled || ctx.Err() != nil {
+				s.status = "stopped: " + reason.String()
+				if reason == StopError {
+					s.status += ": " + err.Error()
+				}
+				s.running = nil
+				s.mu.Unlock()
+				cancel()
+				return
+			}
+			s.mu.Unlock()
+		}
+	}()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *WebServer) pause(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if s.running != nil {
+		s.running()
+	}
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *WebServer) breakpoint(add bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr, ok := addrParam(w, r)
+		if !ok {
+			return
+		}
+		s.mu.Lock()
+		if add {
+			s.d.AddBreakpoint(addr)
+		} else {
+			s.d.RemoveBreakpoint(addr)
+		}
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *WebServer) setBreakOn(w http.ResponseWriter, r *http.Request) {
+	e, err := ParseEvents(r.URL.Query().Get("events"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.d.SetBreakOn(e)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *WebServer) addWatch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("name") == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	err := s.d.AddWatch(q.Get("name"), q.Get("expr"))
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *WebServer) removeWatch(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.d.RemoveWatch(r.URL.Query().Get("name"))
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *WebServer) reset(power bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.running != nil {
+			http.Error(w, "running", http.StatusConflict)
+			return
+		}
+		s.status = ""
+		if !power {
+			s.d.SoftReset()
+		} else if err := s.d.PowerCycle(); err != nil {
+			s.status = err.Error()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func addrParam(w http.ResponseWriter, r *http.Request) (uint16, bool) {
+	v, err := strconv.ParseUint(r.URL.Query().Get("addr"), 0, 16)
+	if err != nil {
+		http.Error(w, "addr must be a 16 bit address", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint16(v), true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,310 @@
+package gemu
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/goldmane/gemu/cpu"
+	"github.com/goldmane/gemu/disasm"
+	"github.com/goldmane/gemu/gemu"
+)
+
+// DefaultDiagnosticsHistory is how many instructions a bundle's trace has
+// unless Diagnostics.History says otherwise.
+const DefaultDiagnosticsHistory = 256
+
+// freezeWindow is how far apart, in bytes, the pcs of a frame may be for
+// the cpu to count as stuck in one loop.
+const freezeWindow = 16
+
+// Diagnostics writes a bundle to Dir when the run stops on an unknown
+// opcode, a bus fault or a freeze, for attaching to bug reports. A bundle
+// is one zip with a report of the ROM, its hash, the settings and the
+// registers, the last instructions executed, a screenshot and dumps of
+// RAM; gemu has no save states to put in yet.
+type Diagnostics struct {
+	// Dir is the folder bundles are written to, one per game folder name
+	// and time.
+	Dir string
+	// History is how many of the last instructions the trace keeps; 0 is
+	// DefaultDiagnosticsHistory.
+	History int
+	// FreezeFrames is how many frames in a row the cpu may stay in one
+	// small loop, with RAM and the picture unchanged, before the run stops
+	// with a FreezeError. 0 never stops it.
+	FreezeFrames int
+
+	e *Emulator
+	// ring of the last instructions, oldest at next once full
+	ring []diagRecord
+	next int
+	full bool
+
+	// pcs seen this frame, and the state at the end of the last one
+	lo, hi   uint16
+	seen     bool
+	ramHash  uint32
+	picHash  uint32
+	still    int
+	bundle   string
+	writeErr error
+}
+
+// diagRecord is the cpu's state before one instruction.
+type diagRecord struct {
+	pc             uint16
+	a, x, y, p, sp uint8
+	cycles         uint64
+}
+
+// FreezeError is returned when a run with Diagnostics.FreezeFrames set
+// spends that many frames in the loop from Start to End without changing
+// RAM or the picture.
+type FreezeError struct {
+	Start, End uint16
+	Frames     int
+}
+
+func (e *FreezeError) Error() string {
+	return fmt.Sprintf("frozen in $%04X-$%04X for %d frames", e.Start, e.End, e.Frames)
+}
+
+// SetDiagnostics writes a diagnostics bundle whenever the run stops on an
+// unknown opcode, a bus fault or a freeze, see Diagnostics. A nil d turns
+// them off.
+func (e *Emulator) SetDiagnostics(d *Diagnostics) {
+	e.diagnostics = d
+	if d != nil {
+		d.e = e
+		n := d.History
+		if n <= 0 {
+			n = DefaultDiagnosticsHistory
+		}
+		d.ring = make([]diagRecord, n)
+		d.next, d.full = 0, false
+		d.seen, d.still = false, 0
+	}
+}
+
+// Diagnostics returns the diagnostics set by SetDiagnostics, or nil.
+func (e *Emulator) Diagnostics() *Diagnostics {
+	return e.diagnostics
+}
+
+// Bundle returns the path of the last bundle written, or "" if there
+// hasn't been one.
+func (d *Diagnostics) Bundle() string {
+	return d.bundle
+}
+
+// Err returns why the last bundle couldn't be written, if it couldn't.
+func (d *Diagnostics) Err() error {
+	return d.writeErr
+}
+
+// record notes the cpu's state before it executes the instruction at pc.
+func (d *Diagnostics) record(c *cpu.CPU, pc uint16) {
+	d.ring[d.next] = diagRecord{
+		pc: pc,
+		a:  c.A.GetValue(), x: c.X.GetValue(), y: c.Y.GetValue(),
+		p: c.Flags.Value(), sp: c.SP,
+		cycles: c.TotalCycles,
+	}
+	d.next++
+	if d.next == len(d.ring) {
+		d.next, d.full = 0, true
+	}
+	if !d.seen {
+		d.lo, d.hi, d.seen = pc, pc, true
+	}
+	d.lo, d.hi = min(d.lo, pc), max(d.hi, pc)
+}
+
+// endFrame checks for a freeze once a frame has run, and returns a
+// FreezeError once the cpu has been stuck for FreezeFrames. It must see the
+// picture as the PPU left it, before the overlay or messages are drawn; a
+// frame that wasn't rendered keeps the last one's hash.
+func (d *Diagnostics) endFrame(rendered bool) error {
+	if d.FreezeFrames <= 0 {
+		return nil
+	}
+	lo, hi, seen := d.lo, d.hi, d.seen
+	d.seen = false
+	ram := crc32.ChecksumIEEE(d.e.cpu.GetMemory()[:0x0800])
+	pic := d.picHash
+	if rendered {
+		pic = crc32.ChecksumIEEE(d.e.frame.Pix)
+	}
+	if !seen || hi-lo >= freezeWindow || ram != d.ramHash || pic != d.picHash {
+		d.ramHash, d.picHash, d.still = ram, pic, 0
+		return nil
+	}
+	d.still++
+	if d.still < d.FreezeFrames {
+		return nil
+	}
+	d.still = 0
+	return &FreezeError{Start: lo, End: hi, Frames: d.FreezeFrames}
+}
+
+// crashed reports whether err is one a bundle is written for.
+func crashed(err error) bool {
+	var (
+		unknown *UnknownOpcodeError
+		fault   *BusFaultError
+		freeze  *FreezeError
+	)
+	return errors.As(err, &unknown) || errors.As(err, &fault) || errors.As(err, &freeze)
+}
+
+// capture writes a bundle for err, logging where it went.
+func (d *Diagnostics) capture(err error) {
+	path, werr := d.Write(err)
+	d.bundle, d.writeErr = "", werr
+	if werr != nil {
+		d.e.logger.Error("writing diagnostics bundle", "err", werr)
+		return
+	}
+	d.bundle = path
+	d.e.logger.Error("emulation stopped, diagnostics bundle written", "err", err, "path", path)
+}
+
+// Write writes a bundle for the run stopping with err and returns its
+// path. It is called for crashes and freezes by itself; call it to capture
+// anything else worth a bug report.
+func (d *Diagnostics) Write(cause error) (string, error) {
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return "", err
+	}
+	name := d.e.GameFolder() + "-" + time.Now().Format("20060102-150405.000") + ".zip"
+	path := filepath.Join(d.Dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	err = d.writeZip(f, cause)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+func (d *Diagnostics) writeZip(w io.Writer, cause error) error {
+	z := zip.NewWriter(w)
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"report.txt", func(w io.Writer) error { return d.writeReport(w, cause) }},
+		{"trace.txt", d.writeTrace},
+		{"screenshot.png", func(w io.Writer) error { return png.Encode(w, d.e.frame) }},
+		{"ram.bin", func(w io.Writer) error {
+			_, err := w.Write(d.e.cpu.GetMemory()[:0x0800])
+			return err
+		}},
+	}
+	if m, ok := d.e.cart.Mapper.(gemu.RAMMapper); ok && len(m.WorkRAM()) > 0 {
+		files = append(files, struct {
+			name  string
+			write func(io.Writer) error
+		}{"prg-ram.bin", func(w io.Writer) error {
+			_, err := w.Write(m.WorkRAM())
+			return err
+		}})
+	}
+	now := time.Now()
+	for _, file := range files {
+		fw, err := z.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if err := file.write(fw); err != nil {
+			return fmt.Errorf("%s: %w", file.name, err)
+		}
+	}
+	return z.Close()
+}
+
+// writeReport writes what went wrong and what was running.
+func (d *Diagnostics) writeReport(w io.Writer, cause error) error {
+	e := d.e
+	var b strings.Builder
+	fmt.Fprintf(&b, "error:        %v\n", cause)
+	fmt.Fprintf(&b, "time:         %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "go:           %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "rom:          %s\n", e.romPath)
+	fmt.Fprintf(&b, "crc32:        %08x\n", e.cart.CRC32())
+	if game, ok := e.Game(); ok {
+		fmt.Fprintf(&b, "game:         %s\n", game.Name)
+	}
+	fmt.Fprintf(&b, "mapper:       %d\n", e.cart.MapperID())
+	fmt.Fprintln(&b)
+	forced := ""
+	if e.regionOverride != nil {
+		forced = " (forced)"
+	}
+	fmt.Fprintf(&b, "region:       %s%s\n", e.region.Name, forced)
+	fmt.Fprintf(&b, "overclock:    %d scanlines\n", e.overclock)
+	fmt.Fprintf(&b, "frame skip:   %d\n", e.frameSkip)
+	if e.patch != "" {
+		fmt.Fprintf(&b, "patch:        %s\n", e.patch)
+	}
+	for i, p := range e.ports {
+		fmt.Fprintf(&b, "port %d:       %T\n", i+1, p)
+	}
+	fmt.Fprintf(&b, "expansion:    %T\n", e.expansion)
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "frame:        %d\n", e.Frame())
+	fmt.Fprintf(&b, "instructions: %d\n", e.counter)
+	fmt.Fprintf(&b, "pc:           $%04X\n", e.cpu.GetPC())
+	fmt.Fprintf(&b, "registers:    %s\n", e.cpu.AppendState(nil))
+	fmt.Fprintf(&b, "stack:        % X\n", e.cpu.Stack())
+	var fault *BusFaultError
+	if errors.As(cause, &fault) {
+		fmt.Fprintf(&b, "\n%s", fault.Stack)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTrace writes the last instructions, oldest first, disassembled from
+// memory as the cpu now sees it, peeked so that code in the register pages
+// doesn't disturb them.
+func (d *Diagnostics) writeTrace(w io.Writer) error {
+	records := d.ring[:d.next]
+	if d.full {
+		records = append(append([]diagRecord(nil), d.ring[d.next:]...), d.ring[:d.next]...)
+	}
+	opts := disasm.Options{}
+	if d.e.labels != nil {
+		opts.Labels = d.e.labels.Labels()
+	}
+	for _, r := range records {
+		code := make([]byte, 3)
+		for i := range code {
+			code[i] = d.e.PeekMemory(r.pc + uint16(i))
+		}
+		opts.Origin = r.pc
+		line := disasm.Disassemble(code, opts)[0]
+		text := strings.TrimSpace(line.Mnemonic + " " + line.Operand)
+		if _, err := fmt.Fprintf(w, "%04X  %-9s %-14s A:%02X X:%02X Y:%02X P:%02X SP:%02X CYC:%d\n",
+			r.pc, fmt.Sprintf("% X", line.Bytes), text, r.a, r.x, r.y, r.p, r.sp, r.cycles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package gemu
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFreezeUnderOverlay(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Patch(0xC000, "loop: JMP loop"); err != nil {
+		t.Fatal(err)
+	}
+	e.cpu.SetPC(0xC000)
+	// the overlay's counters change every frame, but the game's picture
+	// doesn't
+	e.SetOverlay(true)
+	e.ShowMessage("stuck")
+	d := &Diagnostics{Dir: t.TempDir(), FreezeFrames: 5}
+	e.SetDiagnostics(d)
+
+	var freeze *FreezeError
+	for i := 0; i < 10; i++ {
+		err := e.RunFrame()
+		if err == nil {
+			continue
+		}
+		if !errors.As(err, &freeze) {
+			t.Fatal(err)
+		}
+		break
+	}
+	if freeze == nil {
+		t.Fatal("no freeze found in 10 frames")
+	}
+	if freeze.Start != 0xC000 || freeze.End != 0xC000 {
+		t.Errorf("frozen in $%04X-$%04X, want $C000", freeze.Start, freeze.End)
+	}
+	if d.Bundle() == "" {
+		t.Errorf("no bundle written: %v", d.Err())
+	}
+}
@@ -0,0 +1,295 @@
+package disasm
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+// Block is a basic block: a run of instructions entered only at Start and
+// left only at its last instruction.
+type Block struct {
+	Start, End uint16 // End is the address after the last instruction
+	// Succ are the blocks control can pass to, not counting JSR calls
+	Succ []uint16
+	// Calls are the subroutines called from the block
+	Calls []uint16
+	// Sub is the entry of the subroutine the block was first reached from
+	Sub uint16
+}
+
+// Subroutine is a set of blocks reached from an entry point without
+// following calls.
+type Subroutine struct {
+	Name   string
+	Entry  uint16
+	Start  uint16
+	End    uint16 // address after the highest block
+	Blocks []uint16
+}
+
+// Graph is the control flow graph of a PRG image.
+type Graph struct {
+	Origin      uint16
+	Size        int
+	Blocks      map[uint16]*Block
+	Subroutines []*Subroutine
+	// code holds the addresses of every byte reached as an instruction
+	code []bool
+}
+
+// Analyze builds the control flow graph of code mapped at origin, starting
+// from the reset, NMI and IRQ vectors and following branches, JMP and JSR.
+// Targets outside code, and indirect jumps, are not followed. When code is
+// 16KB or 32KB it is taken to fill $8000-$FFFF, mirrored as NROM does.
+func Analyze(code []byte, origin uint16) *Graph {
+	g := &Graph{
+		Origin: origin,
+		Size:   len(code),
+		Blocks: map[uint16]*Block{},
+		code:   make([]bool, len(code)),
+	}
+
+	entries := map[uint16]string{}
+	var roots []uint16
+	for _, v := range vectors {
+		lo, ok1 := g.offset(v.addr)
+		hi, ok2 := g.offset(v.addr + 1)
+		if !ok1 || !ok2 {
+			continue
+		}
+		target, ok := g.canon(uint16(code[lo]) | uint16(code[hi])<<8)
+		if !ok {
+			continue
+		}
+		if _, seen := entries[target]; !seen {
+			entries[target] = v.name
+			roots = append(roots, target)
+		}
+	}
+
+	// first find every reachable instruction and the block leaders
+	leaders := map[uint16]bool{}
+	starts := map[uint16]bool{}
+	queue := slices.Clone(roots)
+	for _, r := range roots {
+		leaders[r] = true
+	}
+	for len(queue) > 0 {
+		addr := queue[0]
+		queue = queue[1:]
+		for {
+			off, ok := g.offset(addr)
+			if !ok || starts[addr] {
+				break
+			}
+			ins, ok := cpu.Lookup(code[off])
+			if !ok || off+ins.Length > len(code) {
+				break
+			}
+			starts[addr] = true
+			for i := 0; i < ins.Length; i++ {
+				g.code[off+i] = true
+			}
+
+			next := addr + uint16(ins.Length)
+			jumps, falls := successors(ins, addr, code[off:off+ins.Length])
+			for _, t := range jumps {
+				t, ok := g.canon(t)
+				if !ok {
+					continue
+				}
+				leaders[t] = true
+				queue = append(queue, t)
+				if ins.Label == "JSR" {
+					if _, seen := entries[t]; !seen {
+						entries[t] = fmt.Sprintf("sub_%04X", t)
+					}
+				}
+			}
+			if !falls {
+				break
+			}
+			if ins.AddressMode == cpu.Relative {
+				leaders[next] = true
+			}
+			addr = next
+		}
+	}
+
+	// then cut the reachable instructions into blocks at the leaders
+	for _, start := range slices.Sorted(maps.Keys(leaders)) {
+		if !starts[start] {
+			continue
+		}
+		b := &Block{Start: start}
+		addr := start
+		for {
+			off, _ := g.offset(addr)
+			ins, _ := cpu.Lookup(code[off])
+			next := addr + uint16(ins.Length)
+			jumps, falls := successors(ins, addr, code[off:off+ins.Length])
+			for _, t := range jumps {
+				if t, ok := g.canon(t); ok && starts[t] {
+					if ins.Label == "JSR" {
+						b.Calls = append(b.Calls, t)
+					} else {
+						b.Succ = append(b.Succ, t)
+					}
+				}
+			}
+			b.End = next
+			if !falls || !starts[next] {
+				break
+			}
+			if leaders[next] {
+				b.Succ = append(b.Succ, next)
+				break
+			}
+			addr = next
+		}
+		g.Blocks[start] = b
+	}
+
+	// and group the blocks into subroutines, vectors first
+	for _, entry := range append(roots, sortedCalls(entries, roots)...) {
+		if g.Blocks[entry] == nil {
+			continue
+		}
+		s := &Subroutine{Name: entries[entry], Entry: entry, Start: entry, End: entry}
+		work := []uint16{entry}
+		seen := map[uint16]bool{entry: true}
+		for len(work) > 0 {
+			b := g.Blocks[work[0]]
+			work = work[1:]
+			if b.Sub != 0 && b.Start != entry {
+				continue
+			}
+			b.Sub = entry
+			s.Blocks = append(s.Blocks, b.Start)
+			s.Start = min(s.Start, b.Start)
+			s.End = max(s.End, b.End)
+			for _, t := range b.Succ {
+				if !seen[t] && g.Blocks[t] != nil {
+					seen[t] = true
+					work = append(work, t)
+				}
+			}
+		}
+		slices.Sort(s.Blocks)
+		g.Subroutines = append(g.Subroutines, s)
+	}
+	return g
+}
+
+func sortedCalls(entries map[uint16]string, roots []uint16) []uint16 {
+	var calls []uint16
+	for _, a := range slices.Sorted(maps.Keys(entries)) {
+		if !slices.Contains(roots, a) {
+			calls = append(calls, a)
+		}
+	}
+	return calls
+}
+
+// successors returns the static targets of an instruction and whether it
+// can fall through to the next one.
+func successors(ins cpu.Instruction, addr uint16, raw []byte) ([]uint16, bool) {
+	switch {
+	case ins.AddressMode == cpu.Relative:
+		return []uint16{BranchTarget(addr, raw[1])}, true
+	case ins.Label == "JSR":
+		return []uint16{uint16(raw[1]) | uint16(raw[2])<<8}, true
+	case ins.Label == "JMP" && ins.AddressMode == cpu.Absolute:
+		return []uint16{uint16(raw[1]) | uint16(raw[2])<<8}, false
+	case ins.Label == "JMP", ins.Label == "RTS", ins.Label == "RTI", ins.Label == "BRK":
+		return nil, false
+	}
+	return nil, true
+}
+
+// offset maps a cpu address to an offset in code.
+func (g *Graph) offset(addr uint16) (int, bool) {
+	if int(addr) >= int(g.Origin) && int(addr)-int(g.Origin) < g.Size {
+		return int(addr - g.Origin), true
+	}
+	if (g.Size == 0x4000 || g.Size == 0x8000) && addr >= 0x8000 {
+		return int(addr-0x8000) % g.Size, true
+	}
+	return 0, false
+}
+
+// canon maps a mirrored address to the one in the origin's range.
+func (g *Graph) canon(addr uint16) (uint16, bool) {
+	off, ok := g.offset(addr)
+	return g.Origin + uint16(off), ok
+}
+
+// Unreached returns the ranges of code never reached as an instruction,
+// as [start, end) address pairs: data, or dead code.
+func (g *Graph) Unreached() [][2]uint16 {
+	var out [][2]uint16
+	for i := 0; i < len(g.code); {
+		if g.code[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(g.code) && !g.code[j] {
+			j++
+		}
+		out = append(out, [2]uint16{g.Origin + uint16(i), g.Origin + uint16(j)})
+		i = j
+	}
+	return out
+}
+
+// WriteSubroutines lists each subroutine's bounds and size in blocks.
+func (g *Graph) WriteSubroutines(w io.Writer) error {
+	for _, s := range g.Subroutines {
+		if _, err := fmt.Fprintf(w, "%04X-%04X  %-12s %d blocks\n", s.Start, s.End-1, s.Name, len(s.Blocks)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDOT writes the graph in Graphviz DOT format, one cluster per
+// subroutine, with calls as dashed edges between clusters.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	p := &errWriter{w: w}
+	p.printf("digraph prg {\n\tnode [shape=box fontname=monospace];\n")
+	for _, s := range g.Subroutines {
+		p.printf("\tsubgraph cluster_%04X {\n\t\tlabel=%q;\n", s.Entry, s.Name)
+		for _, start := range s.Blocks {
+			b := g.Blocks[start]
+			p.printf("\t\tb%04X [label=\"%04X-%04X\"];\n", b.Start, b.Start, b.End-1)
+		}
+		p.printf("\t}\n")
+	}
+	for _, start := range slices.Sorted(maps.Keys(g.Blocks)) {
+		b := g.Blocks[start]
+		for _, t := range b.Succ {
+			p.printf("\tb%04X -> b%04X;\n", b.Start, t)
+		}
+		for _, t := range b.Calls {
+			p.printf("\tb%04X -> b%04X [style=dashed];\n", b.Start, t)
+		}
+	}
+	p.printf("}\n")
+	return p.err
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *errWriter) printf(format string, args ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
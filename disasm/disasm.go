@@ -0,0 +1,199 @@
+// Package disasm turns 6502 machine code back into assembly using the cpu
+// package's instruction table.
+package disasm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+// CDL flags, as written by FCEUX/Mesen code/data loggers (one byte per PRG
+// byte).
+const (
+	CDLCode = 0x01
+	CDLData = 0x02
+)
+
+// Line is one disassembled instruction, or a run of data bytes.
+type Line struct {
+	Addr     uint16
+	Bytes    []byte
+	Mnemonic string
+	Operand  string
+	Data     bool
+	// Mode is the address mode of an instruction
+	Mode uint8
+}
+
+// Options controls how a block of code is disassembled.
+type Options struct {
+	// Origin is the cpu address of the first byte of code.
+	Origin uint16
+	// CDL, if set, is a code/data log aligned with code. Bytes logged only
+	// as data are emitted as .byte rather than decoded.
+	CDL []byte
+	// Labels, if set, names addresses. Operands that are a named address,
+	// or a branch to one, show the name instead.
+	Labels map[uint16]string
+}
+
+// maximum data bytes per .byte line
+const dataPerLine = 8
+
+// Disassemble decodes code from start to end. Unknown opcodes, and
+// instructions that would run past the end, become single .byte lines.
+func Disassemble(code []byte, opts Options) []Line {
+	var lines []Line
+	for i := 0; i < len(code); {
+		addr := opts.Origin + uint16(i)
+
+		if isData(opts.CDL, i) {
+			n := 1
+			for n < dataPerLine && i+n < len(code) && isData(opts.CDL, i+n) {
+				n++
+			}
+			lines = append(lines, dataLine(addr, code[i:i+n]))
+			i += n
+			continue
+		}
+
+		ins, ok := cpu.Lookup(code[i])
+		if !ok || i+ins.Length > len(code) {
+			lines = append(lines, dataLine(addr, code[i:i+1]))
+			i++
+			continue
+		}
+
+		raw := code[i : i+ins.Length]
+		lines = append(lines, Line{
+			Addr:     addr,
+			Bytes:    raw,
+			Mnemonic: ins.Label,
+			Operand:  labelOperand(ins.AddressMode, addr, raw[1:], opts.Labels),
+			Mode:     ins.AddressMode,
+		})
+		i += ins.Length
+	}
+	return lines
+}
+
+func isData(cdl []byte, i int) bool {
+	return i < len(cdl) && cdl[i]&CDLData != 0 && cdl[i]&CDLCode == 0
+}
+
+func dataLine(addr uint16, b []byte) Line {
+	vals := make([]string, len(b))
+	for i, v := range b {
+		vals[i] = fmt.Sprintf("$%02X", v)
+	}
+	return Line{Addr: addr, Bytes: b, Mnemonic: ".byte", Operand: strings.Join(vals, ","), Data: true}
+}
+
+// FormatOperand formats the operand bytes of an instruction at addr in the
+// usual assembler syntax for its addressing mode.
+func FormatOperand(mode uint8, addr uint16, operand []byte) string {
+	var v uint16
+	for i, b := range operand {
+		v |= uint16(b) << (8 * i)
+	}
+
+	switch mode {
+	case cpu.Immediate:
+		return fmt.Sprintf("#$%02X", v)
+	case cpu.ZeroPage:
+		return fmt.Sprintf("$%02X", v)
+	case cpu.ZeroPageX:
+		return fmt.Sprintf("$%02X,X", v)
+	case cpu.ZeroPageY:
+		return fmt.Sprintf("$%02X,Y", v)
+	case cpu.Absolute:
+		return fmt.Sprintf("$%04X", v)
+	case cpu.AbsoluteX:
+		return fmt.Sprintf("$%04X,X", v)
+	case cpu.AbsoluteY:
+		return fmt.Sprintf("$%04X,Y", v)
+	case cpu.Indirect:
+		return fmt.Sprintf("($%04X)", v)
+	case cpu.IndirectX:
+		return fmt.Sprintf("($%02X,X)", v)
+	case cpu.IndirectY:
+		return fmt.Sprintf("($%02X),Y", v)
+	case cpu.Relative:
+		return fmt.Sprintf("$%04X", BranchTarget(addr, uint8(v)))
+	case cpu.Accumulator:
+		return "A"
+	}
+	return ""
+}
+
+// labelOperand formats an operand as FormatOperand does, with the address
+// replaced by its name in labels if it has one.
+func labelOperand(mode uint8, addr uint16, operand []byte, labels map[uint16]string) string {
+	s := FormatOperand(mode, addr, operand)
+	var target uint16
+	switch mode {
+	case cpu.Absolute, cpu.AbsoluteX, cpu.AbsoluteY, cpu.Indirect:
+		target = uint16(operand[0]) | uint16(operand[1])<<8
+	case cpu.Relative:
+		target = BranchTarget(addr, operand[0])
+	default:
+		return s
+	}
+	if name, ok := labels[target]; ok {
+		return strings.Replace(s, fmt.Sprintf("$%04X", target), name, 1)
+	}
+	return s
+}
+
+// BranchTarget returns where a branch at addr with the given offset goes.
+func BranchTarget(addr uint16, offset uint8) uint16 {
+	return addr + 2 + uint16(int8(offset))
+}
+
+// Format controls the optional columns written by Write.
+type Format struct {
+	Bytes bool
+	ASCII bool
+}
+
+// Write writes lines as a listing, one per line:
+//
+//	C000  4C F5 C5  JMP $C5F5
+func Write(w io.Writer, lines []Line, f Format) error {
+	var sb strings.Builder
+	for _, l := range lines {
+		sb.Reset()
+		fmt.Fprintf(&sb, "%04X  ", l.Addr)
+		if f.Bytes {
+			var raw []string
+			for _, b := range l.Bytes {
+				raw = append(raw, fmt.Sprintf("%02X", b))
+			}
+			fmt.Fprintf(&sb, "%-*s", dataPerLine*3, strings.Join(raw, " "))
+		}
+		text := l.Mnemonic
+		if l.Operand != "" {
+			text += " " + l.Operand
+		}
+		if !f.ASCII {
+			sb.WriteString(text)
+		} else {
+			fmt.Fprintf(&sb, "%-32s ; ", text)
+			for _, b := range l.Bytes {
+				if b >= 0x20 && b < 0x7F {
+					sb.WriteByte(b)
+				} else {
+					sb.WriteByte('.')
+				}
+			}
+		}
+		sb.WriteByte('\n')
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
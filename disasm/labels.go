@@ -0,0 +1,82 @@
+package disasm
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/goldmane/gemu/cpu"
+)
+
+// vectors are the interrupt vectors at the top of the cpu address space.
+var vectors = []struct {
+	addr uint16
+	name string
+}{
+	{0xFFFA, "nmi"},
+	{0xFFFC, "reset"},
+	{0xFFFE, "irq"},
+}
+
+// Labels names the jump targets in code that fall inside it: JSR targets
+// become sub_XXXX, JMP and branch targets loc_XXXX, and the handlers the
+// interrupt vectors point at nmi, reset and irq, if the vectors are in
+// code too.
+func Labels(code []byte, opts Options) map[uint16]string {
+	end := int(opts.Origin) + len(code)
+	inside := func(a uint16) bool {
+		return a >= opts.Origin && int(a) < end
+	}
+
+	labels := map[uint16]string{}
+	for _, l := range Disassemble(code, opts) {
+		if l.Data {
+			continue
+		}
+		var target uint16
+		prefix := "loc"
+		switch {
+		case l.Mode == cpu.Relative:
+			target = BranchTarget(l.Addr, l.Bytes[1])
+		case l.Mnemonic == "JSR":
+			target = uint16(l.Bytes[1]) | uint16(l.Bytes[2])<<8
+			prefix = "sub"
+		case l.Mnemonic == "JMP" && l.Mode == cpu.Absolute:
+			target = uint16(l.Bytes[1]) | uint16(l.Bytes[2])<<8
+		default:
+			continue
+		}
+		if !inside(target) {
+			continue
+		}
+		// a subroutine name wins over a plain location
+		if old, ok := labels[target]; !ok || old[:3] == "loc" {
+			labels[target] = fmt.Sprintf("%s_%04X", prefix, target)
+		}
+	}
+
+	for _, v := range vectors {
+		if !inside(v.addr) || !inside(v.addr+1) {
+			continue
+		}
+		i := int(v.addr - opts.Origin)
+		target := uint16(code[i]) | uint16(code[i+1])<<8
+		if inside(target) {
+			labels[target] = v.name
+		}
+	}
+	return labels
+}
+
+// WriteMLB writes labels in Mesen's .mlb format, as PRG ROM offsets. origin
+// is the cpu address of PRG offset base.
+func WriteMLB(w io.Writer, labels map[uint16]string, origin uint16, base int) error {
+	for _, addr := range slices.Sorted(maps.Keys(labels)) {
+		offset := base + int(addr-origin)
+		if _, err := fmt.Fprintf(w, "P:%04X:%s\n", offset, labels[addr]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
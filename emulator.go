@@ -0,0 +1,843 @@
+package gemu
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/goldmane/gemu/asm"
+	"github.com/goldmane/gemu/cpu"
+	"github.com/goldmane/gemu/gemu"
+	"github.com/goldmane/gemu/input"
+	"github.com/goldmane/gemu/ppu"
+)
+
+const (
+	ScreenWidth  = 256
+	ScreenHeight = 240
+)
+
+// Emulator wires a cartridge and a cpu together and drives the
+// fetch/decode/execute loop. It can optionally write a trace of every
+// instruction and compare it against a reference log.
+type Emulator struct {
+	cpu    cpu.CPU
+	ppu    *ppu.PPU
+	cart   gemu.Cartridge
+	clock  *Clock
+	region gemu.Region
+	// region forced by SetRegion, overriding the cartridge header
+	regionOverride *gemu.Region
+	// patch applied by LoadROM; empty to look for one next to the ROM
+	patch string
+	// path of the loaded ROM, for PowerCycle
+	romPath string
+	// known dumps, consulted for the region before the header
+	games *gemu.GameDB
+	// per-game options, applied by LoadROM
+	settings *GameSettings
+
+	// the cartridge's register handler, and the PRG mapped in each 8kb
+	// window of $8000-$FFFF
+	cartPage *mapperPage
+	prg      [4][]byte
+
+	signals gemu.Signals
+	logger  *slog.Logger
+	// error raised by the cpu during the last clock step
+	fault error
+	// set when an NMI hijacked BRK or an IRQ before its edge came, which
+	// is then not passed on to the cpu again
+	nmiHijacked bool
+
+	// devices in the controller ports and the expansion port
+	ports     [2]input.Device
+	expansion input.ExpansionDevice
+
+	frame *image.RGBA
+	// debug statistics drawn over the frame
+	overlay overlay
+	// transient messages drawn over the frame
+	osd osd
+	// goals checked at the end of each frame
+	achievements []*achievement
+	// frames skipped between rendered ones
+	frameSkip uint64
+	// extra cpu-only scanlines run after vblank starts
+	overclock uint64
+	cpuDevice *Device
+	ppuDevice *Device
+
+	trace     io.Writer
+	source    SourceMap
+	reference *bufio.Scanner
+	refFormat TraceFormat
+	// format the trace is written in, or nil for our own with a counter
+	traceFormat TraceFormat
+	tracer      cpu.Tracer
+	traceBuf    []byte
+	audit       *CycleAudit
+	labels      *AutoLabels
+	timeline    *Timeline
+	diagnostics *Diagnostics
+
+	counter uint64
+}
+
+func NewEmulator() *Emulator {
+	e := &Emulator{
+		logger: slog.Default(),
+		ppu:    ppu.New(gemu.NTSC),
+	}
+	e.frame = e.ppu.Frame()
+	e.ppu.SetSignals(&e.signals)
+	e.cpu.PPUPosition = func() (int, int) {
+		e.clock.Sync()
+		return e.ppu.Position()
+	}
+	e.cpu.PollNMI = e.pollNMI
+	e.ports = [2]input.Device{&input.Joypad{}, &input.Joypad{}}
+	e.expansion = input.NoExpansion{}
+	e.cpu.Reset()
+	e.mapSystem()
+	e.cpu.EnableDecodeCache()
+	e.setRegion(gemu.NTSC)
+	return e
+}
+
+// LoadROM inserts the cartridge at path and starts the cpu through its
+// reset vector, as at power on. The patch set by SetPatch, or else an .ips
+// or .bps file next to the ROM, is applied first.
+func (e *Emulator) LoadROM(path string) error {
+	rom := gemu.Cartridge{Logger: e.logger}
+	patch := e.patch
+	if patch == "" {
+		patch = gemu.FindPatch(path)
+	}
+	var err error
+	if patch == "" {
+		err = rom.Insert(path)
+	} else {
+		e.logger.Info("applying patch", "path", patch)
+		err = rom.InsertPatched(path, patch)
+	}
+	if err != nil {
+		return err
+	}
+	e.insert(rom, path)
+	return nil
+}
+
+// insert puts a loaded cartridge in, as LoadROM does once it has read the
+// file at path.
+func (e *Emulator) insert(rom gemu.Cartridge, path string) {
+	e.cart = rom
+	e.romPath = path
+	e.achievements = nil
+	if e.settings != nil {
+		e.SetOverclock(e.settings.For(e).Overclock)
+	}
+
+	game, known := e.games.Lookup(&e.cart)
+	switch {
+	case e.regionOverride != nil:
+		e.setRegion(*e.regionOverride)
+	case known:
+		if game.Region.Name != e.cart.Region().Name {
+			e.logger.Info("region from game database overrides header", "game", game.Name, "header", e.cart.Region().Name, "region", game.Region.Name)
+		}
+		e.setRegion(game.Region)
+	default:
+		e.setRegion(e.cart.Region())
+	}
+
+	e.cpu.Reset()
+	e.mapSystem()
+	e.cpu.LoadCartridge(e.cart)
+	e.mapCartridge()
+	e.cpu.SetPC(e.resetVector())
+	// the reset sequence's 7 cycles, which TotalCycles starts with, pass
+	// with the PPU running before the first instruction
+	e.cpuDevice.Delay(e.cpu.TotalCycles * e.region.CPUDivider)
+	e.ppu.Reset()
+	e.ppu.SetCartridge(e.cart.Mapper, e.cart.Mirroring())
+	e.counter = 0
+	e.nmiHijacked = false
+
+	e.logger.Info("ROM inserted", "path", path, "mapper", e.cart.MapperID(), "region", e.region.Name)
+}
+
+// SoftReset presses the reset button: the cpu jumps through the reset
+// vector at $FFFC with interrupts disabled, and its stack pointer drops by
+// three as the 2A03's does. An NMI or IRQ it hadn't taken yet is dropped,
+// and the PPU's PPUCTRL, PPUMASK, scroll and write toggle are cleared, see
+// ppu.SoftReset. Memory and the other registers are left alone.
+func (e *Emulator) SoftReset() {
+	e.clock.Sync()
+	e.ppu.SoftReset()
+	e.signals.NMI.TakeEdge()
+	e.nmiHijacked = false
+	e.cpu.ClearInterrupts()
+	e.cpu.SetPC(e.resetVector())
+	e.cpu.SP -= 3
+	e.cpu.Flags.SetFlag(gemu.InterruptDisable, true)
+}
+
+// resetVector returns the address the cpu starts at after a reset.
+func (e *Emulator) resetVector() uint16 {
+	pages := e.cpu.Pages()
+	return uint16(pages.Read(0xFFFC)) | uint16(pages.Read(0xFFFD))<<8
+}
+
+// powerOnRAM returns the byte internal RAM holds at addr after power on.
+// Real RAM is unpredictable; this is the four $00s, four $FFs pattern
+// several boards come up with, and that some games are known to rely on
+// not being all zeroes.
+func powerOnRAM(addr int) uint8 {
+	if addr&4 != 0 {
+		return 0xFF
+	}
+	return 0x00
+}
+
+// PowerCycle switches the console off and on: the ROM is loaded again from
+// its file, as by LoadROM, internal RAM ($0000-$07FF) is filled with the
+// power on pattern, and the cpu starts through the reset vector with
+// A, X and Y clear, P $24 and SP $FD.
+func (e *Emulator) PowerCycle() error {
+	if e.romPath == "" {
+		return ErrNoROM
+	}
+	if err := e.LoadROM(e.romPath); err != nil {
+		return err
+	}
+	e.powerOn()
+	return nil
+}
+
+// powerOn fills internal RAM with its power on pattern and starts the cpu
+// through the reset vector, after a cartridge has been inserted.
+func (e *Emulator) powerOn() {
+	mem := e.cpu.GetMemory()
+	for a := 0; a < 0x0800; a++ {
+		mem[a] = powerOnRAM(a)
+	}
+	e.cpu.InvalidateDecodeCache(0x0000, 0x07FF)
+	// the reset sequence takes SP from $00 to $FD
+	e.cpu.SP = 0
+	e.SoftReset()
+}
+
+// ROMPath returns the file the loaded ROM came from, or "" before one is
+// loaded.
+func (e *Emulator) ROMPath() string {
+	return e.romPath
+}
+
+// SetGameDB sets the database of known dumps LoadROM takes the region from,
+// ahead of the ROM header but behind SetRegion.
+func (e *Emulator) SetGameDB(db *gemu.GameDB) {
+	e.games = db
+}
+
+// Game returns the loaded cartridge's entry in the game database, if it
+// has one.
+func (e *Emulator) Game() (gemu.Game, bool) {
+	return e.games.Lookup(&e.cart)
+}
+
+// SetPatch sets an IPS or BPS patch to apply on the next LoadROM, instead
+// of looking for one next to the ROM.
+func (e *Emulator) SetPatch(path string) {
+	e.patch = path
+}
+
+// SetLogger sets the logger used by the emulator and the components it
+// creates. Pass a logger with a discarding handler to silence it.
+func (e *Emulator) SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.Default()
+	}
+	e.logger = l
+	e.cpu.Logger = l
+}
+
+// SetRegion forces the timing region instead of taking it from the cartridge
+// header. It takes effect on the next LoadROM.
+func (e *Emulator) SetRegion(r gemu.Region) {
+	e.regionOverride = &r
+}
+
+// Region returns the timing region in use.
+func (e *Emulator) Region() gemu.Region {
+	return e.region
+}
+
+// setRegion switches timing to r. The clock is rebuilt, so anything attached
+// to the old one is dropped.
+func (e *Emulator) setRegion(r gemu.Region) {
+	e.region = r
+	e.cpu.Region = r
+	e.clock = NewClock()
+	e.cpuDevice = e.clock.Attach(r.CPUDivider, e.tickCPU)
+	e.ppu.SetRegion(r)
+	e.ppuDevice = e.clock.AttachLazy(r.PPUDivider, func() uint64 {
+		e.ppu.Tick()
+		return 1
+	})
+	e.scheduleVBlank()
+}
+
+// SetOverclock runs lines extra scanlines of cpu time after vblank starts
+// every frame, with the PPU and APU held, which gives games more time per
+// frame to cut slowdown and flicker. 0, the default, turns it off. It takes
+// effect from the next vblank. Games that count cycles in vblank may break,
+// so it is best set per game, see SetGameOverclock.
+func (e *Emulator) SetOverclock(lines int) {
+	if lines < 0 {
+		lines = 0
+	}
+	e.overclock = uint64(lines)
+}
+
+// Overclock returns the extra scanlines set by SetOverclock.
+func (e *Emulator) Overclock() int {
+	return int(e.overclock)
+}
+
+// SetGameSettings sets where per-game options are kept. LoadROM applies
+// the loaded game's, and SetGameOverclock changes them.
+func (e *Emulator) SetGameSettings(s *GameSettings) {
+	e.settings = s
+}
+
+// GameSettings returns the settings set by SetGameSettings, or nil.
+func (e *Emulator) GameSettings() *GameSettings {
+	return e.settings
+}
+
+// SetGameOverclock sets the overclock as SetOverclock does, and keeps it
+// for the loaded game in the settings set by SetGameSettings, if any, so
+// the game gets it again whenever it is loaded.
+func (e *Emulator) SetGameOverclock(lines int) error {
+	e.SetOverclock(lines)
+	if e.settings == nil || e.romPath == "" {
+		return nil
+	}
+	g := e.settings.For(e)
+	g.Overclock = e.Overclock()
+	e.settings.Set(e, g)
+	return e.settings.Save()
+}
+
+// framePeriod returns the master cycles in one frame, including any
+// overclock scanlines but not the dot odd frames skip.
+func (e *Emulator) framePeriod() uint64 {
+	return e.region.MasterPerFrame() + e.overclockCycles()
+}
+
+func (e *Emulator) overclockCycles() uint64 {
+	return e.overclock * uint64(e.region.DotsPerScanline) * e.region.PPUDivider
+}
+
+// ppuAfter returns the master cycle by which the PPU will have run n more
+// dots, so that a sync then has run them, unless something holds it.
+func (e *Emulator) ppuAfter(n int) uint64 {
+	return e.ppuDevice.next + uint64(n)*e.region.PPUDivider
+}
+
+// nextVBlank returns the master cycle just after the PPU next sets the
+// vblank flag, on the second dot of the vblank scanline. It may be a dot
+// early on an odd frame, see ppu.DotsUntil.
+func (e *Emulator) nextVBlank() uint64 {
+	e.clock.Sync()
+	return e.ppuAfter(e.ppu.DotsUntil(e.region.VBlankScanline, 2))
+}
+
+// pollNMI reports whether the PPU raises NMI within cycles cpu cycles of
+// the instruction the cpu is running, for an NMI to hijack BRK or an IRQ.
+// Instructions run whole, so its edge only reaches the cpu later, when it
+// has to be dropped.
+func (e *Emulator) pollNMI(cycles uint64) bool {
+	if e.signals.NMI.Asserted() || !e.ppu.NMIAtVBlank() {
+		return false
+	}
+	if e.nextVBlank() > e.clock.Cycle()+cycles*e.region.CPUDivider {
+		return false
+	}
+	e.nmiHijacked = true
+	return true
+}
+
+// inVBlank reports whether the PPU has just set the vblank flag, which a
+// wake at nextVBlank finds unless it came a dot early.
+func (e *Emulator) inVBlank() bool {
+	scanline, dot := e.ppu.Position()
+	return scanline == e.region.VBlankScanline && dot == 2
+}
+
+// scheduleVBlank wakes the PPU as vblank starts every frame. It only runs
+// when something syncs it, and its NMI has to reach the cpu on the
+// instruction it would on hardware. When overclocking, the wake also holds
+// everything but the cpu for the extra scanlines.
+func (e *Emulator) scheduleVBlank() {
+	var wake func()
+	wake = func() {
+		if e.overclock > 0 && e.inVBlank() {
+			e.clock.Hold(e.overclockCycles(), e.cpuDevice)
+		}
+		e.clock.Schedule(e.nextVBlank(), wake)
+	}
+	e.clock.Schedule(e.nextVBlank(), wake)
+}
+
+// SetTrace sets where the per-instruction trace is written. A nil writer
+// disables tracing.
+func (e *Emulator) SetTrace(w io.Writer) {
+	e.trace = w
+}
+
+// SourceMap maps a cpu address back to the source line that assembled it,
+// e.g. *cc65.DebugInfo.
+type SourceMap interface {
+	Lookup(addr uint16) (file string, line int, ok bool)
+}
+
+// SetSourceMap annotates each trace line with the source line of its
+// instruction. It doesn't affect the comparison against the reference.
+func (e *Emulator) SetSourceMap(m SourceMap) {
+	e.source = m
+}
+
+// SetAutoLabels names subroutines and interrupt handlers in the trace as
+// the run reaches them, see AutoLabels. It doesn't affect the comparison
+// against the reference, or traces written in another emulator's format.
+func (e *Emulator) SetAutoLabels(l *AutoLabels) {
+	e.labels = l
+}
+
+// SetTimeline records the run from here on to t, see Timeline. A nil t
+// stops recording.
+func (e *Emulator) SetTimeline(t *Timeline) {
+	e.timeline = t
+	if t != nil {
+		t.e = e
+		t.frame = e.Frame()
+		t.frameStart = t.frame * e.framePeriod()
+	}
+}
+
+// AutoLabels returns the labeler set by SetAutoLabels, or nil.
+func (e *Emulator) AutoLabels() *AutoLabels {
+	return e.labels
+}
+
+// SetReference sets a reference log to compare every trace line against.
+// A nil reader disables the comparison.
+func (e *Emulator) SetReference(r io.Reader) {
+	if r == nil {
+		e.reference = nil
+		return
+	}
+	e.reference = bufio.NewScanner(r)
+}
+
+// SetTraceFormat writes the trace in another emulator's log format, without
+// the instruction counter or source lines, so it can stand in as a golden
+// log for that emulator or a later version of this one. A nil f restores
+// our own format.
+func (e *Emulator) SetTraceFormat(f TraceFormat) {
+	e.traceFormat = f
+}
+
+// SetReferenceFormat compares the reference log field by field, parsing
+// its lines with f, so a log from another emulator can be checked against
+// despite a different layout. A nil f compares the lines as text, which
+// needs a log in our own trace format.
+func (e *Emulator) SetReferenceFormat(f TraceFormat) {
+	e.refFormat = f
+}
+
+// SetCycleAudit checks the cycles every instruction takes against the
+// official timing, collecting mismatches in a. A nil a stops checking.
+func (e *Emulator) SetCycleAudit(a *CycleAudit) {
+	e.audit = a
+}
+
+// SetInput sets the buttons held on the device in a controller port (0 or
+// 1) or the expansion port (ExpansionPort), if it has buttons. For a
+// joypad that is one bit per button in the A, B, Select, Start, Up, Down,
+// Left, Right order.
+func (e *Emulator) SetInput(port int, buttons uint32) {
+	if d, ok := e.inputDevice(port).(input.Buttons); ok {
+		d.SetButtons(buttons)
+	}
+}
+
+// Framebuffer returns the picture the PPU draws. Between calls to RunFrame
+// it holds the last frame, with the overlay and messages drawn over it.
+func (e *Emulator) Framebuffer() *image.RGBA {
+	return e.frame
+}
+
+// SetFrameSkip renders one frame out of every n+1. The skipped frames are
+// still fully emulated; only their video output is dropped, and Framebuffer
+// keeps the last rendered frame. 0 renders every frame.
+func (e *Emulator) SetFrameSkip(n int) {
+	if n < 0 {
+		n = 0
+	}
+	e.frameSkip = uint64(n)
+}
+
+// Frame returns the number of the frame being emulated, as the PPU counts
+// them.
+func (e *Emulator) Frame() uint64 {
+	e.clock.Sync()
+	return e.ppu.FrameCount()
+}
+
+// Rendering reports whether the current frame's video output is kept or
+// skipped under SetFrameSkip.
+func (e *Emulator) Rendering() bool {
+	return e.Frame()%(e.frameSkip+1) == 0
+}
+
+func (e *Emulator) CPU() *cpu.CPU {
+	return &e.cpu
+}
+
+// PPU returns the picture processing unit.
+func (e *Emulator) PPU() *ppu.PPU {
+	return e.ppu
+}
+
+// PeekMemory returns the byte at addr as the cpu would read it, without
+// the side effects of a read: the PPU registers show what a read would
+// return and the I/O registers the last value written, so peeking neither
+// ends vblank nor clocks the controllers.
+func (e *Emulator) PeekMemory(addr uint16) uint8 {
+	switch {
+	case addr >= 0x2000 && addr < 0x4000:
+		return e.ppu.PeekRegister(addr)
+	case addr >= 0x4000 && addr < 0x4020:
+		return e.cpu.GetMemory()[addr]
+	}
+	return e.cpu.Pages().Read(addr)
+}
+
+// Peek reads n bytes (1 to 4), little endian, from cpu memory starting at
+// addr, without side effects (see PeekMemory). This is the memory an
+// achievement runtime such as rcheevos watches; addresses past $FFFF read
+// as 0.
+func (e *Emulator) Peek(addr uint32, n uint32) uint32 {
+	var v uint32
+	for i := uint32(0); i < n && i < 4; i++ {
+		if a := addr + i; a <= 0xFFFF {
+			v |= uint32(e.PeekMemory(uint16(a))) << (8 * i)
+		}
+	}
+	return v
+}
+
+// Patch assembles src (see package asm) at addr and writes it into cpu
+// memory, returning the bytes written. An origin in src overrides addr.
+// The bytes go into whatever backs each address, ROM included, rather
+// than being written on the bus, so patching code doesn't switch banks.
+func (e *Emulator) Patch(addr uint16, src string) ([]byte, error) {
+	addr, code, err := asm.Assemble(src, addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return code, nil
+	}
+	for i, b := range code {
+		e.cpu.Pages().Poke(addr+uint16(i), b)
+	}
+	end := addr + uint16(len(code)-1)
+	if end < addr {
+		end = 0xFFFF
+	}
+	e.cpu.InvalidateDecodeCache(addr, end)
+	return code, nil
+}
+
+// Clock returns the master clock, so other devices can be attached to it and
+// events scheduled on it.
+func (e *Emulator) Clock() *Clock {
+	return e.clock
+}
+
+// Signals returns the interrupt and DMA lines shared by the components.
+func (e *Emulator) Signals() *gemu.Signals {
+	return &e.signals
+}
+
+// Counter returns the number of instructions executed since the ROM was
+// loaded.
+func (e *Emulator) Counter() uint64 {
+	return e.counter
+}
+
+// Run runs the emulator until ctx is cancelled or it stops with an error.
+// Cancellation is checked between frames, so the emulator is always left at
+// an instruction boundary; Run returns ctx.Err() in that case. A trace writer
+// with a Flush method is flushed before returning.
+func (e *Emulator) Run(ctx context.Context) error {
+	defer e.flushTrace()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if err := e.RunFrame(); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *Emulator) flushTrace() {
+	if f, ok := e.trace.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+}
+
+// RunFrame runs the emulator until the PPU starts the next frame.
+func (e *Emulator) RunFrame() error {
+	frame := e.Frame()
+	rendering := frame%(e.frameSkip+1) == 0
+	e.ppu.SetSkip(!rendering)
+	defer e.clock.Sync()
+	// the frame can turn out longer than planned, when it doesn't skip a
+	// dot or when overclocking holds the PPU
+	for e.Frame() == frame {
+		end := e.ppuAfter(e.ppu.DotsUntil(0, 0))
+		for e.clock.Cycle() < end {
+			e.clock.Step()
+			if err := e.takeFault(); err != nil {
+				return err
+			}
+		}
+	}
+	// the PPU has to finish the picture before anything is drawn over it
+	e.clock.Sync()
+	e.checkAchievements()
+	// before the overlay changes the picture it watches
+	if e.diagnostics != nil {
+		if err := e.diagnostics.endFrame(rendering); err != nil {
+			e.diagnostics.capture(err)
+			return err
+		}
+	}
+	if rendering && e.overlay.enabled {
+		e.drawOverlay()
+	}
+	if rendering {
+		e.drawMessages()
+	}
+	return nil
+}
+
+// Step runs the emulator until the cpu has executed one instruction.
+func (e *Emulator) Step() error {
+	defer e.clock.Sync()
+	counter := e.counter
+	for e.counter == counter {
+		e.clock.Step()
+		if err := e.takeFault(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Emulator) takeFault() error {
+	err := e.fault
+	e.fault = nil
+	if err != nil && e.diagnostics != nil && crashed(err) {
+		e.diagnostics.capture(err)
+	}
+	return err
+}
+
+// tickCPU is the cpu's clock device. It runs a whole instruction at a time
+// and reports the cycles it took, so the clock won't tick it again until
+// they have passed.
+func (e *Emulator) tickCPU() (cycles uint64) {
+	pc := e.cpu.GetPC()
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(*gemu.BusFault)
+			if !ok {
+				panic(r)
+			}
+			e.fault = &BusFaultError{PC: pc, Cause: f, Stack: debug.Stack()}
+			cycles = 1
+		}
+	}()
+
+	if e.fault != nil {
+		return 1
+	}
+
+	if e.timeline != nil {
+		e.timeline.advance(e.clock.Cycle(), e.framePeriod())
+	}
+	if n := e.signals.TakeStall(); n > 0 {
+		e.cpu.TotalCycles += n
+		if e.timeline != nil {
+			e.timeline.stall(e.clock.Cycle(), n*e.region.CPUDivider)
+		}
+		return n
+	}
+
+	// pass the lines on to the cpu, which takes an interrupt between
+	// instructions instead of running the next one
+	if e.signals.NMI.TakeEdge() {
+		if e.nmiHijacked {
+			e.nmiHijacked = false
+		} else {
+			e.cpu.TriggerNMI()
+		}
+	}
+	if e.signals.IRQ.Asserted() {
+		e.cpu.TriggerIRQ()
+	} else {
+		e.cpu.ReleaseIRQ()
+	}
+	if kind, ok := e.cpu.ServiceInterrupt(); ok {
+		e.cpu.TotalCycles += cpu.InterruptCycles
+		if e.timeline != nil {
+			e.timeline.Interrupt(kind.String(), e.clock.Cycle()+cpu.InterruptCycles*e.region.CPUDivider)
+		}
+		return cpu.InterruptCycles
+	}
+
+	cr, err := e.execute()
+	if err != nil {
+		e.fault = err
+		return 1
+	}
+	e.cpu.TotalCycles += uint64(cr)
+	return uint64(cr)
+}
+
+func (e *Emulator) execute() (uint8, error) {
+	var refLine []byte
+	if e.reference != nil {
+		if !e.reference.Scan() {
+			return 0, ErrReferenceExhausted
+		}
+		refLine = e.reference.Bytes()
+	}
+	tracing := e.trace != nil || e.reference != nil
+
+	e.counter += 1
+	if tracing {
+		e.tracer.Begin(&e.cpu)
+	}
+
+	// decode instruction
+	pc := e.cpu.GetPC()
+	if e.diagnostics != nil {
+		e.diagnostics.record(&e.cpu, pc)
+	}
+	instruction, ok := e.cpu.Decode()
+	if !ok {
+		return 0, &UnknownOpcodeError{Opcode: e.cpu.FetchAddress(pc), PC: pc}
+	}
+
+	if e.labels != nil {
+		e.labels.observe(&e.cpu, pc)
+	}
+	want, audited := 0, false
+	if e.audit != nil {
+		want, audited = e.audit.expect(&e.cpu)
+	}
+
+	// fetch and execute instruction
+	e.cpu.ClearFetched()
+	e.cpu.Fetch()
+	cr := instruction.Function(&e.cpu)
+	if e.timeline != nil {
+		e.timeline.instruction(pc, instruction.Label, e.clock.Cycle(), uint64(cr)*e.region.CPUDivider, e.cpu.GetPC())
+	}
+	if audited {
+		e.audit.check(pc, e.cpu.Fetched(), int(cr), want)
+	}
+
+	if !tracing {
+		return cr, nil
+	}
+	line := e.tracer.End(&e.cpu, instruction)
+
+	if e.trace != nil && e.traceFormat != nil {
+		rec, err := nestestFormat.Parse(string(line))
+		if err != nil {
+			return cr, fmt.Errorf("trace line %d: %w", e.counter, err)
+		}
+		e.traceBuf = append(append(e.traceBuf[:0], e.traceFormat.Format(rec)...), '\n')
+		e.trace.Write(e.traceBuf)
+	} else if e.trace != nil {
+		e.traceBuf = e.traceBuf[:0]
+		if e.labels != nil {
+			if name, ok := e.labels.Lookup(pc); ok {
+				e.traceBuf = append(append(e.traceBuf, name...), ":\n"...)
+			}
+		}
+		// the counter is not part of the reference
+		e.traceBuf = cpu.AppendCounter(e.traceBuf, e.counter)
+		if e.labels != nil {
+			e.traceBuf = e.labels.appendLabeled(e.traceBuf, line)
+		} else {
+			e.traceBuf = append(e.traceBuf, line...)
+		}
+		if e.source != nil {
+			if file, n, ok := e.source.Lookup(pc); ok {
+				e.traceBuf = append(e.traceBuf, "  ; "...)
+				e.traceBuf = append(e.traceBuf, file...)
+				e.traceBuf = append(e.traceBuf, ':')
+				e.traceBuf = strconv.AppendInt(e.traceBuf, int64(n), 10)
+			}
+		}
+		e.traceBuf = append(e.traceBuf, '\n')
+		e.trace.Write(e.traceBuf)
+	}
+
+	if e.reference == nil {
+		return cr, nil
+	}
+	if e.refFormat != nil {
+		return cr, e.compareRecords(line, refLine)
+	}
+	if !bytes.Equal(line, refLine) {
+		return cr, &MismatchError{Line: e.counter, Got: string(line), Want: string(refLine)}
+	}
+	return cr, nil
+}
+
+// compareRecords parses both trace lines and compares their fields.
+func (e *Emulator) compareRecords(line, refLine []byte) error {
+	want, err := e.refFormat.Parse(string(refLine))
+	if err != nil {
+		return fmt.Errorf("reference line %d: %w", e.counter, err)
+	}
+	got, err := nestestFormat.Parse(string(line))
+	if err != nil {
+		return fmt.Errorf("trace line %d: %w", e.counter, err)
+	}
+	if diffs := compareRecords(got, want); len(diffs) > 0 {
+		return &MismatchError{Line: e.counter, Got: string(line), Want: string(refLine), diffs: diffs}
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package gemu
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/goldmane/gemu/cpu"
+	"github.com/goldmane/gemu/gemu"
+	"github.com/goldmane/gemu/input"
+)
+
+// newTestEmulator returns an emulator that doesn't log.
+func newTestEmulator() *Emulator {
+	e := NewEmulator()
+	e.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return e
+}
+
+func TestLoadROMStartsAtResetVector(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	pages := e.cpu.Pages()
+	want := uint16(pages.Read(0xFFFC)) | uint16(pages.Read(0xFFFD))<<8
+	if got := e.cpu.GetPC(); got != want {
+		t.Errorf("pc $%04X after LoadROM, want the reset vector $%04X", got, want)
+	}
+}
+
+func TestSoftResetDropsPendingInterrupts(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	e.cpu.TriggerNMI()
+	e.cpu.TriggerIRQ()
+	e.SoftReset()
+	// reset sets I, which would hide an IRQ still latched
+	e.cpu.Flags.SetFlag(gemu.InterruptDisable, false)
+	if kind, ok := e.cpu.ServiceInterrupt(); ok {
+		t.Errorf("took %v latched before the reset", kind)
+	}
+}
+
+func TestNMIHijacksBRKBeforeVBlank(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// cpu cycles from the start of BRK to vblank
+		before uint64
+		hijack bool
+	}{
+		{"well before", 20, false},
+		{"before the vector", 3, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTestEmulator()
+			if err := e.LoadROM("nestest.nes"); err != nil {
+				t.Fatal(err)
+			}
+			// NOPs up to a BRK, in RAM
+			mem := e.cpu.GetMemory()
+			mem[0x0300], mem[0x0301] = 0xEA, 0x00
+			e.ppu.WriteRegister(0x2000, 0x80)
+			for i := 0; ; i++ {
+				left := e.nextVBlank() - e.cpuDevice.next
+				if left <= tc.before*e.region.CPUDivider && left > (tc.before-2)*e.region.CPUDivider {
+					break
+				}
+				if i > 20000 {
+					t.Fatal("never got near vblank")
+				}
+				e.cpu.SetPC(0x0300)
+				if err := e.Step(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sp := e.cpu.SP
+			e.cpu.SetPC(0x0301)
+			if err := e.Step(); err != nil {
+				t.Fatal(err)
+			}
+			pages := e.cpu.Pages()
+			vector := uint16(0xFFFE)
+			if tc.hijack {
+				vector = 0xFFFA
+			}
+			want := uint16(pages.Read(vector)) | uint16(pages.Read(vector+1))<<8
+			if got := e.cpu.GetPC(); got != want {
+				t.Fatalf("BRK went to $%04X, want $%04X", got, want)
+			}
+			if status := pages.Read(0x0100 + uint16(sp) - 2); status&0x10 == 0 {
+				t.Errorf("pushed status $%02X without B", status)
+			}
+			if !tc.hijack {
+				return
+			}
+			// the NMI it took must not be taken again when its edge comes
+			for i := 0; i < 3; i++ {
+				if err := e.Step(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if depth := sp - e.cpu.SP; depth > 3+3 {
+				t.Errorf("stack is %d bytes deeper after the handler's first instructions: the NMI was taken twice", depth)
+			}
+		})
+	}
+}
+
+// expansionMapper answers $4020-$5FFF with the low byte of the address and
+// records the writes there. PRG is NOPs.
+type expansionMapper struct {
+	writes map[uint16]uint8
+}
+
+func (m *expansionMapper) CPURead(addr uint16) uint8 {
+	if addr >= 0x8000 {
+		return 0xEA
+	}
+	return uint8(addr)
+}
+
+func (m *expansionMapper) CPUWrite(addr uint16, v uint8) {
+	m.writes[addr] = v
+}
+
+func (m *expansionMapper) PPURead(addr uint16) uint8     { return 0 }
+func (m *expansionMapper) PPUWrite(addr uint16, v uint8) {}
+
+func TestExpansionAreaGoesToMapper(t *testing.T) {
+	e := newTestEmulator()
+	m := &expansionMapper{writes: map[uint16]uint8{}}
+	e.insert(gemu.Cartridge{Mapper: m}, "")
+	bus := e.cpu.Bus()
+	for _, addr := range []uint16{0x4020, 0x40FF, 0x4100, 0x5000, 0x5FFF} {
+		bus.Write(addr, 0x5A)
+		if v, ok := m.writes[addr]; !ok || v != 0x5A {
+			t.Errorf("write to $%04X didn't reach the mapper", addr)
+		}
+		if got := bus.Read(addr); got != uint8(addr) {
+			t.Errorf("$%04X read $%02X, want the mapper's $%02X", addr, got, uint8(addr))
+		}
+		if got := e.PeekMemory(addr); got != uint8(addr) {
+			t.Errorf("$%04X peeked $%02X, want the mapper's $%02X", addr, got, uint8(addr))
+		}
+	}
+	// the I/O registers stay the console's
+	bus.Write(0x4016, 1)
+	bus.Write(0x401F, 1)
+	if len(m.writes) != 5 {
+		t.Errorf("mapper got writes %v, want only the expansion area's", m.writes)
+	}
+}
+
+func TestOAMDMA(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	bus := e.cpu.Bus()
+	for i := 0; i < 256; i++ {
+		bus.Write(0x0200+uint16(i), uint8(i))
+	}
+	// the copy starts at OAMADDR and wraps
+	bus.Write(0x2003, 4)
+	odd := e.cpu.TotalCycles & 1
+	bus.Write(0x4014, 0x02)
+	oam := e.ppu.OAM()
+	for i := 0; i < 256; i++ {
+		if got := oam[(4+i)&0xFF]; got != uint8(i) {
+			t.Fatalf("OAM[$%02X] is $%02X, want $%02X", (4+i)&0xFF, got, i)
+		}
+	}
+	if got, want := e.signals.TakeStall(), 513+odd; got != want {
+		t.Errorf("DMA stalled the cpu %d cycles, want %d", got, want)
+	}
+}
+
+func TestNMIAtVBlank(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ctrl uint8
+		nmi  bool
+	}{
+		{"enabled", 0x80, true},
+		{"disabled", 0x00, false},
+	} {
+		e := newTestEmulator()
+		if err := e.LoadROM("nestest.nes"); err != nil {
+			t.Fatal(err)
+		}
+		// JMP to itself, in RAM
+		mem := e.cpu.GetMemory()
+		mem[0x0300], mem[0x0301], mem[0x0302] = 0x4C, 0x00, 0x03
+		e.cpu.SetPC(0x0300)
+		e.ppu.WriteRegister(0x2000, tc.ctrl)
+		vblank := e.nextVBlank()
+		var took bool
+		e.cpu.SetInterruptHook(func(cpu.Interrupt, uint16) { took = true })
+		var at uint64
+		for e.cpuDevice.next < vblank+e.framePeriod() && !took {
+			at = e.cpuDevice.next
+			if err := e.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if took != tc.nmi {
+			t.Fatalf("%s: NMI taken %v in the frame, want %v", tc.name, took, tc.nmi)
+		}
+		// it's taken after the JMP running when vblank starts
+		if took && (at < vblank || at >= vblank+3*e.region.CPUDivider) {
+			t.Errorf("%s: NMI taken %d master cycles from the start of vblank, want within the JMP's 3 cpu cycles", tc.name, int64(at-vblank))
+		}
+	}
+}
+
+func TestPatchROM(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	// run the code there first, so it is in the decode cache
+	e.cpu.SetPC(0xC000)
+	if err := e.Step(); err != nil {
+		t.Fatal(err)
+	}
+	code, err := e.Patch(0xC000, "LDA #$42 / RTS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 3 || e.PeekMemory(0xC000) != 0xA9 || e.PeekMemory(0xC001) != 0x42 {
+		t.Fatalf("patched % X, ROM reads % X", code, []uint8{e.PeekMemory(0xC000), e.PeekMemory(0xC001)})
+	}
+	e.cpu.SetPC(0xC000)
+	if err := e.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if a := e.cpu.A.GetValue(); a != 0x42 {
+		t.Errorf("A=$%02X after running the patch, want $42", a)
+	}
+}
+
+func TestControllerPortReads(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	pad := &input.Joypad{}
+	pad.SetButtons(input.ButtonA | input.ButtonSelect)
+	e.SetPort(0, pad)
+	e.SetPort(1, nil)
+	bus := e.cpu.Bus()
+	bus.Write(0x4016, 1)
+	bus.Write(0x4016, 0)
+	// the pad drives bit 0 and the rest is open bus
+	for i, want := range []uint8{0x41, 0x40, 0x41, 0x40} {
+		if got := bus.Read(0x4016); got != want {
+			t.Errorf("$4016 read %d is $%02X, want $%02X", i, got, want)
+		}
+	}
+	if got := bus.Read(0x4017); got != 0x40 {
+		t.Errorf("unplugged $4017 reads $%02X, want open bus", got)
+	}
+}
+
+func TestExpansionPortReads(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	h := &input.HyperShot{}
+	h.SetButtons(input.HyperShot1Jump | input.HyperShot2Run)
+	e.SetExpansion(h)
+	e.SetPort(1, nil)
+	bus := e.cpu.Bus()
+	bus.Write(0x4016, 0)
+	if got := bus.Read(0x4017); got != 0x52 {
+		t.Errorf("$4017 reads $%02X, want $52: open bus and the Hyper Shot on bits 1 and 4", got)
+	}
+	// player 2 switched off
+	bus.Write(0x4016, 0x04)
+	if got := bus.Read(0x4017); got != 0x42 {
+		t.Errorf("$4017 reads $%02X, want $42", got)
+	}
+}
@@ -0,0 +1,129 @@
+// Package env wraps a gemu.Machine as a reinforcement learning environment
+// with Gym's reset/step semantics: Reset starts an episode, and Step holds
+// the buttons for a frame and returns what the agent observes.
+//
+//	e, _ := env.New(env.Config{ROM: rom, Observe: []uint16{0x075A}, Done: gameOver})
+//	obs, _ := e.Reset()
+//	for {
+//		s, _ := e.Step(policy(obs))
+//		if s.Done {
+//			break
+//		}
+//		obs = s.Observation
+//	}
+package env
+
+import (
+	"errors"
+	"image"
+
+	"github.com/goldmane/gemu"
+)
+
+var ErrNotReset = errors.New("env: Step called before Reset or after the episode ended")
+
+// Config describes an environment.
+type Config struct {
+	ROM *gemu.ROM
+	// Observe lists the RAM addresses ($0000-$07FF or $6000-$7FFF) whose
+	// values are returned with each observation.
+	Observe []uint16
+	// Done reports whether the episode has ended, e.g. on game over. If
+	// nil, only MaxFrames ends it.
+	Done func(m *gemu.Machine) bool
+	// Reward scores the step just taken. If nil, every step scores 0.
+	Reward func(m *gemu.Machine) float64
+	// MaxFrames ends an episode after this many frames, or never if 0.
+	MaxFrames int
+	// FrameSkip repeats each action for this many frames, observing only
+	// the last, as Atari environments do. 0 means 1.
+	FrameSkip int
+}
+
+// Observation is what the agent sees after a reset or step.
+type Observation struct {
+	// Frame is the machine's framebuffer. It is overwritten by the next
+	// step; copy it to keep it.
+	Frame *image.RGBA
+	// RAM holds the values at Config.Observe, in the same order
+	RAM []byte
+}
+
+// Step is the result of Env.Step.
+type Step struct {
+	Observation
+	Reward float64
+	// Done is set when the episode has ended, by Config.Done or MaxFrames
+	Done bool
+}
+
+// Env is an environment over one machine. Like the machine, it must only
+// be used by one goroutine at a time; run one Env per goroutine to train on
+// many at once.
+type Env struct {
+	cfg    Config
+	m      *gemu.Machine
+	frames int
+	active bool
+}
+
+// New returns an environment for cfg. Call Reset to start an episode.
+func New(cfg Config) (*Env, error) {
+	m, err := gemu.NewMachine(cfg.ROM)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.FrameSkip < 1 {
+		cfg.FrameSkip = 1
+	}
+	return &Env{cfg: cfg, m: m}, nil
+}
+
+// Machine returns the machine the environment runs.
+func (e *Env) Machine() *gemu.Machine {
+	return e.m
+}
+
+// Reset power cycles the machine to start a new episode.
+func (e *Env) Reset() (Observation, error) {
+	if err := e.m.PowerCycle(); err != nil {
+		return Observation{}, err
+	}
+	e.m.SetInput(0, 0)
+	e.frames = 0
+	e.active = true
+	return e.observe(), nil
+}
+
+// Step holds buttons (input.ButtonA and the rest) on controller 1 and runs
+// Config.FrameSkip frames. An emulation error ends the episode.
+func (e *Env) Step(buttons uint32) (Step, error) {
+	if !e.active {
+		return Step{}, ErrNotReset
+	}
+	e.m.SetInput(0, buttons)
+	for i := 0; i < e.cfg.FrameSkip; i++ {
+		if err := e.m.RunFrame(); err != nil {
+			e.active = false
+			return Step{Observation: e.observe(), Done: true}, err
+		}
+		e.frames++
+	}
+
+	s := Step{Observation: e.observe()}
+	if e.cfg.Reward != nil {
+		s.Reward = e.cfg.Reward(e.m)
+	}
+	s.Done = (e.cfg.Done != nil && e.cfg.Done(e.m)) ||
+		(e.cfg.MaxFrames > 0 && e.frames >= e.cfg.MaxFrames)
+	e.active = !s.Done
+	return s, nil
+}
+
+func (e *Env) observe() Observation {
+	obs := Observation{Frame: e.m.Framebuffer(), RAM: make([]byte, len(e.cfg.Observe))}
+	for i, addr := range e.cfg.Observe {
+		obs.RAM[i] = uint8(e.m.Emulator().Peek(uint32(addr), 1))
+	}
+	return obs
+}
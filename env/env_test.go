@@ -0,0 +1,97 @@
+package env
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/goldmane/gemu"
+)
+
+func newEnv(t *testing.T, cfg Config) *Env {
+	t.Helper()
+	rom, err := gemu.ReadROM("../nestest.nes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ROM = rom
+	e, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEpisode(t *testing.T) {
+	rewards := 0
+	e := newEnv(t, Config{
+		Observe:   []uint16{0x0000, 0x0001},
+		MaxFrames: 5,
+		FrameSkip: 2,
+		Reward: func(m *gemu.Machine) float64 {
+			rewards++
+			return float64(m.Frame())
+		},
+	})
+	if _, err := e.Step(0); !errors.Is(err, ErrNotReset) {
+		t.Fatalf("Step before Reset: %v, want ErrNotReset", err)
+	}
+
+	var first []byte
+	for episode := 0; episode < 2; episode++ {
+		obs, err := e.Reset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(obs.RAM) != 2 || obs.Frame == nil {
+			t.Fatalf("observation has %d RAM values and frame %v", len(obs.RAM), obs.Frame != nil)
+		}
+		// 5 frames, 2 a step, take 3 steps
+		var s Step
+		for i := 0; i < 3; i++ {
+			if s.Done {
+				t.Fatalf("episode %d ended after %d steps", episode, i)
+			}
+			if s, err = e.Step(0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !s.Done {
+			t.Errorf("episode %d still going after 6 frames", episode)
+		}
+		if _, err := e.Step(0); !errors.Is(err, ErrNotReset) {
+			t.Errorf("Step after the episode ended: %v, want ErrNotReset", err)
+		}
+		// a reset power cycles, so episodes play out the same
+		if episode == 0 {
+			first = bytes.Clone(s.Frame.Pix)
+		} else if !bytes.Equal(s.Frame.Pix, first) {
+			t.Error("the second episode ended on a different frame")
+		}
+	}
+	if rewards != 6 {
+		t.Errorf("Reward called %d times, want once a step", rewards)
+	}
+}
+
+func TestDone(t *testing.T) {
+	e := newEnv(t, Config{
+		Done: func(m *gemu.Machine) bool { return m.Frame() >= 2 },
+	})
+	if _, err := e.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for s := (Step{}); !s.Done; steps++ {
+		var err error
+		if s, err = e.Step(0); err != nil {
+			t.Fatal(err)
+		}
+		if steps > 10 {
+			t.Fatal("Done never ended the episode")
+		}
+	}
+	if steps != 2 {
+		t.Errorf("episode ended after %d steps, want 2", steps)
+	}
+}
@@ -0,0 +1,154 @@
+package gemu
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/goldmane/gemu/cpu"
+	"github.com/goldmane/gemu/gemu"
+)
+
+// ErrReferenceExhausted is returned when the reference log runs out of lines
+// before the emulator stops.
+var ErrReferenceExhausted = errors.New("no more lines in the reference file")
+
+var ErrNoROM = errors.New("no ROM loaded")
+
+// MismatchError is returned when a trace line differs from the reference log.
+type MismatchError struct {
+	Line uint64
+	Got  string
+	Want string
+	// columns found to differ when the reference is in another emulator's
+	// format; otherwise Diffs works them out from the text
+	diffs []FieldDiff
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("trace mismatch at line %d", e.Line)
+}
+
+// traceFields are the columns of a trace line compared by Divergence, in
+// the order they are checked.
+var traceFields = []string{"A:", "X:", "Y:", "P:", "SP:", "CYC:", "PPU:"}
+
+// Fields of a FieldDiff for the columns without a key, which only show up
+// when comparing against another emulator's trace.
+const (
+	FieldBytes    = "bytes"
+	FieldMnemonic = "mnemonic"
+)
+
+// FieldDiff is a trace column that differs from the reference. Field is
+// the column's key as it appears in the line, e.g. "P:", "" for the pc, or
+// FieldBytes or FieldMnemonic.
+type FieldDiff struct {
+	Field     string
+	Got, Want string
+}
+
+func (d FieldDiff) String() string {
+	name := d.Field
+	if name == "" {
+		name = "PC:"
+	}
+	return fmt.Sprintf("%s got %s, want %s", name, d.Got, d.Want)
+}
+
+// Diffs lists every column that differs between the lines, the pc first and
+// then in the order of Divergence.
+func (e *MismatchError) Diffs() []FieldDiff {
+	if e.diffs != nil {
+		return e.diffs
+	}
+	var diffs []FieldDiff
+	for _, f := range append([]string{""}, traceFields...) {
+		if g, w := traceField(e.Got, f), traceField(e.Want, f); g != w {
+			diffs = append(diffs, FieldDiff{Field: f, Got: g, Want: w})
+		}
+	}
+	return diffs
+}
+
+// Divergence names the first column that differs between the lines, e.g.
+// "P: got 26, want 24", checking the pc and then the registers before the
+// timing columns. It falls back to the disassembly if only that differs.
+func (e *MismatchError) Divergence() string {
+	if diffs := e.Diffs(); len(diffs) > 0 {
+		return diffs[0].String()
+	}
+	return "instruction bytes or disassembly"
+}
+
+// TraceColumn returns where the value of a FieldDiff's field starts in a
+// line of our own trace, such as MismatchError.Got, or -1 if it isn't
+// there.
+func TraceColumn(line, field string) int {
+	// the pc, the bytes and the disassembly are fixed width
+	switch field {
+	case "":
+		return 0
+	case FieldBytes:
+		return 6
+	case FieldMnemonic:
+		// unofficial opcodes are marked with a *
+		if len(line) > 16 && line[16] == '*' {
+			return 17
+		}
+		return 16
+	}
+	i := strings.LastIndex(line, " "+field)
+	if i < 0 {
+		return -1
+	}
+	i += 1 + len(field)
+	for i < len(line) && line[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+// traceField returns the value after key in a trace line, or the pc when
+// key is empty.
+func traceField(line, key string) string {
+	if key == "" {
+		pc, _, _ := strings.Cut(line, " ")
+		return pc
+	}
+	i := strings.Index(line, key)
+	if i < 0 {
+		return "?"
+	}
+	v := strings.TrimLeft(line[i+len(key):], " ")
+	if key == "PPU:" {
+		// "PPU:  0, 21" has a space inside the value
+		if j := strings.Index(v, " CYC"); j >= 0 {
+			return v[:j]
+		}
+		return v
+	}
+	v, _, _ = strings.Cut(v, " ")
+	return v
+}
+
+// UnknownOpcodeError is returned when the cpu fetches an opcode it does not
+// implement.
+type UnknownOpcodeError = cpu.UnknownOpcodeError
+
+// BusFaultError is returned when a component panics with a *gemu.BusFault
+// while the cpu is executing the instruction at PC, for example a mapper
+// asked for a bank it doesn't have. Stack is where the fault was raised.
+type BusFaultError struct {
+	PC    uint16
+	Cause *gemu.BusFault
+	Stack []byte
+}
+
+func (e *BusFaultError) Error() string {
+	return fmt.Sprintf("bus fault executing $%04X: %v", e.PC, e.Cause)
+}
+
+func (e *BusFaultError) Unwrap() error {
+	return e.Cause
+}
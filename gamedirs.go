@@ -0,0 +1,86 @@
+package gemu
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GameDirs are the base directories for the files games make: battery
+// saves, save states and screenshots. Each game gets its own folder under
+// each, named by GameFolder. Crashes holds diagnostics bundles, which are
+// named for the game instead.
+type GameDirs struct {
+	Saves, States, Screenshots string
+	Crashes                    string
+}
+
+// DefaultGameDirs puts saves, states, screenshots and crashes under gemu in
+// the user's config directory.
+func DefaultGameDirs() (GameDirs, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return GameDirs{}, err
+	}
+	base := filepath.Join(dir, "gemu")
+	return GameDirs{
+		Saves:       filepath.Join(base, "saves"),
+		States:      filepath.Join(base, "states"),
+		Screenshots: filepath.Join(base, "screenshots"),
+		Crashes:     filepath.Join(base, "crashes"),
+	}, nil
+}
+
+// GameFolder returns the name of the loaded game's folder: its title from
+// the game database, or else the CRC32 of its PRG and CHR ROM, which
+// doesn't change when a dump is renamed.
+func (e *Emulator) GameFolder() string {
+	if game, ok := e.Game(); ok && game.Name != "" {
+		return strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+				return '_'
+			}
+			return r
+		}, game.Name)
+	}
+	return fmt.Sprintf("%08x", e.cart.CRC32())
+}
+
+// SaveDir returns the folder for the loaded game's battery saves.
+func (d GameDirs) SaveDir(e *Emulator) string {
+	return filepath.Join(d.Saves, e.GameFolder())
+}
+
+// StateDir returns the folder for the loaded game's save states.
+func (d GameDirs) StateDir(e *Emulator) string {
+	return filepath.Join(d.States, e.GameFolder())
+}
+
+// ScreenshotDir returns the folder for the loaded game's screenshots.
+func (d GameDirs) ScreenshotDir(e *Emulator) string {
+	return filepath.Join(d.Screenshots, e.GameFolder())
+}
+
+// SaveScreenshot writes the current frame as a PNG named for the time into
+// the game's screenshot folder, and returns its path.
+func (d GameDirs) SaveScreenshot(e *Emulator) (string, error) {
+	dir := d.ScreenshotDir(e)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, time.Now().Format("20060102-150405.000")+".png")
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	frame := e.CopyFrame()
+	defer e.ReleaseFrame(frame)
+	err = png.Encode(f, frame)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return path, err
+}
@@ -0,0 +1,90 @@
+package gemu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// GameSetting holds the options kept for one game.
+type GameSetting struct {
+	// extra cpu-only scanlines after vblank, see Emulator.SetOverclock
+	Overclock int `json:"overclock,omitempty"`
+}
+
+// GameSettings are the options kept for each game, keyed by the CRC32 of
+// its PRG and CHR ROM as in the game database, so renaming or patching the
+// header of a dump keeps them. They are kept as JSON so every frontend
+// shares them.
+type GameSettings struct {
+	path  string
+	Games map[string]GameSetting
+}
+
+// DefaultGameSettingsPath returns where the settings are kept:
+// gemu/games.json in the user's config directory.
+func DefaultGameSettingsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gemu", "games.json"), nil
+}
+
+// LoadGameSettings reads the settings at path. A missing file has no
+// settings.
+func LoadGameSettings(path string) (*GameSettings, error) {
+	s := &GameSettings{path: path, Games: map[string]GameSetting{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.Games); err != nil {
+		return nil, err
+	}
+	if s.Games == nil {
+		s.Games = map[string]GameSetting{}
+	}
+	return s, nil
+}
+
+func settingsKey(e *Emulator) string {
+	return fmt.Sprintf("%08x", e.cart.CRC32())
+}
+
+// For returns the settings of the game loaded in e, which are all zero if
+// it has none.
+func (s *GameSettings) For(e *Emulator) GameSetting {
+	if s == nil {
+		return GameSetting{}
+	}
+	return s.Games[settingsKey(e)]
+}
+
+// Set replaces the settings of the game loaded in e. A game whose settings
+// are all zero is dropped.
+func (s *GameSettings) Set(e *Emulator, g GameSetting) {
+	if g == (GameSetting{}) {
+		delete(s.Games, settingsKey(e))
+		return
+	}
+	s.Games[settingsKey(e)] = g
+}
+
+// Save writes the settings back to the file they were loaded from.
+func (s *GameSettings) Save() error {
+	b, err := json.MarshalIndent(s.Games, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(b, '\n'), 0o644)
+}
@@ -0,0 +1,65 @@
+package gemu
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGameOverclockIsKeptPerGame(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.json")
+	settings, err := LoadGameSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEmulator()
+	e.SetGameSettings(settings)
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetGameOverclock(20); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh emulator with the settings read back gets it on loading
+	settings, err = LoadGameSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = newTestEmulator()
+	e.SetGameSettings(settings)
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Overclock(); got != 20 {
+		t.Errorf("overclock after loading = %d, want 20", got)
+	}
+}
+
+func TestOverclockLengthensFrames(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	frame := func() uint64 {
+		t.Helper()
+		start := e.cpu.TotalCycles
+		if err := e.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+		return e.cpu.TotalCycles - start
+	}
+	for i := 0; i < 3; i++ {
+		frame()
+	}
+	before := frame()
+	// on the fly, without a new clock
+	e.SetOverclock(30)
+	frame()
+	after := frame()
+	// 30 scanlines of 341 dots, at 3 dots a cpu cycle; frames end on an
+	// instruction, so allow for a few cycles either way
+	want := before + 30*341/3
+	if after < want-8 || after > want+8 {
+		t.Errorf("frame with overclock = %d cpu cycles, want about %d", after, want)
+	}
+}
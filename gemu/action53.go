@@ -0,0 +1,156 @@
+package gemu
+
+import "fmt"
+
+func init() {
+	RegisterMapper(28, NewAction53)
+}
+
+// Action53 is mapper 28, the multicart board of the Action 53 homebrew
+// compilations. A register select at $5000-$5FFF picks which of its four
+// registers the data port at $8000-$FFFF writes:
+//
+//	$00  CHR bank (8kb of 32kb CHR RAM); bit 4 sets single-screen mirroring
+//	$01  inner PRG bank; bit 4 sets single-screen mirroring
+//	$80  mode: mirroring, PRG bank mode and the size of the outer bank
+//	$81  outer PRG bank, in 32kb units
+//
+// The outer bank picks a game and the inner bank switches within it, the
+// way the game's own NROM, CNROM, UNROM or AOROM board would. Like NROM it
+// has PRG RAM at $6000 of the size in the header, for games that keep
+// their work RAM there.
+type Action53 struct {
+	prg    []byte
+	chr    []byte
+	prgRAM []byte
+	chrRAM bool
+
+	reg   uint8
+	chr8  uint8
+	inner uint8
+	mode  uint8
+	outer uint8
+}
+
+func NewAction53(c *Cartridge) (Mapper, error) {
+	if len(c.PRG) == 0 {
+		return nil, fmt.Errorf("Action 53: %w", ErrNoPRG)
+	}
+
+	m := &Action53{
+		prg: c.PRG,
+		chr: c.CHR,
+		// the last bank holds the menu and the reset vector
+		outer: 0xFF,
+	}
+	if size := c.WorkRAMSize(); size > 0 {
+		m.prgRAM = make([]byte, (size+0xFF)&^0xFF)
+	}
+	if len(m.chr) == 0 {
+		m.chr = make([]byte, 32768)
+		m.chrRAM = true
+	}
+	return m, nil
+}
+
+// prgBank returns the 16kb PRG bank the cpu sees at addr ($8000-$FFFF).
+func (m *Action53) prgBank(addr uint16) int {
+	a14 := uint(addr>>14) & 1
+	inner := uint(m.inner)
+	var bank uint
+	switch m.mode >> 2 & 3 {
+	case 0, 1:
+		// 32kb
+		bank = inner<<1 | a14
+	case 2:
+		// $8000 fixed to the first bank of the game, like mapper 180
+		if a14 == 1 {
+			bank = inner
+		}
+	case 3:
+		// $C000 fixed to the last bank of the game, like UNROM
+		bank = 0xFF
+		if a14 == 0 {
+			bank = inner
+		}
+	}
+	// the outer bank size decides how many low bits the inner bank owns
+	mask := uint(2)<<(m.mode>>4&3) - 1
+	return int(uint(m.outer)<<1&^mask | bank&mask)
+}
+
+func (m *Action53) CPURead(addr uint16) uint8 {
+	if addr < 0x8000 {
+		if addr >= 0x6000 && len(m.prgRAM) > 0 {
+			return m.prgRAM[int(addr-0x6000)%len(m.prgRAM)]
+		}
+		return 0
+	}
+	off := m.prgBank(addr)*0x4000 + int(addr&0x3FFF)
+	return m.prg[off%len(m.prg)]
+}
+
+func (m *Action53) PRGWindow(addr uint16) []byte {
+	off := (m.prgBank(addr)*0x4000 + int(addr&0x2000)) % len(m.prg)
+	return m.prg[off:min(off+0x2000, len(m.prg))]
+}
+
+func (m *Action53) CPUWrite(addr uint16, v uint8) {
+	switch {
+	case addr >= 0x8000:
+		switch m.reg {
+		case 0x00:
+			m.chr8 = v
+			m.singleScreen(v)
+		case 0x01:
+			m.inner = v
+			m.singleScreen(v)
+		case 0x80:
+			m.mode = v
+		case 0x81:
+			m.outer = v
+		}
+	case addr >= 0x6000 && len(m.prgRAM) > 0:
+		m.prgRAM[int(addr-0x6000)%len(m.prgRAM)] = v
+	case addr >= 0x5000 && addr < 0x6000:
+		m.reg = v & 0x81
+	}
+}
+
+func (m *Action53) WorkRAM() []byte {
+	return m.prgRAM
+}
+
+// singleScreen copies bit 4 of a CHR or inner bank write to the mirroring,
+// when it is one of the single-screen modes.
+func (m *Action53) singleScreen(v uint8) {
+	if m.mode&0x02 == 0 {
+		m.mode = m.mode&^0x01 | v>>4&0x01
+	}
+}
+
+// Mirroring returns the nametable mirroring the mode register selects.
+func (m *Action53) Mirroring() Mirroring {
+	switch m.mode & 0x03 {
+	case 0:
+		return SingleScreenLower
+	case 1:
+		return SingleScreenUpper
+	case 2:
+		return Vertical
+	}
+	return Horizontal
+}
+
+func (m *Action53) PPURead(addr uint16) uint8 {
+	if addr < 0x2000 {
+		return m.chr[(int(m.chr8&0x03)*0x2000+int(addr))%len(m.chr)]
+	}
+	return 0
+}
+
+func (m *Action53) PPUWrite(addr uint16, v uint8) {
+	if m.chrRAM && addr < 0x2000 {
+		m.chr[(int(m.chr8&0x03)*0x2000+int(addr))%len(m.chr)] = v
+	}
+}
@@ -0,0 +1,110 @@
+package gemu
+
+import "testing"
+
+// newAction53 returns the mapper for 256kb of PRG, each 16kb bank filled
+// with its number, and CHR RAM.
+func newAction53(t *testing.T) *Action53 {
+	t.Helper()
+	c := &Cartridge{Header: [16]byte{'N', 'E', 'S', 0x1A, 16, 0, 0xC0, 0x10}}
+	for bank := 0; bank < 16; bank++ {
+		for i := 0; i < 0x4000; i++ {
+			c.PRG = append(c.PRG, byte(bank))
+		}
+	}
+	m, err := NewAction53(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.(*Action53)
+}
+
+// write53 selects register reg at $5000 and writes v to it at $8000.
+func write53(m *Action53, reg, v uint8) {
+	m.CPUWrite(0x5000, reg)
+	m.CPUWrite(0x8000, v)
+}
+
+func TestAction53Banks(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// register, value pairs
+		writes [][2]uint8
+		// the 16kb banks at $8000 and $C000
+		banks     [2]uint8
+		mirroring Mirroring
+	}{
+		{"power on", nil, [2]uint8{14, 15}, SingleScreenLower},
+		{"32kb outer", [][2]uint8{{0x81, 1}}, [2]uint8{2, 3}, SingleScreenLower},
+		// the inner bank has no say in a 32kb game
+		{"32kb inner ignored", [][2]uint8{{0x81, 1}, {0x01, 3}}, [2]uint8{2, 3}, SingleScreenLower},
+		// UNROM in a 64kb game: $C000 is the game's last bank
+		{"UNROM 64kb", [][2]uint8{{0x80, 0x1F}, {0x81, 2}, {0x01, 1}}, [2]uint8{5, 7}, Horizontal},
+		// mapper 180 in a 128kb game: $8000 is the game's first bank
+		{"fixed $8000 128kb", [][2]uint8{{0x80, 0x2A}, {0x81, 1}, {0x01, 3}}, [2]uint8{0, 3}, Vertical},
+		// BNROM-like 32kb banks of a 256kb game, inner bank 5
+		{"32kb banks 256kb", [][2]uint8{{0x80, 0x30}, {0x81, 7}, {0x01, 5}}, [2]uint8{10, 11}, SingleScreenLower},
+		// inner banks wrap within the outer bank
+		{"inner wraps", [][2]uint8{{0x80, 0x1F}, {0x81, 2}, {0x01, 6}}, [2]uint8{6, 7}, Horizontal},
+	} {
+		m := newAction53(t)
+		for _, w := range tc.writes {
+			write53(m, w[0], w[1])
+		}
+		for i, addr := range []uint16{0x8000, 0xC000} {
+			if got := m.PRGWindow(addr)[0]; got != tc.banks[i] {
+				t.Errorf("%s: window at $%04X shows bank %d, want %d", tc.name, addr, got, tc.banks[i])
+			}
+			if got := m.PRGWindow(addr + 0x2000)[0x1FFF]; got != tc.banks[i] {
+				t.Errorf("%s: window at $%04X shows bank %d, want %d", tc.name, addr+0x2000, got, tc.banks[i])
+			}
+			if got := m.CPURead(addr + 0x3FFF); got != tc.banks[i] {
+				t.Errorf("%s: $%04X reads bank %d, want %d", tc.name, addr+0x3FFF, got, tc.banks[i])
+			}
+		}
+		if got := m.Mirroring(); got != tc.mirroring {
+			t.Errorf("%s: mirroring %v, want %v", tc.name, got, tc.mirroring)
+		}
+	}
+}
+
+func TestAction53SingleScreen(t *testing.T) {
+	m := newAction53(t)
+	// bit 4 of a CHR or inner bank write picks the screen
+	write53(m, 0x01, 0x10)
+	if got := m.Mirroring(); got != SingleScreenUpper {
+		t.Errorf("mirroring %v after inner bank $10, want upper", got)
+	}
+	write53(m, 0x00, 0x00)
+	if got := m.Mirroring(); got != SingleScreenLower {
+		t.Errorf("mirroring %v after CHR bank $00, want lower", got)
+	}
+	// but not once the mode has picked vertical or horizontal
+	write53(m, 0x80, 0x02)
+	write53(m, 0x01, 0x10)
+	if got := m.Mirroring(); got != Vertical {
+		t.Errorf("mirroring %v after inner bank $10 in vertical mode, want vertical", got)
+	}
+}
+
+func TestAction53CHRAndWorkRAM(t *testing.T) {
+	m := newAction53(t)
+	write53(m, 0x00, 2)
+	m.PPUWrite(0x0010, 0xAB)
+	write53(m, 0x00, 1)
+	if got := m.PPURead(0x0010); got == 0xAB {
+		t.Error("CHR bank 1 shows bank 2's write")
+	}
+	write53(m, 0x00, 2)
+	if got := m.PPURead(0x0010); got != 0xAB {
+		t.Errorf("CHR bank 2 reads $%02X, want $AB", got)
+	}
+
+	if len(m.WorkRAM()) != 0x2000 {
+		t.Fatalf("%d bytes of work RAM, want 8kb", len(m.WorkRAM()))
+	}
+	m.CPUWrite(0x6123, 0x5A)
+	if got := m.CPURead(0x6123); got != 0x5A || m.WorkRAM()[0x123] != 0x5A {
+		t.Errorf("work RAM reads $%02X after writing $5A", got)
+	}
+}
@@ -1,7 +1,11 @@
 package gemu
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 )
 
@@ -10,8 +14,36 @@ type Cartridge struct {
 	Trainer []byte // 512 bytes
 	PRG     []byte // 16kb units
 	CHR     []byte // 8kb units
+
+	Mapper Mapper
+
+	// Logger receives details of the header as it is parsed. If nil,
+	// slog.Default() is used.
+	Logger *slog.Logger
+}
+
+func (c *Cartridge) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
 }
 
+var (
+	ErrTruncatedHeader   = errors.New("file is too short for an iNES header")
+	ErrBadMagic          = errors.New("invalid iNES header magic")
+	ErrTruncatedTrainer  = errors.New("file is too short for the trainer")
+	ErrTruncatedPRG      = errors.New("file is too short for the PRG size in the header")
+	ErrTruncatedCHR      = errors.New("file is too short for the CHR size in the header")
+	ErrUnsupportedMapper = errors.New("unsupported mapper")
+	ErrNoPRG             = errors.New("cartridge has no PRG")
+)
+
+const (
+	headerSize  = 16
+	trainerSize = 512
+)
+
 func (c *Cartridge) Insert(path string) error {
 	file, err := os.Open(path)
 	if err != nil {
@@ -19,47 +51,183 @@ func (c *Cartridge) Insert(path string) error {
 	}
 	defer file.Close()
 
-	bytesRead, err := file.Read(c.Header[:])
+	info, err := file.Stat()
 	if err != nil {
 		return err
 	}
 
-	if bytesRead != len(c.Header) {
-		return fmt.Errorf("failed to read header")
+	return c.load(file, info.Size())
+}
+
+// InsertPatched loads the cartridge at path with the IPS or BPS patch at
+// patchPath applied.
+func (c *Cartridge) InsertPatched(path, patchPath string) error {
+	rom, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		return err
 	}
+	patched, err := ApplyPatch(rom, patch)
+	if err != nil {
+		return fmt.Errorf("applying %s: %w", patchPath, err)
+	}
+	return c.InsertBytes(patched)
+}
 
-	// validate the header
-	if c.Header[0] != 0x4E || c.Header[1] != 0x45 || c.Header[2] != 0x53 || c.Header[3] != 0x1A {
-		return fmt.Errorf("invalid header")
+// InsertBytes loads a cartridge from an in-memory iNES image.
+func (c *Cartridge) InsertBytes(data []byte) error {
+	return c.load(bytes.NewReader(data), int64(len(data)))
+}
+
+// ParseImage parses an in-memory iNES image without creating its mapper,
+// for tools that inspect or fix ROMs the emulator can't run.
+func (c *Cartridge) ParseImage(data []byte) error {
+	return c.parse(bytes.NewReader(data), int64(len(data)))
+}
+
+// load reads an iNES image of size bytes from r and attaches its mapper.
+func (c *Cartridge) load(r io.Reader, size int64) error {
+	if err := c.parse(r, size); err != nil {
+		return err
 	}
 
-	c.PRG = make([]byte, uint(c.Header[4])*16384)
-	fmt.Printf("Byte 4 (PRG): %d * 16kb units (%d total)\n", c.Header[4], len(c.PRG))
-	bytesRead, err = file.Read(c.PRG)
+	factory, ok := LookupMapper(c.MapperID())
+	if !ok {
+		return fmt.Errorf("%w %d", ErrUnsupportedMapper, c.MapperID())
+	}
+	mapper, err := factory(c)
 	if err != nil {
 		return err
 	}
-	if bytesRead != len(c.PRG) {
-		return fmt.Errorf("failed to read PRG")
+	c.Mapper = mapper
+
+	return nil
+}
+
+// parse reads an iNES image of size bytes from r. Every section is checked
+// against size before it is allocated, so a header can't ask for more memory
+// than the file could fill.
+func (c *Cartridge) parse(r io.Reader, size int64) error {
+	if size < headerSize {
+		return ErrTruncatedHeader
+	}
+	if _, err := io.ReadFull(r, c.Header[:]); err != nil {
+		return fmt.Errorf("%w: %w", ErrTruncatedHeader, err)
 	}
 
-	if c.Header[5] == 0 {
-		fmt.Println("Byte 5 (CHR RAM)")
-	} else {
-		c.CHR = make([]byte, uint(c.Header[5])*8192)
-		fmt.Printf("Byte 5 (CHR ROM): %d * 8kb units (%d total)\n", c.Header[5], len(c.CHR))
-		bytesRead, err = file.Read(c.CHR)
-		if err != nil {
-			return err
+	// validate the header
+	if c.Header[0] != 0x4E || c.Header[1] != 0x45 || c.Header[2] != 0x53 || c.Header[3] != 0x1A {
+		return ErrBadMagic
+	}
+	remaining := size - headerSize
+
+	c.Trainer = nil
+	if c.Header[6]&0x04 != 0 {
+		if remaining < trainerSize {
+			return ErrTruncatedTrainer
+		}
+		c.Trainer = make([]byte, trainerSize)
+		if _, err := io.ReadFull(r, c.Trainer); err != nil {
+			return fmt.Errorf("%w: %w", ErrTruncatedTrainer, err)
 		}
-		if bytesRead != len(c.CHR) {
-			return fmt.Errorf("failed to read CHR")
+		remaining -= trainerSize
+	}
+
+	prgSize, ok := c.prgSize()
+	if !ok || prgSize > uint64(remaining) {
+		return ErrTruncatedPRG
+	}
+	c.PRG = make([]byte, prgSize)
+	c.logger().Debug("PRG ROM", "units", c.Header[4], "bytes", len(c.PRG))
+	if _, err := io.ReadFull(r, c.PRG); err != nil {
+		return fmt.Errorf("%w: %w", ErrTruncatedPRG, err)
+	}
+	remaining -= int64(prgSize)
+
+	chrSize, ok := c.chrSize()
+	if !ok || chrSize > uint64(remaining) {
+		return ErrTruncatedCHR
+	}
+	c.CHR = nil
+	if chrSize == 0 {
+		c.logger().Debug("CHR RAM")
+	} else {
+		c.CHR = make([]byte, chrSize)
+		c.logger().Debug("CHR ROM", "units", c.Header[5], "bytes", len(c.CHR))
+		if _, err := io.ReadFull(r, c.CHR); err != nil {
+			return fmt.Errorf("%w: %w", ErrTruncatedCHR, err)
 		}
 	}
 
-	// print byte 6 in binary
-	fmt.Printf("Byte 6 (Flags 6): %08b\n", c.Header[6])
-	fmt.Printf("Byte 7 (Flags 7): %08b\n", c.Header[7])
+	c.logger().Debug("header flags",
+		"flags6", fmt.Sprintf("%08b", c.Header[6]),
+		"flags7", fmt.Sprintf("%08b", c.Header[7]),
+		"mapper", c.MapperID())
 
 	return nil
 }
+
+// prgSize returns the PRG ROM size in bytes, and false if the NES 2.0
+// exponent form describes a size too large to be real.
+func (c *Cartridge) prgSize() (uint64, bool) {
+	return c.romSize(c.Header[4], c.Header[9]&0x0F, 16384)
+}
+
+// chrSize returns the CHR ROM size in bytes, and false if the NES 2.0
+// exponent form describes a size too large to be real.
+func (c *Cartridge) chrSize() (uint64, bool) {
+	return c.romSize(c.Header[5], c.Header[9]>>4, 8192)
+}
+
+func (c *Cartridge) romSize(lsb, msb uint8, unit uint64) (uint64, bool) {
+	if !c.IsNES2() {
+		return uint64(lsb) * unit, true
+	}
+	if msb != 0x0F {
+		return (uint64(msb)<<8 | uint64(lsb)) * unit, true
+	}
+	// exponent-multiplier notation: 2^E * (MM*2+1)
+	exp := lsb >> 2
+	mul := uint64(lsb&0x03)*2 + 1
+	if exp > 40 {
+		return 0, false
+	}
+	return (uint64(1) << exp) * mul, true
+}
+
+// MapperID returns the mapper number from the header, including the NES 2.0
+// high bits.
+func (c *Cartridge) MapperID() uint16 {
+	id := uint16(c.Header[7]&0xF0) | uint16(c.Header[6]>>4)
+	if c.IsNES2() {
+		id |= uint16(c.Header[8]&0x0F) << 8
+	}
+	return id
+}
+
+// IsNES2 reports whether the header is in NES 2.0 format.
+func (c *Cartridge) IsNES2() bool {
+	return c.Header[7]&0x0C == 0x08
+}
+
+// Region returns the timing region declared in the header: NES 2.0 byte 12,
+// or the iNES byte 9 TV system bit.
+func (c *Cartridge) Region() Region {
+	if c.IsNES2() {
+		switch c.Header[12] & 0x03 {
+		case 1:
+			return PAL
+		case 3:
+			return Dendy
+		default:
+			return NTSC
+		}
+	}
+	if c.Header[9]&0x01 != 0 {
+		return PAL
+	}
+	return NTSC
+}
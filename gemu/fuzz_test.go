@@ -0,0 +1,130 @@
+package gemu
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// image returns an iNES image with the given header bytes 4-7 and the PRG
+// and CHR they ask for, filled with a pattern.
+func image(prg, chr, flags6, flags7 byte) []byte {
+	b := []byte{'N', 'E', 'S', 0x1A, prg, chr, flags6, flags7, 0, 0, 0, 0, 0, 0, 0, 0}
+	n := int(prg)*16384 + int(chr)*8192
+	if flags6&0x04 != 0 {
+		n += trainerSize
+	}
+	for i := 0; i < n; i++ {
+		b = append(b, byte(i*7))
+	}
+	return b
+}
+
+// ips returns an IPS patch writing data at offset, and a run of n copies
+// of fill after it.
+func ips(offset int, data []byte, n int, fill byte) []byte {
+	p := []byte("PATCH")
+	p = append(p, byte(offset>>16), byte(offset>>8), byte(offset), 0, byte(len(data)))
+	p = append(p, data...)
+	end := offset + len(data)
+	p = append(p, byte(end>>16), byte(end>>8), byte(end), 0, 0, 0, byte(n), fill)
+	return append(p, "EOF"...)
+}
+
+// bps returns a BPS patch turning source into target, by reading every
+// byte of target from the patch.
+func bps(source, target []byte) []byte {
+	number := func(p []byte, v uint64) []byte {
+		for {
+			x := byte(v & 0x7F)
+			v >>= 7
+			if v == 0 {
+				return append(p, x|0x80)
+			}
+			p = append(p, x)
+			v--
+		}
+	}
+	p := []byte("BPS1")
+	p = number(p, uint64(len(source)))
+	p = number(p, uint64(len(target)))
+	p = number(p, 0)
+	if len(target) > 0 {
+		p = number(p, uint64(len(target)-1)<<2|1)
+		p = append(p, target...)
+	}
+	p = binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(source))
+	p = binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(p))
+}
+
+// wraps fails t unless err is nil or wraps one of targets.
+func wraps(t *testing.T, err error, targets ...error) {
+	t.Helper()
+	if err == nil {
+		return
+	}
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return
+		}
+	}
+	t.Fatalf("error %q wraps none of the exported errors", err)
+}
+
+func FuzzInsertBytes(f *testing.F) {
+	f.Add(image(1, 1, 0, 0))
+	f.Add(image(2, 0, 0x01, 0))
+	f.Add(image(1, 1, 0x04, 0))
+	f.Add(image(2, 0, 0xC0, 0x10)) // mapper 28
+	f.Add(image(1, 1, 0x10, 0x08)) // NES 2.0, mapper 1
+	f.Add(image(0, 0, 0, 0))
+	f.Add(image(1, 1, 0, 0)[:100])
+	f.Add([]byte("NES"))
+
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c := Cartridge{Logger: quiet}
+		err := c.InsertBytes(data)
+		wraps(t, err, ErrTruncatedHeader, ErrBadMagic, ErrTruncatedTrainer,
+			ErrTruncatedPRG, ErrTruncatedCHR, ErrUnsupportedMapper, ErrNoPRG)
+		if err != nil {
+			return
+		}
+		// a cartridge that loads can be read all over
+		for a := 0x6000; a < 0x10000; a += 0x7F {
+			c.Mapper.CPURead(uint16(a))
+		}
+		for a := 0; a < 0x2000; a += 0x3F {
+			c.Mapper.PPURead(uint16(a))
+		}
+	})
+}
+
+func FuzzApplyPatch(f *testing.F) {
+	// the start of an image: patches don't care what's in it, and the
+	// fuzzer minimizes small inputs much faster
+	rom := image(1, 1, 0, 0)[:512]
+	target := append([]byte(nil), rom...)
+	target[0x10] = 0xEA
+	f.Add(rom, ips(0x10, []byte{0xEA, 0xEA}, 4, 0xFF))
+	f.Add(rom, ips(len(rom), []byte{1, 2, 3}, 0, 0))
+	f.Add(rom, []byte("PATCHEOF\x00\x40\x00"))
+	f.Add(rom, bps(rom, target))
+	f.Add(rom[:64], bps(rom[:64], nil))
+	f.Add(rom, []byte("BPS1"))
+	f.Add(rom, []byte("UPS1"))
+
+	f.Fuzz(func(t *testing.T, rom, patch []byte) {
+		for _, apply := range []func(rom, patch []byte) ([]byte, error){ApplyPatch, ApplyIPS, ApplyBPS} {
+			out, err := apply(rom, patch)
+			wraps(t, err, ErrUnknownPatch, ErrBadPatch, ErrPatchChecksum)
+			if err == nil && len(out) > maxPatchedSize {
+				t.Fatalf("patched ROM is %d bytes", len(out))
+			}
+		}
+	})
+}
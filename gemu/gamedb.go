@@ -0,0 +1,97 @@
+package gemu
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Game is a known dump in a GameDB.
+type Game struct {
+	Name   string
+	Region Region
+}
+
+// BadDump reports whether the name carries a GoodNES bad dump tag, [b] or
+// a numbered one such as [b2].
+func (g Game) BadDump() bool {
+	for rest := g.Name; ; {
+		i := strings.Index(rest, "[b")
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+2:]
+		tag, _, ok := strings.Cut(rest, "]")
+		if ok && strings.Trim(tag, "0123456789") == "" {
+			return true
+		}
+	}
+}
+
+// GameDB identifies dumps by the CRC32 of their PRG and CHR ROM, the way
+// NesCartDB and No-Intro do, so the header (often wrong on old dumps) is
+// not part of the key.
+type GameDB struct {
+	games map[uint32]Game
+}
+
+// LoadGameDB reads a database file. See ParseGameDB for the format.
+func LoadGameDB(path string) (*GameDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseGameDB(f)
+}
+
+// ParseGameDB reads a database with one game per line:
+//
+//	# crc32    region  name
+//	1B2D5F8C   pal     Some Game (Europe)
+//
+// Blank lines and lines starting with # are skipped.
+func ParseGameDB(r io.Reader) (*GameDB, error) {
+	db := &GameDB{games: map[uint32]Game{}}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("game database line %d: want crc32, region and name", n)
+		}
+		crc, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("game database line %d: invalid crc32 %q", n, fields[0])
+		}
+		region, ok := RegionByName(fields[1])
+		if !ok {
+			return nil, fmt.Errorf("game database line %d: unknown region %q", n, fields[1])
+		}
+		db.games[uint32(crc)] = Game{Name: strings.Join(fields[2:], " "), Region: region}
+	}
+	return db, sc.Err()
+}
+
+// Lookup finds the cartridge's dump in the database.
+func (db *GameDB) Lookup(c *Cartridge) (Game, bool) {
+	if db == nil {
+		return Game{}, false
+	}
+	g, ok := db.games[c.CRC32()]
+	return g, ok
+}
+
+// CRC32 returns the CRC32 of the PRG and CHR ROM, without the header or
+// trainer.
+func (c *Cartridge) CRC32() uint32 {
+	crc := crc32.ChecksumIEEE(c.PRG)
+	return crc32.Update(crc, crc32.IEEETable, c.CHR)
+}
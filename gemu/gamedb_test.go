@@ -0,0 +1,42 @@
+package gemu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGameDB(t *testing.T) {
+	db, err := ParseGameDB(strings.NewReader(`# crc32    region  name
+1B2D5F8C   pal     Some Game (Europe)
+
+00000001   ntsc    Other Game (USA) [b1]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		crc  uint32
+		want Game
+	}{
+		{0x1B2D5F8C, Game{"Some Game (Europe)", PAL}},
+		{0x00000001, Game{"Other Game (USA) [b1]", NTSC}},
+	} {
+		if g, ok := db.games[tc.crc]; !ok || g.Name != tc.want.Name || g.Region.Name != tc.want.Region.Name {
+			t.Errorf("%08X: %+v, want %+v", tc.crc, g, tc.want)
+		}
+	}
+}
+
+func TestParseGameDBErrors(t *testing.T) {
+	for _, line := range []string{
+		"1B2D5F8C",
+		// a game needs a name
+		"1B2D5F8C pal",
+		"XYZ pal Some Game",
+		"1B2D5F8C mars Some Game",
+	} {
+		if _, err := ParseGameDB(strings.NewReader(line)); err == nil {
+			t.Errorf("parsed %q", line)
+		}
+	}
+}
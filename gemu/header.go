@@ -0,0 +1,205 @@
+package gemu
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Mirroring is the nametable arrangement wired on the cartridge.
+type Mirroring uint8
+
+const (
+	Horizontal Mirroring = iota
+	Vertical
+	FourScreen
+	// single-screen mirroring, which only mappers can select
+	SingleScreenLower
+	SingleScreenUpper
+)
+
+func (m Mirroring) String() string {
+	switch m {
+	case Horizontal:
+		return "horizontal"
+	case Vertical:
+		return "vertical"
+	case FourScreen:
+		return "four-screen"
+	case SingleScreenLower:
+		return "single-screen lower"
+	case SingleScreenUpper:
+		return "single-screen upper"
+	}
+	return fmt.Sprintf("Mirroring(%d)", uint8(m))
+}
+
+// MirroringByName looks up a mirroring by name, ignoring case.
+func MirroringByName(name string) (Mirroring, bool) {
+	for _, m := range []Mirroring{Horizontal, Vertical, FourScreen} {
+		if strings.EqualFold(m.String(), name) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+var ErrMapperRange = errors.New("mapper number needs a NES 2.0 header")
+
+// Mirroring returns the nametable mirroring from header byte 6.
+func (c *Cartridge) Mirroring() Mirroring {
+	switch {
+	case c.Header[6]&0x08 != 0:
+		return FourScreen
+	case c.Header[6]&0x01 != 0:
+		return Vertical
+	}
+	return Horizontal
+}
+
+// Battery reports whether the cartridge has battery backed memory.
+func (c *Cartridge) Battery() bool {
+	return c.Header[6]&0x02 != 0
+}
+
+// Submapper returns the NES 2.0 submapper, or 0 for iNES.
+func (c *Cartridge) Submapper() uint8 {
+	if !c.IsNES2() {
+		return 0
+	}
+	return c.Header[8] >> 4
+}
+
+// PRGRAMSize returns the volatile and battery backed PRG RAM sizes in
+// bytes declared by a NES 2.0 header.
+func (c *Cartridge) PRGRAMSize() (ram, nvram int) {
+	if !c.IsNES2() {
+		return 0, 0
+	}
+	return shiftSize(c.Header[10] & 0x0F), shiftSize(c.Header[10] >> 4)
+}
+
+// WorkRAMSize returns how many bytes of PRG RAM the board has for
+// $6000-$7FFF: the volatile and battery backed sizes of a NES 2.0 header
+// together, or iNES byte 8 in 8KB units, where 0 also means 8KB since old
+// dumps leave it unset.
+func (c *Cartridge) WorkRAMSize() int {
+	if c.IsNES2() {
+		ram, nvram := c.PRGRAMSize()
+		return ram + nvram
+	}
+	return max(int(c.Header[8]), 1) * 8192
+}
+
+// CHRRAMSize returns the volatile and battery backed CHR RAM sizes in
+// bytes declared by a NES 2.0 header.
+func (c *Cartridge) CHRRAMSize() (ram, nvram int) {
+	if !c.IsNES2() {
+		return 0, 0
+	}
+	return shiftSize(c.Header[11] & 0x0F), shiftSize(c.Header[11] >> 4)
+}
+
+// shiftSize decodes a NES 2.0 RAM size, 64 << shift, with 0 meaning none.
+func shiftSize(shift uint8) int {
+	if shift == 0 {
+		return 0
+	}
+	return 64 << shift
+}
+
+// SetMapperID rewrites the mapper number. Mappers above 255 need a NES 2.0
+// header.
+func (c *Cartridge) SetMapperID(id uint16) error {
+	if id > 0xFF && !c.IsNES2() {
+		return fmt.Errorf("%w: %d", ErrMapperRange, id)
+	}
+	c.Header[6] = c.Header[6]&0x0F | uint8(id&0x0F)<<4
+	c.Header[7] = c.Header[7]&0x0F | uint8(id&0xF0)
+	if c.IsNES2() {
+		c.Header[8] = c.Header[8]&0xF0 | uint8(id>>8)&0x0F
+	}
+	return nil
+}
+
+// SetMirroring rewrites the mirroring bits of header byte 6.
+func (c *Cartridge) SetMirroring(m Mirroring) {
+	c.Header[6] &^= 0x09
+	switch m {
+	case Vertical:
+		c.Header[6] |= 0x01
+	case FourScreen:
+		c.Header[6] |= 0x08
+	}
+}
+
+// SetBattery rewrites the battery bit of header byte 6.
+func (c *Cartridge) SetBattery(on bool) {
+	c.Header[6] &^= 0x02
+	if on {
+		c.Header[6] |= 0x02
+	}
+}
+
+// UpgradeNES2 converts an iNES header to NES 2.0, carrying over what the
+// iNES header says and filling in the usual 8KB of PRG RAM (battery backed
+// if the battery bit is set) and CHR RAM for boards without CHR ROM.
+func (c *Cartridge) UpgradeNES2() {
+	if c.IsNES2() {
+		return
+	}
+	region := c.Region()
+	// bytes 7-15 of old iNES dumps are often garbage, so start them over.
+	// Byte 7 holds the high nibble of the mapper unless bytes 12-15 show
+	// the header was written by a tool such as DiskDude!, which filled
+	// 7-15 with its name.
+	if c.dirtyHeader() {
+		c.Header[7] = 0x08
+	} else {
+		c.Header[7] = c.Header[7]&0xF3 | 0x08
+	}
+	clear(c.Header[8:])
+	c.SyncSizes()
+
+	if c.Battery() {
+		c.Header[10] = 0x70
+	} else {
+		c.Header[10] = 0x07
+	}
+	if len(c.CHR) == 0 {
+		c.Header[11] = 0x07
+	}
+
+	switch region.Name {
+	case PAL.Name:
+		c.Header[12] = 1
+	case Dendy.Name:
+		c.Header[12] = 3
+	}
+}
+
+// dirtyHeader reports whether an iNES header has something other than 0
+// in bytes 12-15, which only a tool writing junk over bytes 7-15 leaves.
+func (c *Cartridge) dirtyHeader() bool {
+	return c.Header[12]|c.Header[13]|c.Header[14]|c.Header[15] != 0
+}
+
+// SyncSizes rewrites the PRG and CHR sizes in the header from the lengths
+// of PRG and CHR, after they have been replaced.
+func (c *Cartridge) SyncSizes() {
+	prg, chr := len(c.PRG)/16384, len(c.CHR)/8192
+	c.Header[4] = uint8(prg)
+	c.Header[5] = uint8(chr)
+	if c.IsNES2() {
+		c.Header[9] = uint8(prg>>8)&0x0F | uint8(chr>>8)<<4
+	}
+}
+
+// Bytes returns the cartridge as an iNES image, with any header edits.
+func (c *Cartridge) Bytes() []byte {
+	b := make([]byte, 0, headerSize+len(c.Trainer)+len(c.PRG)+len(c.CHR))
+	b = append(b, c.Header[:]...)
+	b = append(b, c.Trainer...)
+	b = append(b, c.PRG...)
+	return append(b, c.CHR...)
+}
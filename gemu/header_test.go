@@ -0,0 +1,29 @@
+package gemu
+
+import "testing"
+
+func TestUpgradeNES2(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header [16]byte
+		mapper uint16
+	}{
+		{"mapper 28", [16]byte{'N', 'E', 'S', 0x1A, 2, 0, 0xC1, 0x10}, 28},
+		// "DiskDude!" over bytes 7-15 would make mapper 4 read as 68
+		{"DiskDude", [16]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x40, 'D', 'i', 's', 'k', 'D', 'u', 'd', 'e', '!'}, 4},
+	} {
+		c := Cartridge{Header: tc.header}
+		c.PRG = make([]byte, int(tc.header[4])*16384)
+		c.CHR = make([]byte, int(tc.header[5])*8192)
+		c.UpgradeNES2()
+		if !c.IsNES2() {
+			t.Errorf("%s: header isn't NES 2.0 after the upgrade", tc.name)
+		}
+		if got := c.MapperID(); got != tc.mapper {
+			t.Errorf("%s: mapper %d after the upgrade, want %d", tc.name, got, tc.mapper)
+		}
+		if c.Submapper() != 0 || c.Header[7]&0x03 != 0 || c.Header[13] != 0 || c.Header[15] != 0 {
+			t.Errorf("%s: old bytes left in the header: % X", tc.name, c.Header[7:])
+		}
+	}
+}
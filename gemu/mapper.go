@@ -0,0 +1,90 @@
+package gemu
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Mapper maps cartridge memory into the cpu and ppu address spaces.
+type Mapper interface {
+	CPURead(addr uint16) uint8
+	CPUWrite(addr uint16, v uint8)
+	PPURead(addr uint16) uint8
+	PPUWrite(addr uint16, v uint8)
+}
+
+// RAMMapper is a Mapper with work RAM that the cpu reads and writes
+// directly at $6000-$7FFF, mirrored if it is smaller than the window. A
+// mapper with no RAM returns nil and the window is left unmapped.
+type RAMMapper interface {
+	Mapper
+	WorkRAM() []byte
+}
+
+// BankMapper is a Mapper that can say which of its PRG the cpu sees in
+// each 8kb window of $8000-$FFFF, so the emulator can read the window
+// straight from it. PRGWindow returns the 8kb at the window holding addr,
+// or less if the PRG is smaller, mirrored across the window; it is asked
+// again after every write to the cartridge.
+type BankMapper interface {
+	Mapper
+	PRGWindow(addr uint16) []byte
+}
+
+// BusFault is what a mapper, or anything else on the cpu's bus, panics
+// with when the cpu makes an access the hardware can't serve, such as a
+// bank switch past the end of the ROM on a board that would then float the
+// bus. The emulator stops with an error rather than crashing. Any other
+// panic is a bug in gemu and isn't recovered.
+type BusFault struct {
+	Addr   uint16
+	Reason string
+}
+
+func (f *BusFault) Error() string {
+	return fmt.Sprintf("$%04X: %s", f.Addr, f.Reason)
+}
+
+// MapperFactory builds a mapper for a cartridge that has been read.
+type MapperFactory func(c *Cartridge) (Mapper, error)
+
+var (
+	mappersMu sync.RWMutex
+	mappers   = make(map[uint16]MapperFactory)
+)
+
+// RegisterMapper makes a mapper implementation available to the cartridge
+// loader under its iNES/NES 2.0 mapper number. It panics if factory is nil or
+// the id is already registered.
+func RegisterMapper(id uint16, factory MapperFactory) {
+	mappersMu.Lock()
+	defer mappersMu.Unlock()
+	if factory == nil {
+		panic("gemu: RegisterMapper factory is nil")
+	}
+	if _, dup := mappers[id]; dup {
+		panic(fmt.Sprintf("gemu: RegisterMapper called twice for mapper %d", id))
+	}
+	mappers[id] = factory
+}
+
+// LookupMapper returns the factory registered for a mapper number.
+func LookupMapper(id uint16) (MapperFactory, bool) {
+	mappersMu.RLock()
+	defer mappersMu.RUnlock()
+	f, ok := mappers[id]
+	return f, ok
+}
+
+// Mappers returns the registered mapper numbers in ascending order.
+func Mappers() []uint16 {
+	mappersMu.RLock()
+	defer mappersMu.RUnlock()
+	ids := make([]uint16, 0, len(mappers))
+	for id := range mappers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,81 @@
+package gemu
+
+import "fmt"
+
+func init() {
+	RegisterMapper(0, NewNROM)
+}
+
+// NROM is mapper 0: 16kb or 32kb of PRG at $8000 (16kb is mirrored at
+// $C000), 8kb of CHR, and optional PRG RAM at $6000 of the size in the
+// header, mirrored across the window.
+type NROM struct {
+	prg    []byte
+	chr    []byte
+	prgRAM []byte
+	chrRAM bool
+}
+
+func NewNROM(c *Cartridge) (Mapper, error) {
+	if len(c.PRG) == 0 {
+		return nil, fmt.Errorf("NROM: %w", ErrNoPRG)
+	}
+
+	m := &NROM{
+		prg: c.PRG,
+		chr: c.CHR,
+	}
+	if size := c.WorkRAMSize(); size > 0 {
+		// the cpu maps RAM in 256 byte pages, so round tiny sizes up
+		m.prgRAM = make([]byte, (size+0xFF)&^0xFF)
+		if len(c.Trainer) > 0 && len(m.prgRAM) >= len(c.Trainer) {
+			// the trainer is loaded at $7000
+			copy(m.prgRAM[0x1000%len(m.prgRAM):], c.Trainer)
+		}
+	}
+	if len(m.chr) == 0 {
+		// CHR RAM
+		m.chr = make([]byte, 8192)
+		m.chrRAM = true
+	}
+	return m, nil
+}
+
+func (m *NROM) CPURead(addr uint16) uint8 {
+	switch {
+	case addr >= 0x8000:
+		return m.prg[int(addr-0x8000)%len(m.prg)]
+	case addr >= 0x6000 && len(m.prgRAM) > 0:
+		return m.prgRAM[int(addr-0x6000)%len(m.prgRAM)]
+	}
+	return 0
+}
+
+func (m *NROM) PRGWindow(addr uint16) []byte {
+	off := int(addr&0x6000) % len(m.prg)
+	return m.prg[off:min(off+0x2000, len(m.prg))]
+}
+
+func (m *NROM) CPUWrite(addr uint16, v uint8) {
+	if addr >= 0x6000 && addr < 0x8000 && len(m.prgRAM) > 0 {
+		m.prgRAM[int(addr-0x6000)%len(m.prgRAM)] = v
+	}
+}
+
+func (m *NROM) WorkRAM() []byte {
+	return m.prgRAM
+}
+
+func (m *NROM) PPURead(addr uint16) uint8 {
+	if addr < 0x2000 {
+		// NES 2.0 headers can describe CHR smaller than the 8kb window
+		return m.chr[int(addr)%len(m.chr)]
+	}
+	return 0
+}
+
+func (m *NROM) PPUWrite(addr uint16, v uint8) {
+	if m.chrRAM && addr < 0x2000 {
+		m.chr[int(addr)%len(m.chr)] = v
+	}
+}
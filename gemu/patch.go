@@ -0,0 +1,216 @@
+package gemu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrUnknownPatch  = errors.New("unrecognised patch format")
+	ErrBadPatch      = errors.New("malformed patch")
+	ErrPatchChecksum = errors.New("patch checksum mismatch")
+)
+
+// patchExtensions are tried, in order, next to a ROM by FindPatch.
+var patchExtensions = []string{".ips", ".bps"}
+
+// FindPatch returns the path of a patch with the same base name as the ROM
+// at romPath (game.nes -> game.ips or game.bps), or "" if there is none.
+func FindPatch(romPath string) string {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	for _, e := range patchExtensions {
+		p := base + e
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// ApplyPatch applies an IPS or BPS patch to rom, picking the format from
+// the patch's magic.
+func ApplyPatch(rom, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte("PATCH")):
+		return ApplyIPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte("BPS1")):
+		return ApplyBPS(rom, patch)
+	}
+	return nil, ErrUnknownPatch
+}
+
+// ApplyIPS applies an IPS patch to a copy of rom. Records past the end of
+// rom grow it, and the optional truncation length after EOF shrinks it.
+func ApplyIPS(rom, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, []byte("PATCH")) {
+		return nil, ErrUnknownPatch
+	}
+	out := bytes.Clone(rom)
+	p := patch[len("PATCH"):]
+	for {
+		if len(p) < 3 {
+			return nil, fmt.Errorf("%w: missing EOF", ErrBadPatch)
+		}
+		if string(p[:3]) == "EOF" {
+			p = p[3:]
+			break
+		}
+		if len(p) < 5 {
+			return nil, fmt.Errorf("%w: truncated record", ErrBadPatch)
+		}
+		offset := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		size := int(binary.BigEndian.Uint16(p[3:]))
+		p = p[5:]
+
+		var data []byte
+		if size == 0 {
+			// run-length record
+			if len(p) < 3 {
+				return nil, fmt.Errorf("%w: truncated RLE record", ErrBadPatch)
+			}
+			data = bytes.Repeat(p[2:3], int(binary.BigEndian.Uint16(p)))
+			p = p[3:]
+		} else {
+			if len(p) < size {
+				return nil, fmt.Errorf("%w: truncated record", ErrBadPatch)
+			}
+			data = p[:size]
+			p = p[size:]
+		}
+
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	if len(p) >= 3 {
+		if n := int(p[0])<<16 | int(p[1])<<8 | int(p[2]); n < len(out) {
+			out = out[:n]
+		}
+	}
+	return out, nil
+}
+
+// maxPatchedSize bounds the ROM a BPS patch may declare, far above any
+// real cartridge, so a few bytes of patch can't demand gigabytes.
+const maxPatchedSize = 64 << 20
+
+// ApplyBPS applies a BPS patch to rom, checking the source, target and
+// patch checksums.
+func ApplyBPS(rom, patch []byte) ([]byte, error) {
+	const footer = 12
+	if len(patch) < len("BPS1")+footer {
+		return nil, fmt.Errorf("%w: too short", ErrBadPatch)
+	}
+	body := patch[:len(patch)-footer]
+	sums := patch[len(patch)-footer:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(sums[8:]) {
+		return nil, fmt.Errorf("%w: patch is corrupt", ErrPatchChecksum)
+	}
+	if crc32.ChecksumIEEE(rom) != binary.LittleEndian.Uint32(sums[0:]) {
+		return nil, fmt.Errorf("%w: patch is for a different ROM", ErrPatchChecksum)
+	}
+
+	r := bpsReader{b: body, pos: len("BPS1")}
+	sourceSize := r.number()
+	targetSize := r.number()
+	metadata := r.number()
+	if r.err != nil || sourceSize != uint64(len(rom)) || metadata > uint64(len(body)-r.pos) {
+		return nil, fmt.Errorf("%w: bad header", ErrBadPatch)
+	}
+	if targetSize > maxPatchedSize {
+		return nil, fmt.Errorf("%w: target too large", ErrBadPatch)
+	}
+	r.pos += int(metadata)
+
+	out := make([]byte, targetSize)
+	var written, sourceRel, targetRel int
+	for r.pos < len(body) && r.err == nil {
+		cmd := r.number()
+		// checked before converting, so a huge length can't wrap negative
+		if cmd>>2 >= uint64(len(out)-written) {
+			return nil, fmt.Errorf("%w: writes past the target", ErrBadPatch)
+		}
+		n := int(cmd>>2) + 1
+		switch cmd & 3 {
+		case 0: // source read
+			if written+n > len(rom) {
+				return nil, fmt.Errorf("%w: reads past the source", ErrBadPatch)
+			}
+			copy(out[written:], rom[written:written+n])
+		case 1: // target read
+			if r.pos+n > len(body) {
+				return nil, fmt.Errorf("%w: truncated data", ErrBadPatch)
+			}
+			copy(out[written:], body[r.pos:r.pos+n])
+			r.pos += n
+		case 2: // source copy
+			sourceRel += r.offset()
+			if sourceRel < 0 || sourceRel+n > len(rom) {
+				return nil, fmt.Errorf("%w: reads past the source", ErrBadPatch)
+			}
+			copy(out[written:], rom[sourceRel:sourceRel+n])
+			sourceRel += n
+		case 3: // target copy, which may overlap what it is writing
+			targetRel += r.offset()
+			if targetRel < 0 || targetRel >= written {
+				return nil, fmt.Errorf("%w: reads past the target", ErrBadPatch)
+			}
+			for i := 0; i < n; i++ {
+				out[written+i] = out[targetRel]
+				targetRel++
+			}
+		}
+		written += n
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if crc32.ChecksumIEEE(out) != binary.LittleEndian.Uint32(sums[4:]) {
+		return nil, fmt.Errorf("%w: patched ROM is wrong", ErrPatchChecksum)
+	}
+	return out, nil
+}
+
+type bpsReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+// number reads a BPS variable length number.
+func (r *bpsReader) number() uint64 {
+	var v uint64
+	shift := uint64(1)
+	for {
+		if r.pos >= len(r.b) || shift > 1<<56 {
+			r.err = fmt.Errorf("%w: truncated number", ErrBadPatch)
+			return 0
+		}
+		x := r.b[r.pos]
+		r.pos++
+		v += uint64(x&0x7F) * shift
+		if x&0x80 != 0 {
+			return v
+		}
+		shift <<= 7
+		v += shift
+	}
+}
+
+// offset reads a signed relative offset.
+func (r *bpsReader) offset() int {
+	d := r.number()
+	if d&1 != 0 {
+		return -int(d >> 1)
+	}
+	return int(d >> 1)
+}
@@ -0,0 +1,51 @@
+package gemu
+
+import (
+	"fmt"
+	"plugin"
+	"sync"
+)
+
+// PluginAPI is the version of the registration API plugins are built
+// against. A plugin may export
+//
+//	var PluginAPI = gemu.PluginAPI
+//
+// to be refused by a gemu whose API has since changed, rather than failing
+// in some less obvious way.
+const PluginAPI = 1
+
+// pluginMu keeps plugins from registering concurrently, as the registries
+// aren't locked.
+var pluginMu sync.Mutex
+
+// LoadPlugin opens a Go plugin (go build -buildmode=plugin) built against
+// the same version of this module. Plugins extend gemu the same way built
+// in components do, by calling RegisterMapper from their init. A panic
+// during registration, e.g. a mapper number that is already taken, is
+// returned as an error.
+func LoadPlugin(path string) (err error) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin %s: %v", path, r)
+		}
+	}()
+
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	if sym, err := p.Lookup("PluginAPI"); err == nil {
+		v, ok := sym.(*int)
+		if !ok {
+			return fmt.Errorf("plugin %s: PluginAPI is %T, not int", path, sym)
+		}
+		if *v != PluginAPI {
+			return fmt.Errorf("plugin %s: built for plugin API %d, this is %d", path, *v, PluginAPI)
+		}
+	}
+	return nil
+}
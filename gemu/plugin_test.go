@@ -0,0 +1,20 @@
+package gemu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPluginErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPlugin := filepath.Join(dir, "mapper.so")
+	if err := os.WriteFile(notPlugin, []byte("not a shared object"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join(dir, "missing.so"), notPlugin} {
+		if err := LoadPlugin(path); err == nil {
+			t.Errorf("loaded %s", path)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package gemu
+
+import "strings"
+
+// Region carries the timing of a console region. Every subsystem takes its
+// clock rates, frame layout and APU tables from here rather than assuming
+// NTSC.
+type Region struct {
+	Name string
+
+	// master clock in Hz, and the dividers of it that clock the cpu and ppu
+	MasterClock float64
+	CPUDivider  uint64
+	PPUDivider  uint64
+
+	// frame layout in ppu dots and scanlines
+	DotsPerScanline int
+	Scanlines       int
+	VBlankScanline  int
+	// whether the pre-render scanline skips a dot on odd frames
+	SkipOddDot bool
+
+	// apu tables, in cpu cycles
+	NoisePeriods      [16]uint16
+	DMCPeriods        [16]uint16
+	FrameCounterSteps [4]uint32
+}
+
+var NTSC = Region{
+	Name:            "NTSC",
+	MasterClock:     21477272,
+	CPUDivider:      12,
+	PPUDivider:      4,
+	DotsPerScanline: 341,
+	Scanlines:       262,
+	VBlankScanline:  241,
+	SkipOddDot:      true,
+	NoisePeriods:    ntscNoisePeriods,
+	DMCPeriods:      ntscDMCPeriods,
+	FrameCounterSteps: [4]uint32{
+		7457, 14913, 22371, 29829,
+	},
+}
+
+var PAL = Region{
+	Name:            "PAL",
+	MasterClock:     26601712,
+	CPUDivider:      16,
+	PPUDivider:      5,
+	DotsPerScanline: 341,
+	Scanlines:       312,
+	VBlankScanline:  241,
+	NoisePeriods: [16]uint16{
+		4, 8, 14, 30, 60, 88, 118, 148, 188, 236, 354, 472, 708, 944, 1890, 3778,
+	},
+	DMCPeriods: [16]uint16{
+		398, 354, 316, 298, 276, 236, 210, 198, 176, 148, 132, 118, 98, 78, 66, 50,
+	},
+	FrameCounterSteps: [4]uint32{
+		8313, 16627, 24939, 33253,
+	},
+}
+
+// Dendy famiclones run at the PAL frame rate but keep the NTSC cpu:ppu ratio
+// and apu timing.
+var Dendy = Region{
+	Name:              "Dendy",
+	MasterClock:       26601712,
+	CPUDivider:        15,
+	PPUDivider:        5,
+	DotsPerScanline:   341,
+	Scanlines:         312,
+	VBlankScanline:    291,
+	NoisePeriods:      ntscNoisePeriods,
+	DMCPeriods:        ntscDMCPeriods,
+	FrameCounterSteps: NTSC.FrameCounterSteps,
+}
+
+var ntscNoisePeriods = [16]uint16{
+	4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068,
+}
+
+var ntscDMCPeriods = [16]uint16{
+	428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 84, 72, 54,
+}
+
+// RegionByName looks up a region by name, ignoring case.
+func RegionByName(name string) (Region, bool) {
+	for _, r := range []Region{NTSC, PAL, Dendy} {
+		if strings.EqualFold(r.Name, name) {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// MasterPerFrame returns the number of master cycles in one frame.
+func (r Region) MasterPerFrame() uint64 {
+	return uint64(r.DotsPerScanline*r.Scanlines) * r.PPUDivider
+}
+
+// FrameRate returns the number of frames per second.
+func (r Region) FrameRate() float64 {
+	return r.MasterClock / float64(r.MasterPerFrame())
+}
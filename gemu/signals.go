@@ -0,0 +1,79 @@
+package gemu
+
+// Source identifies a component driving an interrupt line, so several
+// components can hold the same line without knowing about each other.
+type Source uint32
+
+const (
+	SourcePPU Source = 1 << iota
+	SourceAPUFrame
+	SourceAPUDMC
+	SourceMapper
+	SourceExternal
+)
+
+// Line is an interrupt line held asserted while any source asserts it.
+type Line struct {
+	sources Source
+	edge    bool
+}
+
+// Assert pulls the line on behalf of src.
+func (l *Line) Assert(src Source) {
+	if l.sources == 0 {
+		l.edge = true
+	}
+	l.sources |= src
+}
+
+// Release lets go of the line on behalf of src. If that leaves the line
+// released before its edge was taken, the edge is dropped too: the pulse
+// was too short for the cpu to see, which is how reading PPUSTATUS as
+// vblank starts suppresses the NMI.
+func (l *Line) Release(src Source) {
+	l.sources &^= src
+	if l.sources == 0 {
+		l.edge = false
+	}
+}
+
+// Asserted reports whether any source holds the line.
+func (l *Line) Asserted() bool {
+	return l.sources != 0
+}
+
+// AssertedBy reports whether src holds the line.
+func (l *Line) AssertedBy(src Source) bool {
+	return l.sources&src != 0
+}
+
+// TakeEdge reports whether the line has gone from released to asserted
+// since the last call, for edge triggered inputs like NMI.
+func (l *Line) TakeEdge() bool {
+	e := l.edge
+	l.edge = false
+	return e
+}
+
+// Signals is the set of lines between the components and the cpu. The PPU
+// asserts NMI, mappers and the APU assert IRQ, and DMA stalls the cpu, all
+// without holding a pointer to the cpu or to each other.
+type Signals struct {
+	NMI Line
+	IRQ Line
+
+	stall uint64
+}
+
+// StallCPU halts the cpu for the given number of cpu cycles once its
+// current instruction completes.
+func (s *Signals) StallCPU(cycles uint64) {
+	s.stall += cycles
+}
+
+// TakeStall returns the pending stall cycles and clears them.
+func (s *Signals) TakeStall() uint64 {
+	n := s.stall
+	s.stall = 0
+	return n
+}
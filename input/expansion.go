@@ -0,0 +1,93 @@
+package input
+
+import (
+	"maps"
+	"slices"
+)
+
+// ExpansionDevice is a peripheral in the Famicom expansion port. It sees
+// all three output lines written to $4016 and can drive data bits 1-4 of
+// reads of both $4016 and $4017, alongside the controller ports.
+type ExpansionDevice interface {
+	// Write gets the output lines, bits 0-2 of a $4016 write.
+	Write(out uint8)
+	// Read is a cpu read of $4016 (port 0) or $4017 (port 1). It returns
+	// the bits the device drives, of bits 1-4.
+	Read(port int) uint8
+}
+
+var expansions = map[string]func() ExpansionDevice{}
+
+// RegisterExpansion makes an expansion port device available by name to
+// NewExpansion, replacing any registered under the same name.
+func RegisterExpansion(name string, f func() ExpansionDevice) {
+	expansions[name] = f
+}
+
+// NewExpansion returns a new expansion port device of the kind registered
+// as name.
+func NewExpansion(name string) (ExpansionDevice, bool) {
+	f, ok := expansions[name]
+	if !ok {
+		return nil, false
+	}
+	return f(), true
+}
+
+// ExpansionNames returns the names of the registered expansion port
+// devices, sorted.
+func ExpansionNames() []string {
+	return slices.Sorted(maps.Keys(expansions))
+}
+
+func init() {
+	RegisterExpansion("none", func() ExpansionDevice { return NoExpansion{} })
+	RegisterExpansion("hypershot", func() ExpansionDevice { return &HyperShot{} })
+}
+
+// NoExpansion is an empty expansion port.
+type NoExpansion struct{}
+
+func (NoExpansion) Write(out uint8)     {}
+func (NoExpansion) Read(port int) uint8 { return 0 }
+
+// HyperShot buttons
+const (
+	HyperShot1Jump = 1 << iota
+	HyperShot1Run
+	HyperShot2Jump
+	HyperShot2Run
+)
+
+// HyperShot is Konami's pair of two-button controllers for Hyper Olympic
+// and Hyper Sports. There is no shift register: the buttons read directly
+// from $4017, player 1's Jump and Run on bits 1 and 2 and player 2's on
+// bits 3 and 4, each player only while enabled by a 0 in bit 1 (player 1)
+// or bit 2 (player 2) of the last $4016 write.
+type HyperShot struct {
+	buttons uint8
+	out     uint8
+}
+
+// SetButtons sets the buttons held, as HyperShot1Jump and the like.
+func (h *HyperShot) SetButtons(b uint32) {
+	h.buttons = uint8(b) & 0x0F
+}
+
+func (h *HyperShot) Write(out uint8) {
+	h.out = out
+}
+
+func (h *HyperShot) Read(port int) uint8 {
+	if port != 1 {
+		return 0
+	}
+	b := h.buttons
+	if h.out&0x02 != 0 {
+		b &^= HyperShot1Jump | HyperShot1Run
+	}
+	if h.out&0x04 != 0 {
+		b &^= HyperShot2Jump | HyperShot2Run
+	}
+	return b << 1
+}
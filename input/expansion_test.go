@@ -0,0 +1,38 @@
+package input
+
+import "testing"
+
+func TestHyperShot(t *testing.T) {
+	h := &HyperShot{}
+	h.SetButtons(HyperShot1Run | HyperShot2Jump)
+	for _, tc := range []struct {
+		out  uint8
+		want uint8
+	}{
+		// both players enabled: 1 Run on bit 2, 2 Jump on bit 3
+		{0x00, 0x0C},
+		// a 1 in bit 1 turns player 1 off, in bit 2 player 2
+		{0x02, 0x08},
+		{0x04, 0x04},
+		{0x06, 0x00},
+		// the strobe doesn't matter
+		{0x01, 0x0C},
+	} {
+		h.Write(tc.out)
+		if got := h.Read(1); got != tc.want {
+			t.Errorf("after writing $%02X, $4017 reads $%02X, want $%02X", tc.out, got, tc.want)
+		}
+		if got := h.Read(0); got != 0 {
+			t.Errorf("after writing $%02X, $4016 reads $%02X, want 0", tc.out, got)
+		}
+	}
+}
+
+func TestNewExpansion(t *testing.T) {
+	if _, ok := NewExpansion("hypershot"); !ok {
+		t.Error("no hypershot")
+	}
+	if _, ok := NewExpansion("zapper2"); ok {
+		t.Error("made an unregistered device")
+	}
+}
@@ -0,0 +1,123 @@
+// Package input emulates the devices plugged into the console's controller
+// ports. The cpu reaches them through two registers:
+//
+//	$4016 write  bit 0 is the strobe line (OUT0) to both ports
+//	$4016 read   port 1's data lines
+//	$4017 read   port 2's data lines
+//
+// A device drives data bits 0-4 of its port; the rest of the byte is open
+// bus. The Famicom's expansion port sees the other output bits of $4016
+// too and can drive data bits 1-4 of both reads; see ExpansionDevice.
+package input
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Device is a peripheral in a controller port.
+type Device interface {
+	// Strobe sets the strobe line written through $4016.
+	Strobe(on bool)
+	// Read is a cpu read of the port. It returns the data lines in bits
+	// 0-4, advancing any shift register.
+	Read() uint8
+}
+
+// Buttons is implemented by devices whose state is a set of buttons, one
+// bit each, so frontends can drive them without knowing the device.
+type Buttons interface {
+	SetButtons(b uint32)
+}
+
+var devices = map[string]func() Device{}
+
+// Register makes a device available by name to New, replacing any
+// registered under the same name.
+func Register(name string, f func() Device) {
+	devices[name] = f
+}
+
+// New returns a new device of the kind registered as name.
+func New(name string) (Device, bool) {
+	f, ok := devices[name]
+	if !ok {
+		return nil, false
+	}
+	return f(), true
+}
+
+// Names returns the names of the registered devices, sorted.
+func Names() []string {
+	return slices.Sorted(maps.Keys(devices))
+}
+
+func init() {
+	Register("none", func() Device { return Unplugged{} })
+	Register("joypad", func() Device { return &Joypad{} })
+}
+
+// Unplugged is an empty port. Its data lines read as 0.
+type Unplugged struct{}
+
+func (Unplugged) Strobe(on bool) {}
+func (Unplugged) Read() uint8    { return 0 }
+
+// Joypad buttons, in the order the pad shifts them out.
+const (
+	ButtonA = 1 << iota
+	ButtonB
+	ButtonSelect
+	ButtonStart
+	ButtonUp
+	ButtonDown
+	ButtonLeft
+	ButtonRight
+)
+
+var buttonNames = []string{"a", "b", "select", "start", "up", "down", "left", "right"}
+
+// ParseButtons parses joypad buttons joined with +, such as "a+right",
+// ignoring case.
+func ParseButtons(s string) (uint32, error) {
+	var b uint32
+	for _, name := range strings.Split(strings.ToLower(s), "+") {
+		i := slices.Index(buttonNames, strings.TrimSpace(name))
+		if i < 0 {
+			return 0, fmt.Errorf("unknown button %q", name)
+		}
+		b |= 1 << i
+	}
+	return b, nil
+}
+
+// Joypad is the standard controller. The strobe latches the eight buttons
+// into a shift register read out on bit 0, A first; once all eight are
+// out it reads 1.
+type Joypad struct {
+	buttons uint8
+	shift   uint8
+	strobe  bool
+}
+
+// SetButtons sets the buttons held, using the low eight bits.
+func (p *Joypad) SetButtons(b uint32) {
+	p.buttons = uint8(b)
+}
+
+func (p *Joypad) Strobe(on bool) {
+	p.strobe = on
+	p.shift = p.buttons
+}
+
+func (p *Joypad) Read() uint8 {
+	if p.strobe {
+		// the register keeps reloading, so A is all that can be read
+		return p.buttons & 1
+	}
+	v := p.shift & 1
+	p.shift = p.shift>>1 | 0x80
+	return v
+}
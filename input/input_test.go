@@ -0,0 +1,61 @@
+package input
+
+import (
+	"slices"
+	"testing"
+)
+
+// readBits strobes d and reads it n times, returning the given bit of each
+// read.
+func readBits(d Device, n int, bit uint) []uint8 {
+	d.Strobe(true)
+	d.Strobe(false)
+	var bits []uint8
+	for range n {
+		bits = append(bits, d.Read()>>bit&1)
+	}
+	return bits
+}
+
+func TestJoypadShift(t *testing.T) {
+	p := &Joypad{}
+	p.SetButtons(ButtonA | ButtonStart | ButtonRight)
+	got := readBits(p, 10, 0)
+	// A, B, Select, Start, Up, Down, Left, Right, then 1s
+	want := []uint8{1, 0, 0, 1, 0, 0, 0, 1, 1, 1}
+	if !slices.Equal(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+
+	// strobing again starts over
+	if got := readBits(p, 1, 0); got[0] != 1 {
+		t.Error("A not read first after a second strobe")
+	}
+}
+
+func TestJoypadStrobeHeld(t *testing.T) {
+	p := &Joypad{}
+	p.SetButtons(ButtonA)
+	p.Strobe(true)
+	for i := range 10 {
+		if v := p.Read(); v != 1 {
+			t.Fatalf("read %d with the strobe held is %d, want A", i, v)
+		}
+	}
+	// the buttons are latched while the strobe is held
+	p.SetButtons(ButtonB)
+	p.Strobe(false)
+	if got := []uint8{p.Read(), p.Read()}; got[0] != 0 || got[1] != 1 {
+		t.Errorf("read %v after changing the buttons under the strobe, want B", got)
+	}
+}
+
+func TestParseButtons(t *testing.T) {
+	b, err := ParseButtons("A+Right + select")
+	if err != nil || b != ButtonA|ButtonRight|ButtonSelect {
+		t.Errorf("parsed %08b, %v", b, err)
+	}
+	if _, err := ParseButtons("a+turbo"); err == nil {
+		t.Error("parsed an unknown button")
+	}
+}
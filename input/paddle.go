@@ -0,0 +1,69 @@
+package input
+
+// Vaus range of the potentiometer over the knob's travel, from the left
+// stop to the right. Arkanoid expects values in about this range.
+const (
+	VausMin = 98
+	VausMax = 242
+)
+
+func init() {
+	Register("vaus", func() Device { return NewVaus() })
+}
+
+// Analog is implemented by devices with a knob or axis, so frontends can
+// drive them from a mouse or analog stick.
+type Analog interface {
+	// SetPosition sets the axis from 0 (left) to 1 (right).
+	SetPosition(x float64)
+}
+
+// Vaus is the Arkanoid paddle for the NES, in port 2. The strobe latches
+// the knob's 8-bit potentiometer reading, which then shifts out inverted
+// on bit 4, most significant bit first. Bit 3 is the fire button.
+type Vaus struct {
+	value  uint8
+	fire   bool
+	shift  uint8
+	strobe bool
+}
+
+// NewVaus returns a paddle with the knob centred.
+func NewVaus() *Vaus {
+	v := &Vaus{}
+	v.SetPosition(0.5)
+	return v
+}
+
+// SetValue sets the raw potentiometer reading.
+func (v *Vaus) SetValue(value uint8) {
+	v.value = value
+}
+
+func (v *Vaus) SetPosition(x float64) {
+	x = min(max(x, 0), 1)
+	v.value = uint8(VausMin + x*(VausMax-VausMin) + 0.5)
+}
+
+// SetButtons sets the fire button from bit 0.
+func (v *Vaus) SetButtons(b uint32) {
+	v.fire = b&1 != 0
+}
+
+func (v *Vaus) Strobe(on bool) {
+	v.strobe = on
+	v.shift = ^v.value
+}
+
+func (v *Vaus) Read() uint8 {
+	var r uint8
+	if v.fire {
+		r |= 0x08
+	}
+	if v.strobe {
+		return r | ^v.value>>7<<4
+	}
+	r |= v.shift >> 7 << 4
+	v.shift <<= 1
+	return r
+}
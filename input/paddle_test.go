@@ -0,0 +1,52 @@
+package input
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVausShift(t *testing.T) {
+	v := NewVaus()
+	v.SetValue(0xA5)
+	// inverted, most significant bit first, then 0s
+	got := readBits(v, 10, 4)
+	want := []uint8{0, 1, 0, 1, 1, 0, 1, 0, 0, 0}
+	if !slices.Equal(got, want) {
+		t.Errorf("knob read %v, want %v", got, want)
+	}
+	if fire := readBits(v, 1, 3); fire[0] != 0 {
+		t.Error("fire reads pressed")
+	}
+
+	v.SetButtons(1)
+	for i, b := range readBits(v, 10, 3) {
+		if b != 1 {
+			t.Errorf("fire read %d is released", i)
+		}
+	}
+	// only bits 3 and 4 are driven
+	v.Strobe(true)
+	if r := v.Read(); r&^0x18 != 0 {
+		t.Errorf("read $%02X drives bits other than 3 and 4", r)
+	}
+}
+
+func TestVausPosition(t *testing.T) {
+	v := NewVaus()
+	for _, tc := range []struct {
+		x    float64
+		want uint8
+	}{
+		{0, VausMin},
+		{1, VausMax},
+		{0.5, (VausMin + VausMax) / 2},
+		// the knob stops at the ends
+		{-1, VausMin},
+		{2, VausMax},
+	} {
+		v.SetPosition(tc.x)
+		if v.value != tc.want {
+			t.Errorf("position %v reads %d, want %d", tc.x, v.value, tc.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package input
+
+func init() {
+	Register("powerpad", func() Device { return &PowerPad{} })
+}
+
+// PowerPad is the Power Pad (Family Trainer) floor mat, with its twelve
+// buttons numbered as on side B:
+//
+//	1  2  3  4
+//	5  6  7  8
+//	9 10 11 12
+//
+// The strobe latches the buttons into two shift registers read out
+// together, a pressed button reading 1: bit 3 gives buttons 2, 1, 5, 9, 6,
+// 10, 11 and 7, bit 4 gives 4, 3, 12 and 8. Both read 1 once empty.
+type PowerPad struct {
+	buttons uint32
+	// d3 and d4 shift out from bit 0
+	d3, d4 uint8
+	strobe bool
+}
+
+// the buttons each register shifts out, in order
+var (
+	powerPadD3 = [8]int{2, 1, 5, 9, 6, 10, 11, 7}
+	powerPadD4 = [4]int{4, 3, 12, 8}
+)
+
+// SetButtons sets the buttons pressed, button n in bit n-1.
+func (p *PowerPad) SetButtons(b uint32) {
+	p.buttons = b
+}
+
+func (p *PowerPad) Strobe(on bool) {
+	p.strobe = on
+	p.latch()
+}
+
+func (p *PowerPad) latch() {
+	pressed := func(n int) uint8 {
+		return uint8(p.buttons >> (n - 1) & 1)
+	}
+	p.d3, p.d4 = 0, 0xF0
+	for i, n := range powerPadD3 {
+		p.d3 |= pressed(n) << i
+	}
+	for i, n := range powerPadD4 {
+		p.d4 |= pressed(n) << i
+	}
+}
+
+func (p *PowerPad) Read() uint8 {
+	if p.strobe {
+		p.latch()
+	}
+	r := (p.d3&1)<<3 | (p.d4&1)<<4
+	if !p.strobe {
+		p.d3 = p.d3>>1 | 0x80
+		p.d4 = p.d4>>1 | 0x80
+	}
+	return r
+}
@@ -0,0 +1,43 @@
+package input
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPowerPadShift(t *testing.T) {
+	p := &PowerPad{}
+	// buttons 2, 6 and 12
+	p.SetButtons(1<<1 | 1<<5 | 1<<11)
+	p.Strobe(true)
+	p.Strobe(false)
+	var d3, d4 []uint8
+	for range 10 {
+		r := p.Read()
+		if r&^0x18 != 0 {
+			t.Fatalf("read $%02X drives bits other than 3 and 4", r)
+		}
+		d3 = append(d3, r>>3&1)
+		d4 = append(d4, r>>4&1)
+	}
+	// bit 3 gives 2, 1, 5, 9, 6, 10, 11, 7 and bit 4 gives 4, 3, 12, 8,
+	// then both read 1
+	if want := []uint8{1, 0, 0, 0, 1, 0, 0, 0, 1, 1}; !slices.Equal(d3, want) {
+		t.Errorf("bit 3 read %v, want %v", d3, want)
+	}
+	if want := []uint8{0, 0, 1, 0, 1, 1, 1, 1, 1, 1}; !slices.Equal(d4, want) {
+		t.Errorf("bit 4 read %v, want %v", d4, want)
+	}
+}
+
+func TestPowerPadStrobeHeld(t *testing.T) {
+	p := &PowerPad{}
+	p.Strobe(true)
+	// pressed under the strobe, the first buttons of each register show
+	p.SetButtons(1<<1 | 1<<3)
+	for range 3 {
+		if r := p.Read(); r != 0x18 {
+			t.Fatalf("read $%02X with the strobe held, want buttons 2 and 4", r)
+		}
+	}
+}
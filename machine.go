@@ -0,0 +1,134 @@
+package gemu
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/goldmane/gemu/gemu"
+)
+
+// ROM is a parsed ROM image that any number of Machines can run at once.
+// Each machine gets a mapper of its own, but the PRG and CHR ROM are
+// shared rather than copied.
+type ROM struct {
+	cart    gemu.Cartridge
+	factory gemu.MapperFactory
+}
+
+// ParseROM parses an iNES or NES 2.0 image.
+func ParseROM(data []byte) (*ROM, error) {
+	r := &ROM{}
+	r.cart.Logger = quietLogger
+	if err := r.cart.ParseImage(data); err != nil {
+		return nil, err
+	}
+	f, ok := gemu.LookupMapper(r.cart.MapperID())
+	if !ok {
+		return nil, fmt.Errorf("%w %d", gemu.ErrUnsupportedMapper, r.cart.MapperID())
+	}
+	r.factory = f
+	return r, nil
+}
+
+// ReadROM reads and parses the ROM image at path.
+func ReadROM(path string) (*ROM, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseROM(data)
+}
+
+// cartridge returns the ROM as a cartridge with a new mapper.
+func (r *ROM) cartridge() (gemu.Cartridge, error) {
+	c := r.cart
+	m, err := r.factory(&c)
+	if err != nil {
+		return gemu.Cartridge{}, err
+	}
+	c.Mapper = m
+	return c, nil
+}
+
+// quietLogger is the logger of machines, which run in numbers too large for
+// their load messages to be useful.
+var quietLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// Machine is a console running a ROM with no frontend attached, for batch
+// testing and training workloads that run many at once: nothing is logged,
+// traced or drawn over the frame. Its state is all its own, so machines
+// can run concurrently on separate goroutines, though a single machine
+// must only be used by one at a time.
+type Machine struct {
+	emu *Emulator
+	rom *ROM
+}
+
+// NewMachine powers on a console with rom in it.
+func NewMachine(rom *ROM) (*Machine, error) {
+	m := &Machine{emu: NewEmulator(), rom: rom}
+	m.emu.SetLogger(quietLogger)
+	if err := m.PowerCycle(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PowerCycle switches the console off and on, as Emulator.PowerCycle does,
+// with a fresh mapper for the ROM.
+func (m *Machine) PowerCycle() error {
+	c, err := m.rom.cartridge()
+	if err != nil {
+		return err
+	}
+	m.emu.insert(c, "")
+	m.emu.powerOn()
+	return nil
+}
+
+// Reset presses the reset button.
+func (m *Machine) Reset() {
+	m.emu.SoftReset()
+}
+
+// RunFrame runs the console for one frame.
+func (m *Machine) RunFrame() error {
+	return m.emu.RunFrame()
+}
+
+// Step runs the console until the cpu has executed one instruction.
+func (m *Machine) Step() error {
+	return m.emu.Step()
+}
+
+// SetInput sets the buttons held on a controller port's device, as
+// Emulator.SetInput does.
+func (m *Machine) SetInput(port int, buttons uint32) {
+	m.emu.SetInput(port, buttons)
+}
+
+// Frame returns the number of frames the machine has run.
+func (m *Machine) Frame() uint64 {
+	return m.emu.Frame()
+}
+
+// Framebuffer returns the current frame. It is overwritten by the next
+// RunFrame.
+func (m *Machine) Framebuffer() *image.RGBA {
+	return m.emu.Framebuffer()
+}
+
+// RAM returns the console's 2kb of internal RAM. The slice is the
+// machine's own memory, so it reads the current values without copying.
+func (m *Machine) RAM() []byte {
+	return m.emu.cpu.GetMemory()[:0x0800]
+}
+
+// Emulator returns the emulator inside the machine, for everything else,
+// e.g. debugging a single machine that went wrong.
+func (m *Machine) Emulator() *Emulator {
+	return m.emu
+}
@@ -0,0 +1,58 @@
+package gemu
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentMachines runs many machines off one ROM on their own
+// goroutines; run it with -race. They share the PRG and CHR, so they
+// must all end up where a machine run alone does.
+func TestConcurrentMachines(t *testing.T) {
+	const frames = 10
+	machines := 200
+	if testing.Short() {
+		machines = 20
+	}
+	rom, err := ReadROM("nestest.nes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func() (*Machine, error) {
+		m, err := NewMachine(rom)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < frames; i++ {
+			if err := m.RunFrame(); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	want, err := run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]*Machine, machines)
+	errs := make([]error, machines)
+	var wg sync.WaitGroup
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i], errs[i] = run()
+		}()
+	}
+	wg.Wait()
+	for i, m := range got {
+		if errs[i] != nil {
+			t.Fatalf("machine %d: %v", i, errs[i])
+		}
+		if !bytes.Equal(m.RAM(), want.RAM()) || !bytes.Equal(m.Framebuffer().Pix, want.Framebuffer().Pix) {
+			t.Fatalf("machine %d ended with different RAM or picture than one run alone", i)
+		}
+	}
+}
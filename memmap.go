@@ -0,0 +1,39 @@
+package gemu
+
+// The cpu's address space, as mapSystem and mapCartridge lay it out:
+//
+//	$0000-$07FF  2kb internal RAM, mirrored through $1FFF
+//	$2000-$2007  PPU registers, mirrored through $3FFF
+//	$4000-$401F  APU and I/O registers
+//	$4020-$5FFF  cartridge expansion area, answered by the mapper
+//	$6000-$7FFF  cartridge work RAM, if the mapper has any
+//	$8000-$FFFF  cartridge PRG ROM, with the mapper's registers behind it
+
+// ramSize is the size of the internal RAM.
+const ramSize = 0x0800
+
+// ppuPage passes the cpu's accesses to $2000-$3FFF on to the PPU's
+// registers, once it has caught up with the cpu.
+type ppuPage struct {
+	e *Emulator
+}
+
+func (p *ppuPage) Read(addr uint16) uint8 {
+	p.e.clock.Sync()
+	return p.e.ppu.ReadRegister(addr)
+}
+
+func (p *ppuPage) Write(addr uint16, v uint8) {
+	p.e.clock.Sync()
+	p.e.ppu.WriteRegister(addr, v)
+}
+
+// mapSystem lays the console's own memory and registers over the cpu's
+// address space after a reset has mapped it flat. The cartridge is mapped
+// over the rest by mapCartridge once one is inserted.
+func (e *Emulator) mapSystem() {
+	pages := e.cpu.Pages()
+	pages.MapMemory(0x00, 0x20, e.cpu.GetMemory()[:ramSize], true)
+	pages.MapHandler(0x20, 0x20, &ppuPage{e: e})
+	e.mapIO()
+}
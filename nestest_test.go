@@ -0,0 +1,40 @@
+package gemu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+// TestNestest runs nestest from $C000, its automated mode, against
+// reference.txt, the trace of a known good emulator. Every line must match
+// up to the first unofficial opcode, NOP zp ($04) at $C6BD, which gemu
+// doesn't implement.
+func TestNestest(t *testing.T) {
+	ref, err := os.Open("reference.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ref.Close()
+
+	e := newTestEmulator()
+	if err := e.LoadROM("nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	e.cpu.SetPC(0xC000)
+	e.SetReference(ref)
+	err = e.Run(context.Background())
+
+	var mismatch *MismatchError
+	if errors.As(err, &mismatch) {
+		t.Fatalf("line %d: %s\n got: %s\nwant: %s", mismatch.Line, mismatch.Divergence(), mismatch.Got, mismatch.Want)
+	}
+	var unknown *UnknownOpcodeError
+	if !errors.As(err, &unknown) || unknown.Opcode != 0x04 || unknown.PC != 0xC6BD {
+		t.Fatalf("run ended with %v, want unknown opcode 04 at $C6BD", err)
+	}
+	if got := e.Counter(); got != 5004 {
+		t.Errorf("stopped on line %d, want 5004", got)
+	}
+}
@@ -0,0 +1,64 @@
+package gemu
+
+import "fmt"
+
+const (
+	// osdSeconds is how long a message stays up
+	osdSeconds = 2
+	// osdMaxMessages is how many messages show at once; older ones go first
+	osdMaxMessages = 4
+)
+
+// osd holds the transient messages drawn at the bottom left of the frame.
+type osd struct {
+	messages []osdMessage
+}
+
+type osdMessage struct {
+	text string
+	// frame the message comes down on
+	until uint64
+}
+
+// ShowMessage puts a message such as "State 3 saved" on screen for a
+// couple of seconds of emulated time, under any already showing. Any part
+// of the emulator or a frontend can use it; the text is drawn in the
+// overlay's font, which has no lower case.
+func (e *Emulator) ShowMessage(format string, args ...any) {
+	m := osdMessage{
+		text:  fmt.Sprintf(format, args...),
+		until: e.Frame() + uint64(e.region.FrameRate()*osdSeconds),
+	}
+	e.osd.messages = append(e.osd.messages, m)
+	if len(e.osd.messages) > osdMaxMessages {
+		e.osd.messages = e.osd.messages[len(e.osd.messages)-osdMaxMessages:]
+	}
+}
+
+// Messages returns the messages currently on screen, oldest first.
+func (e *Emulator) Messages() []string {
+	var s []string
+	for _, m := range e.osd.messages {
+		s = append(s, m.text)
+	}
+	return s
+}
+
+// drawMessages expires old messages and draws the rest over the frame.
+func (e *Emulator) drawMessages() {
+	o := &e.osd
+	frame := e.Frame()
+	live := o.messages[:0]
+	for _, m := range o.messages {
+		if frame < m.until {
+			live = append(live, m)
+		}
+	}
+	o.messages = live
+	if len(o.messages) == 0 {
+		return
+	}
+	lines := e.Messages()
+	y := ScreenHeight - 2 - len(lines)*6 - 1
+	drawText(e.frame, 2, y, 0, lines)
+}
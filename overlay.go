@@ -0,0 +1,135 @@
+package gemu
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+)
+
+// overlay draws emulator statistics over the frame, so every frontend
+// showing Framebuffer gets them without drawing text itself.
+type overlay struct {
+	enabled bool
+	// frames drawn since start, for measuring the frame rate
+	start  time.Time
+	frames int
+	// frames per second of wall time, over the last half second or so
+	fps float64
+	// extra lines drawn under the statistics, e.g. watch values
+	lines  []string
+	source func() []string
+}
+
+// SetOverlay turns the debug overlay on or off. It shows the frame rate,
+// emulation speed, frame counter and cpu and ppu cycle counters in the top
+// left corner of the frame.
+func (e *Emulator) SetOverlay(on bool) {
+	e.overlay.enabled = on
+	e.overlay.start = time.Time{}
+}
+
+// SetOverlayLines sets extra lines shown under the overlay statistics,
+// e.g. watched memory values.
+func (e *Emulator) SetOverlayLines(lines ...string) {
+	e.overlay.lines = append(e.overlay.lines[:0], lines...)
+}
+
+// SetOverlaySource has f called every frame the overlay is drawn, and the
+// lines it returns shown after those set with SetOverlayLines. A nil f
+// stops it.
+func (e *Emulator) SetOverlaySource(f func() []string) {
+	e.overlay.source = f
+}
+
+// drawOverlay updates the timing and draws the overlay over the frame.
+func (e *Emulator) drawOverlay() {
+	o := &e.overlay
+	now := time.Now()
+	if o.start.IsZero() {
+		o.start, o.frames = now, 0
+	}
+	o.frames++
+	if dt := now.Sub(o.start).Seconds(); dt >= 0.5 {
+		o.fps = float64(o.frames) / dt
+		o.start, o.frames = now, 0
+	}
+
+	speed := o.fps / e.region.FrameRate() * 100
+	text := []string{
+		fmt.Sprintf("FPS %.1f", o.fps),
+		fmt.Sprintf("SPD %.0f%%", speed),
+		fmt.Sprintf("FRM %d", e.Frame()),
+		fmt.Sprintf("CPU %d", e.cpu.TotalCycles),
+		fmt.Sprintf("PPU %d", e.clock.Cycle()/e.region.PPUDivider),
+	}
+	text = append(text, o.lines...)
+	if o.source != nil {
+		text = append(text, o.source()...)
+	}
+	// at least as wide as the widest usual line, so the box doesn't change
+	// size as the numbers do
+	drawText(e.frame, 2, 2, 20, text)
+}
+
+var (
+	overlayBack = image.NewUniform(color.RGBA{0, 0, 0, 0xFF})
+	overlayFore = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+// drawText draws lines of text in the 3x5 font on a black box at least
+// minWidth characters wide.
+func drawText(dst *image.RGBA, x, y, minWidth int, lines []string) {
+	width := minWidth
+	for _, l := range lines {
+		width = max(width, len(l))
+	}
+	box := image.Rect(x, y, x+width*4+1, y+len(lines)*6+1)
+	draw.Draw(dst, box, overlayBack, image.Point{}, draw.Src)
+
+	for row, l := range lines {
+		for col, r := range l {
+			glyph := font3x5[toUpper(r)]
+			for gy := 0; gy < 5; gy++ {
+				bits := glyph[gy]
+				for gx := 0; gx < 3; gx++ {
+					if bits&(4>>gx) != 0 {
+						dst.SetRGBA(x+1+col*4+gx, y+1+row*6+gy, overlayFore)
+					}
+				}
+			}
+		}
+	}
+}
+
+func toUpper(r rune) rune {
+	if r >= 'a' && r <= 'z' {
+		return r - 'a' + 'A'
+	}
+	return r
+}
+
+// font3x5 holds a 3x5 pixel glyph per character, one row per byte with the
+// leftmost pixel in bit 2. Characters without a glyph are blank.
+var font3x5 = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7}, '1': {2, 6, 2, 2, 7}, '2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 3, 1, 7}, '4': {5, 5, 7, 1, 1}, '5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7}, '7': {7, 1, 1, 2, 2}, '8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7},
+	'A': {2, 5, 7, 5, 5}, 'B': {6, 5, 6, 5, 6}, 'C': {3, 4, 4, 4, 3},
+	'D': {6, 5, 5, 5, 6}, 'E': {7, 4, 6, 4, 7}, 'F': {7, 4, 6, 4, 4},
+	'G': {3, 4, 5, 5, 3}, 'H': {5, 5, 7, 5, 5}, 'I': {7, 2, 2, 2, 7},
+	'J': {1, 1, 1, 5, 2}, 'K': {5, 5, 6, 5, 5}, 'L': {4, 4, 4, 4, 7},
+	'M': {5, 7, 7, 5, 5}, 'N': {6, 5, 5, 5, 5}, 'O': {2, 5, 5, 5, 2},
+	'P': {6, 5, 6, 4, 4}, 'Q': {2, 5, 5, 6, 3}, 'R': {6, 5, 6, 5, 5},
+	'S': {3, 4, 2, 1, 6}, 'T': {7, 2, 2, 2, 2}, 'U': {5, 5, 5, 5, 7},
+	'V': {5, 5, 5, 5, 2}, 'W': {5, 5, 7, 7, 5}, 'X': {5, 5, 2, 5, 5},
+	'Y': {5, 5, 2, 2, 2}, 'Z': {7, 1, 2, 4, 7},
+	'%': {5, 1, 2, 4, 5}, '.': {0, 0, 0, 0, 2}, ':': {0, 2, 0, 2, 0},
+	'-': {0, 0, 7, 0, 0}, '$': {3, 6, 2, 3, 6}, '=': {0, 7, 0, 7, 0},
+	'!': {2, 2, 2, 0, 2}, '?': {6, 1, 2, 0, 2}, ',': {0, 0, 0, 2, 4},
+	'/': {1, 1, 2, 4, 4}, '(': {1, 2, 2, 2, 1}, ')': {4, 2, 2, 2, 4},
+	'+': {0, 2, 7, 2, 0}, '#': {5, 7, 5, 7, 5}, '\'': {2, 2, 0, 0, 0},
+	'_': {0, 0, 0, 0, 7},
+}
@@ -0,0 +1,32 @@
+package gemu
+
+import (
+	"image"
+	"sync"
+)
+
+// frames recycles framebuffer copies handed out by CopyFrame, so a
+// frontend presenting every frame doesn't allocate one each time.
+var frames = sync.Pool{
+	New: func() any {
+		return image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+	},
+}
+
+// CopyFrame returns a copy of the current frame that the caller owns until
+// it hands it back with ReleaseFrame. Use it when a frame has to outlive the
+// next RunFrame, e.g. to present or encode it on another goroutine.
+func (e *Emulator) CopyFrame() *image.RGBA {
+	f := frames.Get().(*image.RGBA)
+	copy(f.Pix, e.frame.Pix)
+	return f
+}
+
+// ReleaseFrame returns a frame from CopyFrame to the pool. The frame must
+// not be used afterwards.
+func (e *Emulator) ReleaseFrame(f *image.RGBA) {
+	if f == nil || len(f.Pix) != len(e.frame.Pix) {
+		return
+	}
+	frames.Put(f)
+}
@@ -0,0 +1,114 @@
+package gemu
+
+import "github.com/goldmane/gemu/input"
+
+// ioPage handles cpu page $40, where the controller ports and the OAM DMA
+// register are. The rest of $4000-$401F is left as memory until the APU
+// registers are there, and $4020 on belongs to the cartridge.
+type ioPage struct {
+	e   *Emulator
+	mem []byte
+}
+
+// ExpansionPort is the port number SetInput and SetPosition take for the
+// expansion port device.
+const ExpansionPort = 2
+
+// openBus is what the bits a port doesn't drive read as: the high byte of
+// the address, the last value on the bus.
+const openBus = 0x40
+
+func (p *ioPage) Read(addr uint16) uint8 {
+	if addr >= 0x4020 && p.e.cartPage != nil {
+		return p.e.cartPage.Read(addr)
+	}
+	if addr == 0x4016 || addr == 0x4017 {
+		port := int(addr - 0x4016)
+		return openBus | p.e.ports[port].Read()&0x1F | p.e.expansion.Read(port)&0x1E
+	}
+	return p.mem[addr]
+}
+
+func (p *ioPage) Write(addr uint16, v uint8) {
+	if addr >= 0x4020 && p.e.cartPage != nil {
+		p.e.cartPage.Write(addr, v)
+		return
+	}
+	if addr == 0x4014 {
+		p.e.oamDMA(v)
+	}
+	if addr == 0x4016 {
+		p.e.ports[0].Strobe(v&1 != 0)
+		p.e.ports[1].Strobe(v&1 != 0)
+		p.e.expansion.Write(v & 0x07)
+	}
+	p.mem[addr] = v
+}
+
+// oamDMA copies the 256 bytes of cpu page page to OAM through OAMDATA, as a
+// write to $4014 does. The cpu is halted for the 513 cycles it takes, 514
+// if it starts on an odd cycle.
+func (e *Emulator) oamDMA(page uint8) {
+	e.clock.Sync()
+	bus := e.cpu.Bus()
+	for i := 0; i < 256; i++ {
+		e.ppu.WriteRegister(0x2004, bus.Read(uint16(page)<<8|uint16(i)))
+	}
+	e.signals.StallCPU(513 + e.cpu.TotalCycles&1)
+}
+
+// mapIO puts the controller ports into the cpu's address space.
+func (e *Emulator) mapIO() {
+	e.cpu.Pages().MapHandler(0x40, 1, &ioPage{e: e, mem: e.cpu.GetMemory()})
+}
+
+// SetPort plugs d into a controller port (0 or 1), replacing what was
+// there. A nil d unplugs the port.
+func (e *Emulator) SetPort(port int, d input.Device) {
+	if port < 0 || port >= len(e.ports) {
+		return
+	}
+	if d == nil {
+		d = input.Unplugged{}
+	}
+	e.ports[port] = d
+}
+
+// Port returns the device in a controller port (0 or 1).
+func (e *Emulator) Port(port int) input.Device {
+	if port < 0 || port >= len(e.ports) {
+		return nil
+	}
+	return e.ports[port]
+}
+
+// SetExpansion plugs d into the Famicom expansion port, replacing what was
+// there. A nil d unplugs it.
+func (e *Emulator) SetExpansion(d input.ExpansionDevice) {
+	if d == nil {
+		d = input.NoExpansion{}
+	}
+	e.expansion = d
+}
+
+// Expansion returns the device in the expansion port.
+func (e *Emulator) Expansion() input.ExpansionDevice {
+	return e.expansion
+}
+
+// inputDevice returns the device SetInput and SetPosition address as port:
+// 0 and 1 are the controller ports and 2 the expansion port.
+func (e *Emulator) inputDevice(port int) any {
+	if port == ExpansionPort {
+		return e.expansion
+	}
+	return e.Port(port)
+}
+
+// SetPosition sets the knob or axis of the device in a controller port, if
+// it has one, from 0 (left) to 1 (right).
+func (e *Emulator) SetPosition(port int, x float64) {
+	if d, ok := e.inputDevice(port).(input.Analog); ok {
+		d.SetPosition(x)
+	}
+}
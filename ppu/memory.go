@@ -0,0 +1,80 @@
+package ppu
+
+import "github.com/goldmane/gemu/gemu"
+
+// The PPU's 16kb address space:
+//
+//	$0000-$1FFF  pattern tables, on the cartridge
+//	$2000-$2FFF  four nametables, in 2kb of RAM as the mirroring arranges
+//	$3000-$3EFF  a mirror of $2000-$2EFF
+//	$3F00-$3F1F  palette, mirrored through $3FFF
+
+func (p *PPU) read(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		if p.cart == nil {
+			return 0
+		}
+		return p.cart.PPURead(addr)
+	case addr < 0x3F00:
+		return p.vram[p.nametableIndex(addr)]
+	}
+	return p.palette[paletteIndex(addr)]
+}
+
+// PeekMemory returns the byte at addr in the PPU's address space, as
+// PPUDATA would see it once buffered, without moving the VRAM address.
+func (p *PPU) PeekMemory(addr uint16) uint8 {
+	return p.read(addr & 0x3FFF)
+}
+
+func (p *PPU) write(addr uint16, v uint8) {
+	switch {
+	case addr < 0x2000:
+		if p.cart != nil {
+			p.cart.PPUWrite(addr, v)
+		}
+	case addr < 0x3F00:
+		p.vram[p.nametableIndex(addr)] = v
+	default:
+		p.palette[paletteIndex(addr)] = v & 0x3F
+	}
+}
+
+// Mirroring returns the nametable mirroring in effect: the mapper's if it
+// switches it, or else the cartridge's wiring.
+func (p *PPU) Mirroring() gemu.Mirroring {
+	if m, ok := p.cart.(interface{ Mirroring() gemu.Mirroring }); ok {
+		return m.Mirroring()
+	}
+	return p.mirroring
+}
+
+// nametableIndex returns where in vram the nametable byte at addr is.
+func (p *PPU) nametableIndex(addr uint16) uint16 {
+	table := addr >> 10 & 3
+	offset := addr & 0x3FF
+	switch p.Mirroring() {
+	case gemu.Horizontal:
+		// $2000 and $2400 share the first table, $2800 and $2C00 the second
+		table >>= 1
+	case gemu.Vertical:
+		table &= 1
+	case gemu.SingleScreenLower:
+		table = 0
+	case gemu.SingleScreenUpper:
+		table = 1
+	}
+	return table<<10 | offset
+}
+
+// paletteIndex returns the palette entry at addr. The backdrop entries of
+// the sprite palettes, $3F10, $3F14, $3F18 and $3F1C, are those of the
+// background palettes.
+func paletteIndex(addr uint16) uint16 {
+	i := addr & 0x1F
+	if i&0x13 == 0x10 {
+		i &^= 0x10
+	}
+	return i
+}
@@ -0,0 +1,243 @@
+// Package ppu emulates the 2C02 picture processing unit: its registers as
+// the cpu sees them at $2000-$2007, its own address space of pattern
+// tables, nametables and palette, and the timing of scanlines and vblank.
+package ppu
+
+import (
+	"image"
+
+	"github.com/goldmane/gemu/gemu"
+)
+
+// Cartridge is the part of the cartridge on the PPU's bus: the pattern
+// tables at $0000-$1FFF. gemu.Mapper satisfies it.
+type Cartridge interface {
+	PPURead(addr uint16) uint8
+	PPUWrite(addr uint16, v uint8)
+}
+
+// PPUSTATUS bits
+const (
+	statusOverflow = 0x20
+	statusSprite0  = 0x40
+	statusVBlank   = 0x80
+)
+
+// PPUCTRL bits
+const (
+	ctrlIncrement32 = 0x04
+	ctrlNMI         = 0x80
+)
+
+type PPU struct {
+	region gemu.Region
+	cart   Cartridge
+	// lines the PPU drives, NMI among them, or nil
+	signals *gemu.Signals
+	// mirroring wired on the cartridge, used unless the mapper switches it
+	mirroring gemu.Mirroring
+
+	ctrl    uint8
+	mask    uint8
+	status  uint8
+	oamAddr uint8
+	oam     [256]uint8
+	// the sprites on the scanline being drawn, and the dot sprite 0 hits
+	// the background at, or 0
+	secondary [32]uint8
+	sprites   [spritesLimit]sprite
+	nsprites  int
+	hitDot    int
+
+	// loopy's scroll registers, see scroll.go; w is false for the first
+	// write of a pair
+	v, t uint16
+	x    uint8
+	w    bool
+	// PPUDATA reads return what the previous read fetched
+	readBuffer uint8
+	// the last value written to a register, which the bits a read doesn't
+	// drive come back as, and the frame each bit was last driven on
+	latch      uint8
+	latchFrame [8]uint64
+
+	// nametable RAM: 2kb on the console, 4kb with four-screen
+	vram    [0x1000]uint8
+	palette [32]uint8
+
+	// position of the next dot to run, and frames completed
+	scanline int
+	dot      int
+	frames   uint64
+	// set when PPUSTATUS was read just before vblank, which then doesn't
+	// start this frame
+	suppress bool
+
+	// the picture, drawn a scanline at a time unless skip is set, and the
+	// palette entries of the scanline being drawn
+	frame *image.RGBA
+	skip  bool
+	line  [Width]uint8
+}
+
+// New returns a PPU timed for region, with nothing on its bus until
+// SetCartridge.
+func New(region gemu.Region) *PPU {
+	p := &PPU{frame: image.NewRGBA(image.Rect(0, 0, Width, Height))}
+	p.SetRegion(region)
+	return p
+}
+
+// SetRegion switches the PPU's frame layout to region's, starting again at
+// the first dot of a frame.
+func (p *PPU) SetRegion(region gemu.Region) {
+	p.region = region
+	p.scanline, p.dot = 0, 0
+}
+
+// SetCartridge puts c on the PPU's bus, with its nametables mirrored as m
+// unless c has a Mirroring method of its own.
+func (p *PPU) SetCartridge(c Cartridge, m gemu.Mirroring) {
+	p.cart = c
+	p.mirroring = m
+}
+
+// SetSignals connects the PPU's NMI output to s.NMI, as SourcePPU.
+func (p *PPU) SetSignals(s *gemu.Signals) {
+	p.signals = s
+	p.updateNMI()
+}
+
+// updateNMI drives the NMI line: it is held while the vblank flag and
+// PPUCTRL's NMI bit are both set, so the cpu sees an edge when vblank
+// starts with NMIs on, or when they are turned on during vblank.
+func (p *PPU) updateNMI() {
+	if p.signals == nil {
+		return
+	}
+	if p.status&statusVBlank != 0 && p.ctrl&ctrlNMI != 0 {
+		p.signals.NMI.Assert(gemu.SourcePPU)
+	} else {
+		p.signals.NMI.Release(gemu.SourcePPU)
+	}
+}
+
+// NMIAtVBlank reports whether the PPU will raise NMI when vblank next
+// starts: PPUCTRL enables it, and PPUSTATUS wasn't read just before.
+func (p *PPU) NMIAtVBlank() bool {
+	return p.ctrl&ctrlNMI != 0 && !p.suppress
+}
+
+// Reset puts the PPU in its power on state.
+func (p *PPU) Reset() {
+	p.ctrl, p.mask, p.status, p.oamAddr = 0, 0, 0, 0
+	p.v, p.t, p.x, p.w = 0, 0, 0, false
+	p.readBuffer, p.latch, p.hitDot = 0, 0, 0
+	p.latchFrame = [8]uint64{}
+	p.scanline, p.dot, p.frames = 0, 0, 0
+	p.suppress = false
+	p.updateNMI()
+}
+
+// SoftReset is what the reset button does to the PPU: PPUCTRL, PPUMASK,
+// the scroll, the write toggle and the PPUDATA buffer are cleared. VRAM,
+// OAM, PPUSTATUS and the VRAM address are kept, and the frame goes on.
+func (p *PPU) SoftReset() {
+	p.ctrl, p.mask = 0, 0
+	p.t, p.x, p.w = 0, 0, false
+	p.readBuffer = 0
+	p.updateNMI()
+}
+
+// Tick runs one dot. Each visible scanline is drawn into the frame as its
+// first pixel goes out, from the scroll it starts with, and sprite 0 sets
+// its flag on the dot it hits at. vblank starts on the second dot of the
+// region's vblank scanline, raising NMI if PPUCTRL enables it, and ends on
+// the second dot of the pre-render scanline, the last of the frame, which
+// also clears the sprite flags. In regions that skip it, the last dot of
+// the pre-render scanline is left out of odd frames while rendering.
+func (p *PPU) Tick() {
+	if p.dot == 1 && p.scanline < Height {
+		p.renderLine()
+	}
+	if p.hitDot != 0 && p.dot == p.hitDot {
+		p.status |= statusSprite0
+		p.hitDot = 0
+	}
+	p.scroll()
+	if p.dot == 1 {
+		switch p.scanline {
+		case p.region.VBlankScanline:
+			if !p.suppress {
+				p.status |= statusVBlank
+			}
+			p.suppress = false
+			p.updateNMI()
+		case p.region.Scanlines - 1:
+			p.status &^= statusVBlank | statusSprite0 | statusOverflow
+			p.updateNMI()
+		}
+	}
+	p.dot++
+	if p.dot == p.region.DotsPerScanline-1 && p.scanline == p.region.Scanlines-1 && p.oddFrame() && p.rendering() {
+		p.dot++
+	}
+	if p.dot == p.region.DotsPerScanline {
+		p.dot = 0
+		p.scanline++
+		if p.scanline == p.region.Scanlines {
+			p.scanline = 0
+			p.frames++
+		}
+	}
+}
+
+// Position returns the scanline and dot the PPU will run next.
+func (p *PPU) Position() (scanline, dot int) {
+	return p.scanline, p.dot
+}
+
+// oddFrame reports whether the frame in progress is one that skips a dot.
+func (p *PPU) oddFrame() bool {
+	return p.region.SkipOddDot && p.frames&1 == 1
+}
+
+// DotsUntil returns how many dots the PPU will run before it is next at
+// scanline, dot: at least one, and a whole frame if it is there now. An
+// odd frame's skipped dot is counted as skipped whether or not rendering
+// is on when the PPU gets to it, so the answer is never too many.
+func (p *PPU) DotsUntil(scanline, dot int) int {
+	dots := p.region.DotsPerScanline
+	frame := p.region.Scanlines * dots
+	at := p.scanline*dots + p.dot
+	n := scanline*dots + dot - at
+	if n <= 0 {
+		n += frame
+		if p.oddFrame() && at < frame-1 {
+			n--
+		}
+	}
+	return n
+}
+
+// FrameCount returns the number of frames the PPU has completed.
+func (p *PPU) FrameCount() uint64 {
+	return p.frames
+}
+
+// Frame returns the picture, which is drawn over as the PPU runs. Copy it
+// at the end of a frame to keep it.
+func (p *PPU) Frame() *image.RGBA {
+	return p.frame
+}
+
+// SetSkip stops the PPU drawing the picture while skip is true, to save the
+// time of frames that won't be shown. Everything else runs as usual.
+func (p *PPU) SetSkip(skip bool) {
+	p.skip = skip
+}
+
+// OAM returns the 256 bytes of sprite memory.
+func (p *PPU) OAM() []uint8 {
+	return p.oam[:]
+}
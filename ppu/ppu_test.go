@@ -0,0 +1,93 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/goldmane/gemu/gemu"
+)
+
+func TestNMILine(t *testing.T) {
+	p := New(gemu.NTSC)
+	var s gemu.Signals
+	p.SetSignals(&s)
+	p.WriteRegister(0x2000, ctrlNMI)
+	vblank := gemu.NTSC.VBlankScanline
+
+	runTo(p, vblank, 1)
+	if s.NMI.Asserted() {
+		t.Fatal("NMI asserted before vblank")
+	}
+	p.Tick()
+	if !s.NMI.TakeEdge() {
+		t.Fatal("no NMI edge on the second dot of the vblank scanline")
+	}
+
+	// turning NMIs off and on again during vblank makes another edge
+	p.WriteRegister(0x2000, 0)
+	if s.NMI.Asserted() {
+		t.Error("NMI still asserted with PPUCTRL's NMI bit clear")
+	}
+	p.WriteRegister(0x2000, ctrlNMI)
+	if !s.NMI.TakeEdge() {
+		t.Error("no NMI edge from turning NMIs on during vblank")
+	}
+
+	// reading PPUSTATUS ends the flag, and the line with it; turning NMIs
+	// on after that makes no edge
+	p.ReadRegister(0x2002)
+	if s.NMI.Asserted() {
+		t.Error("NMI still asserted after PPUSTATUS was read")
+	}
+	p.WriteRegister(0x2000, 0)
+	p.WriteRegister(0x2000, ctrlNMI)
+	if s.NMI.TakeEdge() {
+		t.Error("NMI edge during vblank with the flag read")
+	}
+
+	// the flag is set again next frame, and ends on the second dot of the
+	// pre-render scanline
+	runTo(p, vblank, 2)
+	if !s.NMI.TakeEdge() {
+		t.Fatal("no NMI edge the next frame")
+	}
+	pre := gemu.NTSC.Scanlines - 1
+	runTo(p, pre, 1)
+	if !s.NMI.Asserted() || p.status&statusVBlank == 0 {
+		t.Error("vblank ended before the pre-render scanline's second dot")
+	}
+	p.Tick()
+	if s.NMI.Asserted() || p.status&statusVBlank != 0 {
+		t.Error("vblank still set after the pre-render scanline's second dot")
+	}
+}
+
+func TestFrameLength(t *testing.T) {
+	ntsc := gemu.NTSC.Scanlines * gemu.NTSC.DotsPerScanline
+	for _, tc := range []struct {
+		name   string
+		region gemu.Region
+		mask   uint8
+		// dots in the first frame and the second
+		want [2]int
+	}{
+		// odd frames drop the last dot of the pre-render scanline while
+		// rendering
+		{"rendering", gemu.NTSC, maskBackground, [2]int{ntsc, ntsc - 1}},
+		{"not rendering", gemu.NTSC, 0, [2]int{ntsc, ntsc}},
+		{"PAL", gemu.PAL, maskBackground, [2]int{gemu.PAL.Scanlines * gemu.PAL.DotsPerScanline, gemu.PAL.Scanlines * gemu.PAL.DotsPerScanline}},
+	} {
+		p := New(tc.region)
+		p.mask = tc.mask
+		for i, want := range tc.want {
+			frame := p.FrameCount()
+			n := 0
+			for p.FrameCount() == frame {
+				p.Tick()
+				n++
+			}
+			if n != want {
+				t.Errorf("%s: frame %d took %d dots, want %d", tc.name, i, n, want)
+			}
+		}
+	}
+}
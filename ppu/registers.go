@@ -0,0 +1,141 @@
+package ppu
+
+// latchDecay is how long, in seconds, a bit of the open bus latch holds
+// its value once nothing drives it before fading to 0.
+const latchDecay = 0.6
+
+// ReadRegister reads the register at addr, which is mirrored every eight
+// bytes through $3FFF. Reading PPUSTATUS ends vblank's flag, and with it
+// an NMI the cpu hasn't taken yet, and resets the write toggle. Reading
+// PPUDATA advances the VRAM address. The write
+// only registers read back the last value written to any register, less
+// the bits that have since decayed.
+func (p *PPU) ReadRegister(addr uint16) uint8 {
+	switch addr & 7 {
+	case 2: // PPUSTATUS
+		if p.scanline == p.region.VBlankScanline && p.dot == 1 {
+			// read on the dot before vblank starts: the flag reads clear
+			// and then isn't set, so there's no NMI this frame either
+			p.suppress = true
+		}
+		v := p.status & 0xE0
+		p.status &^= statusVBlank
+		p.updateNMI()
+		p.w = false
+		p.drive(v, 0xE0)
+	case 4: // OAMDATA
+		p.drive(p.oam[p.oamAddr], 0xFF)
+	case 7: // PPUDATA
+		a := p.v & 0x3FFF
+		v, driven := p.readBuffer, uint8(0xFF)
+		p.readBuffer = p.read(a)
+		if a >= 0x3F00 {
+			// the palette answers at once, with the open bus in the two
+			// bits it doesn't have, and the buffer gets the nametable
+			// byte underneath it
+			v, driven = p.readBuffer, 0x3F
+			p.readBuffer = p.read(a - 0x1000)
+		}
+		p.increment()
+		p.drive(v, driven)
+	}
+	return p.openBus()
+}
+
+// drive puts the bits of v that are set in mask on the latch, refreshing
+// them; the others keep what is left of their value.
+func (p *PPU) drive(v, mask uint8) {
+	p.latch = p.openBus()&^mask | v&mask
+	for i := range p.latchFrame {
+		if mask&(1<<i) != 0 {
+			p.latchFrame[i] = p.frames
+		}
+	}
+}
+
+// openBus returns the latch with the bits not driven for latchDecay
+// seconds read as 0.
+func (p *PPU) openBus() uint8 {
+	decay := uint64(latchDecay * p.region.FrameRate())
+	v := p.latch
+	for i, f := range p.latchFrame {
+		if p.frames > f+decay {
+			v &^= 1 << i
+		}
+	}
+	return v
+}
+
+// WriteRegister writes v to the register at addr, mirrored every eight
+// bytes through $3FFF. PPUSCROLL and PPUADDR take two writes each, which
+// share one toggle.
+func (p *PPU) WriteRegister(addr uint16, v uint8) {
+	p.drive(v, 0xFF)
+	switch addr & 7 {
+	case 0: // PPUCTRL
+		p.ctrl = v
+		p.updateNMI()
+		p.t = p.t&^nametableBits | uint16(v&ctrlNametable)<<10
+	case 1: // PPUMASK
+		p.mask = v
+	case 3: // OAMADDR
+		p.oamAddr = v
+	case 4: // OAMDATA
+		p.oam[p.oamAddr] = v
+		p.oamAddr++
+	case 5: // PPUSCROLL
+		if !p.w {
+			p.t = p.t&^coarseXBits | uint16(v>>3)
+			p.x = v & 7
+		} else {
+			p.t = p.t&^(fineYBits|coarseYBits) | uint16(v&7)<<12 | uint16(v>>3)<<5
+		}
+		p.w = !p.w
+	case 6: // PPUADDR
+		if !p.w {
+			// the top bit of t is cleared, as PPUADDR only has 14
+			p.t = p.t&0x00FF | uint16(v&0x3F)<<8
+		} else {
+			p.t = p.t&0xFF00 | uint16(v)
+			p.v = p.t
+		}
+		p.w = !p.w
+	case 7: // PPUDATA
+		p.write(p.v&0x3FFF, v)
+		p.increment()
+	}
+}
+
+// increment moves v on after a PPUDATA access, across a nametable row if
+// PPUCTRL says so. While rendering, the access instead bumps both coarse X
+// and Y, as the PPU's own increments would.
+func (p *PPU) increment() {
+	switch {
+	case p.renderingLine():
+		p.incrementX()
+		p.incrementY()
+	case p.ctrl&ctrlIncrement32 != 0:
+		p.v += 32
+	default:
+		p.v++
+	}
+	p.v &= 0x7FFF
+}
+
+// PeekRegister returns what reading the register at addr would, without
+// ending vblank, resetting the toggle or moving the VRAM address, for a
+// debugger to show.
+func (p *PPU) PeekRegister(addr uint16) uint8 {
+	switch addr & 7 {
+	case 2:
+		return p.status&0xE0 | p.openBus()&0x1F
+	case 4:
+		return p.oam[p.oamAddr]
+	case 7:
+		if a := p.v & 0x3FFF; a >= 0x3F00 {
+			return p.read(a)&0x3F | p.openBus()&0xC0
+		}
+		return p.readBuffer
+	}
+	return p.openBus()
+}
@@ -0,0 +1,182 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/goldmane/gemu/gemu"
+)
+
+func TestOpenBusDecays(t *testing.T) {
+	p := New(gemu.NTSC)
+	// about 600ms of NTSC frames
+	decay := uint64(latchDecay * gemu.NTSC.FrameRate())
+
+	p.WriteRegister(0x2000, 0xFF)
+	p.frames += decay
+	if got := p.ReadRegister(0x2001); got != 0xFF {
+		t.Fatalf("open bus read $%02X %d frames after the write, want $FF", got, decay)
+	}
+	// PPUSTATUS drives only its top three bits, and vblank is set
+	p.status |= statusVBlank
+	p.ReadRegister(0x2002)
+	p.frames++
+	if got := p.ReadRegister(0x2001); got != 0x80 {
+		t.Fatalf("open bus read $%02X after decay, want $80 from the PPUSTATUS read", got)
+	}
+	if got := p.PeekRegister(0x2001); got != 0x80 {
+		t.Fatalf("peek read $%02X, want $80", got)
+	}
+	p.frames += decay + 1
+	if got := p.ReadRegister(0x2001); got != 0 {
+		t.Fatalf("open bus read $%02X once every bit decayed, want 0", got)
+	}
+}
+
+func TestSoftResetClearsControl(t *testing.T) {
+	p := New(gemu.NTSC)
+	var s gemu.Signals
+	p.SetSignals(&s)
+	p.status |= statusVBlank
+	p.WriteRegister(0x2000, 0x80)
+	p.WriteRegister(0x2001, 0x1E)
+	p.WriteRegister(0x2005, 0x7D)
+	if !s.NMI.Asserted() {
+		t.Fatal("NMI not asserted with vblank and PPUCTRL bit 7 set")
+	}
+
+	p.SoftReset()
+	if p.ctrl != 0 || p.mask != 0 || p.t != 0 || p.x != 0 || p.w {
+		t.Errorf("after reset ctrl=%02X mask=%02X t=%04X x=%d w=%v, want all clear", p.ctrl, p.mask, p.t, p.x, p.w)
+	}
+	if s.NMI.Asserted() {
+		t.Error("NMI still asserted after reset cleared PPUCTRL")
+	}
+	if p.status&statusVBlank == 0 {
+		t.Error("reset cleared the vblank flag")
+	}
+}
+
+// writeAddr points v at addr through PPUADDR.
+func writeAddr(p *PPU, addr uint16) {
+	p.WriteRegister(0x2006, uint8(addr>>8))
+	p.WriteRegister(0x2006, uint8(addr))
+}
+
+func TestPPUDATABuffer(t *testing.T) {
+	p := New(gemu.NTSC)
+	writeAddr(p, 0x2100)
+	for _, v := range []uint8{0x11, 0x22, 0x33} {
+		p.WriteRegister(0x2007, v)
+	}
+	writeAddr(p, 0x2100)
+	// each read returns what the one before fetched
+	for i, want := range []uint8{0x00, 0x11, 0x22, 0x33} {
+		if got := p.ReadRegister(0x2007); got != want {
+			t.Errorf("read %d returned $%02X, want $%02X", i, got, want)
+		}
+	}
+	if p.v != 0x2104 {
+		t.Errorf("v=$%04X after four reads, want $2104", p.v)
+	}
+
+	// with PPUCTRL's increment bit, v moves down a row
+	p.WriteRegister(0x2000, ctrlIncrement32)
+	writeAddr(p, 0x2100)
+	p.ReadRegister(0x2007)
+	if p.v != 0x2120 {
+		t.Errorf("v=$%04X after a read going down, want $2120", p.v)
+	}
+}
+
+func TestPaletteReads(t *testing.T) {
+	p := New(gemu.NTSC)
+	writeAddr(p, 0x2F00)
+	p.WriteRegister(0x2007, 0x5A)
+	writeAddr(p, 0x3F01)
+	// the top two bits aren't stored
+	p.WriteRegister(0x2007, 0xE7)
+	// $3F10 is the backdrop, shared with $3F00
+	writeAddr(p, 0x3F10)
+	p.WriteRegister(0x2007, 0x0F)
+
+	writeAddr(p, 0x3F01)
+	// the bits the palette doesn't drive are the open bus, which the
+	// PPUADDR write left as $01
+	if got := p.ReadRegister(0x2007); got != 0x27 {
+		t.Errorf("$3F01 read $%02X, want $27 at once", got)
+	}
+	// the buffer has the nametable byte under the palette, from $2F01
+	writeAddr(p, 0x3F00)
+	if got := p.ReadRegister(0x2007); got != 0x0F {
+		t.Errorf("$3F00 read $%02X, want the backdrop $0F written at $3F10", got)
+	}
+	writeAddr(p, 0x2000)
+	if got := p.ReadRegister(0x2007); got != 0x5A {
+		t.Errorf("buffer held $%02X after reading $3F00, want $5A from $2F00", got)
+	}
+}
+
+func TestWriteToggle(t *testing.T) {
+	p := New(gemu.NTSC)
+	// PPUCTRL's nametable bits go to t
+	p.WriteRegister(0x2000, 0x02)
+	// X scroll 125: coarse X 15, fine X 5
+	p.WriteRegister(0x2005, 0x7D)
+	if !p.w || p.t != 0x080F || p.x != 5 {
+		t.Fatalf("after the first PPUSCROLL write t=$%04X x=%d w=%v, want $080F 5 true", p.t, p.x, p.w)
+	}
+	// the second write of the pair goes to PPUADDR's low byte: the toggle
+	// is shared
+	p.WriteRegister(0x2006, 0x34)
+	if p.w || p.t != 0x0834 || p.v != 0x0834 {
+		t.Fatalf("after a PPUADDR write t=$%04X v=$%04X w=%v, want $0834 $0834 false", p.t, p.v, p.w)
+	}
+
+	// reading PPUSTATUS resets the toggle, so this is a first write again
+	p.WriteRegister(0x2005, 0x00)
+	p.ReadRegister(0x2002)
+	// Y scroll 94 is fine Y 6, coarse Y 11, after a first write of 0
+	p.WriteRegister(0x2005, 0x00)
+	p.WriteRegister(0x2005, 0x5E)
+	if p.w || p.t != 0x6960 || p.x != 0 {
+		t.Errorf("after PPUSCROLL 0, 94 t=$%04X x=%d w=%v, want $6960 0 false", p.t, p.x, p.w)
+	}
+	// PPUADDR's first write clears bit 14 of t and leaves v
+	p.WriteRegister(0x2006, 0xFF)
+	if p.t != 0x3F60 || p.v != 0x0834 || !p.w {
+		t.Errorf("after PPUADDR $FF t=$%04X v=$%04X w=%v, want $3F60 $0834 true", p.t, p.v, p.w)
+	}
+}
+
+func TestVBlankReadSuppression(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		dot  int
+		flag bool
+	}{
+		// reading the dot before it's set hides the flag for the frame
+		{"dot before", 1, false},
+		{"dot after", 2, true},
+	} {
+		p := New(gemu.NTSC)
+		var s gemu.Signals
+		p.SetSignals(&s)
+		p.WriteRegister(0x2000, ctrlNMI)
+		p.scanline = gemu.NTSC.VBlankScanline
+		p.dot = 1
+		if tc.dot == 2 {
+			p.Tick()
+		}
+		got := p.ReadRegister(0x2002)&statusVBlank != 0
+		if got != tc.flag {
+			t.Errorf("%s: PPUSTATUS read vblank %v, want %v", tc.name, got, tc.flag)
+		}
+		p.Tick()
+		if p.status&statusVBlank != 0 || s.NMI.Asserted() {
+			t.Errorf("%s: vblank set or NMI raised after the read", tc.name)
+		}
+		if !p.NMIAtVBlank() {
+			t.Errorf("%s: suppression outlived the frame's vblank", tc.name)
+		}
+	}
+}
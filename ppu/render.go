@@ -0,0 +1,98 @@
+package ppu
+
+// Width and Height are the size of the picture, in pixels.
+const (
+	Width  = 256
+	Height = 240
+)
+
+// PPUMASK bits
+const (
+	maskGrayscale      = 0x01
+	maskBackgroundLeft = 0x02
+	maskBackground     = 0x08
+	maskSprites        = 0x10
+)
+
+// PPUCTRL bits the background uses
+const (
+	ctrlNametable         = 0x03
+	ctrlBackgroundPattern = 0x10
+)
+
+// rendering reports whether PPUMASK shows the background or sprites.
+func (p *PPU) rendering() bool {
+	return p.mask&(maskBackground|maskSprites) != 0
+}
+
+// renderLine draws the current scanline into the frame, once the dots
+// that output it have run. With skip set the sprites are still evaluated
+// and drawn into line, for the flags they set, but the frame is left.
+func (p *PPU) renderLine() {
+	p.renderBackground()
+	if p.rendering() {
+		p.evaluateSprites()
+		p.renderSprites()
+	}
+	if p.skip {
+		return
+	}
+	y := p.scanline
+	row := p.frame.Pix[y*p.frame.Stride : y*p.frame.Stride+Width*4]
+	for x := 0; x < Width; x++ {
+		i := uint16(0)
+		if px := p.line[x]; px&3 != 0 {
+			i = uint16(px)
+		}
+		c := Palette[p.color(i)]
+		row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = c.R, c.G, c.B, 0xFF
+	}
+}
+
+// color returns the colour of palette entry i, 0-31, as PPUMASK shows it.
+func (p *PPU) color(i uint16) uint8 {
+	c := p.palette[paletteIndex(i)]
+	if p.mask&maskGrayscale != 0 {
+		c &= 0x30
+	}
+	return c
+}
+
+// renderBackground fills line with the background of the current scanline:
+// for each pixel the palette, 0-3, in bits 2-3 and the colour within it,
+// 0-3 with 0 transparent, in bits 0-1. It walks the tiles from v, starting
+// fine X pixels into the first, without moving v itself.
+func (p *PPU) renderBackground() {
+	if p.mask&maskBackground == 0 {
+		clear(p.line[:])
+		return
+	}
+	base := uint16(p.ctrl&ctrlBackgroundPattern) << 8
+	v := p.v
+	fineY := v >> 12
+	for x, fineX := 0, int(p.x); x < Width; fineX = 0 {
+		tile := p.read(0x2000 | v&0x0FFF)
+		attr := p.read(0x23C0 | v&nametableBits | v>>4&0x38 | v>>2&0x07)
+		pal := attr >> (v>>4&4 | v&2) & 3
+		addr := base | uint16(tile)<<4 | fineY
+		lo, hi := p.read(addr), p.read(addr|8)
+		for ; fineX < 8 && x < Width; fineX++ {
+			bit := 7 - fineX
+			px := lo>>bit&1 | hi>>bit&1<<1
+			if px != 0 {
+				px |= pal << 2
+			}
+			p.line[x] = px
+			x++
+		}
+		// the next tile along, into the next nametable after the 32nd
+		if v&coarseXBits == 31 {
+			v = v&^coarseXBits ^ 0x0400
+		} else {
+			v++
+		}
+	}
+	if p.mask&maskBackgroundLeft == 0 {
+		clear(p.line[:8])
+	}
+}
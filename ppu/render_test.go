@@ -0,0 +1,113 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/goldmane/gemu/gemu"
+)
+
+// chr is 8kb of pattern table RAM, as a cartridge.
+type chr [0x2000]uint8
+
+func (c *chr) PPURead(addr uint16) uint8     { return c[addr&0x1FFF] }
+func (c *chr) PPUWrite(addr uint16, v uint8) { c[addr&0x1FFF] = v }
+
+// newTestPPU returns a PPU with pattern table RAM and vertical mirroring.
+// Tile 1 of the first table is four pixels of colour 1 and then four of
+// colour 2 on every row.
+func newTestPPU() (*PPU, *chr) {
+	p := New(gemu.NTSC)
+	c := new(chr)
+	for row := 0; row < 8; row++ {
+		c[0x10+row], c[0x18+row] = 0xF0, 0x0F
+	}
+	p.SetCartridge(c, gemu.Vertical)
+	return p, c
+}
+
+func TestRenderBackground(t *testing.T) {
+	// tiles 0 and 1 of the top row are tile 1 in palette 2, the rest blank
+	const (
+		c1 = 2<<2 | 1
+		c2 = 2<<2 | 2
+	)
+	for _, tc := range []struct {
+		name string
+		mask uint8
+		x    uint8
+		want [18]uint8
+	}{
+		{"unscrolled", maskBackground | maskBackgroundLeft, 0,
+			[18]uint8{c1, c1, c1, c1, c2, c2, c2, c2, c1, c1, c1, c1, c2, c2, c2, c2, 0, 0}},
+		{"fine X", maskBackground | maskBackgroundLeft, 3,
+			[18]uint8{c1, c2, c2, c2, c2, c1, c1, c1, c1, c2, c2, c2, c2, 0, 0, 0, 0, 0}},
+		// the left 8 pixels are blanked unless PPUMASK shows them
+		{"left clipped", maskBackground, 0,
+			[18]uint8{0, 0, 0, 0, 0, 0, 0, 0, c1, c1, c1, c1, c2, c2, c2, c2, 0, 0}},
+		{"off", maskSprites | maskBackgroundLeft, 0, [18]uint8{}},
+	} {
+		p, _ := newTestPPU()
+		p.vram[0], p.vram[1] = 1, 1
+		// the top left quadrant's palette is in bits 0-1
+		p.vram[0x3C0] = 2
+		p.mask = tc.mask
+		p.x = tc.x
+		p.renderBackground()
+		if got := [18]uint8(p.line[:18]); got != tc.want {
+			t.Errorf("%s: line starts % X, want % X", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRenderBackgroundAcrossNametables(t *testing.T) {
+	p, _ := newTestPPU()
+	// the last tile of the third row of the first nametable, in the bottom
+	// right quadrant of its attribute byte, then the first of the next
+	// nametable's, in the bottom left; drawn from fine Y 5
+	p.vram[0x05F] = 1
+	p.vram[0x440] = 1
+	p.vram[0x3C7] = 1 << 6
+	p.vram[0x7C0] = 3 << 4
+	p.mask = maskBackground | maskBackgroundLeft
+	p.v = 5<<12 | 2<<5 | 31
+	p.renderBackground()
+	want := [16]uint8{}
+	for i := range want {
+		pal := uint8(1)
+		if i >= 8 {
+			pal = 3
+		}
+		want[i] = pal<<2 | 1
+		if i&7 >= 4 {
+			want[i]++
+		}
+	}
+	if got := [16]uint8(p.line[:16]); got != want {
+		t.Errorf("line starts % X, want % X", got, want)
+	}
+}
+
+func TestRenderLineColors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mask uint8
+		// the colours of pixels 0 and 16, tile 1 and the backdrop
+		want [2]uint8
+	}{
+		{"colour", maskBackground | maskBackgroundLeft, [2]uint8{0x16, 0x21}},
+		{"grayscale", maskBackground | maskBackgroundLeft | maskGrayscale, [2]uint8{0x10, 0x20}},
+	} {
+		p, _ := newTestPPU()
+		p.vram[0] = 1
+		p.palette[0] = 0x21
+		p.palette[1] = 0x16
+		p.mask = tc.mask
+		p.dot = 1
+		p.Tick()
+		for i, x := range []int{0, 16} {
+			if got, want := p.frame.RGBAAt(x, 0), Palette[tc.want[i]]; got != want {
+				t.Errorf("%s: pixel %d is %v, want %v", tc.name, x, got, want)
+			}
+		}
+	}
+}
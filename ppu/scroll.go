@@ -0,0 +1,89 @@
+package ppu
+
+// The scroll lives in the PPU's internal registers, named after loopy, who
+// worked them out:
+//
+//	v  the current VRAM address, which rendering walks as it draws
+//	t  the address the next scanline or frame starts from
+//	x  the fine X scroll, 0-7
+//	w  the write toggle shared by PPUSCROLL and PPUADDR
+//
+// While rendering, v and t are laid out as
+//
+//	yyy NN YYYYY XXXXX
+//
+// for fine Y, nametable, coarse Y and coarse X. PPUSCROLL and PPUCTRL
+// write t, and PPUADDR writes t and then copies it to v. Each visible line
+// v moves down a row at dot 256 and gets t's horizontal bits back at dot
+// 257, and the pre-render line copies t's vertical bits over at dot 280,
+// so a write in hblank takes effect from the next scanline.
+const (
+	coarseXBits   = 0x001F
+	coarseYBits   = 0x03E0
+	nametableBits = 0x0C00
+	fineYBits     = 0x7000
+
+	horizontalBits = 0x041F
+	verticalBits   = 0x7BE0
+)
+
+// incrementX moves v to the next tile along, into the next nametable
+// across after the 32nd.
+func (p *PPU) incrementX() {
+	if p.v&coarseXBits == 31 {
+		p.v &^= coarseXBits
+		p.v ^= 0x0400
+	} else {
+		p.v++
+	}
+}
+
+// incrementY moves v down a row of pixels, into the next nametable down
+// after the 30th row of tiles. Coarse Y set to 30 or 31 by a write runs on
+// through the attribute table and wraps without switching nametables.
+func (p *PPU) incrementY() {
+	if p.v&fineYBits != fineYBits {
+		p.v += 0x1000
+		return
+	}
+	p.v &^= fineYBits
+	switch y := p.v & coarseYBits >> 5; y {
+	case 29:
+		p.v &^= coarseYBits
+		p.v ^= 0x0800
+	case 31:
+		p.v &^= coarseYBits
+	default:
+		p.v += 0x20
+	}
+}
+
+// copyX and copyY reload the horizontal and vertical parts of v from t.
+func (p *PPU) copyX() {
+	p.v = p.v&^horizontalBits | p.t&horizontalBits
+}
+
+func (p *PPU) copyY() {
+	p.v = p.v&^verticalBits | p.t&verticalBits
+}
+
+// renderingLine reports whether the PPU is rendering on the current
+// scanline, which is when it moves v by itself.
+func (p *PPU) renderingLine() bool {
+	return p.rendering() && (p.scanline < Height || p.scanline == p.region.Scanlines-1)
+}
+
+// scroll runs the updates to v due at the current dot.
+func (p *PPU) scroll() {
+	if !p.renderingLine() {
+		return
+	}
+	switch {
+	case p.dot == 256 && p.scanline < Height:
+		p.incrementY()
+	case p.dot == 257:
+		p.copyX()
+	case p.dot == 280 && p.scanline == p.region.Scanlines-1:
+		p.copyY()
+	}
+}
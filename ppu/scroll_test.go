@@ -0,0 +1,120 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/goldmane/gemu/gemu"
+)
+
+func TestIncrementX(t *testing.T) {
+	for _, tc := range []struct{ v, want uint16 }{
+		{0x0000, 0x0001},
+		{0x701E, 0x701F},
+		// past the 32nd tile into the next nametable across, and back
+		{0x001F, 0x0400},
+		{0x0C3F, 0x0820},
+	} {
+		p := New(gemu.NTSC)
+		p.v = tc.v
+		p.incrementX()
+		if p.v != tc.want {
+			t.Errorf("incrementX from $%04X gave $%04X, want $%04X", tc.v, p.v, tc.want)
+		}
+	}
+}
+
+func TestIncrementY(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		v, want uint16
+	}{
+		{"fine Y", 0x0005, 0x1005},
+		{"next row", 0x7005, 0x0025},
+		// after the 30th row into the next nametable down
+		{"last row", 0x73A5, 0x0805},
+		{"last row back", 0x7BA5, 0x0005},
+		// the attribute rows wrap without switching nametables
+		{"row 30", 0x73C5, 0x03E5},
+		{"row 31", 0x77E5, 0x0405},
+	} {
+		p := New(gemu.NTSC)
+		p.v = tc.v
+		p.incrementY()
+		if p.v != tc.want {
+			t.Errorf("%s: incrementY from $%04X gave $%04X, want $%04X", tc.name, tc.v, p.v, tc.want)
+		}
+	}
+}
+
+// runTo ticks p until it is about to run scanline, dot.
+func runTo(p *PPU, scanline, dot int) {
+	for n := p.DotsUntil(scanline, dot); n > 0; n-- {
+		p.Tick()
+	}
+}
+
+func TestScrollUpdates(t *testing.T) {
+	pre := gemu.NTSC.Scanlines - 1
+	for _, tc := range []struct {
+		name     string
+		mask     uint8
+		scanline int
+		dot      int
+		want     uint16
+	}{
+		// v starts at $0000 and t is $7FFF
+		{"down a row at 256", maskBackground, 0, 257, 0x1000},
+		{"across from t at 257", maskBackground, 0, 258, 0x141F},
+		{"sprites alone", maskSprites, 0, 258, 0x141F},
+		{"vblank leaves it", maskBackground, gemu.NTSC.VBlankScanline, 300, 0x0000},
+		// the pre-render line copies across at 257 too
+		{"down from t at 280", maskBackground, pre, 281, 0x7FFF},
+		{"off", 0, 0, 258, 0x0000},
+	} {
+		p := New(gemu.NTSC)
+		p.scanline, p.dot = tc.scanline, 0
+		p.mask = tc.mask
+		p.t = 0x7FFF
+		runTo(p, tc.scanline, tc.dot)
+		if p.v != tc.want {
+			t.Errorf("%s: v=$%04X at scanline %d dot %d, want $%04X", tc.name, p.v, tc.scanline, tc.dot, tc.want)
+		}
+	}
+}
+
+func TestScrollWriteInHBlank(t *testing.T) {
+	p, _ := newTestPPU()
+	p.mask = maskBackground | maskBackgroundLeft
+	// tile 1 is at coarse X 2 of every row
+	for row := 0; row < 30; row++ {
+		p.vram[row*32+2] = 1
+	}
+	p.palette[1] = 0x16
+	p.scanline, p.dot = 0, 0
+	// scroll 16 pixels right in hblank of scanline 9, before dot 257
+	// copies t across: scanline 10 starts from it, scanline 9 doesn't
+	runTo(p, 9, 256)
+	p.ReadRegister(0x2002)
+	p.WriteRegister(0x2005, 16)
+	p.WriteRegister(0x2005, 0)
+	runTo(p, 11, 0)
+	lit := Palette[0x16]
+	if p.frame.RGBAAt(16, 9) != lit || p.frame.RGBAAt(0, 9) == lit {
+		t.Error("scanline 9 changed by the write after it was drawn")
+	}
+	if p.frame.RGBAAt(0, 10) != lit || p.frame.RGBAAt(16, 10) == lit {
+		t.Error("scanline 10 not drawn 16 pixels further right")
+	}
+}
+
+func TestPPUDATADuringRendering(t *testing.T) {
+	p := New(gemu.NTSC)
+	p.mask = maskBackground
+	p.scanline, p.dot = 20, 100
+	p.v = 0x001F
+	p.ReadRegister(0x2007)
+	// both coarse X and fine Y move on, instead of the usual +1 or +32
+	if p.v != 0x1400 {
+		t.Errorf("v=$%04X after a PPUDATA read while rendering, want $1400", p.v)
+	}
+}
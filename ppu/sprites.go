@@ -0,0 +1,141 @@
+package ppu
+
+// PPUCTRL bits the sprites use
+const (
+	ctrlSpritePattern = 0x08
+	ctrlSprite8x16    = 0x20
+)
+
+// PPUMASK bit showing sprites in the leftmost 8 pixels
+const maskSpritesLeft = 0x04
+
+// sprite attribute bits, in the third byte of an OAM entry
+const (
+	attrPalette = 0x03
+	attrBehind  = 0x20
+	attrFlipX   = 0x40
+	attrFlipY   = 0x80
+)
+
+// spritesLimit is how many sprites a scanline can show.
+const spritesLimit = 8
+
+// sprite is one of the sprites found on a scanline, with its row of the
+// pattern fetched.
+type sprite struct {
+	x      uint8
+	attr   uint8
+	lo, hi uint8
+	// whether this is sprite 0, the one that can set the hit flag
+	zero bool
+}
+
+// spriteHeight returns 8, or 16 in 8x16 mode.
+func (p *PPU) spriteHeight() int {
+	if p.ctrl&ctrlSprite8x16 != 0 {
+		return 16
+	}
+	return 8
+}
+
+// evaluateSprites finds the first eight sprites in OAM on the current
+// scanline, copying them to secondary OAM, and fetches their pattern rows.
+// A ninth sets the overflow flag. A sprite's OAM Y is one less than the
+// first scanline it is on.
+func (p *PPU) evaluateSprites() {
+	p.nsprites = 0
+	for i := range p.secondary {
+		p.secondary[i] = 0xFF
+	}
+	h := p.spriteHeight()
+	for i := 0; i < 64; i++ {
+		e := p.oam[i*4 : i*4+4]
+		row := p.scanline - int(e[0]) - 1
+		if row < 0 || row >= h {
+			continue
+		}
+		if p.nsprites == spritesLimit {
+			p.status |= statusOverflow
+			break
+		}
+		copy(p.secondary[p.nsprites*4:], e)
+		lo, hi := p.spritePattern(e[1], e[2], row)
+		p.sprites[p.nsprites] = sprite{x: e[3], attr: e[2], lo: lo, hi: hi, zero: i == 0}
+		p.nsprites++
+	}
+}
+
+// spritePattern fetches the row of a sprite's tile, flipped as attr says.
+// In 8x16 mode bit 0 of the tile picks the pattern table and the rest the
+// top of a pair of tiles; otherwise PPUCTRL picks the table.
+func (p *PPU) spritePattern(tile, attr uint8, row int) (lo, hi uint8) {
+	h := p.spriteHeight()
+	if attr&attrFlipY != 0 {
+		row = h - 1 - row
+	}
+	var addr uint16
+	if h == 16 {
+		addr = uint16(tile&1)<<12 | uint16(tile&0xFE)<<4
+		if row >= 8 {
+			addr += 16
+			row -= 8
+		}
+	} else {
+		addr = uint16(p.ctrl&ctrlSpritePattern)<<9 | uint16(tile)<<4
+	}
+	addr |= uint16(row)
+	lo, hi = p.read(addr), p.read(addr|8)
+	if attr&attrFlipX != 0 {
+		lo, hi = reverse(lo), reverse(hi)
+	}
+	return lo, hi
+}
+
+func reverse(b uint8) uint8 {
+	b = b&0xF0>>4 | b&0x0F<<4
+	b = b&0xCC>>2 | b&0x33<<2
+	b = b&0xAA>>1 | b&0x55<<1
+	return b
+}
+
+// renderSprites draws the sprites found by evaluateSprites over the
+// background in line, as palette entries 16-31. Of sprites on the same
+// pixel the first in OAM wins, and it then shows in front of the
+// background unless it is marked behind it and the background there is
+// opaque. The first pixel where sprite 0 and the background are both
+// opaque is where the hit flag will be set.
+func (p *PPU) renderSprites() {
+	if p.mask&maskSprites == 0 {
+		return
+	}
+	left := 0
+	if p.mask&maskSpritesLeft == 0 {
+		left = 8
+	}
+	var drawn [Width]bool
+	for _, s := range p.sprites[:p.nsprites] {
+		for i := 0; i < 8; i++ {
+			x := int(s.x) + i
+			if x >= Width {
+				break
+			}
+			if x < left || drawn[x] {
+				continue
+			}
+			bit := 7 - i
+			px := s.lo>>bit&1 | s.hi>>bit&1<<1
+			if px == 0 {
+				continue
+			}
+			drawn[x] = true
+			bg := p.line[x]&3 != 0
+			if s.zero && bg && x != Width-1 && p.hitDot == 0 && p.status&statusSprite0 == 0 {
+				// pixel x goes out on dot x+1
+				p.hitDot = x + 1
+			}
+			if s.attr&attrBehind == 0 || !bg {
+				p.line[x] = 0x10 | (s.attr&attrPalette)<<2 | px
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package ppu
+
+import "testing"
+
+// setSprite fills OAM entry i.
+func setSprite(p *PPU, i int, y, tile, attr, x uint8) {
+	copy(p.oam[i*4:], []uint8{y, tile, attr, x})
+}
+
+func TestSpriteEvaluation(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		ctrl     uint8
+		scanline int
+		want     int
+	}{
+		// a sprite at Y 9 starts on scanline 10
+		{"above", 0, 9, 0},
+		{"top row", 0, 10, 1},
+		{"bottom row", 0, 17, 1},
+		{"below", 0, 18, 0},
+		{"8x16 lower tile", ctrlSprite8x16, 18, 1},
+		{"8x16 bottom row", ctrlSprite8x16, 25, 1},
+		{"8x16 below", ctrlSprite8x16, 26, 0},
+	} {
+		p, _ := newTestPPU()
+		for i := 0; i < 64; i++ {
+			setSprite(p, i, 0xF0, 0, 0, 0)
+		}
+		setSprite(p, 5, 9, 1, 0, 0)
+		p.ctrl = tc.ctrl
+		p.scanline = tc.scanline
+		p.evaluateSprites()
+		if p.nsprites != tc.want {
+			t.Errorf("%s: found %d sprites on scanline %d, want %d", tc.name, p.nsprites, tc.scanline, tc.want)
+		}
+	}
+}
+
+func TestSpriteOverflow(t *testing.T) {
+	for _, n := range []int{8, 9} {
+		p, _ := newTestPPU()
+		for i := 0; i < 64; i++ {
+			setSprite(p, i, 0xF0, 0, 0, 0)
+		}
+		for i := 0; i < n; i++ {
+			setSprite(p, i*3, 9, 1, 0, uint8(i*8))
+		}
+		p.scanline = 10
+		p.evaluateSprites()
+		if p.nsprites != 8 {
+			t.Errorf("%d sprites: %d evaluated, want 8", n, p.nsprites)
+		}
+		if got := p.status&statusOverflow != 0; got != (n > 8) {
+			t.Errorf("%d sprites: overflow %v", n, got)
+		}
+		// secondary OAM has the first eight in OAM order
+		if p.secondary[7*4+3] != 56 {
+			t.Errorf("%d sprites: eighth sprite in secondary OAM is at X %d, want 56", n, p.secondary[7*4+3])
+		}
+	}
+}
+
+func TestRenderSprites(t *testing.T) {
+	const all = maskBackground | maskBackgroundLeft | maskSprites | maskSpritesLeft
+	type spr struct{ y, tile, attr, x uint8 }
+	for _, tc := range []struct {
+		name     string
+		ctrl     uint8
+		mask     uint8
+		scanline int
+		sprites  []spr
+		// tile 1 at the top left of the background, in palette 0
+		background bool
+		want       [12]uint8
+	}{
+		{"palette", 0, all, 10, []spr{{9, 1, 1, 0}}, false,
+			[12]uint8{0x15, 0x15, 0x15, 0x15, 0x16, 0x16, 0x16, 0x16}},
+		{"flip X", 0, all, 10, []spr{{9, 1, attrFlipX, 0}}, false,
+			[12]uint8{0x12, 0x12, 0x12, 0x12, 0x11, 0x11, 0x11, 0x11}},
+		// tile 2 is a diagonal, so row 2 has pixel 2 set
+		{"row", 0, all, 12, []spr{{9, 2, 0, 0}}, false,
+			[12]uint8{2: 0x11}},
+		{"flip Y", 0, all, 12, []spr{{9, 2, attrFlipY, 0}}, false,
+			[12]uint8{5: 0x11}},
+		// tile 3 picks the second table, where the lower tile is solid
+		{"8x16 upper", ctrlSprite8x16, all, 10, []spr{{9, 3, 0, 0}}, false,
+			[12]uint8{}},
+		{"8x16 lower", ctrlSprite8x16, all, 18, []spr{{9, 3, 0, 0}}, false,
+			[12]uint8{0x13, 0x13, 0x13, 0x13, 0x13, 0x13, 0x13, 0x13}},
+		{"8x16 lower flipped", ctrlSprite8x16, all, 10, []spr{{9, 3, attrFlipY, 0}}, false,
+			[12]uint8{0x13, 0x13, 0x13, 0x13, 0x13, 0x13, 0x13, 0x13}},
+		{"left clipped", 0, all &^ maskSpritesLeft, 10, []spr{{9, 1, 0, 4}}, false,
+			[12]uint8{8: 0x12, 0x12, 0x12, 0x12}},
+		{"first in OAM wins", 0, all, 10, []spr{{9, 1, 0, 0}, {9, 1, 3, 2}}, false,
+			[12]uint8{0x11, 0x11, 0x11, 0x11, 0x12, 0x12, 0x12, 0x12, 0x1E, 0x1E}},
+		{"in front", 0, all, 10, []spr{{9, 1, 0, 4}}, true,
+			[12]uint8{1, 1, 1, 1, 0x11, 0x11, 0x11, 0x11, 0x12, 0x12, 0x12, 0x12}},
+		{"behind", 0, all, 10, []spr{{9, 1, attrBehind, 4}}, true,
+			[12]uint8{1, 1, 1, 1, 2, 2, 2, 2, 0x12, 0x12, 0x12, 0x12}},
+		{"sprites off", 0, all &^ maskSprites, 10, []spr{{9, 1, 0, 4}}, true,
+			[12]uint8{1, 1, 1, 1, 2, 2, 2, 2}},
+	} {
+		p, c := newTestPPU()
+		for row := 0; row < 8; row++ {
+			c[0x20+row] = 0x80 >> row
+			c[0x1030+row], c[0x1038+row] = 0xFF, 0xFF
+		}
+		for i := 0; i < 64; i++ {
+			setSprite(p, i, 0xF0, 0, 0, 0)
+		}
+		for i, s := range tc.sprites {
+			setSprite(p, i, s.y, s.tile, s.attr, s.x)
+		}
+		if tc.background {
+			p.vram[0] = 1
+		}
+		p.ctrl, p.mask = tc.ctrl, tc.mask
+		p.scanline = tc.scanline
+		p.renderLine()
+		if got := [12]uint8(p.line[:12]); got != tc.want {
+			t.Errorf("%s: line starts % X, want % X", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSprite0Hit(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// OAM entry and X of the sprite
+		i, x uint8
+		// dot the flag is set on, or 0
+		want int
+	}{
+		// pixel 2 goes out on dot 3
+		{"overlapping", 0, 2, 3},
+		{"not sprite 0", 1, 2, 0},
+		{"transparent background", 0, 16, 0},
+		// the last pixel never hits
+		{"right edge", 0, 255, 0},
+	} {
+		p, _ := newTestPPU()
+		for i := 0; i < 64; i++ {
+			setSprite(p, i, 0xF0, 0, 0, 0)
+		}
+		setSprite(p, int(tc.i), 9, 1, 0, tc.x)
+		p.vram[0], p.vram[1], p.vram[31] = 1, 1, 1
+		p.mask = maskBackground | maskBackgroundLeft | maskSprites | maskSpritesLeft
+		p.scanline, p.dot = 10, 1
+		hit := 0
+		for p.dot <= Width {
+			dot := p.dot
+			p.Tick()
+			if hit == 0 && p.status&statusSprite0 != 0 {
+				hit = dot
+			}
+		}
+		if hit != tc.want {
+			t.Errorf("%s: hit on dot %d, want %d", tc.name, hit, tc.want)
+		}
+	}
+}
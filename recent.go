@@ -0,0 +1,85 @@
+package gemu
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxRecentROMs is how many ROMs the recent list keeps.
+const maxRecentROMs = 20
+
+// RecentROM is an entry of the recent ROMs list.
+type RecentROM struct {
+	Path string `json:"path"`
+	// Title is the game's name from the database, or the file name
+	Title  string    `json:"title"`
+	Played time.Time `json:"played"`
+}
+
+// RecentROMs is the list of recently played ROMs, most recent first, kept
+// as JSON so every frontend shares it.
+type RecentROMs struct {
+	path string
+	ROMs []RecentROM
+}
+
+// DefaultRecentPath returns where the recent list is kept: gemu/recent.json
+// in the user's config directory.
+func DefaultRecentPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gemu", "recent.json"), nil
+}
+
+// LoadRecentROMs reads the recent list at path. A missing file is an empty
+// list.
+func LoadRecentROMs(path string) (*RecentROMs, error) {
+	r := &RecentROMs{path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &r.ROMs); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Add moves the ROM at path to the top of the list, or adds it there.
+// An empty title is replaced with the file name.
+func (r *RecentROMs) Add(path, title string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	r.ROMs = slices.DeleteFunc(r.ROMs, func(rom RecentROM) bool { return rom.Path == path })
+	r.ROMs = slices.Insert(r.ROMs, 0, RecentROM{Path: path, Title: title, Played: time.Now()})
+	if len(r.ROMs) > maxRecentROMs {
+		r.ROMs = r.ROMs[:maxRecentROMs]
+	}
+}
+
+// Save writes the list back to the file it was loaded from.
+func (r *RecentROMs) Save() error {
+	b, err := json.MarshalIndent(r.ROMs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(b, '\n'), 0o644)
+}
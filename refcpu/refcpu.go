@@ -0,0 +1,500 @@
+// Package refcpu is a deliberately plain 6502 interpreter, written straight
+// from the datasheet for clarity rather than speed. It exists to check
+// package cpu against: both are run over the same random programs and
+// their state compared after every instruction. Like the 2A03 it has no
+// decimal mode.
+package refcpu
+
+// Mode is an addressing mode.
+type Mode int
+
+const (
+	Implied Mode = iota
+	Accumulator
+	Immediate
+	ZeroPage
+	ZeroPageX
+	ZeroPageY
+	Absolute
+	AbsoluteX
+	AbsoluteY
+	Indirect
+	IndirectX
+	IndirectY
+	Relative
+)
+
+// Length returns the instruction length in bytes for the mode.
+func (m Mode) Length() int {
+	switch m {
+	case Implied, Accumulator:
+		return 1
+	case Absolute, AbsoluteX, AbsoluteY, Indirect:
+		return 3
+	}
+	return 2
+}
+
+// Op is an entry of the opcode table.
+type Op struct {
+	Mnemonic string
+	Mode     Mode
+	// Cycles is the base cycle count. Reads that cross a page with an
+	// indexed mode take one more, and branches one more when taken and
+	// another when the target is on a different page.
+	Cycles int
+	// PageCross says whether crossing a page costs a cycle
+	PageCross bool
+}
+
+// Ops is the table of the 151 official opcodes.
+var Ops = map[uint8]Op{}
+
+func init() {
+	// the loads, arithmetic and logic share a layout across the modes
+	alu := func(name string, imm, zp, zpx, abs, abx, aby, izx, izy uint8) {
+		Ops[imm] = Op{name, Immediate, 2, false}
+		Ops[zp] = Op{name, ZeroPage, 3, false}
+		Ops[zpx] = Op{name, ZeroPageX, 4, false}
+		Ops[abs] = Op{name, Absolute, 4, false}
+		Ops[abx] = Op{name, AbsoluteX, 4, true}
+		Ops[aby] = Op{name, AbsoluteY, 4, true}
+		Ops[izx] = Op{name, IndirectX, 6, false}
+		Ops[izy] = Op{name, IndirectY, 5, true}
+	}
+	alu("ADC", 0x69, 0x65, 0x75, 0x6D, 0x7D, 0x79, 0x61, 0x71)
+	alu("AND", 0x29, 0x25, 0x35, 0x2D, 0x3D, 0x39, 0x21, 0x31)
+	alu("CMP", 0xC9, 0xC5, 0xD5, 0xCD, 0xDD, 0xD9, 0xC1, 0xD1)
+	alu("EOR", 0x49, 0x45, 0x55, 0x4D, 0x5D, 0x59, 0x41, 0x51)
+	alu("LDA", 0xA9, 0xA5, 0xB5, 0xAD, 0xBD, 0xB9, 0xA1, 0xB1)
+	alu("ORA", 0x09, 0x05, 0x15, 0x0D, 0x1D, 0x19, 0x01, 0x11)
+	alu("SBC", 0xE9, 0xE5, 0xF5, 0xED, 0xFD, 0xF9, 0xE1, 0xF1)
+
+	// shifts and read-modify-writes
+	rmw := func(name string, acc, zp, zpx, abs, abx uint8) {
+		if acc != 0 {
+			Ops[acc] = Op{name, Accumulator, 2, false}
+		}
+		Ops[zp] = Op{name, ZeroPage, 5, false}
+		Ops[zpx] = Op{name, ZeroPageX, 6, false}
+		Ops[abs] = Op{name, Absolute, 6, false}
+		Ops[abx] = Op{name, AbsoluteX, 7, false}
+	}
+	rmw("ASL", 0x0A, 0x06, 0x16, 0x0E, 0x1E)
+	rmw("LSR", 0x4A, 0x46, 0x56, 0x4E, 0x5E)
+	rmw("ROL", 0x2A, 0x26, 0x36, 0x2E, 0x3E)
+	rmw("ROR", 0x6A, 0x66, 0x76, 0x6E, 0x7E)
+	rmw("DEC", 0, 0xC6, 0xD6, 0xCE, 0xDE)
+	rmw("INC", 0, 0xE6, 0xF6, 0xEE, 0xFE)
+
+	for op, name := range map[uint8]string{
+		0x90: "BCC", 0xB0: "BCS", 0xF0: "BEQ", 0x30: "BMI",
+		0xD0: "BNE", 0x10: "BPL", 0x50: "BVC", 0x70: "BVS",
+	} {
+		Ops[op] = Op{name, Relative, 2, false}
+	}
+	for op, name := range map[uint8]string{
+		0x18: "CLC", 0xD8: "CLD", 0x58: "CLI", 0xB8: "CLV",
+		0x38: "SEC", 0xF8: "SED", 0x78: "SEI",
+		0xCA: "DEX", 0x88: "DEY", 0xE8: "INX", 0xC8: "INY",
+		0xAA: "TAX", 0xA8: "TAY", 0xBA: "TSX", 0x8A: "TXA", 0x9A: "TXS", 0x98: "TYA",
+		0xEA: "NOP",
+	} {
+		Ops[op] = Op{name, Implied, 2, false}
+	}
+
+	rest := []struct {
+		op     uint8
+		name   string
+		mode   Mode
+		cycles int
+		cross  bool
+	}{
+		{0x24, "BIT", ZeroPage, 3, false}, {0x2C, "BIT", Absolute, 4, false},
+		{0x00, "BRK", Implied, 7, false},
+		{0xE0, "CPX", Immediate, 2, false}, {0xE4, "CPX", ZeroPage, 3, false}, {0xEC, "CPX", Absolute, 4, false},
+		{0xC0, "CPY", Immediate, 2, false}, {0xC4, "CPY", ZeroPage, 3, false}, {0xCC, "CPY", Absolute, 4, false},
+		{0x4C, "JMP", Absolute, 3, false}, {0x6C, "JMP", Indirect, 5, false},
+		{0x20, "JSR", Absolute, 6, false},
+		{0xA2, "LDX", Immediate, 2, false}, {0xA6, "LDX", ZeroPage, 3, false}, {0xB6, "LDX", ZeroPageY, 4, false},
+		{0xAE, "LDX", Absolute, 4, false}, {0xBE, "LDX", AbsoluteY, 4, true},
+		{0xA0, "LDY", Immediate, 2, false}, {0xA4, "LDY", ZeroPage, 3, false}, {0xB4, "LDY", ZeroPageX, 4, false},
+		{0xAC, "LDY", Absolute, 4, false}, {0xBC, "LDY", AbsoluteX, 4, true},
+		{0x48, "PHA", Implied, 3, false}, {0x08, "PHP", Implied, 3, false},
+		{0x68, "PLA", Implied, 4, false}, {0x28, "PLP", Implied, 4, false},
+		{0x40, "RTI", Implied, 6, false}, {0x60, "RTS", Implied, 6, false},
+		{0x85, "STA", ZeroPage, 3, false}, {0x95, "STA", ZeroPageX, 4, false}, {0x8D, "STA", Absolute, 4, false},
+		{0x9D, "STA", AbsoluteX, 5, false}, {0x99, "STA", AbsoluteY, 5, false},
+		{0x81, "STA", IndirectX, 6, false}, {0x91, "STA", IndirectY, 6, false},
+		{0x86, "STX", ZeroPage, 3, false}, {0x96, "STX", ZeroPageY, 4, false}, {0x8E, "STX", Absolute, 4, false},
+		{0x84, "STY", ZeroPage, 3, false}, {0x94, "STY", ZeroPageX, 4, false}, {0x8C, "STY", Absolute, 4, false},
+	}
+	for _, r := range rest {
+		Ops[r.op] = Op{r.name, r.mode, r.cycles, r.cross}
+	}
+}
+
+// status flags
+const (
+	C = 1 << iota
+	Z
+	I
+	D
+	B
+	U
+	V
+	N
+)
+
+// State is the register file.
+type State struct {
+	PC         uint16
+	A, X, Y, P uint8
+	SP         uint8
+}
+
+// CPU is the interpreter and its flat 64KB of memory.
+type CPU struct {
+	State
+	Mem [0x10000]uint8
+	// Writes lists the addresses written by the last Step
+	Writes []uint16
+}
+
+func (c *CPU) read(a uint16) uint8 {
+	return c.Mem[a]
+}
+
+func (c *CPU) write(a uint16, v uint8) {
+	c.Mem[a] = v
+	c.Writes = append(c.Writes, a)
+}
+
+func (c *CPU) read16(a uint16) uint16 {
+	return uint16(c.read(a)) | uint16(c.read(a+1))<<8
+}
+
+// read16zp reads a pointer from the zero page, wrapping within it.
+func (c *CPU) read16zp(a uint8) uint16 {
+	return uint16(c.read(uint16(a))) | uint16(c.read(uint16(a+1)))<<8
+}
+
+func (c *CPU) push(v uint8) {
+	c.write(0x100|uint16(c.SP), v)
+	c.SP--
+}
+
+func (c *CPU) pull() uint8 {
+	c.SP++
+	return c.read(0x100 | uint16(c.SP))
+}
+
+func (c *CPU) setZN(v uint8) {
+	c.flag(Z, v == 0)
+	c.flag(N, v&0x80 != 0)
+}
+
+func (c *CPU) flag(f uint8, on bool) {
+	if on {
+		c.P |= f
+	} else {
+		c.P &^= f
+	}
+}
+
+func pageCrossed(a, b uint16) bool {
+	return a&0xFF00 != b&0xFF00
+}
+
+// address works out the operand address for the mode.
+func (c *CPU) address(mode Mode) uint16 {
+	pc := c.PC + 1
+	switch mode {
+	case Immediate, Relative:
+		return pc
+	case ZeroPage:
+		return uint16(c.read(pc))
+	case ZeroPageX:
+		return uint16(c.read(pc) + c.X)
+	case ZeroPageY:
+		return uint16(c.read(pc) + c.Y)
+	case Absolute:
+		return c.read16(pc)
+	case AbsoluteX:
+		return c.read16(pc) + uint16(c.X)
+	case AbsoluteY:
+		return c.read16(pc) + uint16(c.Y)
+	case Indirect:
+		// the pointer's high byte comes from the same page as its low byte
+		ptr := c.read16(pc)
+		hi := ptr&0xFF00 | uint16(uint8(ptr)+1)
+		return uint16(c.read(ptr)) | uint16(c.read(hi))<<8
+	case IndirectX:
+		return c.read16zp(c.read(pc) + c.X)
+	case IndirectY:
+		return c.read16zp(c.read(pc)) + uint16(c.Y)
+	}
+	return 0
+}
+
+// Cycles returns the cycles the instruction at s.PC takes from state s,
+// reading memory through read, or false if the opcode isn't an official
+// one. It is the timing Step uses, for checking another core's counts.
+func Cycles(s State, read func(uint16) uint8) (int, bool) {
+	op, ok := Ops[read(s.PC)]
+	if !ok {
+		return 0, false
+	}
+	cycles := op.Cycles
+	operand := uint16(read(s.PC+1)) | uint16(read(s.PC+2))<<8
+	crossed := false
+	switch op.Mode {
+	case AbsoluteX:
+		crossed = pageCrossed(operand, operand+uint16(s.X))
+	case AbsoluteY:
+		crossed = pageCrossed(operand, operand+uint16(s.Y))
+	case IndirectY:
+		zp := uint8(operand)
+		base := uint16(read(uint16(zp))) | uint16(read(uint16(zp+1)))<<8
+		crossed = pageCrossed(base, base+uint16(s.Y))
+	case Relative:
+		if branchTaken(op.Mnemonic, s.P) {
+			next := s.PC + 2
+			cycles++
+			if pageCrossed(next, next+uint16(int8(operand))) {
+				cycles++
+			}
+		}
+	}
+	if crossed && op.PageCross {
+		cycles++
+	}
+	return cycles, true
+}
+
+// branchTaken reports whether the branch mnemonic is taken with status p.
+func branchTaken(mnemonic string, p uint8) bool {
+	switch mnemonic {
+	case "BCC":
+		return p&C == 0
+	case "BCS":
+		return p&C != 0
+	case "BNE":
+		return p&Z == 0
+	case "BEQ":
+		return p&Z != 0
+	case "BPL":
+		return p&N == 0
+	case "BMI":
+		return p&N != 0
+	case "BVC":
+		return p&V == 0
+	case "BVS":
+		return p&V != 0
+	}
+	return false
+}
+
+// Step executes the instruction at PC and returns the cycles it took, or
+// false without doing anything if the opcode isn't an official one.
+func (c *CPU) Step() (int, bool) {
+	op, ok := Ops[c.read(c.PC)]
+	if !ok {
+		return 0, false
+	}
+	c.Writes = c.Writes[:0]
+	cycles, _ := Cycles(c.State, c.read)
+	addr := c.address(op.Mode)
+	next := c.PC + uint16(op.Mode.Length())
+
+	// operand value for the modes that read memory or the accumulator
+	load := func() uint8 {
+		if op.Mode == Accumulator {
+			return c.A
+		}
+		return c.read(addr)
+	}
+	store := func(v uint8) {
+		if op.Mode == Accumulator {
+			c.A = v
+		} else {
+			c.write(addr, v)
+		}
+	}
+	compare := func(reg uint8) {
+		m := load()
+		c.flag(C, reg >= m)
+		c.setZN(reg - m)
+	}
+	add := func(m uint8) {
+		sum := uint16(c.A) + uint16(m) + uint16(c.P&C)
+		r := uint8(sum)
+		c.flag(C, sum > 0xFF)
+		c.flag(V, (c.A^r)&(m^r)&0x80 != 0)
+		c.A = r
+		c.setZN(r)
+	}
+	switch op.Mnemonic {
+	case "ADC":
+		add(load())
+	case "SBC":
+		add(^load())
+	case "AND":
+		c.A &= load()
+		c.setZN(c.A)
+	case "ORA":
+		c.A |= load()
+		c.setZN(c.A)
+	case "EOR":
+		c.A ^= load()
+		c.setZN(c.A)
+	case "CMP":
+		compare(c.A)
+	case "CPX":
+		compare(c.X)
+	case "CPY":
+		compare(c.Y)
+	case "BIT":
+		m := load()
+		c.flag(Z, c.A&m == 0)
+		c.flag(N, m&0x80 != 0)
+		c.flag(V, m&0x40 != 0)
+
+	case "ASL":
+		m := load()
+		c.flag(C, m&0x80 != 0)
+		m <<= 1
+		store(m)
+		c.setZN(m)
+	case "LSR":
+		m := load()
+		c.flag(C, m&1 != 0)
+		m >>= 1
+		store(m)
+		c.setZN(m)
+	case "ROL":
+		m := load()
+		carry := c.P & C
+		c.flag(C, m&0x80 != 0)
+		m = m<<1 | carry
+		store(m)
+		c.setZN(m)
+	case "ROR":
+		m := load()
+		carry := c.P & C
+		c.flag(C, m&1 != 0)
+		m = m>>1 | carry<<7
+		store(m)
+		c.setZN(m)
+	case "INC":
+		m := load() + 1
+		store(m)
+		c.setZN(m)
+	case "DEC":
+		m := load() - 1
+		store(m)
+		c.setZN(m)
+
+	case "LDA":
+		c.A = load()
+		c.setZN(c.A)
+	case "LDX":
+		c.X = load()
+		c.setZN(c.X)
+	case "LDY":
+		c.Y = load()
+		c.setZN(c.Y)
+	case "STA":
+		c.write(addr, c.A)
+	case "STX":
+		c.write(addr, c.X)
+	case "STY":
+		c.write(addr, c.Y)
+
+	case "TAX":
+		c.X = c.A
+		c.setZN(c.X)
+	case "TAY":
+		c.Y = c.A
+		c.setZN(c.Y)
+	case "TXA":
+		c.A = c.X
+		c.setZN(c.A)
+	case "TYA":
+		c.A = c.Y
+		c.setZN(c.A)
+	case "TSX":
+		c.X = c.SP
+		c.setZN(c.X)
+	case "TXS":
+		c.SP = c.X
+	case "INX":
+		c.X++
+		c.setZN(c.X)
+	case "INY":
+		c.Y++
+		c.setZN(c.Y)
+	case "DEX":
+		c.X--
+		c.setZN(c.X)
+	case "DEY":
+		c.Y--
+		c.setZN(c.Y)
+
+	case "CLC":
+		c.flag(C, false)
+	case "SEC":
+		c.flag(C, true)
+	case "CLI":
+		c.flag(I, false)
+	case "SEI":
+		c.flag(I, true)
+	case "CLD":
+		c.flag(D, false)
+	case "SED":
+		c.flag(D, true)
+	case "CLV":
+		c.flag(V, false)
+
+	case "BCC", "BCS", "BNE", "BEQ", "BPL", "BMI", "BVC", "BVS":
+		if branchTaken(op.Mnemonic, c.P) {
+			next += uint16(int8(c.read(addr)))
+		}
+
+	case "JMP":
+		next = addr
+	case "JSR":
+		ret := next - 1
+		c.push(uint8(ret >> 8))
+		c.push(uint8(ret))
+		next = addr
+	case "RTS":
+		lo := c.pull()
+		next = uint16(c.pull())<<8 | uint16(lo) + 1
+	case "BRK":
+		ret := c.PC + 2
+		c.push(uint8(ret >> 8))
+		c.push(uint8(ret))
+		c.push(c.P | B | U)
+		c.flag(I, true)
+		next = c.read16(0xFFFE)
+	case "RTI":
+		c.P = c.pull()&^B | U
+		lo := c.pull()
+		next = uint16(c.pull())<<8 | uint16(lo)
+
+	case "PHA":
+		c.push(c.A)
+	case "PHP":
+		c.push(c.P | B | U)
+	case "PLA":
+		c.A = c.pull()
+		c.setZN(c.A)
+	case "PLP":
+		c.P = c.pull()&^B | U
+	case "NOP":
+	}
+
+	c.PC = next
+	return cycles, true
+}
@@ -0,0 +1,85 @@
+package refcpu
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOfficialOpcodes(t *testing.T) {
+	if len(Ops) != 151 {
+		t.Errorf("%d opcodes, want 151", len(Ops))
+	}
+	c := &CPU{}
+	c.PC = 0x8000
+	c.Mem[0x8000] = 0x04 // unofficial NOP $zp
+	if _, ok := c.Step(); ok || c.PC != 0x8000 {
+		t.Errorf("stepped an unofficial opcode to $%04X", c.PC)
+	}
+}
+
+func TestStep(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		code   []byte
+		before State
+		after  State
+		cycles int
+	}{
+		{"ADC overflow", []byte{0x69, 0x50}, State{A: 0x50, P: U},
+			State{A: 0xA0, P: U | V | N}, 2},
+		{"ADC carry", []byte{0x69, 0x01}, State{A: 0xFF, P: U | C},
+			State{A: 0x01, P: U | C}, 2},
+		{"SBC borrow", []byte{0xE9, 0x01}, State{A: 0x00, P: U | C},
+			State{A: 0xFF, P: U | N}, 2},
+		{"CMP equal", []byte{0xC9, 0x42}, State{A: 0x42, P: U},
+			State{A: 0x42, P: U | Z | C}, 2},
+		{"LDA abs,X crossing", []byte{0xBD, 0xFF, 0x02}, State{X: 0x01, P: U},
+			State{A: 0x77, X: 0x01, P: U}, 5},
+		// the pointer's high byte wraps within $02FF's page, to $0200
+		{"JMP indirect page end", []byte{0x6C, 0xFF, 0x02}, State{P: U},
+			State{PC: 0x3412, P: U}, 5},
+		{"BNE taken", []byte{0xD0, 0x10}, State{P: U},
+			State{PC: 0x8012, P: U}, 3},
+		{"BNE taken crossing", []byte{0xD0, 0x80}, State{P: U},
+			State{PC: 0x7F82, P: U}, 4},
+		{"BNE not taken", []byte{0xD0, 0x10}, State{P: U | Z},
+			State{PC: 0x8002, P: U | Z}, 2},
+		{"JSR", []byte{0x20, 0x00, 0x90}, State{SP: 0xFD, P: U},
+			State{PC: 0x9000, SP: 0xFB, P: U}, 6},
+	} {
+		c := &CPU{State: tc.before}
+		c.PC = 0x8000
+		copy(c.Mem[0x8000:], tc.code)
+		c.Mem[0x0300] = 0x77
+		c.Mem[0x02FF], c.Mem[0x0200] = 0x12, 0x34
+		if tc.after.PC == 0 {
+			tc.after.PC = 0x8000 + uint16(len(tc.code))
+		}
+		cycles, ok := c.Step()
+		if !ok || c.State != tc.after || cycles != tc.cycles {
+			t.Errorf("%s: %+v in %d cycles, want %+v in %d", tc.name, c.State, cycles, tc.after, tc.cycles)
+		}
+	}
+}
+
+func TestBRKAndRTI(t *testing.T) {
+	c := &CPU{}
+	c.PC, c.SP, c.P = 0x8000, 0xFD, U|C
+	c.Mem[0x8000] = 0x00
+	c.Mem[0xFFFE], c.Mem[0xFFFF] = 0x00, 0x90
+	c.Mem[0x9000] = 0x40
+
+	if cycles, _ := c.Step(); cycles != 7 || c.PC != 0x9000 || c.P != U|C|I {
+		t.Fatalf("BRK went to $%04X with P=%02X in %d cycles", c.PC, c.P, cycles)
+	}
+	// BRK skips the byte after it, and pushes B
+	if got := c.Mem[0x01FB:0x01FE]; !slices.Equal(got, []byte{U | C | B, 0x02, 0x80}) {
+		t.Errorf("BRK pushed % X", got)
+	}
+	if want := []uint16{0x01FD, 0x01FC, 0x01FB}; !slices.Equal(c.Writes, want) {
+		t.Errorf("BRK wrote %04X, want %04X", c.Writes, want)
+	}
+	if cycles, _ := c.Step(); cycles != 6 || c.PC != 0x8002 || c.P != U|C || c.SP != 0xFD {
+		t.Errorf("RTI went to $%04X with P=%02X SP=%02X in %d cycles", c.PC, c.P, c.SP, cycles)
+	}
+}
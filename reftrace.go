@@ -0,0 +1,271 @@
+package gemu
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrTraceLine = errors.New("unrecognised trace line")
+
+// TraceRecord is one instruction of a trace, parsed into the fields that
+// can be compared between emulators whatever their log looks like.
+type TraceRecord struct {
+	PC       uint16
+	Bytes    []byte
+	Mnemonic string
+	// Disasm is the instruction as the log shows it, e.g. "LDA $10 = 00"
+	Disasm  string
+	A, X, Y uint8
+	P, SP   uint8
+	// Cycles is the cpu cycle count, or -1 if the format has none
+	Cycles int64
+	// Scanline and Dot are the ppu position, or -1 if the format has none
+	Scanline, Dot int
+}
+
+// TraceFormat reads and writes the lines of an emulator's trace log.
+type TraceFormat interface {
+	Parse(line string) (TraceRecord, error)
+	Format(rec TraceRecord) string
+}
+
+var traceFormats = map[string]TraceFormat{}
+
+// RegisterTraceFormat makes a trace format available by name to
+// TraceFormatByName, replacing any registered under the same name.
+func RegisterTraceFormat(name string, f TraceFormat) {
+	traceFormats[name] = f
+}
+
+// TraceFormatByName returns the trace format registered as name.
+func TraceFormatByName(name string) (TraceFormat, bool) {
+	f, ok := traceFormats[name]
+	return f, ok
+}
+
+// TraceFormats returns the names of the registered trace formats, sorted.
+func TraceFormats() []string {
+	return slices.Sorted(maps.Keys(traceFormats))
+}
+
+// nestestFormat is the layout of nestest.log and our own trace, where CYC
+// is the cpu cycle count and PPU the scanline and dot.
+var nestestFormat = columnFormat{
+	sp:     []string{"SP:"},
+	cycles: []string{"CYC:"},
+	ppu:    true,
+	write: func(r TraceRecord) string {
+		return fmt.Sprintf("%04X  %-8s  %-32sA:%02X X:%02X Y:%02X P:%02X SP:%02X PPU:%3d,%3d CYC:%d",
+			r.PC, fmt.Sprintf("% X", r.Bytes), r.Disasm, r.A, r.X, r.Y, r.P, r.SP,
+			max(r.Scanline, 0), max(r.Dot, 0), max(r.Cycles, 0))
+	},
+}
+
+func init() {
+	RegisterTraceFormat("nestest", nestestFormat)
+	// Nintendulator's CYC is the ppu dot, and it has no cycle count
+	RegisterTraceFormat("nintendulator", columnFormat{
+		sp:       []string{"SP:"},
+		dot:      []string{"CYC:"},
+		scanline: []string{"SL:"},
+		write: func(r TraceRecord) string {
+			return fmt.Sprintf("%04X  %-8s  %-32sA:%02X X:%02X Y:%02X P:%02X SP:%02X CYC:%3d SL:%d",
+				r.PC, fmt.Sprintf("% X", r.Bytes), r.Disasm, r.A, r.X, r.Y, r.P, r.SP,
+				max(r.Dot, 0), max(r.Scanline, 0))
+		},
+	})
+	// Mesen writes opcode bytes as $4C; Mesen 2 has S:, letter flags and
+	// V:/H: for the ppu position
+	RegisterTraceFormat("mesen", columnFormat{
+		sp:       []string{"SP:", "S:"},
+		cycles:   []string{"CPU Cycle:", "Cy:"},
+		dot:      []string{"CYC:", "H:"},
+		scanline: []string{"SL:", "V:"},
+		write: func(r TraceRecord) string {
+			var b []string
+			for _, v := range r.Bytes {
+				b = append(b, fmt.Sprintf("$%02X", v))
+			}
+			return fmt.Sprintf("%04X  %-12s  %-32sA:%02X X:%02X Y:%02X P:%02X SP:%02X CYC:%3d SL:%-3d CPU Cycle:%d",
+				r.PC, strings.Join(b, " "), r.Disasm, r.A, r.X, r.Y, r.P, r.SP,
+				max(r.Dot, 0), max(r.Scanline, 0), max(r.Cycles, 0))
+		},
+	})
+}
+
+// columnFormat is the usual trace layout: the pc, the opcode bytes and the
+// disassembly, then registers as KEY:value pairs. Each field lists the keys
+// it may appear under.
+type columnFormat struct {
+	sp            []string
+	cycles        []string
+	dot, scanline []string
+	// ppu is a "PPU: scanline, dot" column
+	ppu   bool
+	write func(TraceRecord) string
+}
+
+func (f columnFormat) Format(rec TraceRecord) string {
+	return f.write(rec)
+}
+
+func (f columnFormat) Parse(line string) (TraceRecord, error) {
+	rec := TraceRecord{Cycles: -1, Scanline: -1, Dot: -1}
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return rec, fmt.Errorf("%w: %q", ErrTraceLine, line)
+	}
+	pc, err := strconv.ParseUint(fields[0], 16, 16)
+	if err != nil {
+		return rec, fmt.Errorf("%w: no pc in %q", ErrTraceLine, line)
+	}
+	rec.PC = uint16(pc)
+
+	i := 1
+	for ; i < len(fields) && len(rec.Bytes) < 3; i++ {
+		tok := strings.TrimPrefix(fields[i], "$")
+		if len(tok) != 2 {
+			break
+		}
+		b, err := strconv.ParseUint(tok, 16, 8)
+		if err != nil {
+			break
+		}
+		rec.Bytes = append(rec.Bytes, uint8(b))
+	}
+	if i < len(fields) {
+		rec.Mnemonic = strings.ToUpper(strings.TrimPrefix(fields[i], "*"))
+		// the disassembly runs from the mnemonic to the registers
+		start := strings.Index(line, " "+fields[i]) + 1
+		end := strings.LastIndex(line, " A:")
+		if start > 0 && end > start {
+			rec.Disasm = strings.TrimSpace(line[start:end])
+		}
+	}
+
+	regs := []struct {
+		keys []string
+		v    *uint8
+	}{
+		{[]string{"A:"}, &rec.A},
+		{[]string{"X:"}, &rec.X},
+		{[]string{"Y:"}, &rec.Y},
+		{f.sp, &rec.SP},
+	}
+	for _, r := range regs {
+		s, ok := lookupKey(line, r.keys...)
+		if !ok {
+			return rec, fmt.Errorf("%w: no %s in %q", ErrTraceLine, r.keys[0], line)
+		}
+		v, err := strconv.ParseUint(s, 16, 8)
+		if err != nil {
+			return rec, fmt.Errorf("%w: bad %s in %q", ErrTraceLine, r.keys[0], line)
+		}
+		*r.v = uint8(v)
+	}
+	p, ok := lookupKey(line, "P:")
+	if !ok {
+		return rec, fmt.Errorf("%w: no P: in %q", ErrTraceLine, line)
+	}
+	if rec.P, ok = parseStatus(p); !ok {
+		return rec, fmt.Errorf("%w: bad P: in %q", ErrTraceLine, line)
+	}
+
+	if s, ok := lookupKey(line, f.cycles...); ok {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			rec.Cycles = n
+		}
+	}
+	rec.Scanline = lookupInt(line, f.scanline...)
+	rec.Dot = lookupInt(line, f.dot...)
+	if i := strings.LastIndex(line, " PPU:"); f.ppu && i >= 0 {
+		sl, dot, _ := strings.Cut(line[i+len(" PPU:"):], ",")
+		dot, _, _ = strings.Cut(strings.TrimLeft(dot, " "), " ")
+		if n, err := strconv.Atoi(strings.TrimSpace(sl)); err == nil {
+			rec.Scanline = n
+		}
+		if n, err := strconv.Atoi(dot); err == nil {
+			rec.Dot = n
+		}
+	}
+	return rec, nil
+}
+
+// lookupKey returns the value after the last of keys found in line, where
+// the key starts a column. Registers follow the disassembly, so the last
+// occurrence is the register even if the operand happens to look like one.
+func lookupKey(line string, keys ...string) (string, bool) {
+	for _, key := range keys {
+		i := strings.LastIndex(line, " "+key)
+		if i < 0 {
+			continue
+		}
+		v := strings.TrimLeft(line[i+1+len(key):], " ")
+		v, _, _ = strings.Cut(v, " ")
+		return v, v != ""
+	}
+	return "", false
+}
+
+// lookupInt returns the decimal value after the last of keys, or -1.
+func lookupInt(line string, keys ...string) int {
+	s, ok := lookupKey(line, keys...)
+	if !ok {
+		return -1
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// parseStatus reads the status register as hex, or as Mesen's flag letters
+// (NV--DIZC), upper case for a set flag.
+func parseStatus(s string) (uint8, bool) {
+	if len(s) == 8 {
+		var p uint8
+		for i, c := range s {
+			if c >= 'A' && c <= 'Z' {
+				p |= 0x80 >> i
+			}
+		}
+		return p, true
+	}
+	v, err := strconv.ParseUint(s, 16, 8)
+	return uint8(v), err == nil
+}
+
+// compareRecords returns the fields that differ between got and want,
+// skipping cycles or the ppu position if either side has none. The field names match the keys
+// of our own trace, so Diffs can point into MismatchError.Got.
+func compareRecords(got, want TraceRecord) []FieldDiff {
+	var diffs []FieldDiff
+	add := func(field, format string, g, w any) {
+		gs, ws := fmt.Sprintf(format, g), fmt.Sprintf(format, w)
+		if gs != ws {
+			diffs = append(diffs, FieldDiff{Field: field, Got: gs, Want: ws})
+		}
+	}
+	add("", "%04X", got.PC, want.PC)
+	add(FieldBytes, "% X", got.Bytes, want.Bytes)
+	add(FieldMnemonic, "%s", got.Mnemonic, want.Mnemonic)
+	add("A:", "%02X", got.A, want.A)
+	add("X:", "%02X", got.X, want.X)
+	add("Y:", "%02X", got.Y, want.Y)
+	add("P:", "%02X", got.P, want.P)
+	add("SP:", "%02X", got.SP, want.SP)
+	if got.Cycles >= 0 && want.Cycles >= 0 {
+		add("CYC:", "%d", got.Cycles, want.Cycles)
+	}
+	if got.Scanline >= 0 && got.Dot >= 0 && want.Scanline >= 0 && want.Dot >= 0 {
+		// as our PPU: column shows it
+		g, w := fmt.Sprintf("%d,%3d", got.Scanline, got.Dot), fmt.Sprintf("%d,%3d", want.Scanline, want.Dot)
+		add("PPU:", "%s", g, w)
+	}
+	return diffs
+}
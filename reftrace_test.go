@@ -0,0 +1,91 @@
+package gemu
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseTraceFormats(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		line   string
+		want   TraceRecord
+	}{
+		{"nestest",
+			"C72D  EA        NOP                             A:00 X:00 Y:00 P:24 SP:FB PPU:  0, 51 CYC:17",
+			TraceRecord{PC: 0xC72D, Bytes: []byte{0xEA}, Mnemonic: "NOP", Disasm: "NOP",
+				P: 0x24, SP: 0xFB, Cycles: 17, Scanline: 0, Dot: 51}},
+		// an unofficial opcode, and an operand that looks like a register
+		{"nestest",
+			"DBB5  07 A9    *SLO $A9 = 00                    A:F0 X:09 Y:88 P:A5 SP:F9 PPU:241,330 CYC:26458",
+			TraceRecord{PC: 0xDBB5, Bytes: []byte{0x07, 0xA9}, Mnemonic: "SLO", Disasm: "*SLO $A9 = 00",
+				A: 0xF0, X: 0x09, Y: 0x88, P: 0xA5, SP: 0xF9, Cycles: 26458, Scanline: 241, Dot: 330}},
+		// CYC is the dot, and there's no cycle count
+		{"nintendulator",
+			"C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD CYC:  0 SL:241",
+			TraceRecord{PC: 0xC000, Bytes: []byte{0x4C, 0xF5, 0xC5}, Mnemonic: "JMP", Disasm: "JMP $C5F5",
+				P: 0x24, SP: 0xFD, Cycles: -1, Scanline: 241, Dot: 0}},
+		{"mesen",
+			"C5F5  $A2 $00     LDX #$00                        A:00 X:00 Y:00 P:24 SP:FD CYC:  9 SL:0   CPU Cycle:10",
+			TraceRecord{PC: 0xC5F5, Bytes: []byte{0xA2, 0x00}, Mnemonic: "LDX", Disasm: "LDX #$00",
+				P: 0x24, SP: 0xFD, Cycles: 10, Scanline: 0, Dot: 9}},
+		// Mesen 2
+		{"mesen",
+			"C5F7  86 00     STX $00 = $00                   A:00 X:00 Y:00 S:FD P:nvUbdIzc V:0   H:15  Cy:12",
+			TraceRecord{PC: 0xC5F7, Bytes: []byte{0x86, 0x00}, Mnemonic: "STX", Disasm: "STX $00 = $00",
+				P: 0x24, SP: 0xFD, Cycles: 12, Scanline: 0, Dot: 15}},
+	} {
+		f, ok := TraceFormatByName(tc.format)
+		if !ok {
+			t.Fatalf("no %s format", tc.format)
+		}
+		got, err := f.Parse(tc.line)
+		if err != nil {
+			t.Errorf("%s %q: %v", tc.format, tc.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %q:\nparsed %+v\nwant   %+v", tc.format, tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestParseTraceErrors(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"C000",
+		"ZZZZ  4C F5 C5  JMP $C5F5  A:00 X:00 Y:00 P:24 SP:FD",
+		// no SP
+		"C000  4C F5 C5  JMP $C5F5  A:00 X:00 Y:00 P:24",
+		"C000  4C F5 C5  JMP $C5F5  A:00 X:00 Y:00 P:2G SP:FD",
+	} {
+		if _, err := nestestFormat.Parse(line); !errors.Is(err, ErrTraceLine) {
+			t.Errorf("%q: error %v, want ErrTraceLine", line, err)
+		}
+	}
+}
+
+func TestCompareRecords(t *testing.T) {
+	got := TraceRecord{PC: 0xC000, Bytes: []byte{0x4C}, Mnemonic: "JMP", Cycles: 7, Scanline: 0, Dot: 21}
+	for _, tc := range []struct {
+		name string
+		want TraceRecord
+		diff []FieldDiff
+	}{
+		{"same", got, nil},
+		{"dot", TraceRecord{PC: 0xC000, Bytes: []byte{0x4C}, Mnemonic: "JMP", Cycles: 7, Scanline: 0, Dot: 24},
+			[]FieldDiff{{"PPU:", "0, 21", "0, 24"}}},
+		{"scanline", TraceRecord{PC: 0xC000, Bytes: []byte{0x4C}, Mnemonic: "JMP", Cycles: 7, Scanline: 241, Dot: 21},
+			[]FieldDiff{{"PPU:", "0, 21", "241, 21"}}},
+		// a reference without a ppu position or cycle count
+		{"none", TraceRecord{PC: 0xC000, Bytes: []byte{0x4C}, Mnemonic: "JMP", Cycles: -1, Scanline: -1, Dot: -1}, nil},
+		{"mnemonic", TraceRecord{PC: 0xC000, Bytes: []byte{0x4C}, Mnemonic: "JSR", Cycles: -1, Scanline: -1, Dot: -1},
+			[]FieldDiff{{FieldMnemonic, "JMP", "JSR"}}},
+	} {
+		if diff := compareRecords(got, tc.want); !slices.Equal(diff, tc.diff) {
+			t.Errorf("%s: diffs %v, want %v", tc.name, diff, tc.diff)
+		}
+	}
+}
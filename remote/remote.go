@@ -0,0 +1,389 @@
+// Package remote serves an HTTP API to drive a running emulator from
+// external tools and test scripts.
+//
+//	GET  /status               run state, frame, instruction count and pc
+//	POST /rom                  {"path": "game.nes"} loads a ROM, or
+//	                           {"recent": 1} the first of the recent list
+//	GET  /recent               recently played ROMs, most recent first
+//	POST /pause, /resume       stop and restart emulation
+//	POST /reset                press reset
+//	POST /power                switch off and on, reloading the ROM
+//	GET  /memory?addr=&len=    reads cpu memory as raw bytes, without
+//	                           the side effects of a cpu read
+//	PUT  /memory?addr=         writes the request body to cpu memory
+//	POST /input                {"port": 0, "buttons": 9} sets the buttons
+//	                           held on a controller port's device (port
+//	                           2 is the expansion port), and "position":
+//	                           0-1 its knob, e.g. a paddle's
+//	GET  /screenshot           the current frame as a PNG
+//	POST /screenshot           saves the frame in the game's screenshot
+//	                           folder, returning {"path": "..."}
+//	POST /message              {"text": "..."} shows a message on screen
+//	PUT  /overclock            {"lines": n} sets the extra cpu-only
+//	                           scanlines after vblank, kept for the game
+//
+// Addresses are decimal or 0x prefixed hex.
+//
+// There are no endpoints to save and load state yet. The emulator can't
+// snapshot the PPU, the mapper registers or the events pending on the
+// clock, and a state missing any of them wouldn't resume where it was
+// saved. They will be /state endpoints once it can.
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goldmane/gemu"
+)
+
+// Server runs an emulator and serves the API for it. The emulator must only
+// be used through the server once Run has been called.
+type Server struct {
+	mu     sync.Mutex
+	emu    *gemu.Emulator
+	loaded bool
+	paused bool
+	// error that stopped emulation, cleared by loading a ROM
+	fault error
+	// list loaded ROMs are added to, if any
+	recent *gemu.RecentROMs
+	dirs   gemu.GameDirs
+
+	mux *http.ServeMux
+}
+
+// NewServer returns a server for emu. loaded says whether a ROM is already
+// in; emulation doesn't start until one is.
+func NewServer(emu *gemu.Emulator, loaded bool) *Server {
+	s := &Server{emu: emu, loaded: loaded, mux: http.NewServeMux()}
+	s.dirs, _ = gemu.DefaultGameDirs()
+	s.mux.HandleFunc("GET /status", s.status)
+	s.mux.HandleFunc("POST /rom", s.loadROM)
+	s.mux.HandleFunc("GET /recent", s.recentROMs)
+	s.mux.HandleFunc("POST /pause", s.setPaused(true))
+	s.mux.HandleFunc("POST /resume", s.setPaused(false))
+	s.mux.HandleFunc("POST /reset", s.reset(false))
+	s.mux.HandleFunc("POST /power", s.reset(true))
+	s.mux.HandleFunc("GET /memory", s.readMemory)
+	s.mux.HandleFunc("PUT /memory", s.writeMemory)
+	s.mux.HandleFunc("POST /input", s.setInput)
+	s.mux.HandleFunc("GET /screenshot", s.screenshot)
+	s.mux.HandleFunc("POST /screenshot", s.saveScreenshot)
+	s.mux.HandleFunc("POST /message", s.showMessage)
+	s.mux.HandleFunc("PUT /overclock", s.setOverclock)
+	return s
+}
+
+// SetRecent keeps r up to date with the ROMs loaded through the API, and
+// lets them be loaded again by their place in it.
+func (s *Server) SetRecent(r *gemu.RecentROMs) {
+	s.mu.Lock()
+	s.recent = r
+	s.mu.Unlock()
+}
+
+// SetGameDirs sets where files for the loaded game are saved, e.g. by
+// POST /screenshot. It defaults to gemu.DefaultGameDirs.
+func (s *Server) SetGameDirs(d gemu.GameDirs) {
+	s.mu.Lock()
+	s.dirs = d
+	s.mu.Unlock()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Run emulates frames at the region's frame rate until ctx is cancelled.
+// An emulation error pauses the server and is reported by /status.
+func (s *Server) Run(ctx context.Context) error {
+	s.mu.Lock()
+	rate := s.emu.Region().FrameRate()
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		if s.loaded && !s.paused && s.fault == nil {
+			if err := s.emu.RunFrame(); err != nil {
+				s.fault = err
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+type status struct {
+	Loaded bool   `json:"loaded"`
+	Paused bool   `json:"paused"`
+	Error  string `json:"error,omitempty"`
+	// diagnostics bundle written for the error, if any
+	Bundle  string `json:"bundle,omitempty"`
+	Frame   uint64 `json:"frame"`
+	Counter uint64 `json:"instructions"`
+	PC      uint16 `json:"pc"`
+	Region  string `json:"region"`
+	// extra cpu-only scanlines run after vblank
+	Overclock int `json:"overclock"`
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	st := status{
+		Loaded:    s.loaded,
+		Paused:    s.paused,
+		Frame:     s.emu.Frame(),
+		Counter:   s.emu.Counter(),
+		PC:        s.emu.CPU().GetPC(),
+		Region:    s.emu.Region().Name,
+		Overclock: s.emu.Overclock(),
+	}
+	if s.fault != nil {
+		st.Error = s.fault.Error()
+		if d := s.emu.Diagnostics(); d != nil {
+			st.Bundle = d.Bundle()
+		}
+	}
+	s.mu.Unlock()
+	writeJSON(w, st)
+}
+
+func (s *Server) loadROM(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path   string `json:"path"`
+		Recent int    `json:"recent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Path == "") == (req.Recent == 0) {
+		http.Error(w, "body must be {\"path\": \"...\"} or {\"recent\": n}", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Recent != 0 {
+		if s.recent == nil || req.Recent < 1 || req.Recent > len(s.recent.ROMs) {
+			http.Error(w, "no such recent ROM", http.StatusNotFound)
+			return
+		}
+		req.Path = s.recent.ROMs[req.Recent-1].Path
+	}
+	if err := s.emu.LoadROM(req.Path); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	s.loaded = true
+	s.fault = nil
+	s.emu.ShowMessage("Loaded %s", filepath.Base(req.Path))
+	if s.recent != nil {
+		game, _ := s.emu.Game()
+		s.recent.Add(req.Path, game.Name)
+		// the ROM is in either way
+		s.recent.Save()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) recentROMs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	roms := []gemu.RecentROM{}
+	if s.recent != nil {
+		roms = append(roms, s.recent.ROMs...)
+	}
+	s.mu.Unlock()
+	writeJSON(w, roms)
+}
+
+func (s *Server) setPaused(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.paused = paused
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// reset presses reset or power cycles. Either clears a fault, since the
+// cpu starts over.
+func (s *Server) reset(power bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.loaded {
+			http.Error(w, gemu.ErrNoROM.Error(), http.StatusConflict)
+			return
+		}
+		if !power {
+			s.emu.SoftReset()
+			s.emu.ShowMessage("Reset")
+		} else if err := s.emu.PowerCycle(); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		} else {
+			s.emu.ShowMessage("Power cycled")
+		}
+		s.fault = nil
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) readMemory(w http.ResponseWriter, r *http.Request) {
+	addr, err := param(r, "addr", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := param(r, "len", 1)
+	if err != nil || addr+n > 0x10000 {
+		http.Error(w, "addr+len must be within $0000-$FFFF", http.StatusBadRequest)
+		return
+	}
+
+	buf := make([]byte, n)
+	s.mu.Lock()
+	for i := range buf {
+		buf[i] = s.emu.PeekMemory(uint16(addr + i))
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(buf)
+}
+
+func (s *Server) writeMemory(w http.ResponseWriter, r *http.Request) {
+	addr, err := param(r, "addr", -1)
+	if err != nil || addr < 0 {
+		http.Error(w, "addr is required", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, 0x10000))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if addr+len(data) > 0x10000 {
+		http.Error(w, "write runs past $FFFF", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	for i, b := range data {
+		s.emu.CPU().Store(uint16(addr+i), b)
+	}
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setInput(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Port     int      `json:"port"`
+		Buttons  uint32   `json:"buttons"`
+		Position *float64 `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Port < 0 || req.Port > gemu.ExpansionPort {
+		http.Error(w, "body must be {\"port\": 0-2, \"buttons\": n}", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.emu.SetInput(req.Port, req.Buttons)
+	if req.Position != nil {
+		s.emu.SetPosition(req.Port, *req.Position)
+	}
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) showMessage(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Text == "" {
+		http.Error(w, "body must be {\"text\": \"...\"}", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.emu.ShowMessage("%s", req.Text)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setOverclock(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Lines *int `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Lines == nil || *req.Lines < 0 {
+		http.Error(w, "body must be {\"lines\": n}", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loaded {
+		http.Error(w, gemu.ErrNoROM.Error(), http.StatusConflict)
+		return
+	}
+	if err := s.emu.SetGameOverclock(*req.Lines); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.emu.ShowMessage("Overclock %d scanlines", *req.Lines)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) screenshot(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	frame := s.emu.CopyFrame()
+	s.mu.Unlock()
+	defer s.emu.ReleaseFrame(frame)
+
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, frame)
+}
+
+func (s *Server) saveScreenshot(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loaded {
+		http.Error(w, gemu.ErrNoROM.Error(), http.StatusConflict)
+		return
+	}
+	path, err := s.dirs.SaveScreenshot(s.emu)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.emu.ShowMessage("Screenshot saved")
+	writeJSON(w, map[string]string{"path": path})
+}
+
+// param parses an integer query parameter, or returns def if it is absent.
+func param(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseInt(s, 0, 32)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return int(v), nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,69 @@
+package remote
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goldmane/gemu"
+)
+
+// countingPad counts the reads of its port.
+type countingPad struct {
+	reads, strobes int
+}
+
+func (p *countingPad) Strobe(on bool) { p.strobes++ }
+func (p *countingPad) Read() uint8    { p.reads++; return 1 }
+
+func get(t *testing.T, s *Server, url string) []byte {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", url, w.Code, w.Body)
+	}
+	return w.Body.Bytes()
+}
+
+func TestReadMemoryHasNoSideEffects(t *testing.T) {
+	emu := gemu.NewEmulator()
+	emu.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := emu.LoadROM("../nestest.nes"); err != nil {
+		t.Fatal(err)
+	}
+	pad := &countingPad{}
+	emu.SetPort(0, pad)
+	// stop in vblank, before the game sees it
+	for emu.PeekMemory(0x2002)&0x80 == 0 {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var regs [8]byte
+	for i := range regs {
+		regs[i] = emu.PeekMemory(0x2000 + uint16(i))
+	}
+
+	s := NewServer(emu, true)
+	got := get(t, s, "/memory?addr=0x2000&len=8")
+	if !bytes.Equal(got, regs[:]) {
+		t.Errorf("read PPU registers % X, want % X", got, regs)
+	}
+	get(t, s, "/memory?addr=0x4016")
+
+	for i := range regs {
+		if v := emu.PeekMemory(0x2000 + uint16(i)); v != regs[i] {
+			t.Errorf("$%04X reads $%02X after the read over HTTP, was $%02X", 0x2000+i, v, regs[i])
+		}
+	}
+	if emu.PeekMemory(0x2002)&0x80 == 0 {
+		t.Error("reading $2002 over HTTP ended vblank")
+	}
+	if pad.reads != 0 || pad.strobes != 0 {
+		t.Errorf("reading $4016 over HTTP read the pad %d times and strobed it %d times", pad.reads, pad.strobes)
+	}
+}
@@ -0,0 +1,76 @@
+package remote
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// romFile is what WatchROM compares to notice that a ROM was rebuilt.
+type romFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func statROM(path string) (romFile, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return romFile{}, false
+	}
+	return romFile{path, info.Size(), info.ModTime()}, true
+}
+
+// WatchROM power cycles into a fresh copy of the loaded ROM whenever its
+// file changes, so a homebrew build runs as soon as the assembler has
+// written it. The file is polled every interval until ctx is cancelled. A
+// change is acted on once the file has stayed the same for an interval, so
+// a half-written ROM isn't loaded, and a ROM that fails to load leaves the
+// old one running.
+func (s *Server) WatchROM(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var loaded, pending romFile
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		path := s.emu.ROMPath()
+		s.mu.Unlock()
+		cur, ok := statROM(path)
+		switch {
+		case !ok:
+			// gone while the assembler replaces it
+			continue
+		case cur.path != loaded.path:
+			// the first ROM, or another one loaded through the API
+			loaded, pending = cur, cur
+			continue
+		case cur == loaded:
+			pending = cur
+			continue
+		case cur != pending:
+			pending = cur
+			continue
+		}
+
+		loaded = cur
+		s.mu.Lock()
+		if err := s.emu.PowerCycle(); err != nil {
+			slog.Warn("reloading changed ROM", "path", path, "err", err)
+			s.emu.ShowMessage("Reload failed")
+		} else {
+			slog.Info("reloaded changed ROM", "path", path)
+			s.emu.ShowMessage("Reloaded %s", filepath.Base(path))
+			s.fault = nil
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,226 @@
+// Package scenario runs scripted plays of a ROM with checks along the way,
+// so gameplay-level regressions can be encoded as tests. Build a scenario
+// in Go:
+//
+//	s := scenario.New("game.nes").
+//		At(60).Press(input.ButtonStart, 1).
+//		Run(600).
+//		AssertRAM(0x07FF, scenario.Equal, 3).
+//		AssertFrameHash("1a2b3c4d")
+//	res, err := s.Play()
+//
+// or write it as a script and load it with ParseFile; see Parse.
+package scenario
+
+import (
+	"fmt"
+	"hash/crc32"
+	"runtime"
+
+	"github.com/goldmane/gemu"
+)
+
+// Op compares a byte of memory with a value.
+type Op string
+
+const (
+	Equal     Op = "=="
+	NotEqual  Op = "!="
+	Less      Op = "<"
+	LessEq    Op = "<="
+	Greater   Op = ">"
+	GreaterEq Op = ">="
+)
+
+func (op Op) compare(a, b uint8) (bool, error) {
+	switch op {
+	case Equal:
+		return a == b, nil
+	case NotEqual:
+		return a != b, nil
+	case Less:
+		return a < b, nil
+	case LessEq:
+		return a <= b, nil
+	case Greater:
+		return a > b, nil
+	case GreaterEq:
+		return a >= b, nil
+	}
+	return false, fmt.Errorf("unknown comparison %q", op)
+}
+
+// Scenario is a ROM and the steps to play it through, run in order from
+// power on. Actions run frames with buttons held on controller 1;
+// assertions check the state where the actions left it.
+type Scenario struct {
+	ROM   string
+	steps []step
+}
+
+// step is an action or an assertion. An action returns an error if the run
+// can't go on; an assertion returns a failure message.
+type step struct {
+	// where the step was written: a script line or a builder call
+	pos string
+	do  func(r *runner) (failure string, err error)
+}
+
+type runner struct {
+	m    *gemu.Machine
+	held uint32
+}
+
+// frames runs n frames with the held buttons.
+func (r *runner) frames(n uint64) error {
+	r.m.SetInput(0, r.held)
+	for i := uint64(0); i < n; i++ {
+		if err := r.m.RunFrame(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// New starts a scenario for the ROM at path.
+func New(rom string) *Scenario {
+	return &Scenario{ROM: rom}
+}
+
+// add appends a step written at the caller of the builder method.
+func (s *Scenario) add(do func(r *runner) (string, error)) *Scenario {
+	pos := "?"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		pos = fmt.Sprintf("%s:%d", file, line)
+	}
+	s.steps = append(s.steps, step{pos: pos, do: do})
+	return s
+}
+
+// At runs to the start of frame n, counted from power on. It does nothing
+// if the run is already past it.
+func (s *Scenario) At(frame uint64) *Scenario {
+	return s.add(func(r *runner) (string, error) {
+		if f := r.m.Frame(); f < frame {
+			return "", r.frames(frame - f)
+		}
+		return "", nil
+	})
+}
+
+// Run runs n frames.
+func (s *Scenario) Run(frames uint64) *Scenario {
+	return s.add(func(r *runner) (string, error) {
+		return "", r.frames(frames)
+	})
+}
+
+// Press holds buttons, along with any held by Hold, for n frames and then
+// lets go of them.
+func (s *Scenario) Press(buttons uint32, frames uint64) *Scenario {
+	return s.add(func(r *runner) (string, error) {
+		held := r.held
+		r.held |= buttons
+		err := r.frames(frames)
+		r.held = held
+		return "", err
+	})
+}
+
+// Hold keeps buttons held until Release.
+func (s *Scenario) Hold(buttons uint32) *Scenario {
+	return s.add(func(r *runner) (string, error) {
+		r.held |= buttons
+		return "", nil
+	})
+}
+
+// Release lets go of buttons.
+func (s *Scenario) Release(buttons uint32) *Scenario {
+	return s.add(func(r *runner) (string, error) {
+		r.held &^= buttons
+		return "", nil
+	})
+}
+
+// AssertRAM checks the byte at addr, in internal RAM ($0000-$07FF) or
+// cartridge RAM ($6000-$7FFF), against v.
+func (s *Scenario) AssertRAM(addr uint16, op Op, v uint8) *Scenario {
+	return s.add(func(r *runner) (string, error) {
+		got := uint8(r.m.Emulator().Peek(uint32(addr), 1))
+		ok, err := op.compare(got, v)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return fmt.Sprintf("$%04X is $%02X, want %s $%02X", addr, got, op, v), nil
+		}
+		return "", nil
+	})
+}
+
+// FrameHash returns the hash AssertFrameHash compares: the crc32 of the
+// frame's RGBA pixels in hex, as gemu testsuite's .hash files hold.
+func FrameHash(m *gemu.Machine) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE(m.Framebuffer().Pix))
+}
+
+// AssertFrameHash checks the current frame against a FrameHash.
+func (s *Scenario) AssertFrameHash(hash string) *Scenario {
+	return s.add(func(r *runner) (string, error) {
+		if got := FrameHash(r.m); got != hash {
+			return fmt.Sprintf("frame hash is %s, want %s", got, hash), nil
+		}
+		return "", nil
+	})
+}
+
+// Failure is an assertion that didn't hold.
+type Failure struct {
+	// Pos is where the assertion was written
+	Pos     string
+	Frame   uint64
+	Message string
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("%s: frame %d: %s", f.Pos, f.Frame, f.Message)
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	Frames   uint64
+	Failures []Failure
+}
+
+// Passed reports whether every assertion held.
+func (r *Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Play runs the scenario on a new machine. Failed assertions are collected
+// in the result and the run goes on; an error means the ROM couldn't be
+// loaded or emulation stopped, and the result holds what ran before it.
+func (s *Scenario) Play() (*Result, error) {
+	rom, err := gemu.ReadROM(s.ROM)
+	if err != nil {
+		return nil, err
+	}
+	m, err := gemu.NewMachine(rom)
+	if err != nil {
+		return nil, err
+	}
+	r := &runner{m: m}
+	res := &Result{}
+	for _, st := range s.steps {
+		failure, err := st.do(r)
+		res.Frames = m.Frame()
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", st.pos, err)
+		}
+		if failure != "" {
+			res.Failures = append(res.Failures, Failure{Pos: st.pos, Frame: res.Frames, Message: failure})
+		}
+	}
+	return res, nil
+}
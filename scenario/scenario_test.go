@@ -0,0 +1,77 @@
+package scenario
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goldmane/gemu/input"
+)
+
+func TestPlayScript(t *testing.T) {
+	s, err := Parse(strings.NewReader(`# nestest's menu
+rom ../nestest.nes
+at 10
+press start 2   # into the tests
+assert $0000 == $00
+assert $0000 != 0
+run 5
+`), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.Play()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Frames != 17 {
+		t.Errorf("ran %d frames, want 17", res.Frames)
+	}
+	// $0000 can only pass one of the two
+	if len(res.Failures) != 1 || res.Passed() {
+		t.Fatalf("failures %v, want one", res.Failures)
+	}
+	if f := res.Failures[0]; (f.Pos != "test:5" && f.Pos != "test:6") || f.Frame != 12 {
+		t.Errorf("failure %v, want at line 5 or 6 on frame 12", f)
+	}
+}
+
+func TestPlayBuilder(t *testing.T) {
+	res, err := New("../nestest.nes").
+		Hold(input.ButtonSelect).
+		Run(3).
+		Release(input.ButtonSelect).
+		At(2).
+		AssertRAM(0x07FF, GreaterEq, 0).
+		AssertFrameHash("00000000").
+		Play()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// At doesn't go back
+	if res.Frames != 3 {
+		t.Errorf("ran %d frames, want 3", res.Frames)
+	}
+	if len(res.Failures) != 1 || !strings.Contains(res.Failures[0].Message, "frame hash is") {
+		t.Fatalf("failures %v, want the frame hash", res.Failures)
+	}
+	if pos := res.Failures[0].Pos; !strings.Contains(pos, "scenario_test.go:") {
+		t.Errorf("failure at %s, want the builder call", pos)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, script := range []string{
+		"at 10",
+		"rom a.nes\nat",
+		"rom a.nes\nat ten",
+		"rom a.nes\npress turbo",
+		"rom a.nes\nassert $0000 =~ 1",
+		"rom a.nes\nassert $0000 == 256",
+		"rom a.nes\nassert frame",
+		"rom a.nes\njump",
+	} {
+		if _, err := Parse(strings.NewReader(script), "test"); err == nil {
+			t.Errorf("parsed %q", script)
+		}
+	}
+}
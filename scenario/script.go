@@ -0,0 +1,166 @@
+package scenario
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/goldmane/gemu/input"
+)
+
+// Parse reads a scenario script, one step per line, named name in failure
+// messages:
+//
+//	# title screen to the first level
+//	rom game.nes
+//	at 60
+//	press start         hold Start for a frame (or: press a+right 30)
+//	hold right          keep Right held until released
+//	run 600
+//	release right
+//	assert $07FF == 3   also !=, <, <=, > and >=
+//	assert frame 1a2b3c4d
+//
+// Numbers are decimal, or hex with a $ or 0x prefix. Text after a # is a
+// comment.
+func Parse(r io.Reader, name string) (*Scenario, error) {
+	s := &Scenario{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		pos := fmt.Sprintf("%s:%d", name, n)
+		added := len(s.steps)
+		if err := s.parseStep(fields); err != nil {
+			return nil, fmt.Errorf("%s: %w", pos, err)
+		}
+		// the builder took the parser for the step's caller
+		if len(s.steps) > added {
+			s.steps[added].pos = pos
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if s.ROM == "" {
+		return nil, fmt.Errorf("%s: no rom line", name)
+	}
+	return s, nil
+}
+
+// ParseFile reads the scenario script at path. Its ROM is found relative
+// to the script.
+func ParseFile(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := Parse(f, path)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(s.ROM) {
+		s.ROM = filepath.Join(filepath.Dir(path), s.ROM)
+	}
+	return s, nil
+}
+
+// parseStep adds the step on a line split into fields.
+func (s *Scenario) parseStep(f []string) error {
+	args := func(min, max int) error {
+		if len(f)-1 < min || len(f)-1 > max {
+			return fmt.Errorf("%s takes %d to %d arguments", f[0], min, max)
+		}
+		return nil
+	}
+	switch f[0] {
+	case "rom":
+		if err := args(1, 1); err != nil {
+			return err
+		}
+		s.ROM = f[1]
+		return nil
+	case "at", "run":
+		if err := args(1, 1); err != nil {
+			return err
+		}
+		v, err := parseNumber(f[1], 64)
+		if err != nil {
+			return err
+		}
+		if f[0] == "at" {
+			s.At(v)
+		} else {
+			s.Run(v)
+		}
+	case "press", "hold", "release":
+		if err := args(1, 2); err != nil {
+			return err
+		}
+		b, err := input.ParseButtons(f[1])
+		if err != nil {
+			return err
+		}
+		switch f[0] {
+		case "press":
+			frames := uint64(1)
+			if len(f) > 2 {
+				if frames, err = parseNumber(f[2], 64); err != nil {
+					return err
+				}
+			}
+			s.Press(b, frames)
+		case "hold":
+			s.Hold(b)
+		default:
+			s.Release(b)
+		}
+	case "assert":
+		if len(f) == 3 && f[1] == "frame" {
+			s.AssertFrameHash(strings.ToLower(f[2]))
+			break
+		}
+		if len(f) != 4 {
+			return fmt.Errorf("assert takes an address, a comparison and a value, or frame and a hash")
+		}
+		addr, err := parseNumber(f[1], 16)
+		if err != nil {
+			return err
+		}
+		op := Op(f[2])
+		if _, err := op.compare(0, 0); err != nil {
+			return err
+		}
+		v, err := parseNumber(f[3], 8)
+		if err != nil {
+			return err
+		}
+		s.AssertRAM(uint16(addr), op, uint8(v))
+	default:
+		return fmt.Errorf("unknown step %q", f[0])
+	}
+	return nil
+}
+
+func parseNumber(s string, bits int) (uint64, error) {
+	base := 10
+	t := s
+	if h, ok := strings.CutPrefix(s, "$"); ok {
+		t, base = h, 16
+	} else if h, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		t, base = h, 16
+	}
+	v, err := strconv.ParseUint(t, base, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,146 @@
+package gemu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Timeline tracks, as Chrome trace event thread ids.
+const (
+	timelineCPU = iota + 1
+	timelineCalls
+	timelineFrames
+)
+
+// Timeline records a run as Chrome trace events (the JSON array format),
+// to explore in chrome://tracing or Perfetto. Time is the console's, from
+// the master clock. The cpu track has a slice per instruction and per DMA
+// stall, the calls track one per subroutine and interrupt handler, nested
+// as JSR/RTS and interrupts/RTI nest, and the frames track one per frame.
+type Timeline struct {
+	w   *bufio.Writer
+	err error
+	// emulator recording to the timeline, for its clock rate and labels
+	e *Emulator
+	// events written, which all but the first need a comma before
+	events uint64
+	// the frame in progress and the master cycle it began
+	frame      uint64
+	frameStart uint64
+	// calls open on the calls track
+	depth int
+	// Instructions is whether to record every instruction. Without them a
+	// timeline stays small enough for long runs.
+	Instructions bool
+}
+
+// NewTimeline starts a timeline on w. Call Close to finish it.
+func NewTimeline(w io.Writer) *Timeline {
+	t := &Timeline{w: bufio.NewWriterSize(w, 64<<10), Instructions: true}
+	_, t.err = t.w.WriteString("[")
+	t.metadata("process_name", 0, "gemu")
+	t.metadata("thread_name", timelineCPU, "cpu")
+	t.metadata("thread_name", timelineCalls, "calls")
+	t.metadata("thread_name", timelineFrames, "frames")
+	return t
+}
+
+func (t *Timeline) metadata(name string, tid int, value string) {
+	t.event(fmt.Sprintf(`{"ph":"M","pid":1,"tid":%d,"name":%q,"args":{"name":%q}}`, tid, name, value))
+}
+
+// event writes one event's JSON object.
+func (t *Timeline) event(s string) {
+	if t.err != nil {
+		return
+	}
+	if t.events > 0 {
+		t.w.WriteByte(',')
+	}
+	t.events++
+	t.w.WriteByte('\n')
+	_, t.err = t.w.WriteString(s)
+}
+
+// micros converts a master cycle to the microseconds trace events count in.
+func (t *Timeline) micros(cycle uint64) string {
+	return strconv.FormatFloat(float64(cycle)*1e6/t.e.region.MasterClock, 'f', 3, 64)
+}
+
+// slice writes a complete event of dur master cycles from start.
+func (t *Timeline) slice(tid int, name string, start, dur uint64, args string) {
+	t.event(fmt.Sprintf(`{"ph":"X","pid":1,"tid":%d,"name":%q,"ts":%s,"dur":%s%s}`,
+		tid, name, t.micros(start), t.micros(dur), args))
+}
+
+// begin and end open and close a slice on the calls track.
+func (t *Timeline) begin(name string, at uint64) {
+	t.depth++
+	t.event(fmt.Sprintf(`{"ph":"B","pid":1,"tid":%d,"name":%q,"ts":%s}`, timelineCalls, name, t.micros(at)))
+}
+
+func (t *Timeline) end(at uint64) {
+	// an RTS used as a jump returns from nothing
+	if t.depth == 0 {
+		return
+	}
+	t.depth--
+	t.event(fmt.Sprintf(`{"ph":"E","pid":1,"tid":%d,"ts":%s}`, timelineCalls, t.micros(at)))
+}
+
+// Interrupt records the cpu entering the handler of an interrupt, such as
+// "nmi", at master cycle at.
+func (t *Timeline) Interrupt(kind string, at uint64) {
+	t.event(fmt.Sprintf(`{"ph":"i","pid":1,"tid":%d,"name":%q,"ts":%s,"s":"t"}`, timelineCPU, kind, t.micros(at)))
+	t.begin(kind+"_handler", at)
+}
+
+// instruction records an instruction at pc that ran for dur master cycles
+// from start. target is where the pc went, naming a JSR's subroutine.
+func (t *Timeline) instruction(pc uint16, mnemonic string, start, dur uint64, target uint16) {
+	if t.Instructions {
+		t.slice(timelineCPU, mnemonic, start, dur, fmt.Sprintf(`,"args":{"pc":"%04X"}`, pc))
+	}
+	switch mnemonic {
+	case "JSR":
+		name := fmt.Sprintf("sub_%04X", target)
+		if t.e.labels != nil {
+			if l, ok := t.e.labels.Lookup(target); ok {
+				name = l
+			}
+		}
+		t.begin(name, start+dur)
+	case "RTS", "RTI":
+		t.end(start + dur)
+	case "BRK":
+		t.Interrupt("brk", start+dur)
+	}
+}
+
+// stall records the cpu halted for dur master cycles from start, by DMA.
+func (t *Timeline) stall(start, dur uint64) {
+	t.slice(timelineCPU, "dma stall", start, dur, "")
+}
+
+// advance closes the frames that ended before master cycle now.
+func (t *Timeline) advance(now, period uint64) {
+	for now >= t.frameStart+period {
+		t.slice(timelineFrames, fmt.Sprintf("frame %d", t.frame), t.frameStart, period, "")
+		t.frame++
+		t.frameStart += period
+	}
+}
+
+// Close finishes the JSON and flushes it. It doesn't close the underlying
+// writer.
+func (t *Timeline) Close() error {
+	if t.err == nil {
+		_, t.err = t.w.WriteString("\n]\n")
+	}
+	if t.err != nil {
+		return t.err
+	}
+	return t.w.Flush()
+}